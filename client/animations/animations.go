@@ -111,6 +111,15 @@ func (a *Animation) Draw(screen *ebiten.Image, positionX float64, positionY floa
 	screen.DrawImage(a.CurrentImage(), op)
 }
 
+// Bounds returns the rectangle a frame is drawn in at a position, with y pointing up.
+// Flipped frames are drawn in the same rectangle.
+func (a *Animation) Bounds(positionX float64, positionY float64) (x, y, w, h float64) {
+	frameWidth, frameHeight := a.Size()
+	scaleX, scaleY := a.Scale()
+	shiftX, shiftY := a.Shift()
+	return positionX + shiftX*scaleX, positionY + shiftY*scaleY, scaleX * float64(frameWidth), scaleY * float64(frameHeight)
+}
+
 func (a *Animation) DefaultOptions() *ebiten.DrawImageOptions {
 	return &ebiten.DrawImageOptions{
 		Filter: ebiten.FilterNearest,
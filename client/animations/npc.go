@@ -10,50 +10,50 @@ import (
 )
 
 var (
-	npcIdleSpritesheet    image.Image
-	npcWalkSpritesheet    image.Image
-	npcDeadSpritesheet    image.Image
-	npcAttack1Spritesheet image.Image
-	npcAttack2Spritesheet image.Image
-	npcAttack3Spritesheet image.Image
+	skeletonIdleSpritesheet    image.Image
+	skeletonWalkSpritesheet    image.Image
+	skeletonDeadSpritesheet    image.Image
+	skeletonAttack1Spritesheet image.Image
+	skeletonAttack2Spritesheet image.Image
+	skeletonAttack3Spritesheet image.Image
 )
 
 func init() {
 	var err error
-	npcIdleSpritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonIdle))
+	skeletonIdleSpritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonIdle))
 	if err != nil {
 		panic(fmt.Sprintf("failed to decode image: %v", err))
 	}
 
-	npcWalkSpritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonWalk))
+	skeletonWalkSpritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonWalk))
 	if err != nil {
 		panic(fmt.Sprintf("failed to decode image: %v", err))
 	}
 
-	npcDeadSpritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonDead))
+	skeletonDeadSpritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonDead))
 	if err != nil {
 		panic(fmt.Sprintf("failed to decode image: %v", err))
 	}
 
-	npcAttack1Spritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonAttack1))
+	skeletonAttack1Spritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonAttack1))
 	if err != nil {
 		panic(fmt.Sprintf("failed to decode image: %v", err))
 	}
 
-	npcAttack2Spritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonAttack2))
+	skeletonAttack2Spritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonAttack2))
 	if err != nil {
 		panic(fmt.Sprintf("failed to decode image: %v", err))
 	}
 
-	npcAttack3Spritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonAttack3))
+	skeletonAttack3Spritesheet, _, err = image.Decode(bytes.NewReader(spritesheets.SkeletonAttack3))
 	if err != nil {
 		panic(fmt.Sprintf("failed to decode image: %v", err))
 	}
 }
 
-func NewNPCIdleAnimation() *Animation {
+func NewSkeletonIdleAnimation() *Animation {
 	return NewAnimation(NewAnimationOptions{
-		Image:       ebiten.NewImageFromImage(npcIdleSpritesheet),
+		Image:       ebiten.NewImageFromImage(skeletonIdleSpritesheet),
 		FrameOX:     0,
 		FrameOY:     0,
 		FrameWidth:  128,
@@ -68,9 +68,9 @@ func NewNPCIdleAnimation() *Animation {
 	})
 }
 
-func NewNPCWalkAnimation() *Animation {
+func NewSkeletonWalkAnimation() *Animation {
 	return NewAnimation(NewAnimationOptions{
-		Image:       ebiten.NewImageFromImage(npcWalkSpritesheet),
+		Image:       ebiten.NewImageFromImage(skeletonWalkSpritesheet),
 		FrameOX:     0,
 		FrameOY:     0,
 		FrameWidth:  128,
@@ -85,9 +85,9 @@ func NewNPCWalkAnimation() *Animation {
 	})
 }
 
-func NewNPCDeadAnimation() *Animation {
+func NewSkeletonDeadAnimation() *Animation {
 	return NewAnimation(NewAnimationOptions{
-		Image:       ebiten.NewImageFromImage(npcDeadSpritesheet),
+		Image:       ebiten.NewImageFromImage(skeletonDeadSpritesheet),
 		FrameOX:     0,
 		FrameOY:     0,
 		FrameWidth:  128,
@@ -102,9 +102,9 @@ func NewNPCDeadAnimation() *Animation {
 	})
 }
 
-func NewNPCAttack1Animation() *Animation {
+func NewSkeletonAttack1Animation() *Animation {
 	return NewAnimation(NewAnimationOptions{
-		Image:       ebiten.NewImageFromImage(npcAttack1Spritesheet),
+		Image:       ebiten.NewImageFromImage(skeletonAttack1Spritesheet),
 		FrameOX:     0,
 		FrameOY:     0,
 		FrameWidth:  128,
@@ -119,9 +119,9 @@ func NewNPCAttack1Animation() *Animation {
 	})
 }
 
-func NewNPCAttack2Animation() *Animation {
+func NewSkeletonAttack2Animation() *Animation {
 	return NewAnimation(NewAnimationOptions{
-		Image:       ebiten.NewImageFromImage(npcAttack2Spritesheet),
+		Image:       ebiten.NewImageFromImage(skeletonAttack2Spritesheet),
 		FrameOX:     0,
 		FrameOY:     0,
 		FrameWidth:  128,
@@ -136,9 +136,9 @@ func NewNPCAttack2Animation() *Animation {
 	})
 }
 
-func NewNPCAttack3Animation() *Animation {
+func NewSkeletonAttack3Animation() *Animation {
 	return NewAnimation(NewAnimationOptions{
-		Image:       ebiten.NewImageFromImage(npcAttack3Spritesheet),
+		Image:       ebiten.NewImageFromImage(skeletonAttack3Spritesheet),
 		FrameOX:     0,
 		FrameOY:     0,
 		FrameWidth:  128,
@@ -152,3 +152,18 @@ func NewNPCAttack3Animation() *Animation {
 		IsLooping:   false,
 	})
 }
+
+// DefaultNPCSprite is the sprite NPCs are drawn with if the sprite of their archetype has no animations
+const DefaultNPCSprite = "skeleton"
+
+// NPCSprites maps the sprites of NPC archetypes to the constructors of their animations by animation key
+var NPCSprites = map[string]map[string]func() *Animation{
+	"skeleton": {
+		"idle":    NewSkeletonIdleAnimation,
+		"walk":    NewSkeletonWalkAnimation,
+		"dead":    NewSkeletonDeadAnimation,
+		"attack1": NewSkeletonAttack1Animation,
+		"attack2": NewSkeletonAttack2Animation,
+		"attack3": NewSkeletonAttack3Animation,
+	},
+}
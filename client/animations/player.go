@@ -226,3 +226,17 @@ func NewPlayerDeadAnimation() *Animation {
 		IsLooping:   false,
 	})
 }
+
+// PlayerAnimations are the constructors of the player animations by animation key
+var PlayerAnimations = map[string]func() *Animation{
+	"idle":         NewPlayerIdleAnimation,
+	"run":          NewPlayerRunAnimation,
+	"jump":         NewPlayerJumpAnimation,
+	"ladder-idle":  NewPlayerLadderIdleAnimation,
+	"ladder-climb": NewPlayerLadderClimbAnimation,
+	"fall":         NewPlayerFallAnimation,
+	"attack1":      NewPlayerAttack1Animation,
+	"attack2":      NewPlayerAttack2Animation,
+	"attack3":      NewPlayerAttack3Animation,
+	"dead":         NewPlayerDeadAnimation,
+}
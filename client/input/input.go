@@ -78,3 +78,11 @@ func IsAttack3JustPressed() bool {
 func IsRespawnJustPressed() bool {
 	return inpututil.IsKeyJustPressed(ebiten.KeyR)
 }
+
+func IsZoomInJustPressed() bool {
+	return inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadAdd)
+}
+
+func IsZoomOutJustPressed() bool {
+	return inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadSubtract)
+}
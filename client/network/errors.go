@@ -1,5 +1,12 @@
 package network
 
+import (
+	"fmt"
+
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/version"
+)
+
 // ErrConnectionClosedByServer is returned when the TCP connection is closed
 type ErrConnectionClosedByServer struct{}
 
@@ -13,3 +20,43 @@ type ErrConnectionClosedByClient struct{}
 func (e *ErrConnectionClosedByClient) Error() string {
 	return "connection closed by client"
 }
+
+// ErrConnectionLost is returned when the TCP connection fails, e.g. when it is reset
+type ErrConnectionLost struct {
+	Err error
+}
+
+func (e *ErrConnectionLost) Error() string {
+	return fmt.Sprintf("connection lost: %v", e.Err)
+}
+
+// ErrServerLoginFailure is returned when the server rejects a login
+type ErrServerLoginFailure struct {
+	Code          messages.LoginFailureCode
+	Reason        string
+	ServerVersion string
+}
+
+func (e *ErrServerLoginFailure) Error() string {
+	return fmt.Sprintf("server login failure: %s", e.Reason)
+}
+
+// ErrKicked is returned when the server kicks the client
+type ErrKicked struct {
+	Reason string
+}
+
+func (e *ErrKicked) Error() string {
+	return fmt.Sprintf("kicked by the server: %s", e.Reason)
+}
+
+// ErrIncompatibleServer is returned when the server accepts a login
+// but speaks a protocol that is not compatible with the client
+type ErrIncompatibleServer struct {
+	ServerVersion string
+	Err           *version.ErrIncompatibleProtocol
+}
+
+func (e *ErrIncompatibleServer) Error() string {
+	return fmt.Sprintf("incompatible server version %s: %v", e.ServerVersion, e.Err)
+}
@@ -2,8 +2,10 @@ package network
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -12,12 +14,21 @@ import (
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/transport"
+	"github.com/cbodonnell/flywheel/pkg/version"
 )
 
 const (
 	DefaultServerHostname = "localhost"
 	DefaultServerTCPPort  = 8888
 	DefaultServerUDPPort  = 8889
+	// ResumeInitialBackoff is how long to wait before retrying to resume a session
+	ResumeInitialBackoff = 250 * time.Millisecond
+	// ResumeMaxBackoff is the longest wait between attempts to resume a session
+	ResumeMaxBackoff = 4 * time.Second
+	// HeartbeatInterval is how often the client pings the server over UDP so it is not
+	// reaped as idle when it has nothing else to send. TCP is kept alive by time syncs.
+	HeartbeatInterval = 5 * time.Second
 )
 
 // NetworkManager represents a network manager.
@@ -34,9 +45,14 @@ type NetworkManager struct {
 	clientWaitGroup sync.WaitGroup
 	clientID        uint32
 	clientIDMutex   sync.Mutex
-	clientIDChan    <-chan uint32
+	loginChan       <-chan *messages.ServerLoginSuccess
 	loginErr        error
 	loginErrChan    <-chan error
+	// resumeToken and resumeWindow are issued by the server at login
+	// to resume the session if the TCP connection drops
+	resumeToken  string
+	resumeWindow time.Duration
+	resumeMutex  sync.Mutex
 
 	isConnected     bool
 	serverTime      float64
@@ -51,15 +67,53 @@ type ServerSettings struct {
 	Hostname string
 	TCPPort  int
 	UDPPort  int
+	// TLS enables TLS for the TCP connection, which carries the ID token at login
+	TLS bool
+	// TLSCAFile is an optional PEM file with the CA certificates used to verify the server
+	TLSCAFile string
+	// TLSInsecureSkipVerify disables verification of the server certificate, for development only
+	TLSInsecureSkipVerify bool
+}
+
+// tlsConfig returns the TLS configuration for the TCP connection, or nil if TLS is disabled
+func (s ServerSettings) tlsConfig() (*tls.Config, error) {
+	if !s.TLS {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         s.Hostname,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: s.TLSInsecureSkipVerify,
+	}
+	if s.TLSCAFile != "" {
+		pem, err := os.ReadFile(s.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", s.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
 }
 
 // NewNetworkManager creates a new network manager.
 func NewNetworkManager(serverSettings ServerSettings, messageQueue queue.Queue) (*NetworkManager, error) {
-	clientIDChan := make(chan uint32)
+	loginChan := make(chan *messages.ServerLoginSuccess)
 	loginErrChan := make(chan error)
 	serverTimeChan := make(chan *messages.ServerSyncTime)
 
-	tcpClient := NewTCPClient(fmt.Sprintf("%s:%d", serverSettings.Hostname, serverSettings.TCPPort), messageQueue, clientIDChan, loginErrChan, serverTimeChan)
+	tlsConfig, err := serverSettings.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS config: %v", err)
+	}
+	if tlsConfig == nil {
+		log.Warn("TLS is disabled, the login token will be sent to the game server unencrypted")
+	}
+
+	tcpClient := NewTCPClient(fmt.Sprintf("%s:%d", serverSettings.Hostname, serverSettings.TCPPort), tlsConfig, messageQueue, loginChan, loginErrChan, serverTimeChan)
 	udpClient, err := NewUDPClient(fmt.Sprintf("%s:%d", serverSettings.Hostname, serverSettings.UDPPort), messageQueue)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create UDP client: %v", err)
@@ -72,7 +126,7 @@ func NewNetworkManager(serverSettings ServerSettings, messageQueue queue.Queue)
 		tcpClientErrChan:   make(chan error),
 		udpClient:          udpClient,
 		udpClientErrChan:   make(chan error),
-		clientIDChan:       clientIDChan,
+		loginChan:          loginChan,
 		loginErrChan:       loginErrChan,
 		serverTimeChan:     serverTimeChan,
 	}, nil
@@ -90,13 +144,17 @@ func (m *NetworkManager) Start(token string, characterID int32) error {
 	m.clientWaitGroup.Add(1)
 	go func(ctx context.Context) {
 		defer m.clientWaitGroup.Done()
-		if err := m.tcpClient.HandleMessages(ctx); err != nil {
+		if err := m.handleTCPClient(ctx); err != nil {
 			// TODO: find a way to do this without channels (maybe a flag w/ mutex?)
 			m.tcpClientErrChan <- err
 		}
 	}(ctx)
 
-	if err := m.login(token, characterID); err != nil {
+	cipher, err := m.login(token, characterID)
+	if err != nil {
+		if actionableErr, ok := err.(*ui.ActionableError); ok {
+			return actionableErr
+		}
 		if strings.Contains(err.Error(), "is already connected") {
 			return &ui.ActionableError{
 				Message: "You are already connected to the server",
@@ -109,7 +167,7 @@ func (m *NetworkManager) Start(token string, characterID int32) error {
 		return fmt.Errorf("failed to start time sync: %v", err)
 	}
 
-	if err := m.udpClient.Connect(); err != nil {
+	if err := m.udpClient.Connect(m.ClientID(), cipher); err != nil {
 		return fmt.Errorf("failed to start UDP client: %v", err)
 	}
 
@@ -122,42 +180,228 @@ func (m *NetworkManager) Start(token string, characterID int32) error {
 		}
 	}(ctx)
 
+	m.clientWaitGroup.Add(1)
+	go func(ctx context.Context) {
+		defer m.clientWaitGroup.Done()
+		m.udpClient.HandleResends(ctx)
+	}(ctx)
+
 	if err := m.pingUDP(); err != nil {
 		return fmt.Errorf("failed to ping UDP: %v", err)
 	}
 
+	m.clientWaitGroup.Add(1)
+	go func(ctx context.Context) {
+		defer m.clientWaitGroup.Done()
+		m.sendHeartbeats(ctx)
+	}(ctx)
+
 	m.isConnected = true
 	return nil
 }
 
-func (m *NetworkManager) login(token string, characterID int32) error {
+// login logs in to the server and returns the cipher for the UDP session
+// derived from the keys exchanged with the server.
+func (m *NetworkManager) login(token string, characterID int32) (*transport.SessionCipher, error) {
+	privateKey, err := transport.GenerateSessionKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session key pair: %v", err)
+	}
+
 	login := &messages.ClientLogin{
-		Token:       token,
-		CharacterID: characterID,
+		Token:           token,
+		CharacterID:     characterID,
+		ProtocolVersion: version.ProtocolVersion,
+		Capabilities:    version.Capabilities,
+		ClientVersion:   version.Get(),
+		PublicKey:       privateKey.PublicKey().Bytes(),
+
+		CompressionDictionaryChecksum: messages.CompressionDictionaryChecksum(),
+	}
+	loginSuccess, err := m.sendLogin(login)
+	if err != nil {
+		return nil, err
+	}
+	m.setClientID(loginSuccess.ClientID)
+	log.Info("Connected to server with client ID %d", loginSuccess.ClientID)
+
+	cipher, err := transport.NewSessionCipher(transport.RoleClient, privateKey, loginSuccess.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session cipher: %v", err)
 	}
-	b, err := json.Marshal(login)
+
+	return cipher, nil
+}
+
+// sendLogin sends a login message to the server and waits for the response.
+// The resume token issued by the server is saved to resume the session.
+func (m *NetworkManager) sendLogin(login *messages.ClientLogin) (*messages.ServerLoginSuccess, error) {
+	b, err := messages.SerializeClientLogin(login)
 	if err != nil {
-		return fmt.Errorf("failed to serialize login message: %v", err)
+		return nil, fmt.Errorf("failed to serialize login message: %v", err)
 	}
 	msg := &messages.Message{
 		ClientID: 0,
 		Type:     messages.MessageTypeClientLogin,
 		Payload:  b,
 	}
-	if err := m.SendReliableMessage(msg); err != nil {
-		return fmt.Errorf("failed to send login message: %v", err)
+	if err := m.tcpClient.SendMessage(msg); err != nil {
+		return nil, fmt.Errorf("failed to send login message: %v", err)
 	}
 
+	var loginSuccess *messages.ServerLoginSuccess
 	select {
-	case m.clientID = <-m.clientIDChan:
-		log.Info("Connected to server with client ID %d", m.clientID)
+	case loginSuccess = <-m.loginChan:
 	case m.loginErr = <-m.loginErrChan:
-		return fmt.Errorf("failed to login: %v", m.loginErr)
+		switch err := m.loginErr.(type) {
+		case *ErrServerLoginFailure:
+			switch err.Code {
+			case messages.LoginFailureCodeUpdateRequired:
+				return nil, updateRequiredError(err.ServerVersion)
+			case messages.LoginFailureCodeCompressionDictionaryMismatch:
+				return nil, compressionDictionaryMismatchError()
+			}
+		case *messages.ErrCompressionDictionaryMismatch:
+			return nil, compressionDictionaryMismatchError()
+		case *ErrIncompatibleServer:
+			if err.Err.IsPeerOutdated() {
+				return nil, &ui.ActionableError{
+					Message: fmt.Sprintf("The server (version %s) is not compatible with this version, please try again later", err.ServerVersion),
+				}
+			}
+			return nil, updateRequiredError(err.ServerVersion)
+		}
+		return nil, m.loginErr
 	case <-time.After(10 * time.Second):
-		return fmt.Errorf("timed out waiting for login response")
+		return nil, fmt.Errorf("timed out waiting for login response")
 	}
 
-	return nil
+	m.resumeMutex.Lock()
+	m.resumeToken = loginSuccess.ResumeToken
+	m.resumeWindow = time.Duration(loginSuccess.ResumeWindow) * time.Millisecond
+	m.resumeMutex.Unlock()
+
+	return loginSuccess, nil
+}
+
+// handleTCPClient handles messages from the TCP client until the context is done.
+// If the connection drops after login, the session is resumed on a new connection
+// and an error is returned only if it cannot be resumed or the client was kicked.
+func (m *NetworkManager) handleTCPClient(ctx context.Context) error {
+	errChan := m.handleTCPMessages(ctx)
+
+	for {
+		err := <-errChan
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*ErrKicked); ok {
+			return err
+		}
+
+		m.resumeMutex.Lock()
+		resumeToken, resumeWindow := m.resumeToken, m.resumeWindow
+		m.resumeMutex.Unlock()
+		if resumeToken == "" {
+			return err
+		}
+
+		log.Warn("Lost connection to server, resuming session: %v", err)
+		errChan, err = m.resumeSession(ctx, resumeToken, resumeWindow)
+		if err != nil {
+			return fmt.Errorf("failed to resume session: %v", err)
+		}
+		if errChan == nil {
+			// the network manager was stopped while resuming
+			return nil
+		}
+	}
+}
+
+// handleTCPMessages handles messages from the current TCP connection
+// and returns a channel for the result once the connection is closed
+func (m *NetworkManager) handleTCPMessages(ctx context.Context) <-chan error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- m.tcpClient.HandleMessages(ctx)
+	}()
+	return errChan
+}
+
+// resumeSession reconnects to the server and resumes the session, retrying with a backoff
+// until the resume window ends. It returns a channel for the result of handling messages
+// from the new connection, or nil if the context is done first.
+func (m *NetworkManager) resumeSession(ctx context.Context, resumeToken string, resumeWindow time.Duration) (<-chan error, error) {
+	deadline := time.Now().Add(resumeWindow)
+	backoff := ResumeInitialBackoff
+	for ctx.Err() == nil {
+		errChan, err := m.tryResumeSession(ctx, resumeToken)
+		if err == nil {
+			return errChan, nil
+		}
+		switch err.(type) {
+		case *ErrServerLoginFailure, *ui.ActionableError:
+			// the server rejected the session, so retrying will not help
+			return nil, err
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("server unreachable for %s: %v", resumeWindow, err)
+		}
+		log.Warn("Failed to resume session, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, ResumeMaxBackoff)
+	}
+	return nil, nil
+}
+
+// tryResumeSession connects to the server and resumes the session with the resume token.
+// The UDP session is kept, since the server keeps the client's endpoint while it is suspended.
+func (m *NetworkManager) tryResumeSession(ctx context.Context, resumeToken string) (<-chan error, error) {
+	if err := m.tcpClient.Connect(); err != nil {
+		return nil, err
+	}
+	errChan := m.handleTCPMessages(ctx)
+
+	login := &messages.ClientLogin{
+		ProtocolVersion: version.ProtocolVersion,
+		Capabilities:    version.Capabilities,
+		ClientVersion:   version.Get(),
+		ResumeClientID:  m.ClientID(),
+		ResumeToken:     resumeToken,
+
+		CompressionDictionaryChecksum: messages.CompressionDictionaryChecksum(),
+	}
+	if _, err := m.sendLogin(login); err != nil {
+		m.tcpClient.Close()
+		<-errChan
+		return nil, err
+	}
+	log.Info("Resumed session with client ID %d", m.ClientID())
+
+	// the client's address may have changed, so let the server know where to send updates
+	if err := m.pingUDP(); err != nil {
+		log.Warn("Failed to ping UDP after resuming session: %v", err)
+	}
+
+	return errChan, nil
+}
+
+func updateRequiredError(serverVersion string) error {
+	return &ui.ActionableError{
+		Message: fmt.Sprintf("Update required: version %s is not compatible with the server (version %s)", version.Get(), serverVersion),
+	}
+}
+
+// compressionDictionaryMismatchError is returned when the client and server compress messages with different dictionaries
+func compressionDictionaryMismatchError() error {
+	return &ui.ActionableError{
+		Message: "The compression dictionary (-compression-dict) does not match the server's, please use the same dictionary as the server",
+	}
 }
 
 func (m *NetworkManager) startSyncTime(ctx context.Context) error {
@@ -186,18 +430,18 @@ func (m *NetworkManager) syncTime() error {
 		Timestamp: time.Now().UnixMilli(),
 	}
 
-	payload, err := json.Marshal(clientSyncTime)
+	payload, err := messages.SerializeClientSyncTime(clientSyncTime)
 	if err != nil {
-		return fmt.Errorf("failed to marshal client sync time: %v", err)
+		return fmt.Errorf("failed to serialize client sync time: %v", err)
 	}
 
 	msg := &messages.Message{
-		ClientID: m.clientID,
+		ClientID: m.ClientID(),
 		Type:     messages.MessageTypeClientSyncTime,
 		Payload:  payload,
 	}
 
-	if err := m.SendReliableMessage(msg); err != nil {
+	if err := m.tcpClient.SendMessage(msg); err != nil {
 		return fmt.Errorf("failed to send client sync time message: %v", err)
 	}
 
@@ -227,9 +471,26 @@ func (m *NetworkManager) syncTime() error {
 	return nil
 }
 
+// sendHeartbeats pings the server over UDP every HeartbeatInterval until the context is done
+func (m *NetworkManager) sendHeartbeats(ctx context.Context) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.pingUDP(); err != nil {
+				log.Warn("Failed to send heartbeat: %v", err)
+			}
+		}
+	}
+}
+
 func (m *NetworkManager) pingUDP() error {
 	pingUDPMsg := &messages.Message{
-		ClientID: m.clientID,
+		ClientID: m.ClientID(),
 		Type:     messages.MessageTypeClientPing,
 	}
 	return m.SendUnreliableMessage(pingUDPMsg)
@@ -275,9 +536,14 @@ func (m *NetworkManager) Stop() error {
 		return fmt.Errorf("failed to clear server message queue: %v", err)
 	}
 
-	m.clientID = 0
+	m.setClientID(0)
 	m.cancelClientCtx = nil
 
+	m.resumeMutex.Lock()
+	m.resumeToken = ""
+	m.resumeWindow = 0
+	m.resumeMutex.Unlock()
+
 	m.serverTimeMutex.Lock()
 	defer m.serverTimeMutex.Unlock()
 	m.serverTime = 0
@@ -292,6 +558,15 @@ func (m *NetworkManager) Stop() error {
 	return nil
 }
 
+// DisableSessionResume stops the session from being resumed if the connection drops,
+// such as when the server announces that it is shutting down.
+func (m *NetworkManager) DisableSessionResume() {
+	m.resumeMutex.Lock()
+	defer m.resumeMutex.Unlock()
+	m.resumeToken = ""
+	m.resumeWindow = 0
+}
+
 func (m *NetworkManager) ServerSettings() ServerSettings {
 	return m.serverSettings
 }
@@ -324,10 +599,19 @@ func (m *NetworkManager) ClientID() uint32 {
 	return m.clientID
 }
 
+// setClientID sets the client ID, which is read by the goroutines of the clients
+func (m *NetworkManager) setClientID(clientID uint32) {
+	m.clientIDMutex.Lock()
+	defer m.clientIDMutex.Unlock()
+	m.clientID = clientID
+}
+
+// SendReliableMessage sends a message on the reliable-ordered UDP channel.
 func (m *NetworkManager) SendReliableMessage(msg *messages.Message) error {
-	return m.tcpClient.SendMessage(msg)
+	return m.udpClient.SendMessage(msg, transport.ChannelTypeReliableOrdered)
 }
 
+// SendUnreliableMessage sends a message on the unreliable UDP channel selected by its type.
 func (m *NetworkManager) SendUnreliableMessage(msg *messages.Message) error {
-	return m.udpClient.SendMessage(msg)
+	return m.udpClient.SendMessage(msg, transport.ChannelTypeForMessage(msg.Type))
 }
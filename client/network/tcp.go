@@ -1,48 +1,73 @@
 package network
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
+	"sync"
 
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/version"
 )
 
 // TCPClient represents a TCP client.
 type TCPClient struct {
 	serverAddr     string
+	tlsConfig      *tls.Config
 	messageQueue   queue.Queue
-	clientIDChan   chan<- uint32
+	loginChan      chan<- *messages.ServerLoginSuccess
 	loginErrChan   chan<- error
 	serverTimeChan chan<- *messages.ServerSyncTime
-	conn           net.Conn
+	// connLock guards the connection, which is replaced when the client reconnects
+	connLock sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
 }
 
 // NewTCPClient creates a new TCP client.
-func NewTCPClient(serverAddr string, messageQueue queue.Queue, clientIDChan chan<- uint32, loginErrChan chan<- error, serverTimeChan chan<- *messages.ServerSyncTime) *TCPClient {
+func NewTCPClient(serverAddr string, tlsConfig *tls.Config, messageQueue queue.Queue, loginChan chan<- *messages.ServerLoginSuccess, loginErrChan chan<- error, serverTimeChan chan<- *messages.ServerSyncTime) *TCPClient {
 	return &TCPClient{
 		serverAddr:     serverAddr,
+		tlsConfig:      tlsConfig,
 		messageQueue:   messageQueue,
-		clientIDChan:   clientIDChan,
+		loginChan:      loginChan,
 		loginErrChan:   loginErrChan,
 		serverTimeChan: serverTimeChan,
 	}
 }
 
+// Connect connects to the server, over TLS if the client has a TLS configuration.
 func (c *TCPClient) Connect() error {
-	conn, err := net.Dial("tcp", c.serverAddr)
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		conn, err = tls.Dial("tcp", c.serverAddr, c.tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", c.serverAddr)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to connect to server: %v", err)
 	}
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
 	c.conn = conn
+	c.reader = bufio.NewReaderSize(conn, messages.TCPMessageBufferSize)
 	return nil
 }
 
+// HandleMessages handles messages from the current connection until it is closed.
+// It returns an error if the connection is closed by the server or lost.
 func (c *TCPClient) HandleMessages(ctx context.Context) error {
-	defer c.conn.Close()
+	c.connLock.Lock()
+	conn, reader := c.conn, c.reader
+	c.connLock.Unlock()
+
+	defer conn.Close()
 	for {
 		select {
 		case <-ctx.Done():
@@ -50,52 +75,84 @@ func (c *TCPClient) HandleMessages(ctx context.Context) error {
 		default:
 		}
 
-		b, err := ReadFromTCP(c.conn)
+		b, err := ReadFromTCP(reader)
 		if err != nil {
-			if _, ok := err.(*ErrConnectionClosedByServer); ok {
+			switch err.(type) {
+			case *ErrConnectionClosedByServer, *ErrConnectionLost:
 				return err
-			} else if _, ok := err.(*ErrConnectionClosedByClient); ok {
+			case *ErrConnectionClosedByClient:
 				log.Info("TCP connection closed by client")
 				return nil
+			case *messages.ErrFrameTooLarge, *messages.ErrUnsupportedFrameVersion:
+				return fmt.Errorf("failed to read frame from TCP connection: %v", err)
 			}
-			log.Error("Failed to read from TCP connection: %v", err)
+			// the stream can't be trusted after a failed read, so drop the connection and let it be resumed
+			return err
+		}
+		msg, err := messages.DeserializeMessage(b)
+		if err != nil {
+			log.Error("Failed to deserialize message: %v", err)
 			continue
 		}
+		if err := kickedBy(msg); err != nil {
+			// the server drops the connection right after a kick, so there is no session to resume
+			return err
+		}
 		go func() {
-			if err := c.handleMessage(b); err != nil {
+			if err := c.handleMessage(msg); err != nil {
 				log.Error("Failed to handle message: %v", err)
 			}
 		}()
 	}
 }
 
-func (c *TCPClient) handleMessage(b []byte) error {
-	msg, err := messages.DeserializeMessage(b)
-	if err != nil {
-		return fmt.Errorf("failed to deserialize message: %v", err)
+// kickedBy returns an ErrKicked if a message is the server kicking the client
+func kickedBy(msg *messages.Message) error {
+	if msg.Type != messages.MessageTypeServerLoginFailure {
+		return nil
 	}
+	loginFailure, err := messages.DeserializeServerLoginFailure(msg.Payload)
+	if err != nil || loginFailure.Code != messages.LoginFailureCodeKicked {
+		return nil
+	}
+	return &ErrKicked{Reason: loginFailure.Reason}
+}
+
+func (c *TCPClient) handleMessage(msg *messages.Message) error {
 	log.Trace("Received message from TCP server of type %s", msg.Type)
 
 	switch msg.Type {
 	case messages.MessageTypeServerLoginSuccess:
-		assignID := &messages.ServerLoginSuccess{}
-		err := json.Unmarshal(msg.Payload, assignID)
+		assignID, err := messages.DeserializeServerLoginSuccess(msg.Payload)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize server login success message: %v", err)
 		}
-		// write the client ID back to the manager
-		c.clientIDChan <- assignID.ClientID
+		if err := version.CheckCompatibility(assignID.ProtocolVersion, assignID.Capabilities); err != nil {
+			c.loginErrChan <- &ErrIncompatibleServer{
+				ServerVersion: assignID.ServerVersion,
+				Err:           err.(*version.ErrIncompatibleProtocol),
+			}
+			return nil
+		}
+		if err := messages.CheckCompressionDictionary(assignID.CompressionDictionaryChecksum); err != nil {
+			c.loginErrChan <- err
+			return nil
+		}
+		log.Info("Server version %s, protocol version %d", assignID.ServerVersion, assignID.ProtocolVersion)
+		// write the login success back to the manager
+		c.loginChan <- assignID
 	case messages.MessageTypeServerLoginFailure:
-		loginFailure := &messages.ServerLoginFailure{}
-		err := json.Unmarshal(msg.Payload, loginFailure)
+		loginFailure, err := messages.DeserializeServerLoginFailure(msg.Payload)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize server login failure message: %v", err)
 		}
-		loginErr := fmt.Errorf("server login failure: %s", loginFailure.Reason)
-		c.loginErrChan <- loginErr
+		c.loginErrChan <- &ErrServerLoginFailure{
+			Code:          loginFailure.Code,
+			Reason:        loginFailure.Reason,
+			ServerVersion: loginFailure.ServerVersion,
+		}
 	case messages.MessageTypeServerSyncTime:
-		serverSyncTime := &messages.ServerSyncTime{}
-		err := json.Unmarshal(msg.Payload, serverSyncTime)
+		serverSyncTime, err := messages.DeserializeServerSyncTime(msg.Payload)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize server sync time message: %v", err)
 		}
@@ -118,6 +175,8 @@ func (c *TCPClient) handleMessage(b []byte) error {
 
 // Close closes the TCP connection.
 func (c *TCPClient) Close() error {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
 	if c.conn == nil {
 		log.Warn("TCP connection is already closed")
 		return nil
@@ -132,27 +191,36 @@ func (c *TCPClient) SendMessage(msg *messages.Message) error {
 		return fmt.Errorf("failed to serialize message: %v", err)
 	}
 
-	_, err = c.conn.Write(b)
-	if err != nil {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	if err := messages.WriteFrame(c.conn, b); err != nil {
 		return fmt.Errorf("failed to write message to TCP connection: %v", err)
 	}
 
 	return nil
 }
 
-// ReadFromTCP reads a buffer from a TCP connection
-func ReadFromTCP(conn net.Conn) ([]byte, error) {
-	buf := make([]byte, messages.UDPMessageBufferSize)
-	n, err := conn.Read(buf)
+// ReadFromTCP reads the next framed buffer from a TCP connection.
+// The reader should be buffered and reused for the lifetime of the connection.
+func ReadFromTCP(r io.Reader) ([]byte, error) {
+	b, err := messages.ReadFrame(r)
 	if err != nil {
-		if err.Error() == "EOF" {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			return nil, &ErrConnectionClosedByServer{}
 		}
-		if err, ok := err.(*net.OpError); ok && err.Err.Error() == "use of closed network connection" {
-			return nil, &ErrConnectionClosedByClient{}
+		if err, ok := err.(*net.OpError); ok {
+			if err.Err.Error() == "use of closed network connection" {
+				return nil, &ErrConnectionClosedByClient{}
+			}
+			return nil, &ErrConnectionLost{Err: err}
+		}
+		switch err.(type) {
+		case *messages.ErrFrameTooLarge, *messages.ErrUnsupportedFrameVersion:
+			// the stream can't be recovered after a bad frame header
+			return nil, err
 		}
 		return nil, fmt.Errorf("failed to read message from TCP connection: %v", err)
 	}
 
-	return buf[:n], nil
+	return b, nil
 }
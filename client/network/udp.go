@@ -4,10 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/transport"
+)
+
+const (
+	// ResendInterval is how often unacked reliable datagrams are checked for resending
+	ResendInterval = 20 * time.Millisecond
 )
 
 // UDPClient represents a UDP client.
@@ -15,6 +22,7 @@ type UDPClient struct {
 	serverAddr   *net.UDPAddr
 	messageQueue queue.Queue
 	conn         *net.UDPConn
+	endpoint     *transport.Endpoint
 }
 
 // NewUDPClient creates a new UDP client.
@@ -30,13 +38,18 @@ func NewUDPClient(serverAddr string, messageQueue queue.Queue) (*UDPClient, erro
 	}, nil
 }
 
-// Connect starts the UDP client.
-func (c *UDPClient) Connect() error {
+// Connect starts the UDP client for the client ID assigned by the server at login.
+// The cipher seals and opens datagrams with the session keys negotiated at login.
+func (c *UDPClient) Connect(clientID uint32, cipher *transport.SessionCipher) error {
 	conn, err := net.ListenUDP("udp", nil)
 	if err != nil {
 		return fmt.Errorf("failed to listen on UDP address: %v", err)
 	}
 	c.conn = conn
+	c.endpoint = transport.NewEndpoint(transport.NewEndpointOptions{
+		ClientID: clientID,
+		Cipher:   cipher,
+	})
 	return nil
 }
 
@@ -60,11 +73,43 @@ func (c *UDPClient) HandleMessages(ctx context.Context) error {
 			log.Error("Failed to read from UDP connection: %v", err)
 			continue
 		}
-		go func() {
-			if err := c.handleMessage(b); err != nil {
+
+		payloads, err := c.endpoint.Receive(b)
+		if err != nil {
+			log.Error("Failed to receive datagram: %v", err)
+			continue
+		}
+		// payloads are handled in order so reliable messages are enqueued in the order they were sent
+		for _, payload := range payloads {
+			if err := c.handleMessage(payload); err != nil {
 				log.Error("Failed to handle message: %v", err)
 			}
-		}()
+		}
+	}
+}
+
+// HandleResends periodically resends unacked reliable datagrams and
+// sends acks to the server if they have not been sent with other traffic.
+func (c *UDPClient) HandleResends(ctx context.Context) {
+	ticker := time.NewTicker(ResendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			datagrams := c.endpoint.Resend(now)
+			if ack, ok := c.endpoint.Ack(); ok {
+				datagrams = append(datagrams, ack)
+			}
+
+			for _, datagram := range datagrams {
+				if _, err := c.conn.WriteToUDP(datagram, c.serverAddr); err != nil {
+					log.Error("Failed to write datagram to UDP connection: %v", err)
+				}
+			}
+		}
 	}
 }
 
@@ -78,7 +123,18 @@ func (c *UDPClient) handleMessage(b []byte) error {
 	switch msg.Type {
 	case messages.MessageTypeServerPong:
 		log.Debug("Received server pong")
-	case messages.MessageTypeServerGameUpdate, messages.MessageTypeServerPlayerUpdate, messages.MessageTypeServerNPCUpdate:
+	case messages.MessageTypeServerGameUpdate,
+		messages.MessageTypeServerPlayerUpdate,
+		messages.MessageTypeServerNPCUpdate,
+		messages.MessageTypeServerPlayerConnect,
+		messages.MessageTypeServerPlayerDisconnect,
+		messages.MessageTypeServerNPCHit,
+		messages.MessageTypeServerNPCKill,
+		messages.MessageTypeServerPlayerHit,
+		messages.MessageTypeServerPlayerKill,
+		messages.MessageTypeServerEntityEnterView,
+		messages.MessageTypeServerEntityLeaveView,
+		messages.MessageTypeServerShutdown:
 		if err := c.messageQueue.Enqueue(msg); err != nil {
 			return fmt.Errorf("failed to enqueue message: %v", err)
 		}
@@ -98,14 +154,19 @@ func (c *UDPClient) Close() {
 	c.conn.Close()
 }
 
-// SendMessage sends a message to the UDP server.
-func (c *UDPClient) SendMessage(msg *messages.Message) error {
+// SendMessage sends a message to the UDP server on the given channel.
+func (c *UDPClient) SendMessage(msg *messages.Message, channel transport.ChannelType) error {
 	b, err := messages.SerializeMessage(msg)
 	if err != nil {
 		return fmt.Errorf("failed to serialize message: %v", err)
 	}
 
-	_, err = c.conn.WriteToUDP(b, c.serverAddr)
+	datagram, err := c.endpoint.Send(channel, b)
+	if err != nil {
+		return fmt.Errorf("failed to send message on endpoint: %v", err)
+	}
+
+	_, err = c.conn.WriteToUDP(datagram, c.serverAddr)
 	if err != nil {
 		return fmt.Errorf("failed to write message to UDP connection: %v", err)
 	}
@@ -115,7 +176,7 @@ func (c *UDPClient) SendMessage(msg *messages.Message) error {
 
 // ReadFromUDP reads a buffer from a UDP connection
 func ReadFromUDP(conn *net.UDPConn) ([]byte, error) {
-	buf := make([]byte, messages.UDPMessageBufferSize)
+	buf := make([]byte, transport.HeaderSize+transport.SealOverhead+messages.UDPMessageBufferSize)
 	n, _, err := conn.ReadFromUDP(buf)
 	if err != nil {
 		if err, ok := err.(*net.OpError); ok && err.Err.Error() == "use of closed network connection" {
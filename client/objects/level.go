@@ -16,9 +16,9 @@ type LevelObject struct {
 }
 
 type NewLevelObjectOptions struct {
-	// X is the x-coordinate of the level object.
+	// X is the x-coordinate of the left of the level object.
 	X float32
-	// Y is the y-coordinate of the level object.
+	// Y is the y-coordinate of the bottom of the level object, pointing up.
 	Y float32
 	// W is the width of the level object.
 	W float32
@@ -43,6 +43,13 @@ func NewLevelObject(id string, opts NewLevelObjectOptions) *LevelObject {
 	}
 }
 
-func (o *LevelObject) Draw(screen *ebiten.Image) {
-	vector.DrawFilledRect(screen, o.x, o.y, o.w, o.h, o.clr, false)
+// DrawWorld draws the level object relative to the viewport
+func (o *LevelObject) DrawWorld(screen *ebiten.Image, viewport Viewport) {
+	x := o.x - float32(viewport.X)
+	y := float32(screen.Bounds().Dy()) - (o.y - float32(viewport.Y)) - o.h
+	vector.DrawFilledRect(screen, x, y, o.w, o.h, o.clr, false)
+}
+
+func (o *LevelObject) WorldBounds() (x, y, w, h float64) {
+	return float64(o.x), float64(o.y), float64(o.w), float64(o.h)
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/cbodonnell/flywheel/client/fonts"
 	"github.com/cbodonnell/flywheel/pkg/game/constants"
 	gametypes "github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
@@ -38,38 +39,52 @@ func NewNPC(id string, state *gametypes.NPCState) (*NPC, error) {
 		BaseObject: NewBaseObject(id, baseObjectOpts),
 		ID:         id,
 		// debug: true,
-		State: state,
-		animations: map[gametypes.NPCAnimation]*animations.Animation{
-			gametypes.NPCAnimationIdle:    animations.NewNPCIdleAnimation(),
-			gametypes.NPCAnimationWalk:    animations.NewNPCWalkAnimation(),
-			gametypes.NPCAnimationDead:    animations.NewNPCDeadAnimation(),
-			gametypes.NPCAnimationAttack1: animations.NewNPCAttack1Animation(),
-			gametypes.NPCAnimationAttack2: animations.NewNPCAttack2Animation(),
-			gametypes.NPCAnimationAttack3: animations.NewNPCAttack3Animation(),
-		},
+		State:      state,
+		animations: newNPCAnimations(state.Archetype.Sprite),
 	}, nil
 }
 
+// newNPCAnimations creates the animations of an NPC sprite for each animation key abilities can use
+func newNPCAnimations(sprite string) map[gametypes.NPCAnimation]*animations.Animation {
+	spriteAnimations, ok := animations.NPCSprites[sprite]
+	if !ok {
+		log.Warn("No animations for NPC sprite %s", sprite)
+		spriteAnimations = animations.NPCSprites[animations.DefaultNPCSprite]
+	}
+	npcAnimations := make(map[gametypes.NPCAnimation]*animations.Animation, len(gametypes.NPCAnimationKeys))
+	for key, animation := range gametypes.NPCAnimationKeys {
+		newAnimation, ok := spriteAnimations[key]
+		if !ok {
+			log.Warn("No %s animation of NPC sprite %s", key, sprite)
+			newAnimation = spriteAnimations["idle"]
+		}
+		npcAnimations[animation] = newAnimation()
+	}
+	return npcAnimations
+}
+
 func (o *NPC) Update() error {
 	o.animations[o.State.Animation].Update()
 	return nil
 }
 
-func (o *NPC) Draw(screen *ebiten.Image) {
+// DrawWorld draws the NPC relative to the viewport
+func (o *NPC) DrawWorld(screen *ebiten.Image, viewport Viewport) {
+	x, y := o.State.Position.X-viewport.X, o.State.Position.Y-viewport.Y
 	if o.State.AnimationSequence != o.lastDrawnAnimationSequence {
 		o.animations[o.State.Animation].Reset()
 	}
-	o.animations[o.State.Animation].Draw(screen, o.State.Position.X, o.State.Position.Y, o.State.FlipH)
+	o.animations[o.State.Animation].Draw(screen, x, y, o.State.FlipH)
 	o.lastDrawnAnimationSequence = o.State.AnimationSequence
 
 	if !o.State.IsDead() {
 		// Draw Name
-		t := strings.ToUpper("Skeleton")
+		t := strings.ToUpper(o.State.Archetype.Name)
 		f := fonts.TTFSmallFont
 		bounds, _ := font.BoundString(f, t)
 		op := &ebiten.DrawImageOptions{}
 		offsetY := float64(24)
-		op.GeoM.Translate(float64(o.State.Position.X)+constants.PlayerWidth/2-float64(bounds.Max.X>>6)/2, float64(screen.Bounds().Dy())-float64(o.State.Position.Y)-constants.PlayerHeight-offsetY)
+		op.GeoM.Translate(x+constants.PlayerWidth/2-float64(bounds.Max.X>>6)/2, float64(screen.Bounds().Dy())-y-constants.PlayerHeight-offsetY)
 		op.ColorScale.ScaleWithColor(color.White)
 		text.DrawWithOptions(screen, t, f, op)
 
@@ -77,13 +92,13 @@ func (o *NPC) Draw(screen *ebiten.Image) {
 		hitpointsBarWidth := float32(constants.NPCWidth)
 		hitpointsBarHeight := float32(8)
 		hitpointsBarYOffset := float32(12)
-		hitpointsBarX := float32(o.State.Position.X)
-		hitpointsBarY := float32(float64(screen.Bounds().Dy())-constants.NPCHeight) - float32(o.State.Position.Y) - hitpointsBarHeight - hitpointsBarYOffset
+		hitpointsBarX := float32(x)
+		hitpointsBarY := float32(float64(screen.Bounds().Dy())-constants.NPCHeight) - float32(y) - hitpointsBarHeight - hitpointsBarYOffset
 		hitpointsBarColor := color.RGBA{255, 0, 0, 255} // Red
 		vector.DrawFilledRect(screen, hitpointsBarX, hitpointsBarY, hitpointsBarWidth, hitpointsBarHeight, hitpointsBarColor, false)
 
 		// Draw hitpoints
-		hitpointsWidth := float32(float64(hitpointsBarWidth) * (float64(o.State.Hitpoints) / float64(constants.NPCHitpoints)))
+		hitpointsWidth := float32(float64(hitpointsBarWidth) * (float64(o.State.Hitpoints) / float64(o.State.Archetype.Hitpoints)))
 		hitpointsHeight := float32(hitpointsBarHeight)
 		hitpointsX := hitpointsBarX
 		hitpointsY := hitpointsBarY
@@ -97,10 +112,16 @@ func (o *NPC) Draw(screen *ebiten.Image) {
 		if o.State.IsOnGround {
 			npcColor = color.RGBA{200, 0, 200, 255} // Purple
 		}
-		vector.StrokeRect(screen, float32(o.State.Position.X), float32(float64(screen.Bounds().Dy())-constants.NPCHeight)-float32(o.State.Position.Y), float32(constants.NPCHeight), float32(constants.NPCWidth), strokeWidth, npcColor, false)
+		vector.StrokeRect(screen, float32(x), float32(float64(screen.Bounds().Dy())-constants.NPCHeight)-float32(y), float32(constants.NPCHeight), float32(constants.NPCWidth), strokeWidth, npcColor, false)
 	}
 }
 
+// WorldBounds returns the rectangle the NPC is drawn in, with room for its name and hitpoints
+func (o *NPC) WorldBounds() (x, y, w, h float64) {
+	x, y, w, h = o.animations[o.State.Animation].Bounds(o.State.Position.X, o.State.Position.Y)
+	return x - labelMargin, y - labelMargin, w + 2*labelMargin, h + 2*labelMargin
+}
+
 func (o *NPC) InterpolateState(from *gametypes.NPCState, to *gametypes.NPCState, factor float64) {
 	if from.IsDead() && !to.IsDead() {
 		o.State.Position.X = to.Position.X
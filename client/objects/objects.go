@@ -202,3 +202,18 @@ func DrawTree(object GameObject, screen *ebiten.Image) {
 		DrawTree(child, screen)
 	}
 }
+
+// DrawWorldTree draws an object tree to a screen showing a viewport of the world.
+// World objects are only drawn if they intersect the viewport.
+func DrawWorldTree(object GameObject, screen *ebiten.Image, viewport Viewport) {
+	if worldObject, ok := object.(WorldObject); ok {
+		if viewport.Intersects(worldObject.WorldBounds()) {
+			worldObject.DrawWorld(screen, viewport)
+		}
+	} else {
+		object.Draw(screen)
+	}
+	for _, child := range object.GetChildren() {
+		DrawWorldTree(child, screen, viewport)
+	}
+}
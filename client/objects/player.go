@@ -1,9 +1,9 @@
 package objects
 
 import (
-	"encoding/json"
 	"fmt"
 	"image/color"
+	"math"
 	"time"
 
 	"github.com/cbodonnell/flywheel/client/animations"
@@ -37,6 +37,10 @@ type Player struct {
 	State          *gametypes.PlayerState
 	previousStates []PreviousState
 	pastUpdates    []*messages.ClientPlayerUpdate
+	// sequence is the sequence of the last player update
+	sequence uint32
+	// lastTimestamp is the timestamp of the last player update
+	lastTimestamp int64
 
 	animations                 map[gametypes.PlayerAnimation]*animations.Animation
 	lastDrawnAnimationSequence uint8
@@ -44,7 +48,9 @@ type Player struct {
 
 type PreviousState struct {
 	Timestamp int64
-	State     *gametypes.PlayerState
+	// Input is the input that produced the state
+	Input *messages.ClientPlayerUpdate
+	State *gametypes.PlayerState
 }
 
 func NewPlayer(id string, networkManager *network.NetworkManager, state *gametypes.PlayerState) (*Player, error) {
@@ -75,22 +81,25 @@ func NewPlayer(id string, networkManager *network.NetworkManager, state *gametyp
 		networkManager: networkManager,
 		isLocalPlayer:  isLocalPlayer,
 		// debug:          true,
-		State: state,
-		animations: map[gametypes.PlayerAnimation]*animations.Animation{
-			gametypes.PlayerAnimationIdle:        animations.NewPlayerIdleAnimation(),
-			gametypes.PlayerAnimationRun:         animations.NewPlayerRunAnimation(),
-			gametypes.PlayerAnimationJump:        animations.NewPlayerJumpAnimation(),
-			gametypes.PlayerAnimationLadderIdle:  animations.NewPlayerLadderIdleAnimation(),
-			gametypes.PlayerAnimationLadderClimb: animations.NewPlayerLadderClimbAnimation(),
-			gametypes.PlayerAnimationFall:        animations.NewPlayerFallAnimation(),
-			gametypes.PlayerAnimationAttack1:     animations.NewPlayerAttack1Animation(),
-			gametypes.PlayerAnimationAttack2:     animations.NewPlayerAttack2Animation(),
-			gametypes.PlayerAnimationAttack3:     animations.NewPlayerAttack3Animation(),
-			gametypes.PlayerAnimationDead:        animations.NewPlayerDeadAnimation(),
-		},
+		State:      state,
+		animations: newPlayerAnimations(),
 	}, nil
 }
 
+// newPlayerAnimations creates the animations of a player for each animation key abilities can use
+func newPlayerAnimations() map[gametypes.PlayerAnimation]*animations.Animation {
+	playerAnimations := make(map[gametypes.PlayerAnimation]*animations.Animation, len(gametypes.PlayerAnimationKeys))
+	for key, animation := range gametypes.PlayerAnimationKeys {
+		newAnimation, ok := animations.PlayerAnimations[key]
+		if !ok {
+			log.Warn("No player animation for key %s", key)
+			newAnimation = animations.NewPlayerIdleAnimation
+		}
+		playerAnimations[animation] = newAnimation()
+	}
+	return playerAnimations
+}
+
 func (o *Player) Update() error {
 	o.animations[o.State.Animation].Update()
 
@@ -124,8 +133,19 @@ func (o *Player) Update() error {
 
 	inputRespawn := input.IsRespawnJustPressed()
 
+	// inputs are stamped with the estimated server time so the server can rewind hits to
+	// what was rendered, and timestamps must increase with every update to identify the
+	// state acked by the server
+	now := time.Now().UnixMilli()
+	if serverTime, _ := o.networkManager.ServerTime(); serverTime > 0 {
+		now = int64(math.Round(serverTime))
+	}
+	o.sequence++
+	o.lastTimestamp = max(now, o.lastTimestamp+1)
+
 	cpu := &messages.ClientPlayerUpdate{
-		Timestamp:    time.Now().UnixMilli(),
+		Sequence:     o.sequence,
+		Timestamp:    o.lastTimestamp,
 		InputX:       inputX,
 		InputY:       inputY,
 		InputJump:    inputJump,
@@ -133,12 +153,12 @@ func (o *Player) Update() error {
 		InputAttack2: inputAttack2,
 		InputAttack3: inputAttack3,
 		InputRespawn: inputRespawn,
-		DeltaTime:    1.0 / float64(ebiten.TPS()),
+		DeltaTime:    constants.PlayerStepDuration,
 		PastUpdates:  o.pastUpdates,
 	}
-	payload, err := json.Marshal(cpu)
+	payload, err := messages.SerializeClientPlayerUpdate(cpu)
 	if err != nil {
-		return fmt.Errorf("failed to marshal client player update: %v", err)
+		return fmt.Errorf("failed to serialize client player update: %v", err)
 	}
 
 	msg := &messages.Message{
@@ -161,6 +181,7 @@ func (o *Player) Update() error {
 
 	o.previousStates = append(o.previousStates, PreviousState{
 		Timestamp: cpu.Timestamp,
+		Input:     cpu,
 		State:     o.State.Copy(), // store a copy as the state will be modified by the game loop
 	})
 	for len(o.previousStates) > MaxPreviousStates {
@@ -170,11 +191,13 @@ func (o *Player) Update() error {
 	return nil
 }
 
-func (o *Player) Draw(screen *ebiten.Image) {
+// DrawWorld draws the player relative to the viewport
+func (o *Player) DrawWorld(screen *ebiten.Image, viewport Viewport) {
+	x, y := o.State.Position.X-viewport.X, o.State.Position.Y-viewport.Y
 	if o.State.AnimationSequence != o.lastDrawnAnimationSequence {
 		o.animations[o.State.Animation].Reset()
 	}
-	o.animations[o.State.Animation].Draw(screen, o.State.Position.X, o.State.Position.Y, o.State.FlipH)
+	o.animations[o.State.Animation].Draw(screen, x, y, o.State.FlipH)
 	o.lastDrawnAnimationSequence = o.State.AnimationSequence
 
 	// Draw Name
@@ -183,7 +206,7 @@ func (o *Player) Draw(screen *ebiten.Image) {
 	bounds, _ := font.BoundString(f, t)
 	op := &ebiten.DrawImageOptions{}
 	offsetY := float64(24)
-	op.GeoM.Translate(float64(o.State.Position.X)+constants.PlayerWidth/2-float64(bounds.Max.X>>6)/2, float64(screen.Bounds().Dy())-float64(o.State.Position.Y)-constants.PlayerHeight-offsetY)
+	op.GeoM.Translate(x+constants.PlayerWidth/2-float64(bounds.Max.X>>6)/2, float64(screen.Bounds().Dy())-y-constants.PlayerHeight-offsetY)
 	op.ColorScale.ScaleWithColor(color.White)
 	text.DrawWithOptions(screen, t, f, op)
 
@@ -192,8 +215,8 @@ func (o *Player) Draw(screen *ebiten.Image) {
 		hitpointsBarWidth := float32(constants.NPCWidth)
 		hitpointsBarHeight := float32(8)
 		hitpointsBarYOffset := float32(12)
-		hitpointsBarX := float32(o.State.Position.X)
-		hitpointsBarY := float32(float64(screen.Bounds().Dy())-constants.NPCHeight) - float32(o.State.Position.Y) - hitpointsBarHeight - hitpointsBarYOffset
+		hitpointsBarX := float32(x)
+		hitpointsBarY := float32(float64(screen.Bounds().Dy())-constants.NPCHeight) - float32(y) - hitpointsBarHeight - hitpointsBarYOffset
 		hitpointsBarColor := color.RGBA{255, 0, 0, 255} // Red
 		vector.DrawFilledRect(screen, hitpointsBarX, hitpointsBarY, hitpointsBarWidth, hitpointsBarHeight, hitpointsBarColor, false)
 
@@ -221,10 +244,16 @@ func (o *Player) Draw(screen *ebiten.Image) {
 				playerColor = color.RGBA{200, 0, 200, 255} // Purple
 			}
 		}
-		vector.StrokeRect(screen, float32(o.State.Position.X), float32(float64(screen.Bounds().Dy())-constants.PlayerHeight)-float32(o.State.Position.Y), float32(constants.PlayerWidth), float32(constants.PlayerHeight), strokeWidth, playerColor, false)
+		vector.StrokeRect(screen, float32(x), float32(float64(screen.Bounds().Dy())-constants.PlayerHeight)-float32(y), float32(constants.PlayerWidth), float32(constants.PlayerHeight), strokeWidth, playerColor, false)
 	}
 }
 
+// WorldBounds returns the rectangle the player is drawn in, with room for its name and hitpoints
+func (o *Player) WorldBounds() (x, y, w, h float64) {
+	x, y, w, h = o.animations[o.State.Animation].Bounds(o.State.Position.X, o.State.Position.Y)
+	return x - labelMargin, y - labelMargin, w + 2*labelMargin, h + 2*labelMargin
+}
+
 func (o *Player) InterpolateState(from *gametypes.PlayerState, to *gametypes.PlayerState, factor float64) {
 	o.State.LastProcessedTimestamp = to.LastProcessedTimestamp
 	// TODO: extend or shorten movement based on number of client updates processed to address jitter
@@ -262,7 +291,8 @@ func (o *Player) ExtrapolateState(from *gametypes.PlayerState, to *gametypes.Pla
 // by going back through the past client states and checking if it
 // the client state for that timestamp matches the server state.
 // If it doesn't match, the server state is applied and all of the
-// past updates that are after the last processed timestamp are replayed.
+// inputs that are after the last processed timestamp are replayed
+// at the fixed timestep of the server.
 func (o *Player) ReconcileState(state *gametypes.PlayerState) error {
 	if state.LastProcessedTimestamp == 0 {
 		// initial state received from the server, nothing to reconcile
@@ -295,11 +325,12 @@ func (o *Player) ReconcileState(state *gametypes.PlayerState) error {
 				o.State.Object.Position.X = state.Position.X
 				o.State.Object.Position.Y = state.Position.Y
 
-				// replay all of the past updates that are after the reconciled state
-				for j := 0; j < len(o.pastUpdates); j++ {
-					if o.pastUpdates[j].Timestamp > state.LastProcessedTimestamp {
-						o.State.ApplyInput(o.pastUpdates[j])
-					}
+				// replay all of the inputs that are after the reconciled state,
+				// correcting the states predicted from them
+				o.previousStates[i].State = o.State.Copy()
+				for j := i + 1; j < len(o.previousStates); j++ {
+					o.State.ApplyInput(o.previousStates[j].Input)
+					o.previousStates[j].State = o.State.Copy()
 				}
 			}
 			break
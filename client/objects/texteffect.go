@@ -78,12 +78,21 @@ func (o *TextEffect) Update() error {
 	return nil
 }
 
-func (o *TextEffect) Draw(screen *ebiten.Image) {
+// DrawWorld draws the text relative to the viewport
+func (o *TextEffect) DrawWorld(screen *ebiten.Image, viewport Viewport) {
 	t := strings.ToUpper(o.text)
 	f := fonts.TTFSmallFont
 	bounds, _ := font.BoundString(f, t)
 	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(o.x-float64(bounds.Max.X>>6)/2, float64(screen.Bounds().Dy())-o.y)
+	op.GeoM.Translate(o.x-viewport.X-float64(bounds.Max.X>>6)/2, float64(screen.Bounds().Dy())-(o.y-viewport.Y))
 	op.ColorScale.ScaleWithColor(o.color)
 	text.DrawWithOptions(screen, t, f, op)
 }
+
+// WorldBounds returns the rectangle the text is drawn in, with y pointing up from its baseline
+func (o *TextEffect) WorldBounds() (x, y, w, h float64) {
+	bounds, _ := font.BoundString(fonts.TTFSmallFont, strings.ToUpper(o.text))
+	w = float64((bounds.Max.X - bounds.Min.X) >> 6)
+	h = float64((bounds.Max.Y - bounds.Min.Y) >> 6)
+	return o.x - w/2, o.y - float64(bounds.Max.Y>>6), w, h
+}
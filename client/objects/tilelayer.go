@@ -0,0 +1,126 @@
+package objects
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// TileLayer is a tile layer of a level. Only the tiles in the viewport are drawn,
+// so that layers of levels larger than the screen stay fast to draw.
+type TileLayer struct {
+	*BaseObject
+
+	level         *levels.Level
+	layer         *levels.TileLayer
+	tilesetImages map[*levels.Tileset]*ebiten.Image
+	// overhangColumns and overhangRows are how many cells the largest tiles
+	// extend right and up beyond the cell they are in
+	overhangColumns int
+	overhangRows    int
+}
+
+type NewTileLayerOptions struct {
+	// Level is the level the layer is in.
+	Level *levels.Level
+	// Layer is the tile layer to draw.
+	Layer *levels.TileLayer
+	// TilesetImages are the images of the tilesets of the level.
+	TilesetImages map[*levels.Tileset]*ebiten.Image
+}
+
+func NewTileLayer(id string, opts NewTileLayerOptions) (*TileLayer, error) {
+	level, layer := opts.Level, opts.Layer
+	for i, gid := range layer.Tiles {
+		tileset, _, ok := level.Tile(gid)
+		if !ok {
+			if gid != 0 {
+				return nil, fmt.Errorf("tile %d of layer %s has unknown GID %d", i, layer.Name, gid)
+			}
+			continue
+		}
+		if _, ok := opts.TilesetImages[tileset]; !ok {
+			return nil, fmt.Errorf("missing image of tileset %s", tileset.Name)
+		}
+	}
+
+	var overhangColumns, overhangRows int
+	for _, tileset := range level.Tilesets {
+		overhangColumns = max(overhangColumns, (tileset.TileWidth+level.TileWidth-1)/level.TileWidth-1)
+		overhangRows = max(overhangRows, (tileset.TileHeight+level.TileHeight-1)/level.TileHeight-1)
+	}
+
+	return &TileLayer{
+		BaseObject: NewBaseObject(id, &NewBaseObjectOpts{
+			ZIndex: layer.ZIndex,
+		}),
+		level:           level,
+		layer:           layer,
+		tilesetImages:   opts.TilesetImages,
+		overhangColumns: overhangColumns,
+		overhangRows:    overhangRows,
+	}, nil
+}
+
+// applyTileFlips flips a tile of the given size about its center by the flip flags of its GID,
+// diagonally first, then horizontally and vertically
+func applyTileFlips(geoM *ebiten.GeoM, gid uint32, w, h float64) {
+	if gid&(levels.TileFlipHorizontal|levels.TileFlipVertical|levels.TileFlipDiagonal) == 0 {
+		return
+	}
+	geoM.Translate(-w/2, -h/2)
+	if gid&levels.TileFlipDiagonal != 0 {
+		var transpose ebiten.GeoM
+		transpose.SetElement(0, 0, 0)
+		transpose.SetElement(0, 1, 1)
+		transpose.SetElement(1, 0, 1)
+		transpose.SetElement(1, 1, 0)
+		geoM.Concat(transpose)
+	}
+	if gid&levels.TileFlipHorizontal != 0 {
+		geoM.Scale(-1, 1)
+	}
+	if gid&levels.TileFlipVertical != 0 {
+		geoM.Scale(1, -1)
+	}
+	geoM.Translate(w/2, h/2)
+}
+
+// DrawWorld draws the tiles of the layer in the viewport
+func (o *TileLayer) DrawWorld(screen *ebiten.Image, viewport Viewport) {
+	level := o.level
+	// the viewport in the coordinates of the map, where y points down from the top of the level
+	left := viewport.X - o.layer.OffsetX
+	top := float64(level.Height) - viewport.Y - viewport.Height - o.layer.OffsetY
+
+	// tiles are aligned to the bottom left of their cell, so large tiles in cells
+	// left of or below the viewport can overhang into it
+	minColumn := max(0, int(math.Floor(left/float64(level.TileWidth)))-o.overhangColumns)
+	maxColumn := min(level.Columns-1, int(math.Floor((left+viewport.Width)/float64(level.TileWidth))))
+	minRow := max(0, int(math.Floor(top/float64(level.TileHeight))))
+	maxRow := min(level.Rows-1, int(math.Floor((top+viewport.Height)/float64(level.TileHeight)))+o.overhangRows)
+
+	for row := minRow; row <= maxRow; row++ {
+		for column := minColumn; column <= maxColumn; column++ {
+			gid := o.layer.Tiles[row*level.Columns+column]
+			tileset, bounds, ok := level.Tile(gid)
+			if !ok {
+				continue
+			}
+
+			x := float64(column*level.TileWidth) - left
+			y := float64((row+1)*level.TileHeight-tileset.TileHeight) - top
+			op := &ebiten.DrawImageOptions{}
+			applyTileFlips(&op.GeoM, gid, float64(tileset.TileWidth), float64(tileset.TileHeight))
+			op.GeoM.Translate(x, y)
+			op.ColorScale.ScaleAlpha(float32(o.layer.Opacity))
+			screen.DrawImage(o.tilesetImages[tileset].SubImage(bounds).(*ebiten.Image), op)
+		}
+	}
+}
+
+func (o *TileLayer) WorldBounds() (x, y, w, h float64) {
+	return o.layer.OffsetX, -o.layer.OffsetY, float64(o.level.Width), float64(o.level.Height)
+}
@@ -0,0 +1,32 @@
+package objects
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// labelMargin is the room left around the sprites of characters for the labels drawn around them
+const labelMargin = 64.0
+
+// Viewport is the rectangle of the world drawn to the screen, in game coordinates with y pointing up.
+// Objects in the world are drawn relative to it onto a screen of its size, so that worlds
+// larger than the screen are drawn without an image of the whole world.
+type Viewport struct {
+	// X and Y are the bottom left corner of the viewport
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Intersects returns true if a rectangle in game coordinates overlaps the viewport
+func (v Viewport) Intersects(x, y, w, h float64) bool {
+	return x < v.X+v.Width && x+w > v.X && y < v.Y+v.Height && y+h > v.Y
+}
+
+// WorldObject is a game object in the world, drawn relative to the viewport of a camera
+type WorldObject interface {
+	GameObject
+
+	// DrawWorld draws the object to a screen showing the viewport
+	DrawWorld(screen *ebiten.Image, viewport Viewport)
+	// WorldBounds returns the rectangle the object is drawn in, in game coordinates
+	WorldBounds() (x, y, w, h float64)
+}
@@ -0,0 +1,206 @@
+package scenes
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/cbodonnell/flywheel/client/objects"
+)
+
+const (
+	// DefaultCameraSmoothing is how quickly the camera catches up with its target, per second
+	DefaultCameraSmoothing = 8.0
+	// DefaultCameraDeadZoneWidth is the width of the area around the center of the camera
+	// the target can move in without moving the camera
+	DefaultCameraDeadZoneWidth = 96.0
+	// DefaultCameraDeadZoneHeight is the height of the area around the center of the camera
+	// the target can move in without moving the camera
+	DefaultCameraDeadZoneHeight = 64.0
+)
+
+// DefaultZoomLevels are the zoom scales the camera can switch between
+var DefaultZoomLevels = []float64{0.5, 0.75, 1, 1.5, 2}
+
+// Camera follows a target through the world and decides which part of it is drawn to the screen.
+// Positions are in game coordinates with y pointing up.
+type Camera struct {
+	// x and y are the center of the camera
+	x float64
+	y float64
+	// focusX and focusY are where the camera is moving to, which keeps the target in the dead zone
+	focusX float64
+	focusY float64
+	// worldWidth and worldHeight are the size of the world the viewport is kept in
+	worldWidth  float64
+	worldHeight float64
+
+	smoothing      float64
+	deadZoneWidth  float64
+	deadZoneHeight float64
+	zoomLevels     []float64
+	zoomLevel      int
+
+	// shakeIntensity is the largest offset of a shake, which decays over its duration
+	shakeIntensity float64
+	shakeDuration  time.Duration
+	shakeTimeLeft  time.Duration
+	shakeX         float64
+	shakeY         float64
+}
+
+type NewCameraOptions struct {
+	// WorldWidth and WorldHeight are the size of the world the viewport is kept in.
+	WorldWidth  float64
+	WorldHeight float64
+	// Smoothing is how quickly the camera catches up with its target, per second.
+	// The camera snaps to its target if it is negative. Defaults to DefaultCameraSmoothing.
+	Smoothing float64
+	// DeadZoneWidth and DeadZoneHeight are the size of the area around the center of the camera
+	// the target can move in without moving the camera.
+	// Default to DefaultCameraDeadZoneWidth and DefaultCameraDeadZoneHeight.
+	DeadZoneWidth  float64
+	DeadZoneHeight float64
+	// ZoomLevels are the zoom scales the camera can switch between. Defaults to DefaultZoomLevels.
+	ZoomLevels []float64
+	// Zoom is the zoom scale the camera starts at, rounded to the closest zoom level. Defaults to 1.
+	Zoom float64
+}
+
+func NewCamera(opts NewCameraOptions) *Camera {
+	smoothing := opts.Smoothing
+	if smoothing == 0 {
+		smoothing = DefaultCameraSmoothing
+	}
+	deadZoneWidth := opts.DeadZoneWidth
+	if deadZoneWidth == 0 {
+		deadZoneWidth = DefaultCameraDeadZoneWidth
+	}
+	deadZoneHeight := opts.DeadZoneHeight
+	if deadZoneHeight == 0 {
+		deadZoneHeight = DefaultCameraDeadZoneHeight
+	}
+	zoomLevels := opts.ZoomLevels
+	if len(zoomLevels) == 0 {
+		zoomLevels = DefaultZoomLevels
+	}
+	startZoom := opts.Zoom
+	if startZoom == 0 {
+		startZoom = 1
+	}
+	zoomLevel := 0
+	for i, zoom := range zoomLevels {
+		if math.Abs(zoom-startZoom) < math.Abs(zoomLevels[zoomLevel]-startZoom) {
+			zoomLevel = i
+		}
+	}
+
+	return &Camera{
+		worldWidth:     opts.WorldWidth,
+		worldHeight:    opts.WorldHeight,
+		smoothing:      smoothing,
+		deadZoneWidth:  deadZoneWidth,
+		deadZoneHeight: deadZoneHeight,
+		zoomLevels:     zoomLevels,
+		zoomLevel:      zoomLevel,
+	}
+}
+
+// SetWorldSize changes the size of the world the viewport is kept in
+func (c *Camera) SetWorldSize(width, height float64) {
+	c.worldWidth = width
+	c.worldHeight = height
+}
+
+// Snap centers the camera on a target without smoothing
+func (c *Camera) Snap(targetX, targetY float64) {
+	c.x, c.y = targetX, targetY
+	c.focusX, c.focusY = targetX, targetY
+}
+
+// Update moves the camera towards a target and advances its shake by the time since the last update
+func (c *Camera) Update(targetX, targetY float64, deltaTime time.Duration) {
+	// the focus only moves as far as it takes to keep the target in the dead zone
+	c.focusX = clampToDeadZone(c.focusX, targetX, c.deadZoneWidth/2)
+	c.focusY = clampToDeadZone(c.focusY, targetY, c.deadZoneHeight/2)
+
+	if c.smoothing < 0 {
+		c.x, c.y = c.focusX, c.focusY
+	} else {
+		// ease towards the focus independently of the frame rate
+		factor := 1 - math.Exp(-c.smoothing*deltaTime.Seconds())
+		c.x += (c.focusX - c.x) * factor
+		c.y += (c.focusY - c.y) * factor
+	}
+
+	c.shakeX, c.shakeY = 0, 0
+	if c.shakeTimeLeft > 0 {
+		c.shakeTimeLeft = max(c.shakeTimeLeft-deltaTime, 0)
+		intensity := c.shakeIntensity * float64(c.shakeTimeLeft) / float64(c.shakeDuration)
+		c.shakeX = (rand.Float64()*2 - 1) * intensity
+		c.shakeY = (rand.Float64()*2 - 1) * intensity
+	}
+}
+
+// clampToDeadZone returns the focus moved just far enough for the target to be within a margin of it
+func clampToDeadZone(focus, target, margin float64) float64 {
+	if target > focus+margin {
+		return target - margin
+	}
+	if target < focus-margin {
+		return target + margin
+	}
+	return focus
+}
+
+// Shake shakes the camera by up to intensity pixels, decaying over the duration.
+// A shake does not weaken one already in progress.
+func (c *Camera) Shake(intensity float64, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	if c.shakeTimeLeft > 0 && c.shakeIntensity*float64(c.shakeTimeLeft)/float64(c.shakeDuration) > intensity {
+		return
+	}
+	c.shakeIntensity = intensity
+	c.shakeDuration = duration
+	c.shakeTimeLeft = duration
+}
+
+// Zoom returns the zoom scale of the camera
+func (c *Camera) Zoom() float64 {
+	return c.zoomLevels[c.zoomLevel]
+}
+
+// ZoomIn switches to the next larger zoom level, if there is one
+func (c *Camera) ZoomIn() {
+	c.zoomLevel = min(c.zoomLevel+1, len(c.zoomLevels)-1)
+}
+
+// ZoomOut switches to the next smaller zoom level, if there is one
+func (c *Camera) ZoomOut() {
+	c.zoomLevel = max(c.zoomLevel-1, 0)
+}
+
+// Viewport returns the part of the world shown on a screen of the given size, kept within the
+// world, or centered on it along the axes it is smaller than the viewport in. The shake of the
+// camera is applied after clamping so that the camera shakes at the edges of the world too.
+func (c *Camera) Viewport(screenWidth, screenHeight int) objects.Viewport {
+	width := math.Ceil(float64(screenWidth) / c.Zoom())
+	height := math.Ceil(float64(screenHeight) / c.Zoom())
+	return objects.Viewport{
+		X:      math.Round(clampViewport(c.x, width, c.worldWidth) + c.shakeX),
+		Y:      math.Round(clampViewport(c.y, height, c.worldHeight) + c.shakeY),
+		Width:  width,
+		Height: height,
+	}
+}
+
+// clampViewport returns the start of a viewport of a size centered on a position,
+// kept within a world of a size
+func clampViewport(center, size, worldSize float64) float64 {
+	if size >= worldSize {
+		return (worldSize - size) / 2
+	}
+	return min(max(center-size/2, 0), worldSize-size)
+}
@@ -1,30 +1,43 @@
 package scenes
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
 	"math"
+	"slices"
 	"time"
 
+	"github.com/cbodonnell/flywheel/client/fonts"
+	"github.com/cbodonnell/flywheel/client/input"
 	"github.com/cbodonnell/flywheel/client/network"
 	"github.com/cbodonnell/flywheel/client/objects"
 	"github.com/cbodonnell/flywheel/pkg/game"
 	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
 	gametypes "github.com/cbodonnell/flywheel/pkg/game/types"
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/google/uuid"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/solarlune/resolv"
+	"golang.org/x/image/font"
 )
 
 const (
-	// Zoom is the zoom scale of the game viewport.
-	Zoom = 1.0
+	// HitShakeIntensity is how far the camera shakes, in pixels, when the local player is hit
+	HitShakeIntensity = 6.0
+	// HitShakeDuration is how long the camera shakes when the local player is hit
+	HitShakeDuration = 300 * time.Millisecond
+	// AttackShakeIntensity is how far the camera shakes, in pixels, when the local player hits something
+	AttackShakeIntensity = 2.0
+	// AttackShakeDuration is how long the camera shakes when the local player hits something
+	AttackShakeDuration = 150 * time.Millisecond
 	// InterpolationOffset is how far back in time we want to interpolate.
-	InterpolationOffset = 150 // ms - currently 3x the server tick rate (50ms)
+	// The server rewinds hits by the same offset to compensate for it.
+	InterpolationOffset = constants.InterpolationOffset
 )
 
 type GameScene struct {
@@ -32,23 +45,34 @@ type GameScene struct {
 
 	// networkManager is the network manager.
 	networkManager *network.NetworkManager
+	// zoneID is the ID of the zone the local player is in.
+	zoneID uint32
+	// zoneChangedAt is the server time the local player entered the zone at.
+	// Game updates from before it are from the previous zone.
+	zoneChangedAt int64
+	// level is the level of the zone.
+	level *levels.Level
 	// collisionSpace is the collision space.
 	collisionSpace *resolv.Space
-	// world is the world image.
-	world *ebiten.Image
-	// cameraViewport is the current viewport.
-	cameraViewport *CameraViewport
+	// camera follows the local player and decides which part of the world is drawn.
+	camera *Camera
+	// view is the image the viewport of the camera is drawn to before it is scaled to the screen.
+	view *ebiten.Image
 	// deletedObjects is a map of deleted game objects indexed by a unique identifier
 	// and the timestamp of the deletion.
 	deletedObjects map[string]int64
 
+	// snapshotAssembler merges snapshots that were split across datagrams.
+	snapshotAssembler *messages.GameStateAssembler
+	// snapshotHistory holds the most recent snapshots received from the server
+	// to resolve delta snapshots against.
+	snapshotHistory *game.SnapshotHistory
+
 	serverPlayerUpdateBuffers map[uint32]*ServerPlayerUpdateBuffer
 	serverNPCUpdateBuffers    map[uint32]*ServerNPCUpdateBuffer
-}
 
-type CameraViewport struct {
-	X int
-	Y int
+	// shutdownAt is when the server announced it will shut down, or zero if it has not
+	shutdownAt time.Time
 }
 
 type ServerPlayerUpdateBuffer struct {
@@ -64,14 +88,21 @@ type ServerNPCUpdateBuffer struct {
 var _ Scene = &GameScene{}
 
 func NewGameScene(networkManager *network.NetworkManager) (Scene, error) {
-	collisionSpace := game.NewCollisionSpace()
-	world := ebiten.NewImage(collisionSpace.Width()*collisionSpace.CellWidth, collisionSpace.Height()*collisionSpace.CellHeight)
+	zone := levels.Active().Start()
+	level := zone.Level
 	return &GameScene{
-		BaseScene:                 NewBaseScene(objects.NewSortedZIndexObject("game-root")),
-		networkManager:            networkManager,
-		collisionSpace:            game.NewCollisionSpace(),
-		world:                     world,
+		BaseScene:      NewBaseScene(objects.NewSortedZIndexObject("game-root")),
+		networkManager: networkManager,
+		zoneID:         zone.ID,
+		level:          level,
+		collisionSpace: game.NewCollisionSpace(level),
+		camera: NewCamera(NewCameraOptions{
+			WorldWidth:  float64(level.Width),
+			WorldHeight: float64(level.Height),
+		}),
 		deletedObjects:            make(map[string]int64),
+		snapshotAssembler:         messages.NewGameStateAssembler(),
+		snapshotHistory:           game.NewSnapshotHistory(game.SnapshotHistorySize),
 		serverPlayerUpdateBuffers: make(map[uint32]*ServerPlayerUpdateBuffer),
 		serverNPCUpdateBuffers:    make(map[uint32]*ServerNPCUpdateBuffer),
 	}, nil
@@ -85,12 +116,18 @@ func (g *GameScene) Init() error {
 	return g.BaseScene.Init()
 }
 
+// addLevelObjects adds the tile layers of the level, or colored
+// rectangles of its collision objects if it has no tile layers
 func (g *GameScene) addLevelObjects() error {
+	if len(g.level.TileLayers) > 0 {
+		return g.addTileLayers()
+	}
+
 	background := objects.NewLevelObject("level-background", objects.NewLevelObjectOptions{
 		X:      0,
 		Y:      0,
-		W:      float32(g.world.Bounds().Dx()),
-		H:      float32(g.world.Bounds().Dy()),
+		W:      float32(g.level.Width),
+		H:      float32(g.level.Height),
 		Color:  color.RGBA{0x80, 0x80, 0x80, 0xff}, // Gray
 		ZIndex: 0,
 	})
@@ -104,7 +141,7 @@ func (g *GameScene) addLevelObjects() error {
 		if obj.HasTags(gametypes.CollisionSpaceTagLevel) {
 			opts = objects.NewLevelObjectOptions{
 				X:      float32(obj.Position.X),
-				Y:      float32(g.world.Bounds().Dy()) - float32(obj.Position.Y) - float32(obj.Size.Y),
+				Y:      float32(obj.Position.Y),
 				W:      float32(obj.Size.X),
 				H:      float32(obj.Size.Y),
 				Color:  color.RGBA{0x8b, 0x45, 0x13, 0xff}, // Saddle Brown
@@ -113,7 +150,7 @@ func (g *GameScene) addLevelObjects() error {
 		} else if obj.HasTags(gametypes.CollisionSpaceTagPlatform) {
 			opts = objects.NewLevelObjectOptions{
 				X:      float32(obj.Position.X),
-				Y:      float32(g.world.Bounds().Dy()) - float32(obj.Position.Y) - float32(obj.Size.Y),
+				Y:      float32(obj.Position.Y),
 				W:      float32(obj.Size.X),
 				H:      float32(obj.Size.Y),
 				Color:  color.RGBA{0xcd, 0x85, 0x3f, 0xff}, // Peruvian Brown
@@ -122,12 +159,21 @@ func (g *GameScene) addLevelObjects() error {
 		} else if obj.HasTags(gametypes.CollisionSpaceTagLadder) {
 			opts = objects.NewLevelObjectOptions{
 				X:      float32(obj.Position.X + obj.Size.X/4),
-				Y:      float32(g.world.Bounds().Dy()) - float32(obj.Position.Y) - float32(obj.Size.Y),
+				Y:      float32(obj.Position.Y),
 				W:      float32(obj.Size.X / 2),
 				H:      float32(obj.Size.Y),
 				Color:  color.RGBA{0x5a, 0x3a, 0x22, 0xff}, // Brown
 				ZIndex: 5,
 			}
+		} else if obj.HasTags(gametypes.CollisionSpaceTagPortal) {
+			opts = objects.NewLevelObjectOptions{
+				X:      float32(obj.Position.X),
+				Y:      float32(obj.Position.Y),
+				W:      float32(obj.Size.X),
+				H:      float32(obj.Size.Y),
+				Color:  color.RGBA{0x8a, 0x2b, 0xe2, 0xff}, // Blue Violet
+				ZIndex: 4,
+			}
 		} else {
 			log.Warn("Unknown collision space object tags: %v", obj.Tags())
 			continue
@@ -142,6 +188,76 @@ func (g *GameScene) addLevelObjects() error {
 	return nil
 }
 
+// enterZone replaces the level and the entities of the current zone with those of another zone
+func (g *GameScene) enterZone(zoneID uint32) error {
+	zone := levels.Active().Zone(zoneID)
+	if zone == nil {
+		return fmt.Errorf("zone %d does not exist", zoneID)
+	}
+
+	// the children are removed from a copy of the list as removing them modifies it
+	root := g.GetRoot()
+	for _, child := range slices.Clone(root.GetChildren()) {
+		if err := root.RemoveChild(child.GetID()); err != nil {
+			return fmt.Errorf("failed to remove object %s: %v", child.GetID(), err)
+		}
+	}
+
+	g.zoneID = zone.ID
+	g.level = zone.Level
+	g.collisionSpace = game.NewCollisionSpace(zone.Level)
+	g.camera.SetWorldSize(float64(zone.Level.Width), float64(zone.Level.Height))
+	// entity IDs are only unique within a zone
+	g.deletedObjects = make(map[string]int64)
+	g.snapshotAssembler = messages.NewGameStateAssembler()
+	g.snapshotHistory = game.NewSnapshotHistory(game.SnapshotHistorySize)
+	g.serverPlayerUpdateBuffers = make(map[uint32]*ServerPlayerUpdateBuffer)
+	g.serverNPCUpdateBuffers = make(map[uint32]*ServerNPCUpdateBuffer)
+
+	if err := g.addLevelObjects(); err != nil {
+		return fmt.Errorf("failed to add level objects: %v", err)
+	}
+	log.Debug("Entered zone %d (%s)", zone.ID, zone.Name)
+
+	return nil
+}
+
+// addTileLayers adds the visible tile layers of the level
+func (g *GameScene) addTileLayers() error {
+	tilesetImages := make(map[*levels.Tileset]*ebiten.Image)
+	for _, tileset := range g.level.Tilesets {
+		b, err := g.level.ReadFile(tileset.Image)
+		if err != nil {
+			return fmt.Errorf("failed to read image of tileset %s: %v", tileset.Name, err)
+		}
+		img, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("failed to decode image of tileset %s: %v", tileset.Name, err)
+		}
+		tilesetImages[tileset] = ebiten.NewImageFromImage(img)
+	}
+
+	for i, layer := range g.level.TileLayers {
+		if !layer.Visible {
+			continue
+		}
+		id := fmt.Sprintf("tile-layer-%d", i)
+		tileLayer, err := objects.NewTileLayer(id, objects.NewTileLayerOptions{
+			Level:         g.level,
+			Layer:         layer,
+			TilesetImages: tilesetImages,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create tile layer %s: %v", layer.Name, err)
+		}
+		if err := g.GetRoot().AddChild(tileLayer.GetID(), tileLayer); err != nil {
+			return fmt.Errorf("failed to add tile layer %s: %v", layer.Name, err)
+		}
+	}
+
+	return nil
+}
+
 func (g *GameScene) Update() error {
 	if err := g.processPendingServerMessages(); err != nil {
 		return fmt.Errorf("failed to process pending server messages: %v", err)
@@ -155,6 +271,10 @@ func (g *GameScene) Update() error {
 		return fmt.Errorf("failed to update base scene: %v", err)
 	}
 
+	if err := g.updateCamera(); err != nil {
+		return fmt.Errorf("failed to update camera: %v", err)
+	}
+
 	if err := g.cleanupDeletedObjects(); err != nil {
 		return fmt.Errorf("failed to cleanup deleted objects: %v", err)
 	}
@@ -176,6 +296,10 @@ func (g *GameScene) processPendingServerMessages() error {
 		}
 
 		switch message.Type {
+		case messages.MessageTypeServerGameUpdate:
+			if err := g.handleServerGameUpdate(message); err != nil {
+				log.Error("Failed to handle server game update: %v", err)
+			}
 		case messages.MessageTypeServerPlayerUpdate:
 			if err := g.handleServerPlayerUpdate(message); err != nil {
 				log.Error("Failed to handle server player update: %v", err)
@@ -208,6 +332,22 @@ func (g *GameScene) processPendingServerMessages() error {
 			if err := g.handleServerPlayerKill(message); err != nil {
 				log.Error("Failed to handle server player kill: %v", err)
 			}
+		case messages.MessageTypeServerEntityEnterView:
+			if err := g.handleServerEntityEnterView(message); err != nil {
+				log.Error("Failed to handle server entity enter view: %v", err)
+			}
+		case messages.MessageTypeServerEntityLeaveView:
+			if err := g.handleServerEntityLeaveView(message); err != nil {
+				log.Error("Failed to handle server entity leave view: %v", err)
+			}
+		case messages.MessageTypeServerShutdown:
+			if err := g.handleServerShutdown(message); err != nil {
+				log.Error("Failed to handle server shutdown: %v", err)
+			}
+		case messages.MessageTypeServerZoneChange:
+			if err := g.handleServerZoneChange(message); err != nil {
+				log.Error("Failed to handle server zone change: %v", err)
+			}
 		default:
 			log.Warn("Received unexpected message type from server: %s", message.Type)
 		}
@@ -232,12 +372,96 @@ func (g *GameScene) getLocalPlayer() (*objects.Player, error) {
 	return playerObject, nil
 }
 
+// handleServerGameUpdate reassembles a snapshot, resolves it against the snapshot history,
+// acknowledges it and buffers its player and NPC states for interpolation.
+func (g *GameScene) handleServerGameUpdate(message *messages.Message) error {
+	part, err := messages.DeserializeGameState(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize game update: %v", err)
+	}
+
+	if part.Timestamp <= g.zoneChangedAt {
+		// the update is from the zone the local player left
+		return nil
+	}
+
+	serverGameUpdate := g.snapshotAssembler.Add(part)
+	if serverGameUpdate == nil {
+		// the update is stale or still missing parts
+		return nil
+	}
+
+	var baseline *messages.ServerGameUpdate
+	if serverGameUpdate.BaselineTimestamp != 0 {
+		baseline = g.snapshotHistory.Get(serverGameUpdate.BaselineTimestamp)
+	}
+	snapshot, err := serverGameUpdate.Resolve(baseline)
+	if err != nil {
+		// the server keeps sending deltas against the last acked snapshot until it is too old
+		log.Warn("Failed to resolve game update %d: %v", serverGameUpdate.Timestamp, err)
+		return nil
+	}
+	g.snapshotHistory.Add(snapshot)
+
+	if err := g.sendSnapshotAck(snapshot.Timestamp); err != nil {
+		return fmt.Errorf("failed to send snapshot ack: %v", err)
+	}
+
+	for clientID, playerState := range snapshot.Players {
+		serverPlayerUpdate := &messages.ServerPlayerUpdate{
+			Timestamp:   snapshot.Timestamp,
+			ClientID:    clientID,
+			PlayerState: playerState,
+		}
+		if err := g.bufferServerPlayerUpdate(serverPlayerUpdate); err != nil {
+			return fmt.Errorf("failed to buffer player update: %v", err)
+		}
+	}
+
+	for npcID, npcState := range snapshot.NPCs {
+		serverNPCUpdate := &messages.ServerNPCUpdate{
+			Timestamp: snapshot.Timestamp,
+			NPCID:     npcID,
+			NPCState:  npcState,
+		}
+		if err := g.bufferServerNPCUpdate(serverNPCUpdate); err != nil {
+			return fmt.Errorf("failed to buffer NPC update: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (g *GameScene) sendSnapshotAck(timestamp int64) error {
+	payload, err := messages.SerializeClientSnapshotAck(&messages.ClientSnapshotAck{
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize client snapshot ack: %v", err)
+	}
+
+	msg := &messages.Message{
+		ClientID: g.networkManager.ClientID(),
+		Type:     messages.MessageTypeClientSnapshotAck,
+		Payload:  payload,
+	}
+	if err := g.networkManager.SendUnreliableMessage(msg); err != nil {
+		return fmt.Errorf("failed to send client snapshot ack message: %v", err)
+	}
+
+	return nil
+}
+
 func (g *GameScene) handleServerPlayerUpdate(message *messages.Message) error {
 	serverPlayerUpdate, err := messages.DeserializeServerPlayerUpdate(message.Payload)
 	if err != nil {
 		return fmt.Errorf("failed to deserialize player update: %v", err)
 	}
 
+	return g.bufferServerPlayerUpdate(serverPlayerUpdate)
+}
+
+func (g *GameScene) bufferServerPlayerUpdate(serverPlayerUpdate *messages.ServerPlayerUpdate) error {
 	if _, ok := g.serverPlayerUpdateBuffers[serverPlayerUpdate.ClientID]; !ok {
 		g.serverPlayerUpdateBuffers[serverPlayerUpdate.ClientID] = &ServerPlayerUpdateBuffer{
 			LastUpdateReceived: serverPlayerUpdate.Timestamp,
@@ -280,6 +504,10 @@ func (g *GameScene) handleServerNPCUpdate(message *messages.Message) error {
 		return fmt.Errorf("failed to deserialize NPC update: %v", err)
 	}
 
+	return g.bufferServerNPCUpdate(serverNPCUpdate)
+}
+
+func (g *GameScene) bufferServerNPCUpdate(serverNPCUpdate *messages.ServerNPCUpdate) error {
 	if _, ok := g.serverNPCUpdateBuffers[serverNPCUpdate.NPCID]; !ok {
 		g.serverNPCUpdateBuffers[serverNPCUpdate.NPCID] = &ServerNPCUpdateBuffer{
 			LastStateReceived: serverNPCUpdate.Timestamp,
@@ -300,19 +528,39 @@ func (g *GameScene) handleServerNPCUpdate(message *messages.Message) error {
 }
 
 func (g *GameScene) handleServerPlayerConnect(message *messages.Message) error {
-	playerConnect := &messages.ServerPlayerConnect{}
-	if err := json.Unmarshal(message.Payload, playerConnect); err != nil {
-		return fmt.Errorf("failed to unmarshal player connect message: %v", err)
+	playerConnect, err := messages.DeserializeServerPlayerConnect(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize player connect message: %v", err)
 	}
 
 	id := fmt.Sprintf("player-%d", playerConnect.ClientID)
+	// other players are added when they enter the view of the local player
+	delete(g.deletedObjects, id)
+	if playerConnect.ClientID != g.networkManager.ClientID() {
+		log.Debug("Player %s connected as client %d", playerConnect.PlayerState.Name, playerConnect.ClientID)
+		return nil
+	}
+
 	obj := g.GetRoot().GetChild(id)
 	if obj != nil {
 		log.Warn("Player object for client %d already exists", playerConnect.ClientID)
 		return nil
 	}
-	log.Debug("Adding new player object for client %d", playerConnect.ClientID)
-	playerState := game.PlayerStateFromServerUpdate(playerConnect.PlayerState)
+	if playerConnect.ZoneID != g.zoneID {
+		if err := g.enterZone(playerConnect.ZoneID); err != nil {
+			return fmt.Errorf("failed to enter zone: %v", err)
+		}
+	}
+
+	return g.addLocalPlayer(playerConnect.PlayerState)
+}
+
+// addLocalPlayer adds the object of the local player to the current zone
+func (g *GameScene) addLocalPlayer(playerStateUpdate *messages.PlayerStateUpdate) error {
+	id := fmt.Sprintf("player-%d", g.networkManager.ClientID())
+	log.Debug("Adding new player object for client %d", g.networkManager.ClientID())
+	playerState := game.PlayerStateFromServerUpdate(playerStateUpdate)
+	playerState.ZoneID = g.zoneID
 	playerObject, err := objects.NewPlayer(id, g.networkManager, playerState)
 	if err != nil {
 		return fmt.Errorf("failed to create new player object: %v", err)
@@ -321,44 +569,144 @@ func (g *GameScene) handleServerPlayerConnect(message *messages.Message) error {
 	if err := g.GetRoot().AddChild(id, playerObject); err != nil {
 		return fmt.Errorf("failed to add player object: %v", err)
 	}
-	delete(g.deletedObjects, id)
+	g.camera.Snap(playerCenter(playerObject))
 
 	return nil
 }
 
+// handleServerZoneChange moves the local player to the zone it took a portal to
+func (g *GameScene) handleServerZoneChange(message *messages.Message) error {
+	zoneChange, err := messages.DeserializeServerZoneChange(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize zone change message: %v", err)
+	}
+
+	if err := g.enterZone(zoneChange.ZoneID); err != nil {
+		return fmt.Errorf("failed to enter zone: %v", err)
+	}
+	g.zoneChangedAt = zoneChange.Timestamp
+
+	return g.addLocalPlayer(zoneChange.PlayerState)
+}
+
 func (g *GameScene) handleServerPlayerDisconnect(message *messages.Message) error {
-	playerDisconnect := &messages.ServerPlayerDisconnect{}
-	if err := json.Unmarshal(message.Payload, playerDisconnect); err != nil {
-		return fmt.Errorf("failed to unmarshal player disconnect message: %v", err)
+	playerDisconnect, err := messages.DeserializeServerPlayerDisconnect(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize player disconnect message: %v", err)
 	}
 
-	id := fmt.Sprintf("player-%d", playerDisconnect.ClientID)
+	if err := g.removePlayer(playerDisconnect.ClientID); err != nil {
+		return fmt.Errorf("failed to remove player: %v", err)
+	}
+
+	return nil
+}
+
+func (g *GameScene) handleServerEntityEnterView(message *messages.Message) error {
+	entityEnterView, err := messages.DeserializeServerEntityEnterView(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize entity enter view message: %v", err)
+	}
+
+	// the object is instanced from the next snapshot that includes the entity,
+	// so only make sure it is not held back as recently deleted
+	switch entityEnterView.EntityType {
+	case messages.EntityTypePlayer:
+		delete(g.deletedObjects, fmt.Sprintf("player-%d", entityEnterView.EntityID))
+	case messages.EntityTypeNPC:
+		delete(g.deletedObjects, fmt.Sprintf("npc-%d", entityEnterView.EntityID))
+	default:
+		return fmt.Errorf("unknown entity type: %d", entityEnterView.EntityType)
+	}
+
+	return nil
+}
+
+func (g *GameScene) handleServerEntityLeaveView(message *messages.Message) error {
+	entityLeaveView, err := messages.DeserializeServerEntityLeaveView(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize entity leave view message: %v", err)
+	}
+
+	switch entityLeaveView.EntityType {
+	case messages.EntityTypePlayer:
+		if err := g.removePlayer(entityLeaveView.EntityID); err != nil {
+			return fmt.Errorf("failed to remove player: %v", err)
+		}
+	case messages.EntityTypeNPC:
+		if err := g.removeNPC(entityLeaveView.EntityID); err != nil {
+			return fmt.Errorf("failed to remove NPC: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown entity type: %d", entityLeaveView.EntityType)
+	}
+
+	return nil
+}
+
+// handleServerShutdown starts the shutdown countdown. The session is not resumed
+// once the server goes away, since the server will not be there to resume it.
+func (g *GameScene) handleServerShutdown(message *messages.Message) error {
+	shutdown, err := messages.DeserializeServerShutdown(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize server shutdown message: %v", err)
+	}
+
+	log.Warn("Server is shutting down in %dms: %s", shutdown.Countdown, shutdown.Reason)
+	g.shutdownAt = time.Now().Add(time.Duration(shutdown.Countdown) * time.Millisecond)
+	g.networkManager.DisableSessionResume()
+
+	return nil
+}
+
+// removePlayer destroys the object of a player that disconnected or left the view
+func (g *GameScene) removePlayer(clientID uint32) error {
+	// remove the player from the server update buffer
+	delete(g.serverPlayerUpdateBuffers, clientID)
+
+	id := fmt.Sprintf("player-%d", clientID)
+	g.deletedObjects[id] = time.Now().UnixMilli()
 	obj := g.GetRoot().GetChild(id)
 	if obj == nil {
-		log.Warn("Player object for client %d not found", playerDisconnect.ClientID)
+		log.Warn("Player object for client %d not found", clientID)
 		return nil
 	}
 	playerObject, ok := obj.(*objects.Player)
 	if !ok {
 		return fmt.Errorf("failed to cast game object %s to *objects.Player", id)
 	}
-	log.Debug("Removing player object for client %d", playerDisconnect.ClientID)
+	log.Debug("Removing player object for client %d", clientID)
 	g.collisionSpace.Remove(playerObject.State.Object)
 	if err := g.GetRoot().RemoveChild(id); err != nil {
 		return fmt.Errorf("failed to remove player object: %v", err)
 	}
-	g.deletedObjects[id] = time.Now().UnixMilli()
 
-	// remove the player from the server update buffer
-	delete(g.serverPlayerUpdateBuffers, playerDisconnect.ClientID)
+	return nil
+}
+
+// removeNPC destroys the object of an NPC that left the view
+func (g *GameScene) removeNPC(npcID uint32) error {
+	// remove the NPC from the server update buffer
+	delete(g.serverNPCUpdateBuffers, npcID)
+
+	id := fmt.Sprintf("npc-%d", npcID)
+	g.deletedObjects[id] = time.Now().UnixMilli()
+	if obj := g.GetRoot().GetChild(id); obj == nil {
+		log.Warn("NPC object with id %d not found", npcID)
+		return nil
+	}
+	log.Debug("Removing NPC object with id %d", npcID)
+	if err := g.GetRoot().RemoveChild(id); err != nil {
+		return fmt.Errorf("failed to remove NPC object: %v", err)
+	}
 
 	return nil
 }
 
 func (g *GameScene) handleServerNPCHit(message *messages.Message) error {
-	npcHit := &messages.ServerNPCHit{}
-	if err := json.Unmarshal(message.Payload, npcHit); err != nil {
-		return fmt.Errorf("failed to unmarshal NPC hit message: %v", err)
+	npcHit, err := messages.DeserializeServerNPCHit(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize NPC hit message: %v", err)
 	}
 	log.Debug("Player %d hit NPC %d for %d damage", npcHit.PlayerID, npcHit.NPCID, npcHit.Damage)
 	npcID := fmt.Sprintf("npc-%d", npcHit.NPCID)
@@ -376,6 +724,7 @@ func (g *GameScene) handleServerNPCHit(message *messages.Message) error {
 	zIndex := 15
 	if npcHit.PlayerID == g.networkManager.ClientID() {
 		zIndex = 25
+		g.camera.Shake(AttackShakeIntensity, AttackShakeDuration)
 	}
 	hitObject := objects.NewTextEffect(hitID, objects.NewTextEffectOptions{
 		Text:   fmt.Sprintf("%d", npcHit.Damage),
@@ -394,20 +743,20 @@ func (g *GameScene) handleServerNPCHit(message *messages.Message) error {
 }
 
 func (g *GameScene) handleServerNPCKill(message *messages.Message) error {
-	npcKill := &messages.ServerNPCKill{}
-	if err := json.Unmarshal(message.Payload, npcKill); err != nil {
-		return fmt.Errorf("failed to unmarshal NPC kill message: %v", err)
+	npcKill, err := messages.DeserializeServerNPCKill(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize NPC kill message: %v", err)
 	}
 	log.Debug("Player %d killed NPC %d", npcKill.PlayerID, npcKill.NPCID)
 	return nil
 }
 
 func (g *GameScene) handleServerPlayerHit(message *messages.Message) error {
-	playerHit := &messages.ServerPlayerHit{}
-	if err := json.Unmarshal(message.Payload, playerHit); err != nil {
-		return fmt.Errorf("failed to unmarshal player hit message: %v", err)
+	playerHit, err := messages.DeserializeServerPlayerHit(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize player hit message: %v", err)
 	}
-	log.Debug("NPC %d hit player %d for %d damage", playerHit.NPCID, playerHit.PlayerID, playerHit.Damage)
+	log.Debug("%s hit player %d for %d damage", g.describeEntity(playerHit.AttackerType, playerHit.AttackerID), playerHit.PlayerID, playerHit.Damage)
 	playerID := fmt.Sprintf("player-%d", playerHit.PlayerID)
 	obj := g.GetRoot().GetChild(playerID)
 	if obj == nil {
@@ -420,9 +769,17 @@ func (g *GameScene) handleServerPlayerHit(message *messages.Message) error {
 	}
 
 	zIndex := 25
+	hitColor := color.RGBA{255, 0, 0, 255} // Red
 	isLocalPlayer := playerHit.PlayerID == g.networkManager.ClientID()
+	isLocalAttacker := playerHit.AttackerType == messages.EntityTypePlayer && playerHit.AttackerID == g.networkManager.ClientID()
 	if isLocalPlayer {
 		zIndex = 35
+		g.camera.Shake(HitShakeIntensity, HitShakeDuration)
+	} else if isLocalAttacker {
+		// damage dealt by the local player to another player
+		zIndex = 35
+		hitColor = color.RGBA{255, 165, 0, 255} // Orange
+		g.camera.Shake(AttackShakeIntensity, AttackShakeDuration)
 	}
 
 	hitID := fmt.Sprintf("%s-hit-%d", playerObject.ID, uuid.New().ID())
@@ -430,7 +787,7 @@ func (g *GameScene) handleServerPlayerHit(message *messages.Message) error {
 		Text:   fmt.Sprintf("%d", playerHit.Damage),
 		X:      playerObject.State.Position.X + constants.PlayerWidth/2,
 		Y:      playerObject.State.Position.Y + constants.PlayerHeight/2,
-		Color:  color.RGBA{255, 0, 0, 255}, // Red
+		Color:  hitColor,
 		Scroll: true,
 		TTL:    1500,
 		ZIndex: zIndex,
@@ -443,14 +800,29 @@ func (g *GameScene) handleServerPlayerHit(message *messages.Message) error {
 }
 
 func (g *GameScene) handleServerPlayerKill(message *messages.Message) error {
-	playerKill := &messages.ServerPlayerKill{}
-	if err := json.Unmarshal(message.Payload, playerKill); err != nil {
-		return fmt.Errorf("failed to unmarshal player kill message: %v", err)
+	playerKill, err := messages.DeserializeServerPlayerKill(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize player kill message: %v", err)
 	}
-	log.Debug("NPC %d killed player %d", playerKill.NPCID, playerKill.PlayerID)
+	log.Debug("%s killed player %d", g.describeEntity(playerKill.AttackerType, playerKill.AttackerID), playerKill.PlayerID)
 	return nil
 }
 
+// describeEntity returns a description of an entity for logging, using the name of a player if it is known
+func (g *GameScene) describeEntity(entityType messages.EntityType, entityID uint32) string {
+	switch entityType {
+	case messages.EntityTypePlayer:
+		if playerObject, ok := g.GetRoot().GetChild(fmt.Sprintf("player-%d", entityID)).(*objects.Player); ok {
+			return fmt.Sprintf("Player %d (%s)", entityID, playerObject.State.Name)
+		}
+		return fmt.Sprintf("Player %d", entityID)
+	case messages.EntityTypeNPC:
+		return fmt.Sprintf("NPC %d", entityID)
+	default:
+		return fmt.Sprintf("Entity %d of type %d", entityID, entityType)
+	}
+}
+
 func (g *GameScene) updateObjectStates() error {
 	serverTime, _ := g.networkManager.ServerTime()
 	renderTime := int64(math.Round(serverTime)) - InterpolationOffset
@@ -633,6 +1005,33 @@ func (g *GameScene) cleanupDeletedObjects() error {
 	return nil
 }
 
+// updateCamera zooms the camera on input and moves it towards the local player
+func (g *GameScene) updateCamera() error {
+	if input.IsZoomInJustPressed() {
+		g.camera.ZoomIn()
+	}
+	if input.IsZoomOutJustPressed() {
+		g.camera.ZoomOut()
+	}
+
+	localPlayer, err := g.getLocalPlayer()
+	if err != nil {
+		return fmt.Errorf("failed to get local player: %v", err)
+	}
+	if localPlayer == nil {
+		return nil
+	}
+	x, y := playerCenter(localPlayer)
+	g.camera.Update(x, y, time.Second/time.Duration(ebiten.TPS()))
+
+	return nil
+}
+
+// playerCenter returns the center of a player in game coordinates
+func playerCenter(player *objects.Player) (float64, float64) {
+	return player.State.Position.X + constants.PlayerWidth/2, player.State.Position.Y + constants.PlayerHeight/2
+}
+
 func (g *GameScene) Draw(screen *ebiten.Image) {
 	localPlayer, err := g.getLocalPlayer()
 	if err != nil {
@@ -643,46 +1042,42 @@ func (g *GameScene) Draw(screen *ebiten.Image) {
 		log.Debug("Not drawing game scene because local player object not found")
 		return
 	}
-	g.BaseScene.Draw(g.world)
-	g.drawViewport(screen, localPlayer, Zoom)
+	g.drawViewport(screen)
+	if !g.shutdownAt.IsZero() {
+		g.drawShutdownCountdown(screen)
+	}
 }
 
-func (g *GameScene) drawViewport(screen *ebiten.Image, player *objects.Player, zoom float64) {
-	// TODO: test out camera smoothing
-	// calculate the viewport center based on the player position and the viewport center
-	vx, vy := int(player.State.Position.X+constants.PlayerWidth/2), g.world.Bounds().Dy()-int(player.State.Position.Y)-int(constants.PlayerHeight/2)
-	g.cameraViewport = &CameraViewport{X: vx, Y: vy}
-
-	// calculate the viewport bounds based on the zoom level
-	zoomFactor := 1.0 / (zoom * 2)
-	minX, maxX := float64(g.cameraViewport.X)-float64(screen.Bounds().Dx())*zoomFactor, float64(g.cameraViewport.X)+float64(screen.Bounds().Dx())*zoomFactor
-	minY, maxY := float64(g.cameraViewport.Y)-float64(screen.Bounds().Dy())*zoomFactor, float64(g.cameraViewport.Y)+float64(screen.Bounds().Dy())*zoomFactor
-
-	// clamp the viewport to the world bounds
-	if minX < 0 {
-		maxX += math.Abs(minX)
-		minX = 0
-	}
-	if maxX > float64(g.world.Bounds().Dx()) {
-		minX -= maxX - float64(g.world.Bounds().Dx())
-		maxX = float64(g.world.Bounds().Dx())
-	}
-	if minY < 0 {
-		maxY += math.Abs(minY)
-		minY = 0
-	}
-	if maxY > float64(g.world.Bounds().Dy()) {
-		minY -= maxY - float64(g.world.Bounds().Dy())
-		maxY = float64(g.world.Bounds().Dy())
+// drawShutdownCountdown draws the time left until the server shuts down at the top of the screen
+func (g *GameScene) drawShutdownCountdown(screen *ebiten.Image) {
+	remaining := int(math.Ceil(time.Until(g.shutdownAt).Seconds()))
+	if remaining < 0 {
+		remaining = 0
 	}
+	t := fmt.Sprintf("Server shutting down in %d seconds", remaining)
+	f := fonts.TTFNormalFont
+	bounds, _ := font.BoundString(f, t)
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(screen.Bounds().Dx())/2-float64(bounds.Max.X>>6)/2, 32)
+	op.ColorScale.ScaleWithColor(color.White)
+	text.DrawWithOptions(screen, t, f, op)
+}
 
-	viewport := g.world.SubImage(image.Rectangle{
-		Min: image.Point{X: int(minX), Y: int(minY)},
-		Max: image.Point{X: int(maxX), Y: int(maxY)},
-	}).(*ebiten.Image)
+// drawViewport draws the objects in the viewport of the camera to the screen, scaled by its zoom
+func (g *GameScene) drawViewport(screen *ebiten.Image) {
+	viewport := g.camera.Viewport(screen.Bounds().Dx(), screen.Bounds().Dy())
+	width, height := int(viewport.Width), int(viewport.Height)
+	if g.view == nil || g.view.Bounds().Dx() != width || g.view.Bounds().Dy() != height {
+		// the view is resized as the zoom or the size of the screen changes
+		if g.view != nil {
+			g.view.Deallocate()
+		}
+		g.view = ebiten.NewImage(width, height)
+	}
+	g.view.Clear()
+	objects.DrawWorldTree(g.GetRoot(), g.view, viewport)
 
 	opts := &ebiten.DrawImageOptions{}
-	opts.GeoM.Scale(zoom, zoom)
-
-	screen.DrawImage(viewport, opts)
+	opts.GeoM.Scale(g.camera.Zoom(), g.camera.Zoom())
+	screen.DrawImage(g.view, opts)
 }
@@ -8,7 +8,12 @@ import (
 	"github.com/cbodonnell/flywheel/client/game"
 	clientgame "github.com/cbodonnell/flywheel/client/game"
 	"github.com/cbodonnell/flywheel/client/network"
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
 	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
 	"github.com/cbodonnell/flywheel/pkg/version"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -22,10 +27,17 @@ func main() {
 	serverHostname := flag.String("server-hostname", network.DefaultServerHostname, "Server hostname")
 	serverTCPPort := flag.Int("server-tcp-port", network.DefaultServerTCPPort, "Server TCP port")
 	serverUDPPort := flag.Int("server-udp-port", network.DefaultServerUDPPort, "Server UDP port")
+	serverTLS := flag.Bool("server-tls", false, "Connect to the server TCP port with TLS")
+	serverTLSCAFile := flag.String("server-tls-ca-file", "", "Path to a PEM file with the CA certificates used to verify the server")
+	serverTLSSkipVerify := flag.Bool("server-tls-skip-verify", false, "Skip verification of the server TLS certificate (development only)")
 	authServerURL := flag.String("auth-server-url", game.DefaultAuthServerURL, "Auth server URL")
 	apiServerURL := flag.String("api-server-url", game.DefaultAPIServerURL, "API server URL")
 	automationEmail := flag.String("automation-email", "", "Automation email")
 	automationPassword := flag.String("automation-password", "", "Automation password")
+	compressionDict := flag.String("compression-dict", "", "Path to a zstd dictionary used to compress messages (must match the server)")
+	abilitiesFile := flag.String("abilities", "", "Path to a JSON file with the abilities of players and NPCs (must match the server, defaults to the built-in abilities)")
+	worldFile := flag.String("world", "", "Path to a JSON file of the zones of the world (must match the server, defaults to the built-in world)")
+	npcsFile := flag.String("npcs", "", "Path to a JSON file with the archetypes of NPCs (must match the server, defaults to the built-in archetypes)")
 	flag.Parse()
 
 	parsedLogLevel, err := log.ParseLogLevel(*logLevel)
@@ -37,10 +49,50 @@ func main() {
 	log.SetDefaultLogger(logger)
 	log.Info("Log level set to %s", parsedLogLevel)
 
+	if *compressionDict != "" {
+		if err := messages.LoadCompressionDictionary(*compressionDict); err != nil {
+			panic(fmt.Sprintf("Failed to load compression dictionary: %v", err))
+		}
+		log.Info("Loaded compression dictionary %s", *compressionDict)
+	}
+
+	if *abilitiesFile != "" {
+		set, err := abilities.LoadFile(*abilitiesFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load abilities: %v", err))
+		}
+		abilities.SetActive(set)
+		log.Info("Loaded abilities %s", *abilitiesFile)
+	}
+
+	if *npcsFile != "" {
+		set, err := npcs.LoadFile(*npcsFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load NPC archetypes: %v", err))
+		}
+		npcs.SetActive(set)
+		log.Info("Loaded NPC archetypes %s", *npcsFile)
+	}
+	if err := npcs.Active().Validate(abilities.Active()); err != nil {
+		panic(fmt.Sprintf("Invalid NPC archetypes: %v", err))
+	}
+
+	if *worldFile != "" {
+		world, err := levels.LoadWorldFile(*worldFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load world: %v", err))
+		}
+		levels.SetActive(world)
+		log.Info("Loaded world %s", *worldFile)
+	}
+
 	serverSettings := network.ServerSettings{
-		Hostname: *serverHostname,
-		TCPPort:  *serverTCPPort,
-		UDPPort:  *serverUDPPort,
+		Hostname:              *serverHostname,
+		TCPPort:               *serverTCPPort,
+		UDPPort:               *serverUDPPort,
+		TLS:                   *serverTLS,
+		TLSCAFile:             *serverTLSCAFile,
+		TLSInsecureSkipVerify: *serverTLSSkipVerify,
 	}
 	serverMessageQueue := queue.NewInMemoryQueue(1024)
 	networkManager, err := network.NewNetworkManager(serverSettings, serverMessageQueue)
@@ -69,6 +121,8 @@ func main() {
 
 	ebiten.SetWindowSize(clientgame.DefaultScreenWidth, clientgame.DefaultScreenHeight)
 	ebiten.SetWindowTitle("Flywheel Client")
+	// the local player is predicted one step per tick at the same rate the server simulates it
+	ebiten.SetTPS(constants.PlayerTickRate)
 	if err := ebiten.RunGame(game); err != nil {
 		panic(fmt.Sprintf("Failed to run game: %v", err))
 	}
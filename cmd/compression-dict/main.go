@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+)
+
+// compression-dict builds a compression dictionary from the messages captured
+// by a server run with -capture-messages
+func main() {
+	samplesFile := flag.String("samples", "", "Path to the messages captured by a server run with -capture-messages")
+	outFile := flag.String("out", "flywheel.dict", "Path to write the dictionary to")
+	flag.Parse()
+
+	if *samplesFile == "" {
+		panic("No samples file given, set -samples")
+	}
+	f, err := os.Open(*samplesFile)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open samples file: %v", err))
+	}
+	defer f.Close()
+
+	samples, err := messages.ReadCapturedMessages(bufio.NewReader(f))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read samples: %v", err))
+	}
+	log.Info("Read %d samples from %s", len(samples), *samplesFile)
+
+	dict, err := messages.BuildCompressionDictionary(samples)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to build compression dictionary: %v", err))
+	}
+	if err := os.WriteFile(*outFile, dict, 0644); err != nil {
+		panic(fmt.Sprintf("Failed to write compression dictionary: %v", err))
+	}
+	log.Info("Wrote %d byte compression dictionary to %s", len(dict), *outFile)
+}
@@ -58,7 +58,7 @@ func main() {
 			panic(fmt.Sprintf("Failed to create SQLite repository: %v", err))
 		}
 	case "postgresql":
-		repository, err = repositories.NewPostgresRepository(ctx, u.String())
+		repository, err = repositories.NewPostgresRepository(ctx, u.String(), "./migrations/postgres")
 		if err != nil {
 			panic(fmt.Sprintf("Failed to create Postgres repository: %v", err))
 		}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
 	"net/url"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	authproviders "github.com/cbodonnell/flywheel/pkg/auth/providers"
 	"github.com/cbodonnell/flywheel/pkg/game"
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/behaviors"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
 	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/network"
 	"github.com/cbodonnell/flywheel/pkg/queue"
 	"github.com/cbodonnell/flywheel/pkg/repositories"
@@ -22,6 +30,26 @@ func main() {
 	tcpPort := flag.Int("tcp-port", 8888, "TCP port to listen on")
 	udpPort := flag.Int("udp-port", 8889, "UDP port to listen on")
 	logLevel := flag.String("log-level", "info", "Log level")
+	interestRadius := flag.Float64("interest-radius", game.DefaultInterestRadius, "Distance from a player within which entities are sent to its client")
+	compressionDict := flag.String("compression-dict", "", "Path to a zstd dictionary used to compress messages (must match the clients)")
+	captureMessages := flag.String("capture-messages", "", "Path to a file to capture sent messages to, as samples for building a compression dictionary")
+	reconnectGracePeriod := flag.Duration("reconnect-grace-period", network.DefaultReconnectGracePeriod, "How long a player stays in the game after their connection drops so their client can resume the session")
+	idleTimeout := flag.Duration("idle-timeout", network.DefaultIdleTimeout, "How long a client can go without TCP or UDP traffic before it is disconnected (0 to disable)")
+	tcpReadTimeout := flag.Duration("tcp-read-timeout", network.DefaultTCPReadTimeout, "How long to wait for a message on a TCP connection before closing it (0 to disable)")
+	shutdownCountdown := flag.Duration("shutdown-countdown", game.DefaultShutdownCountdown, "How long clients are warned before the server shuts down")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "How long to wait for state to be saved and servers to stop after the shutdown countdown")
+	clientQueueCapacity := flag.Int("client-queue-capacity", network.DefaultClientQueueCapacity, "Maximum number of queued messages of a client")
+	clientMessagesPerTick := flag.Int("client-messages-per-tick", network.DefaultClientMessagesPerTick, "Maximum number of messages of a client processed in a game tick")
+	disableRateLimits := flag.Bool("disable-rate-limits", false, "Disable per-client message rate limits")
+	maxRewind := flag.Duration("max-rewind", game.DefaultMaxRewind, "Furthest back in time hits are rewound to compensate for the latency of a client")
+	pvp := flag.Bool("pvp", false, "Allow the attacks of players to hit other players")
+	pvpTeams := flag.Uint("pvp-teams", 0, "Number of teams players are split into by their character ID when PvP is allowed (0 for no teams)")
+	friendlyFire := flag.Bool("friendly-fire", false, "Allow the attacks of players to hit players on the same team")
+	seed := flag.Int64("seed", 0, "Seed of the random number stream of the simulation, for replaying a game (0 to pick one from the clock)")
+	abilitiesFile := flag.String("abilities", "", "Path to a JSON file with the abilities of players and NPCs (must match the clients, defaults to the built-in abilities)")
+	worldFile := flag.String("world", "", "Path to a JSON file of the zones of the world (must match the clients, defaults to the built-in world)")
+	npcsFile := flag.String("npcs", "", "Path to a JSON file with the archetypes of NPCs (must match the clients, defaults to the built-in archetypes)")
+	behaviorsFile := flag.String("behaviors", "", "Path to a JSON file with the behavior trees of NPCs (defaults to the built-in behaviors)")
 	flag.Parse()
 
 	parsedLogLevel, err := log.ParseLogLevel(*logLevel)
@@ -34,10 +62,91 @@ func main() {
 	log.Info("Log level set to %s", parsedLogLevel)
 
 	log.Info("Starting game server version %s", version.Get())
+
+	if *compressionDict != "" {
+		if err := messages.LoadCompressionDictionary(*compressionDict); err != nil {
+			panic(fmt.Sprintf("Failed to load compression dictionary: %v", err))
+		}
+		log.Info("Loaded compression dictionary %s", *compressionDict)
+	}
+
+	if *captureMessages != "" {
+		captureFile, err := os.Create(*captureMessages)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to create message capture file: %v", err))
+		}
+		captureWriter := bufio.NewWriter(captureFile)
+		messages.CaptureMessages(captureWriter)
+		defer func() {
+			if err := messages.StopCapturingMessages(); err != nil {
+				log.Error("Failed to capture messages: %v", err)
+			}
+			if err := captureWriter.Flush(); err != nil {
+				log.Error("Failed to flush message capture file: %v", err)
+			}
+			captureFile.Close()
+		}()
+		log.Info("Capturing messages to %s", *captureMessages)
+	}
+
+	if *abilitiesFile != "" {
+		set, err := abilities.LoadFile(*abilitiesFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load abilities: %v", err))
+		}
+		abilities.SetActive(set)
+		log.Info("Loaded abilities %s", *abilitiesFile)
+	}
+
+	if *behaviorsFile != "" {
+		set, err := behaviors.LoadFile(*behaviorsFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load NPC behaviors: %v", err))
+		}
+		behaviors.SetActive(set)
+		log.Info("Loaded NPC behaviors %s", *behaviorsFile)
+	}
+
+	if *npcsFile != "" {
+		set, err := npcs.LoadFile(*npcsFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load NPC archetypes: %v", err))
+		}
+		npcs.SetActive(set)
+		log.Info("Loaded NPC archetypes %s", *npcsFile)
+	}
+	if err := npcs.Active().Validate(abilities.Active()); err != nil {
+		panic(fmt.Sprintf("Invalid NPC archetypes: %v", err))
+	}
+	if err := npcs.Active().ValidateBehaviors(behaviors.Active()); err != nil {
+		panic(fmt.Sprintf("Invalid NPC archetypes: %v", err))
+	}
+
+	if *worldFile != "" {
+		world, err := levels.LoadWorldFile(*worldFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load world: %v", err))
+		}
+		levels.SetActive(world)
+		log.Info("Loaded world %s", *worldFile)
+	}
+
 	ctx := context.Background()
 
+	rateLimits := network.DefaultRateLimitOptions()
+	if *disableRateLimits {
+		log.Warn("Rate limits are disabled")
+		rateLimits = nil
+	}
 	connectionEventChan := make(chan network.ConnectionEvent, 100)
-	clientManager := network.NewClientManager(connectionEventChan)
+	clientManager := network.NewClientManager(network.NewClientManagerOptions{
+		ConnectionEventChan:  connectionEventChan,
+		ReconnectGracePeriod: *reconnectGracePeriod,
+		IdleTimeout:          *idleTimeout,
+		RateLimits:           rateLimits,
+	})
+	go clientManager.StartReaper(ctx)
+	go clientManager.LogRateLimitMetrics(ctx, time.Minute)
 
 	firebaseProjectID := os.Getenv("FLYWHEEL_FIREBASE_PROJECT_ID")
 	if firebaseProjectID == "" {
@@ -48,8 +157,24 @@ func main() {
 		panic(fmt.Sprintf("Failed to create Firebase auth provider: %v", err))
 	}
 
-	clientMessageQueue := queue.NewInMemoryQueue(10000)
-	tcpServer := network.NewTCPServer(authProvider, clientManager, clientMessageQueue, *tcpPort)
+	clientMessageQueue := network.NewClientMessageQueue(*clientQueueCapacity, *clientMessagesPerTick)
+	var tcpTLSConfig *network.TLSConfig
+	tcpTLSCertFile := os.Getenv("FLYWHEEL_GAME_TLS_CERT_FILE")
+	tcpTLSKeyFile := os.Getenv("FLYWHEEL_GAME_TLS_KEY_FILE")
+	if tcpTLSCertFile != "" && tcpTLSKeyFile != "" {
+		tcpTLSConfig = &network.TLSConfig{
+			CertFile: tcpTLSCertFile,
+			KeyFile:  tcpTLSKeyFile,
+		}
+	}
+	tcpServer := network.NewTCPServer(network.NewTCPServerOptions{
+		AuthProvider:  authProvider,
+		ClientManager: clientManager,
+		MessageQueue:  clientMessageQueue,
+		Port:          *tcpPort,
+		TLS:           tcpTLSConfig,
+		ReadTimeout:   *tcpReadTimeout,
+	})
 	udpServer := network.NewUDPServer(clientManager, clientMessageQueue, *udpPort)
 	go tcpServer.Start()
 	go udpServer.Start()
@@ -72,7 +197,7 @@ func main() {
 			panic(fmt.Sprintf("Failed to create SQLite repository: %v", err))
 		}
 	case "postgresql":
-		repository, err = repositories.NewPostgresRepository(ctx, u.String())
+		repository, err = repositories.NewPostgresRepository(ctx, u.String(), "./migrations/postgres")
 		if err != nil {
 			panic(fmt.Sprintf("Failed to create Postgres repository: %v", err))
 		}
@@ -110,10 +235,46 @@ func main() {
 		BroadcastMessageChan: broadcastMessageChan,
 		GameLoopInterval:     50 * time.Millisecond, // 20 ticks per second
 		SaveStateInterval:    5 * time.Second,
+		InterestRadius:       *interestRadius,
+		KickClient:           clientManager.KickClient,
+		MaxRewind:            *maxRewind,
+		PvPRules: game.PvPRules{
+			Enabled:      *pvp,
+			Teams:        uint32(*pvpTeams),
+			FriendlyFire: *friendlyFire,
+		},
+		Seed: *seed,
 	})
 
+	signalCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
 	log.Info("Starting game manager")
-	if err := gameManager.Start(ctx); err != nil {
-		panic(fmt.Sprintf("Failed to start game manager: %v", err))
+	gameManagerErrChan := make(chan error, 1)
+	go func() {
+		gameManagerErrChan <- gameManager.Start(ctx)
+	}()
+
+	select {
+	case err := <-gameManagerErrChan:
+		if err != nil {
+			panic(fmt.Sprintf("Failed to start game manager: %v", err))
+		}
+		return
+	case <-signalCtx.Done():
 	}
+	// a second signal kills the server without waiting for the shutdown
+	stopSignals()
+
+	log.Info("Shutting down in %s", *shutdownCountdown)
+	tcpServer.Drain()
+	udpServer.Drain()
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, *shutdownCountdown+*shutdownTimeout)
+	defer cancel()
+	if err := gameManager.Stop(shutdownCtx, *shutdownCountdown); err != nil {
+		log.Error("Failed to stop game manager: %v", err)
+	}
+
+	log.Info("Server stopped")
 }
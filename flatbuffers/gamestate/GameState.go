@@ -93,8 +93,44 @@ func (rcv *GameState) NpcsLength() int {
 	return 0
 }
 
+func (rcv *GameState) BaselineTimestamp() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GameState) MutateBaselineTimestamp(n int64) bool {
+	return rcv._tab.MutateInt64Slot(10, n)
+}
+
+func (rcv *GameState) Part() byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.GetByte(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GameState) MutatePart(n byte) bool {
+	return rcv._tab.MutateByteSlot(12, n)
+}
+
+func (rcv *GameState) PartCount() byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.GetByte(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GameState) MutatePartCount(n byte) bool {
+	return rcv._tab.MutateByteSlot(14, n)
+}
+
 func GameStateStart(builder *flatbuffers.Builder) {
-	builder.StartObject(3)
+	builder.StartObject(6)
 }
 func GameStateAddTimestamp(builder *flatbuffers.Builder, timestamp int64) {
 	builder.PrependInt64Slot(0, timestamp, 0)
@@ -111,6 +147,15 @@ func GameStateAddNpcs(builder *flatbuffers.Builder, npcs flatbuffers.UOffsetT) {
 func GameStateStartNpcsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
 	return builder.StartVector(4, numElems, 4)
 }
+func GameStateAddBaselineTimestamp(builder *flatbuffers.Builder, baselineTimestamp int64) {
+	builder.PrependInt64Slot(3, baselineTimestamp, 0)
+}
+func GameStateAddPart(builder *flatbuffers.Builder, part byte) {
+	builder.PrependByteSlot(4, part, 0)
+}
+func GameStateAddPartCount(builder *flatbuffers.Builder, partCount byte) {
+	builder.PrependByteSlot(5, partCount, 0)
+}
 func GameStateEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -127,8 +127,28 @@ func (rcv *NPCState) MutateHitpoints(n int16) bool {
 	return rcv._tab.MutateInt16Slot(16, n)
 }
 
+func (rcv *NPCState) Fields() uint16 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(18))
+	if o != 0 {
+		return rcv._tab.GetUint16(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *NPCState) MutateFields(n uint16) bool {
+	return rcv._tab.MutateUint16Slot(18, n)
+}
+
+func (rcv *NPCState) Archetype() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(20))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
 func NPCStateStart(builder *flatbuffers.Builder) {
-	builder.StartObject(7)
+	builder.StartObject(9)
 }
 func NPCStateAddPosition(builder *flatbuffers.Builder, position flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(position), 0)
@@ -151,6 +171,12 @@ func NPCStateAddAnimationSequence(builder *flatbuffers.Builder, animationSequenc
 func NPCStateAddHitpoints(builder *flatbuffers.Builder, hitpoints int16) {
 	builder.PrependInt16Slot(6, hitpoints, 0)
 }
+func NPCStateAddFields(builder *flatbuffers.Builder, fields uint16) {
+	builder.PrependUint16Slot(7, fields, 0)
+}
+func NPCStateAddArchetype(builder *flatbuffers.Builder, archetype flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(8, flatbuffers.UOffsetT(archetype), 0)
+}
 func NPCStateEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -183,8 +183,20 @@ func (rcv *PlayerState) MutateHitpoints(n int16) bool {
 	return rcv._tab.MutateInt16Slot(26, n)
 }
 
+func (rcv *PlayerState) Fields() uint16 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(28))
+	if o != 0 {
+		return rcv._tab.GetUint16(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *PlayerState) MutateFields(n uint16) bool {
+	return rcv._tab.MutateUint16Slot(28, n)
+}
+
 func PlayerStateStart(builder *flatbuffers.Builder) {
-	builder.StartObject(12)
+	builder.StartObject(13)
 }
 func PlayerStateAddLastProcessedTimestamp(builder *flatbuffers.Builder, lastProcessedTimestamp int64) {
 	builder.PrependInt64Slot(0, lastProcessedTimestamp, 0)
@@ -222,6 +234,9 @@ func PlayerStateAddAnimationSequence(builder *flatbuffers.Builder, animationSequ
 func PlayerStateAddHitpoints(builder *flatbuffers.Builder, hitpoints int16) {
 	builder.PrependInt16Slot(11, hitpoints, 0)
 }
+func PlayerStateAddFields(builder *flatbuffers.Builder, fields uint16) {
+	builder.PrependUint16Slot(12, fields, 0)
+}
 func PlayerStateEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -0,0 +1,2 @@
+-- Add the zone players are in
+ALTER TABLE players ADD COLUMN IF NOT EXISTS zone_id INT NOT NULL DEFAULT 0;
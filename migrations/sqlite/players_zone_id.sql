@@ -0,0 +1,2 @@
+-- Add the zone players are in
+ALTER TABLE players ADD COLUMN zone_id INTEGER NOT NULL DEFAULT 0;
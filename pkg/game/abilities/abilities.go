@@ -0,0 +1,226 @@
+package abilities
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+)
+
+//go:embed abilities.json
+var defaultAbilities []byte
+
+// Animations are the keys of the animations abilities can play, which players and NPCs both have
+var Animations = []string{"attack1", "attack2", "attack3"}
+
+// Ability is an attack that players or NPCs can use
+type Ability struct {
+	// ID uniquely identifies the ability
+	ID string `json:"id"`
+	// Duration is how long in seconds the ability occupies its user
+	Duration float64 `json:"duration"`
+	// ChannelTime is how long in seconds after the ability starts that it hits
+	ChannelTime float64 `json:"channelTime"`
+	// Cooldown is how long in seconds after the ability ends before it can be used again
+	Cooldown float64 `json:"cooldown"`
+	// Hitbox is the area the ability hits
+	Hitbox Hitbox `json:"hitbox"`
+	// Damage is the amount of damage the ability deals
+	Damage int16 `json:"damage"`
+	// Animation is the key of the animation played while the ability is used,
+	// one of Animations or empty for the first of them
+	Animation string `json:"animation"`
+}
+
+// Hitbox is the area an ability hits, relative to the position of its user when facing right.
+// The hitbox is mirrored about the middle of the user when the user faces left.
+type Hitbox struct {
+	// Width is the width of the hitbox
+	Width float64 `json:"width"`
+	// Height is the height of the hitbox, or zero for the height of the user
+	Height float64 `json:"height"`
+	// OffsetX is the horizontal offset of the hitbox from the position of the user
+	OffsetX float64 `json:"offsetX"`
+	// OffsetY is the vertical offset of the hitbox from the position of the user
+	OffsetY float64 `json:"offsetY"`
+}
+
+// HitTime is the time left in the ability at which it hits
+func (a *Ability) HitTime() float64 {
+	return a.Duration - a.ChannelTime
+}
+
+// Set is a set of abilities and the abilities of players and NPCs
+type Set struct {
+	// Abilities are the abilities of the set
+	Abilities []*Ability `json:"abilities"`
+	// Player are the IDs of the abilities bound to the attack inputs of players, in order
+	Player []string `json:"player"`
+	// NPC are the IDs of the abilities NPCs choose from when they attack,
+	// if their archetype has none of its own
+	NPC []string `json:"npc"`
+
+	byID   map[string]*Ability
+	player []*Ability
+	npc    []*Ability
+}
+
+// Parse parses and validates a set of abilities from JSON
+func Parse(b []byte) (*Set, error) {
+	set := &Set{}
+	if err := json.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal abilities: %v", err)
+	}
+
+	set.byID = make(map[string]*Ability, len(set.Abilities))
+	for _, ability := range set.Abilities {
+		if err := ability.validate(); err != nil {
+			return nil, fmt.Errorf("invalid ability %q: %v", ability.ID, err)
+		}
+		if _, ok := set.byID[ability.ID]; ok {
+			return nil, fmt.Errorf("duplicate ability %q", ability.ID)
+		}
+		set.byID[ability.ID] = ability
+	}
+
+	var err error
+	if set.player, err = set.resolve(set.Player); err != nil {
+		return nil, fmt.Errorf("invalid player abilities: %v", err)
+	}
+	if set.npc, err = set.resolve(set.NPC); err != nil {
+		return nil, fmt.Errorf("invalid npc abilities: %v", err)
+	}
+	return set, nil
+}
+
+// LoadFile loads a set of abilities from a JSON file
+func LoadFile(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read abilities file: %v", err)
+	}
+	return Parse(b)
+}
+
+// Default returns the set of abilities embedded in the build
+func Default() *Set {
+	set, err := Parse(defaultAbilities)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default abilities: %v", err))
+	}
+	return set
+}
+
+func (a *Ability) validate() error {
+	switch {
+	case a.ID == "":
+		return fmt.Errorf("missing id")
+	case a.Duration <= 0:
+		return fmt.Errorf("duration must be positive")
+	case a.ChannelTime < 0 || a.ChannelTime > a.Duration:
+		return fmt.Errorf("channel time must be between zero and the duration")
+	case a.Cooldown < 0:
+		return fmt.Errorf("cooldown must not be negative")
+	case a.Hitbox.Width <= 0 || a.Hitbox.Height < 0:
+		return fmt.Errorf("hitbox must have a positive size")
+	case a.Damage < 0:
+		return fmt.Errorf("damage must not be negative")
+	case a.Animation != "" && !slices.Contains(Animations, a.Animation):
+		return fmt.Errorf("unknown animation %q", a.Animation)
+	}
+	return nil
+}
+
+// resolve returns the abilities with the given IDs
+func (s *Set) resolve(ids []string) ([]*Ability, error) {
+	resolved := make([]*Ability, 0, len(ids))
+	for _, id := range ids {
+		ability, ok := s.byID[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown ability %q", id)
+		}
+		resolved = append(resolved, ability)
+	}
+	return resolved, nil
+}
+
+// Get returns the ability with an ID
+func (s *Set) Get(id string) (*Ability, bool) {
+	ability, ok := s.byID[id]
+	return ability, ok
+}
+
+// PlayerAbility returns the ability bound to an attack input of players, or nil if none is bound
+func (s *Set) PlayerAbility(slot int) *Ability {
+	if slot < 0 || slot >= len(s.player) {
+		return nil
+	}
+	return s.player[slot]
+}
+
+// NPCAbilities returns the abilities NPCs choose from when they attack,
+// if their archetype has none of its own
+func (s *Set) NPCAbilities() []*Ability {
+	return s.npc
+}
+
+var active atomic.Pointer[Set]
+
+func init() {
+	active.Store(Default())
+}
+
+// Active returns the set of abilities used by the game
+func Active() *Set {
+	return active.Load()
+}
+
+// SetActive replaces the set of abilities used by the game.
+// It must be called before the game starts, and the client and server must use the same set.
+func SetActive(set *Set) {
+	active.Store(set)
+}
+
+// Cooldowns are the cooldowns left in seconds of the abilities of a player or NPC by ability ID
+type Cooldowns map[string]float64
+
+// Ready returns true if an ability is not on cooldown
+func (c Cooldowns) Ready(ability *Ability) bool {
+	return c[ability.ID] <= 0
+}
+
+// Start puts an ability on cooldown
+func (c *Cooldowns) Start(ability *Ability) {
+	if ability.Cooldown <= 0 {
+		return
+	}
+	if *c == nil {
+		*c = make(Cooldowns)
+	}
+	(*c)[ability.ID] = ability.Cooldown
+}
+
+// Update advances the cooldowns by the time passed
+func (c Cooldowns) Update(deltaTime float64) {
+	for id, left := range c {
+		if left -= deltaTime; left > 0 {
+			c[id] = left
+		} else {
+			delete(c, id)
+		}
+	}
+}
+
+// Copy returns a copy of the cooldowns
+func (c Cooldowns) Copy() Cooldowns {
+	if len(c) == 0 {
+		return nil
+	}
+	copied := make(Cooldowns, len(c))
+	for id, left := range c {
+		copied[id] = left
+	}
+	return copied
+}
@@ -0,0 +1,100 @@
+package abilities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	set := Default()
+
+	assert.Equal(t, "player-slash", set.PlayerAbility(0).ID)
+	assert.Nil(t, set.PlayerAbility(-1))
+	assert.Nil(t, set.PlayerAbility(len(set.Player)))
+	assert.Len(t, set.NPCAbilities(), len(set.NPC))
+
+	for _, ability := range set.Abilities {
+		got, ok := set.Get(ability.ID)
+		assert.True(t, ok)
+		assert.Same(t, ability, got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			json: `{"abilities": [{"id": "a", "duration": 1, "channelTime": 0.5, "hitbox": {"width": 10}, "damage": 5}], "player": ["a"], "npc": ["a"]}`,
+		},
+		{
+			name:    "invalid json",
+			json:    `{"abilities": [`,
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			json:    `{"abilities": [{"duration": 1, "hitbox": {"width": 10}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "channel time longer than duration",
+			json:    `{"abilities": [{"id": "a", "duration": 1, "channelTime": 2, "hitbox": {"width": 10}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty hitbox",
+			json:    `{"abilities": [{"id": "a", "duration": 1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			json:    `{"abilities": [{"id": "a", "duration": 1, "hitbox": {"width": 10}}, {"id": "a", "duration": 1, "hitbox": {"width": 10}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown animation",
+			json:    `{"abilities": [{"id": "a", "duration": 1, "hitbox": {"width": 10}, "animation": "attack9"}], "player": ["a"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown player ability",
+			json:    `{"abilities": [{"id": "a", "duration": 1, "hitbox": {"width": 10}}], "player": ["b"]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Parse([]byte(tt.json))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 0.5, set.PlayerAbility(0).HitTime())
+		})
+	}
+}
+
+func TestCooldowns(t *testing.T) {
+	ability := &Ability{ID: "a", Cooldown: 1}
+	var cooldowns Cooldowns
+	assert.True(t, cooldowns.Ready(ability))
+
+	cooldowns.Start(ability)
+	assert.False(t, cooldowns.Ready(ability))
+
+	copied := cooldowns.Copy()
+	cooldowns.Update(0.6)
+	assert.False(t, cooldowns.Ready(ability))
+	assert.Equal(t, 1.0, copied["a"])
+
+	cooldowns.Update(0.6)
+	assert.True(t, cooldowns.Ready(ability))
+	assert.Empty(t, cooldowns)
+}
@@ -0,0 +1,131 @@
+package behaviors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+//go:embed behaviors.json
+var defaultBehaviors []byte
+
+// Definition defines a node of a behavior tree
+type Definition struct {
+	// Type is the type of the node
+	Type string `json:"type"`
+	// Children are the children of a selector, sequence or not node
+	Children []*Definition `json:"children,omitempty"`
+	// Range is the distance a leash or call for help node acts within
+	Range float64 `json:"range,omitempty"`
+	// MinRange is how close a ranged attack node lets players get before backing away
+	MinRange float64 `json:"minRange,omitempty"`
+	// Hitpoints is the fraction of its hitpoints an NPC has left when a flee node makes it flee
+	Hitpoints float64 `json:"hitpoints,omitempty"`
+	// IdleTime is the longest a patrol node makes an NPC stand still between walks
+	IdleTime float64 `json:"idleTime,omitempty"`
+}
+
+// Behavior is a behavior tree that decides what NPCs do
+type Behavior struct {
+	// ID uniquely identifies the behavior
+	ID string `json:"id"`
+	// Root is the definition of the root node of the tree
+	Root *Definition `json:"root"`
+
+	root Node
+}
+
+// Tick runs the tree of the behavior for an NPC and returns the status of its root
+func (b *Behavior) Tick(ctx *Context) Status {
+	return b.root.Tick(ctx)
+}
+
+// Set is a set of behaviors
+type Set struct {
+	// Behaviors are the behaviors of the set
+	Behaviors []*Behavior `json:"behaviors"`
+	// Default is the ID of the behavior of NPCs whose archetype has none
+	Default string `json:"default"`
+
+	byID map[string]*Behavior
+}
+
+// Parse parses a set of behaviors from JSON and builds their trees
+func Parse(b []byte) (*Set, error) {
+	set := &Set{}
+	if err := json.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal behaviors: %v", err)
+	}
+
+	set.byID = make(map[string]*Behavior, len(set.Behaviors))
+	for _, behavior := range set.Behaviors {
+		if behavior.ID == "" {
+			return nil, fmt.Errorf("behavior is missing an id")
+		}
+		if behavior.Root == nil {
+			return nil, fmt.Errorf("behavior %q is missing a root", behavior.ID)
+		}
+		if _, ok := set.byID[behavior.ID]; ok {
+			return nil, fmt.Errorf("duplicate behavior %q", behavior.ID)
+		}
+		root, err := build(behavior.Root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid behavior %q: %v", behavior.ID, err)
+		}
+		behavior.root = root
+		set.byID[behavior.ID] = behavior
+	}
+
+	if _, ok := set.byID[set.Default]; !ok {
+		return nil, fmt.Errorf("unknown default behavior %q", set.Default)
+	}
+	return set, nil
+}
+
+// LoadFile loads a set of behaviors from a JSON file
+func LoadFile(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read behaviors file: %v", err)
+	}
+	return Parse(b)
+}
+
+// Default returns the set of behaviors embedded in the build
+func Default() *Set {
+	set, err := Parse(defaultBehaviors)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default behaviors: %v", err))
+	}
+	return set
+}
+
+// Get returns the behavior with an ID
+func (s *Set) Get(id string) (*Behavior, bool) {
+	behavior, ok := s.byID[id]
+	return behavior, ok
+}
+
+// DefaultBehavior returns the behavior of NPCs whose archetype has none
+func (s *Set) DefaultBehavior() *Behavior {
+	return s.byID[s.Default]
+}
+
+var active atomic.Pointer[Set]
+
+func init() {
+	active.Store(Default())
+}
+
+// Active returns the set of behaviors used by the game
+func Active() *Set {
+	return active.Load()
+}
+
+// SetActive replaces the set of behaviors used by the game.
+// It must be called before the game starts.
+func SetActive(set *Set) {
+	active.Store(set)
+}
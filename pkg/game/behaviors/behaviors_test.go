@@ -0,0 +1,117 @@
+package behaviors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	set := Default()
+
+	assert.Equal(t, "aggressive", set.DefaultBehavior().ID)
+	for _, behavior := range set.Behaviors {
+		got, ok := set.Get(behavior.ID)
+		assert.True(t, ok)
+		assert.Same(t, behavior, got)
+	}
+	_, ok := set.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			json: `{"behaviors": [{"id": "a", "root": {"type": "selector", "children": [{"type": "leash", "range": 10}, {"type": "idle"}]}}], "default": "a"}`,
+		},
+		{
+			name:    "invalid json",
+			json:    `{"behaviors": [`,
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			json:    `{"behaviors": [{"root": {"type": "idle"}}], "default": ""}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing root",
+			json:    `{"behaviors": [{"id": "a"}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "idle"}}, {"id": "a", "root": {"type": "idle"}}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown default",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "idle"}}], "default": "b"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown node type",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "dance"}}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "composite without children",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "sequence"}}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "leaf with children",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "chase", "children": [{"type": "idle"}]}}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid nested node",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "selector", "children": [{"type": "leash"}]}}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "flee above full hitpoints",
+			json:    `{"behaviors": [{"id": "a", "root": {"type": "flee", "hitpoints": 1.5}}], "default": "a"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Parse([]byte(tt.json))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "a", set.DefaultBehavior().ID)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-succeed", func(definition *Definition, children []Node) (Node, error) {
+		return &succeed{}, nil
+	})
+	defer delete(builders, "test-succeed")
+
+	set, err := Parse([]byte(`{"behaviors": [{"id": "a", "root": {"type": "not", "children": [{"type": "test-succeed"}]}}], "default": "a"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, StatusFailure, set.DefaultBehavior().Tick(&Context{}))
+
+	assert.Panics(t, func() {
+		Register("selector", buildSelector)
+	})
+}
+
+// succeed is a node that always succeeds
+type succeed struct{}
+
+func (n *succeed) Tick(ctx *Context) Status {
+	return StatusSuccess
+}
@@ -0,0 +1,324 @@
+package behaviors
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+)
+
+// arriveDistance is how close to where it is walking an NPC has to be to have arrived
+const arriveDistance = 1.0
+
+// hasTarget succeeds if the NPC is fighting a player it can still see, and forgets the player otherwise
+type hasTarget struct{}
+
+func buildHasTarget(definition *Definition) (Node, error) {
+	return &hasTarget{}, nil
+}
+
+func (n *hasTarget) Tick(ctx *Context) Status {
+	if _, ok := keepTarget(ctx); !ok {
+		return StatusFailure
+	}
+	return StatusSuccess
+}
+
+// keepTarget returns the player the NPC is fighting if it is alive, close enough
+// and in line of sight, and makes the NPC forget the player otherwise
+func keepTarget(ctx *Context) (Target, bool) {
+	if !ctx.Memory.HasTarget {
+		return Target{}, false
+	}
+	target, ok := ctx.target()
+	if !ok ||
+		ctx.Agent.Position.DistanceFrom(target.Position) > 2*ctx.Agent.LineOfSight ||
+		!ctx.World.InLineOfSight(ctx.Agent.Position, target) {
+		ctx.Memory.Forget()
+		return Target{}, false
+	}
+	return target, true
+}
+
+// lookout succeeds if the NPC is fighting a player, and otherwise
+// starts fighting the first player it sees ahead of it
+type lookout struct{}
+
+func buildLookout(definition *Definition) (Node, error) {
+	return &lookout{}, nil
+}
+
+func (n *lookout) Tick(ctx *Context) Status {
+	if _, ok := keepTarget(ctx); ok {
+		return StatusSuccess
+	}
+
+	// look along a line from the middle of the npc as far ahead as it can see
+	agent := ctx.Agent
+	eyeX := agent.Position.X + constants.NPCWidth/2
+	eyeY := agent.Position.Y + constants.NPCHeight/2
+	minX, maxX := eyeX, eyeX+agent.LineOfSight
+	if agent.FlipH {
+		minX, maxX = eyeX-agent.LineOfSight, eyeX
+	}
+	// players any further away than this cannot cross the line
+	nearby := agent.LineOfSight + constants.NPCWidth + constants.PlayerWidth + constants.PlayerHeight
+	for _, target := range ctx.World.Targets(agent.Position, nearby) {
+		if target.Position.X+constants.PlayerWidth < minX || target.Position.X > maxX {
+			continue
+		}
+		if eyeY < target.Position.Y || eyeY > target.Position.Y+constants.PlayerHeight {
+			continue
+		}
+		if !ctx.World.InLineOfSight(agent.Position, target) {
+			continue
+		}
+		ctx.Memory.Provoke(target.ID)
+		return StatusSuccess
+	}
+	return StatusFailure
+}
+
+// callForHelp alerts the NPCs within range to the player the NPC is fighting, once per fight
+type callForHelp struct {
+	distance float64
+}
+
+func buildCallForHelp(definition *Definition) (Node, error) {
+	if definition.Range <= 0 {
+		return nil, fmt.Errorf("range must be positive")
+	}
+	return &callForHelp{distance: definition.Range}, nil
+}
+
+func (n *callForHelp) Tick(ctx *Context) Status {
+	target, ok := ctx.target()
+	if !ok {
+		return StatusFailure
+	}
+	if !ctx.Memory.CalledForHelp {
+		ctx.World.Alert(ctx.Agent.Position, n.distance, target.ID)
+		ctx.Memory.CalledForHelp = true
+	}
+	return StatusSuccess
+}
+
+// chase walks the NPC up to the player it is fighting, and succeeds once it is within attack range
+type chase struct{}
+
+func buildChase(definition *Definition) (Node, error) {
+	return &chase{}, nil
+}
+
+func (n *chase) Tick(ctx *Context) Status {
+	target, ok := ctx.target()
+	if !ok {
+		return StatusFailure
+	}
+	// stop halfway into attack range on the side of the player the npc is on
+	standX := target.Position.X - direction(ctx, target.Position.X)*ctx.Agent.AttackRange/2
+	if math.Abs(standX-ctx.Agent.Position.X) < arriveDistance {
+		ctx.face(target.Position.X)
+		return StatusSuccess
+	}
+	ctx.moveTo(standX)
+	return StatusRunning
+}
+
+// attack attacks the player the NPC is fighting while the player is in attack range in front of it
+type attack struct{}
+
+func buildAttack(definition *Definition) (Node, error) {
+	return &attack{}, nil
+}
+
+func (n *attack) Tick(ctx *Context) Status {
+	target, ok := ctx.target()
+	if !ok || !inAttackRange(ctx, target) {
+		return StatusFailure
+	}
+	ctx.face(target.Position.X)
+	ctx.Intent.Attack = true
+	return StatusRunning
+}
+
+// rangedAttack attacks the player the NPC is fighting from as far away as its attack range,
+// backing away from players closer than its minimum range while it has room to
+type rangedAttack struct {
+	minDistance float64
+}
+
+func buildRangedAttack(definition *Definition) (Node, error) {
+	if definition.MinRange < 0 {
+		return nil, fmt.Errorf("minimum range must not be negative")
+	}
+	return &rangedAttack{minDistance: definition.MinRange}, nil
+}
+
+func (n *rangedAttack) Tick(ctx *Context) Status {
+	target, ok := ctx.target()
+	if !ok {
+		return StatusFailure
+	}
+	agent := ctx.Agent
+	toward := direction(ctx, target.Position.X)
+	distance := math.Abs(target.Position.X - agent.Position.X)
+
+	if distance < n.minDistance {
+		backX := clampX(ctx, agent.Position.X-toward*n.minDistance)
+		if math.Abs(backX-agent.Position.X) >= arriveDistance {
+			ctx.moveTo(backX)
+			return StatusRunning
+		}
+	} else if distance >= agent.AttackRange {
+		ctx.moveTo(target.Position.X - toward*(n.minDistance+agent.AttackRange)/2)
+		return StatusRunning
+	}
+
+	ctx.face(target.Position.X)
+	ctx.Intent.Attack = inAttackRange(ctx, target)
+	return StatusRunning
+}
+
+// flee runs the NPC away from the player it is fighting while its hitpoints are low,
+// and fails once it is cornered so that it fights back
+type flee struct {
+	hitpoints float64
+}
+
+func buildFlee(definition *Definition) (Node, error) {
+	if definition.Hitpoints <= 0 || definition.Hitpoints > 1 {
+		return nil, fmt.Errorf("hitpoints must be a fraction above zero and up to one")
+	}
+	return &flee{hitpoints: definition.Hitpoints}, nil
+}
+
+func (n *flee) Tick(ctx *Context) Status {
+	target, ok := ctx.target()
+	if !ok {
+		return StatusFailure
+	}
+	agent := ctx.Agent
+	if float64(agent.Hitpoints) > n.hitpoints*float64(agent.MaxHitpoints) {
+		return StatusFailure
+	}
+	fleeX := clampX(ctx, agent.Position.X-direction(ctx, target.Position.X)*agent.LineOfSight)
+	if math.Abs(fleeX-agent.Position.X) < arriveDistance {
+		// turn to face the player when cornered
+		ctx.face(target.Position.X)
+		return StatusFailure
+	}
+	ctx.moveTo(fleeX)
+	return StatusRunning
+}
+
+// leash walks the NPC back home, forgetting the player it is fighting,
+// once it strays further from home than its range, and fails while the NPC is home
+type leash struct {
+	distance float64
+}
+
+func buildLeash(definition *Definition) (Node, error) {
+	if definition.Range <= 0 {
+		return nil, fmt.Errorf("range must be positive")
+	}
+	return &leash{distance: definition.Range}, nil
+}
+
+func (n *leash) Tick(ctx *Context) Status {
+	agent := ctx.Agent
+	distance := math.Abs(agent.Position.X - agent.Home.X)
+	if !ctx.Memory.Leashing {
+		if distance <= n.distance {
+			return StatusFailure
+		}
+		ctx.Memory.Leashing = true
+	}
+	ctx.Memory.Forget()
+
+	if distance < arriveDistance {
+		ctx.Memory.Leashing = false
+		ctx.Memory.Patrolling = false
+		return StatusFailure
+	}
+	ctx.moveTo(agent.Home.X)
+	return StatusRunning
+}
+
+// patrol walks the NPC to random places between its patrol bounds, standing still for a while in between
+type patrol struct {
+	maxIdleTime float64
+}
+
+func buildPatrol(definition *Definition) (Node, error) {
+	if definition.IdleTime < 0 {
+		return nil, fmt.Errorf("idle time must not be negative")
+	}
+	maxIdleTime := definition.IdleTime
+	if maxIdleTime == 0 {
+		maxIdleTime = constants.NPCMaxIdleTime
+	}
+	return &patrol{maxIdleTime: maxIdleTime}, nil
+}
+
+func (n *patrol) Tick(ctx *Context) Status {
+	memory := ctx.Memory
+	if memory.Patrolling {
+		if math.Abs(memory.PatrolX-ctx.Agent.Position.X) < arriveDistance {
+			memory.Patrolling = false
+			memory.IdleTimeLeft = ctx.RNG.Float64() * n.maxIdleTime
+			return StatusRunning
+		}
+		ctx.moveTo(memory.PatrolX)
+		return StatusRunning
+	}
+
+	memory.IdleTimeLeft -= ctx.DeltaTime
+	if memory.IdleTimeLeft <= 0 {
+		memory.Patrolling = true
+		memory.PatrolX = ctx.RNG.Float64()*(ctx.Agent.PatrolMaxX-ctx.Agent.PatrolMinX) + ctx.Agent.PatrolMinX
+		ctx.moveTo(memory.PatrolX)
+	}
+	return StatusRunning
+}
+
+// idle keeps the NPC standing still
+type idle struct{}
+
+func buildIdle(definition *Definition) (Node, error) {
+	return &idle{}, nil
+}
+
+func (n *idle) Tick(ctx *Context) Status {
+	return StatusRunning
+}
+
+// direction returns 1 if x is to the right of the NPC and -1 if it is to the left,
+// or the direction the NPC faces if it is level with x
+func direction(ctx *Context, x float64) float64 {
+	switch {
+	case x > ctx.Agent.Position.X:
+		return 1
+	case x < ctx.Agent.Position.X:
+		return -1
+	case ctx.Agent.FlipH:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// inAttackRange returns true if a player is within attack range in front of the NPC
+func inAttackRange(ctx *Context, target Target) bool {
+	facing := 1.0
+	if ctx.Agent.FlipH {
+		facing = -1.0
+	}
+	distance := facing * (target.Position.X - ctx.Agent.Position.X)
+	return distance > 0 && distance < ctx.Agent.AttackRange
+}
+
+// clampX returns x within the patrol bounds of the NPC
+func clampX(ctx *Context, x float64) float64 {
+	return min(max(x, ctx.Agent.PatrolMinX), ctx.Agent.PatrolMaxX)
+}
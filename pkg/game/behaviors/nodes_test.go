@@ -0,0 +1,309 @@
+package behaviors
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testDeltaTime = 0.05
+	testSpeed     = 100.0
+)
+
+// scriptedPlayer returns the position of a player in a tick, or false if it has left the world
+type scriptedPlayer func(tick int) (kinematic.Vector, bool)
+
+// standAt scripts a player that stands still at x
+func standAt(x float64) scriptedPlayer {
+	return func(tick int) (kinematic.Vector, bool) {
+		return kinematic.NewVector(x, 0), true
+	}
+}
+
+// walkFrom scripts a player that walks from x at a speed
+func walkFrom(x, speed float64) scriptedPlayer {
+	return func(tick int) (kinematic.Vector, bool) {
+		return kinematic.NewVector(x+speed*testDeltaTime*float64(tick), 0), true
+	}
+}
+
+// testWorld is a flat world without walls where players move by script
+type testWorld struct {
+	scripts map[uint32]scriptedPlayer
+	players map[uint32]kinematic.Vector
+	// alerts are the IDs of the players NPCs called for help against
+	alerts []uint32
+}
+
+func (w *testWorld) update(tick int) {
+	w.players = make(map[uint32]kinematic.Vector, len(w.scripts))
+	for id, script := range w.scripts {
+		if position, ok := script(tick); ok {
+			w.players[id] = position
+		}
+	}
+}
+
+func (w *testWorld) Target(id uint32) (Target, bool) {
+	position, ok := w.players[id]
+	return Target{ID: id, Position: position}, ok
+}
+
+func (w *testWorld) Targets(position kinematic.Vector, distance float64) []Target {
+	var targets []Target
+	for id := uint32(1); id <= uint32(len(w.scripts)); id++ {
+		if target, ok := w.Target(id); ok && position.DistanceFrom(target.Position) <= distance {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func (w *testWorld) InLineOfSight(position kinematic.Vector, target Target) bool {
+	return true
+}
+
+func (w *testWorld) Alert(position kinematic.Vector, distance float64, targetID uint32) {
+	w.alerts = append(w.alerts, targetID)
+}
+
+// testNPC steps a behavior for an NPC that walks where the behavior tells it to
+type testNPC struct {
+	behavior *Behavior
+	agent    Agent
+	memory   Memory
+	world    *testWorld
+	rng      *rand.Rand
+	tick     int
+	intent   Intent
+	status   Status
+}
+
+func newTestNPC(t *testing.T, root string, scripts ...scriptedPlayer) *testNPC {
+	set, err := Parse([]byte(`{"behaviors": [{"id": "test", "root": ` + root + `}], "default": "test"}`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	world := &testWorld{scripts: make(map[uint32]scriptedPlayer, len(scripts))}
+	for i, script := range scripts {
+		world.scripts[uint32(i+1)] = script
+	}
+	return &testNPC{
+		behavior: set.DefaultBehavior(),
+		agent: Agent{
+			Hitpoints:    100,
+			MaxHitpoints: 100,
+			PatrolMinX:   -512,
+			PatrolMaxX:   512,
+			LineOfSight:  320,
+			AttackRange:  48,
+		},
+		world: world,
+		rng:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// step ticks the behavior once and moves the NPC as it intends
+func (n *testNPC) step() {
+	n.world.update(n.tick)
+	n.tick++
+
+	ctx := &Context{
+		Agent:     n.agent,
+		Memory:    &n.memory,
+		World:     n.world,
+		RNG:       n.rng,
+		DeltaTime: testDeltaTime,
+	}
+	n.status = n.behavior.Tick(ctx)
+	n.intent = ctx.Intent
+
+	if n.intent.Move {
+		dx := kinematic.MoveTowards(testSpeed, testDeltaTime, 0, n.intent.MoveX, n.agent.Position.X)
+		n.agent.Position.X += dx
+		if dx != 0 {
+			n.agent.FlipH = dx < 0
+		}
+	} else if n.intent.Face && n.intent.FaceX != n.agent.Position.X {
+		n.agent.FlipH = n.intent.FaceX < n.agent.Position.X
+	}
+}
+
+// stepUntil ticks the behavior until a condition holds, and fails the test if it does not within a number of ticks
+func (n *testNPC) stepUntil(t *testing.T, ticks int, condition func() bool) {
+	for i := 0; i < ticks; i++ {
+		n.step()
+		if condition() {
+			return
+		}
+	}
+	t.Fatalf("condition did not hold within %d ticks", ticks)
+}
+
+func TestLookoutChaseAttack(t *testing.T) {
+	npc := newTestNPC(t, `{"type": "sequence", "children": [{"type": "lookout"}, {"type": "selector", "children": [{"type": "attack"}, {"type": "chase"}]}]}`, standAt(200))
+
+	npc.stepUntil(t, 100, func() bool { return npc.intent.Attack })
+	assert.Equal(t, uint32(1), npc.memory.TargetID)
+	assert.Greater(t, 200-npc.agent.Position.X, 0.0)
+	assert.Less(t, 200-npc.agent.Position.X, npc.agent.AttackRange)
+	assert.False(t, npc.agent.FlipH)
+	assert.Equal(t, StatusRunning, npc.status)
+}
+
+func TestLookout_IgnoresPlayersBehind(t *testing.T) {
+	npc := newTestNPC(t, `{"type": "selector", "children": [{"type": "lookout"}, {"type": "idle"}]}`, standAt(-200), standAt(400))
+
+	for i := 0; i < 10; i++ {
+		npc.step()
+	}
+	assert.False(t, npc.memory.HasTarget)
+
+	// turning around, the npc sees the player behind it
+	npc.agent.FlipH = true
+	npc.step()
+	assert.True(t, npc.memory.HasTarget)
+	assert.Equal(t, uint32(1), npc.memory.TargetID)
+}
+
+func TestHasTarget_ForgetsPlayers(t *testing.T) {
+	left := func(tick int) (kinematic.Vector, bool) {
+		return kinematic.NewVector(100, 0), tick < 5
+	}
+	npc := newTestNPC(t, `{"type": "hasTarget"}`, left, standAt(2000))
+
+	// players are forgotten when they leave
+	npc.memory.Provoke(1)
+	npc.step()
+	assert.Equal(t, StatusSuccess, npc.status)
+	npc.stepUntil(t, 10, func() bool { return npc.status == StatusFailure })
+	assert.False(t, npc.memory.HasTarget)
+
+	// and when they get too far away
+	npc.memory.Provoke(2)
+	npc.step()
+	assert.Equal(t, StatusFailure, npc.status)
+	assert.False(t, npc.memory.HasTarget)
+}
+
+func TestLeash(t *testing.T) {
+	// the player walks away as fast as the npc chases it
+	npc := newTestNPC(t, `{"type": "selector", "children": [{"type": "leash", "range": 300}, {"type": "chase"}, {"type": "idle"}]}`, walkFrom(100, testSpeed))
+	npc.memory.Provoke(1)
+
+	npc.stepUntil(t, 200, func() bool { return npc.memory.Leashing })
+	assert.Greater(t, npc.agent.Position.X, 300-testSpeed*testDeltaTime)
+	assert.False(t, npc.memory.HasTarget)
+
+	// the npc ignores the player on its way home
+	npc.memory.Provoke(1)
+	assert.False(t, npc.memory.HasTarget)
+
+	npc.stepUntil(t, 200, func() bool { return !npc.memory.Leashing })
+	assert.InDelta(t, 0, npc.agent.Position.X, arriveDistance)
+	assert.Equal(t, StatusRunning, npc.status, "the npc idles once it is home")
+}
+
+func TestCallForHelp(t *testing.T) {
+	npc := newTestNPC(t, `{"type": "sequence", "children": [{"type": "hasTarget"}, {"type": "callForHelp", "range": 100}, {"type": "idle"}]}`, standAt(100))
+
+	npc.step()
+	assert.Empty(t, npc.world.alerts, "the npc does not call for help until it fights")
+
+	npc.memory.Provoke(1)
+	for i := 0; i < 5; i++ {
+		npc.step()
+	}
+	assert.Equal(t, []uint32{1}, npc.world.alerts, "the npc calls for help once per fight")
+
+	npc.memory.Forget()
+	npc.memory.Provoke(1)
+	npc.step()
+	assert.Equal(t, []uint32{1, 1}, npc.world.alerts)
+}
+
+func TestFlee(t *testing.T) {
+	// the player walks after the npc, slower than the npc moves
+	npc := newTestNPC(t, `{"type": "selector", "children": [{"type": "flee", "hitpoints": 0.5}, {"type": "attack"}, {"type": "idle"}]}`, walkFrom(24, -testSpeed/2))
+	npc.memory.Provoke(1)
+
+	// the npc fights while it is healthy
+	npc.step()
+	assert.True(t, npc.intent.Attack)
+
+	// and runs away from the player when it is hurt, until it is cornered and fights back
+	npc.agent.Hitpoints = 50
+	npc.step()
+	assert.True(t, npc.intent.Move)
+	assert.Less(t, npc.intent.MoveX, npc.agent.Position.X)
+	npc.stepUntil(t, 400, func() bool { return npc.intent.Attack })
+	assert.Equal(t, npc.agent.PatrolMinX, npc.agent.Position.X)
+	assert.False(t, npc.agent.FlipH, "the cornered npc turns to face the player")
+}
+
+func TestRangedAttack(t *testing.T) {
+	// the player walks towards the npc, slower than the npc moves
+	npc := newTestNPC(t, `{"type": "rangedAttack", "minRange": 96}`, walkFrom(400, -testSpeed/2))
+	npc.agent.AttackRange = 224
+	npc.memory.Provoke(1)
+
+	// the npc closes in to attack from range
+	npc.stepUntil(t, 100, func() bool { return npc.intent.Attack })
+	distance := npc.world.players[1].X - npc.agent.Position.X
+	assert.GreaterOrEqual(t, distance, 96.0)
+	assert.Less(t, distance, npc.agent.AttackRange)
+
+	// and backs away to keep its distance
+	for i := 0; i < 100; i++ {
+		npc.step()
+		distance := npc.world.players[1].X - npc.agent.Position.X
+		assert.Greater(t, distance, 96.0-testSpeed*testDeltaTime)
+	}
+	assert.Less(t, npc.agent.Position.X, 0.0)
+}
+
+func TestPatrol(t *testing.T) {
+	npc := newTestNPC(t, `{"type": "patrol", "idleTime": 1}`)
+	npc.agent.PatrolMinX, npc.agent.PatrolMaxX = -100, 100
+
+	walked := 0
+	for i := 0; i < 1000; i++ {
+		npc.step()
+		assert.Equal(t, StatusRunning, npc.status)
+		assert.GreaterOrEqual(t, npc.agent.Position.X, -100.0)
+		assert.LessOrEqual(t, npc.agent.Position.X, 100.0)
+		if npc.intent.Move {
+			walked++
+		}
+	}
+	assert.Greater(t, walked, 0)
+	assert.Less(t, walked, 1000, "the npc stands still between walks")
+}
+
+func TestDefault_Behaviors(t *testing.T) {
+	set := Default()
+	tests := []struct {
+		behavior   string
+		player     scriptedPlayer
+		wantTarget bool
+	}{
+		{behavior: "aggressive", player: standAt(200), wantTarget: true},
+		{behavior: "defensive", player: standAt(200), wantTarget: false},
+		{behavior: "skirmisher", player: standAt(200), wantTarget: true},
+		{behavior: "passive", player: standAt(200), wantTarget: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.behavior, func(t *testing.T) {
+			behavior, ok := set.Get(tt.behavior)
+			assert.True(t, ok)
+			npc := newTestNPC(t, `{"type": "idle"}`, tt.player)
+			npc.behavior = behavior
+			npc.step()
+			assert.Equal(t, tt.wantTarget, npc.memory.HasTarget)
+		})
+	}
+}
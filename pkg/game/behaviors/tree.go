@@ -0,0 +1,270 @@
+package behaviors
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+)
+
+// Status is the result of ticking a node
+type Status uint8
+
+const (
+	// StatusFailure means the node could not act, so its parent tries something else
+	StatusFailure Status = iota
+	// StatusSuccess means the node has done what it does
+	StatusSuccess
+	// StatusRunning means the node is acting and wants to keep acting next tick
+	StatusRunning
+)
+
+// Node is a node of a behavior tree.
+// Nodes are shared by every NPC with the behavior, so they keep
+// what they need to remember between ticks in the memory of the NPC.
+type Node interface {
+	Tick(ctx *Context) Status
+}
+
+// BuildFunc builds a node from its definition and its children, already built
+type BuildFunc func(definition *Definition, children []Node) (Node, error)
+
+// builders are the functions that build the nodes of each type
+var builders = map[string]BuildFunc{
+	"selector":     buildSelector,
+	"sequence":     buildSequence,
+	"not":          buildInverter,
+	"hasTarget":    leaf(buildHasTarget),
+	"lookout":      leaf(buildLookout),
+	"callForHelp":  leaf(buildCallForHelp),
+	"chase":        leaf(buildChase),
+	"attack":       leaf(buildAttack),
+	"rangedAttack": leaf(buildRangedAttack),
+	"flee":         leaf(buildFlee),
+	"leash":        leaf(buildLeash),
+	"patrol":       leaf(buildPatrol),
+	"idle":         leaf(buildIdle),
+}
+
+// Register adds a type of node that behavior definitions can use.
+// It must be called before behaviors are parsed, usually from an init function.
+func Register(nodeType string, build BuildFunc) {
+	if _, ok := builders[nodeType]; ok {
+		panic(fmt.Sprintf("node type %q is already registered", nodeType))
+	}
+	builders[nodeType] = build
+}
+
+// Context is what a behavior tree knows and decides in a tick of an NPC
+type Context struct {
+	// Agent is the NPC the tree is deciding for
+	Agent Agent
+	// Memory is what the NPC remembers between ticks
+	Memory *Memory
+	// World is what the NPC senses around it
+	World World
+	// RNG is the source of the random decisions of the tree
+	RNG *rand.Rand
+	// DeltaTime is the time in seconds since the last tick
+	DeltaTime float64
+	// Intent is what the tree decided the NPC does this tick
+	Intent Intent
+}
+
+// Agent is what an NPC knows about itself
+type Agent struct {
+	// Position is the position of the NPC
+	Position kinematic.Vector
+	// FlipH is true if the NPC faces left
+	FlipH bool
+	// Hitpoints are the hitpoints the NPC has left
+	Hitpoints int16
+	// MaxHitpoints are the hitpoints the NPC spawned with
+	MaxHitpoints int16
+	// Home is where the NPC spawned
+	Home kinematic.Vector
+	// PatrolMinX and PatrolMaxX bound where the NPC patrols and flees
+	PatrolMinX, PatrolMaxX float64
+	// LineOfSight is how far ahead the NPC sees players
+	LineOfSight float64
+	// AttackRange is how close in front of the NPC players have to be for it to attack
+	AttackRange float64
+}
+
+// Target is a player an NPC can fight
+type Target struct {
+	ID       uint32
+	Position kinematic.Vector
+}
+
+// World is what an NPC senses of the zone around it
+type World interface {
+	// Target returns a living player by ID
+	Target(id uint32) (Target, bool)
+	// Targets returns the living players within a distance of a position in ascending order of ID
+	Targets(position kinematic.Vector, distance float64) []Target
+	// InLineOfSight returns true if nothing solid is between an NPC at a position and a player
+	InLineOfSight(position kinematic.Vector, target Target) bool
+	// Alert provokes the other NPCs within a distance of a position into fighting a player
+	Alert(position kinematic.Vector, distance float64, targetID uint32)
+}
+
+// Memory is what an NPC remembers between ticks of its behavior
+type Memory struct {
+	// TargetID is the ID of the player the NPC is fighting, if HasTarget
+	TargetID  uint32
+	HasTarget bool
+	// CalledForHelp is true if the NPC has alerted others to its target
+	CalledForHelp bool
+	// Leashing is true while the NPC returns home after straying too far
+	Leashing bool
+	// PatrolX is where the NPC is walking to while patrolling, if Patrolling
+	PatrolX    float64
+	Patrolling bool
+	// IdleTimeLeft is how long the NPC stands still before patrolling again
+	IdleTimeLeft float64
+}
+
+// Provoke makes the NPC fight a player, unless it is already fighting or returning home
+func (m *Memory) Provoke(targetID uint32) {
+	if m.HasTarget || m.Leashing {
+		return
+	}
+	m.TargetID = targetID
+	m.HasTarget = true
+}
+
+// Forget makes the NPC stop fighting its target
+func (m *Memory) Forget() {
+	m.TargetID = 0
+	m.HasTarget = false
+	m.CalledForHelp = false
+}
+
+// Intent is what an NPC decided to do in a tick
+type Intent struct {
+	// MoveX is where the NPC walks to, if Move
+	MoveX float64
+	Move  bool
+	// FaceX is where the NPC turns to when it is not walking, if Face
+	FaceX float64
+	Face  bool
+	// Attack is true if the NPC attacks when it can
+	Attack bool
+}
+
+// moveTo makes the NPC walk to x
+func (c *Context) moveTo(x float64) {
+	c.Intent.Move = true
+	c.Intent.MoveX = x
+}
+
+// face turns the NPC towards x
+func (c *Context) face(x float64) {
+	c.Intent.Face = true
+	c.Intent.FaceX = x
+}
+
+// target returns the player the NPC is fighting, if it still can
+func (c *Context) target() (Target, bool) {
+	if !c.Memory.HasTarget {
+		return Target{}, false
+	}
+	return c.World.Target(c.Memory.TargetID)
+}
+
+// selector ticks its children in order until one of them does not fail
+type selector struct {
+	children []Node
+}
+
+func (s *selector) Tick(ctx *Context) Status {
+	for _, child := range s.children {
+		if status := child.Tick(ctx); status != StatusFailure {
+			return status
+		}
+	}
+	return StatusFailure
+}
+
+// sequence ticks its children in order until one of them does not succeed
+type sequence struct {
+	children []Node
+}
+
+func (s *sequence) Tick(ctx *Context) Status {
+	for _, child := range s.children {
+		if status := child.Tick(ctx); status != StatusSuccess {
+			return status
+		}
+	}
+	return StatusSuccess
+}
+
+// inverter turns the success of its child into failure and the other way around
+type inverter struct {
+	child Node
+}
+
+func (i *inverter) Tick(ctx *Context) Status {
+	switch status := i.child.Tick(ctx); status {
+	case StatusSuccess:
+		return StatusFailure
+	case StatusFailure:
+		return StatusSuccess
+	default:
+		return status
+	}
+}
+
+func buildSelector(definition *Definition, children []Node) (Node, error) {
+	if len(children) == 0 {
+		return nil, fmt.Errorf("must have children")
+	}
+	return &selector{children: children}, nil
+}
+
+func buildSequence(definition *Definition, children []Node) (Node, error) {
+	if len(children) == 0 {
+		return nil, fmt.Errorf("must have children")
+	}
+	return &sequence{children: children}, nil
+}
+
+func buildInverter(definition *Definition, children []Node) (Node, error) {
+	if len(children) != 1 {
+		return nil, fmt.Errorf("must have exactly one child")
+	}
+	return &inverter{child: children[0]}, nil
+}
+
+// build builds a node and its children from their definitions
+func build(definition *Definition) (Node, error) {
+	builder, ok := builders[definition.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown node type %q", definition.Type)
+	}
+	children := make([]Node, 0, len(definition.Children))
+	for _, childDefinition := range definition.Children {
+		child, err := build(childDefinition)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	node, err := builder(definition, children)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s node: %v", definition.Type, err)
+	}
+	return node, nil
+}
+
+// leaf wraps the builder of a type of node that does not have children
+func leaf(build func(definition *Definition) (Node, error)) BuildFunc {
+	return func(definition *Definition, children []Node) (Node, error) {
+		if len(children) > 0 {
+			return nil, fmt.Errorf("must not have children")
+		}
+		return build(definition)
+	}
+}
@@ -0,0 +1,104 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedInput returns the input of a player in the player step with the given sequence
+type scriptedInput func(sequence uint32) *messages.ClientPlayerUpdate
+
+// runHeadless runs a game from a seed for a number of ticks without a network or clock,
+// with a player driven by a script, and returns the hash of the game state after each tick
+func runHeadless(t *testing.T, seed int64, ticks int, script scriptedInput) []uint64 {
+	gameLoopInterval := 50 * time.Millisecond
+	clientMessageQueue := queue.NewInMemoryQueue(1024)
+	serverEventQueue := queue.NewInMemoryQueue(1024)
+	broadcastMessageChan := make(chan workers.BroadcastMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-broadcastMessageChan:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	gm := NewGameManager(NewGameManagerOptions{
+		ClientMessageQueue:   clientMessageQueue,
+		ServerEventQueue:     serverEventQueue,
+		SaveStateChan:        make(chan workers.SaveStateRequest, 1),
+		BroadcastMessageChan: broadcastMessageChan,
+		GameLoopInterval:     gameLoopInterval,
+		SaveStateInterval:    time.Hour,
+		Seed:                 seed,
+	})
+	ctx := context.Background()
+	assert.NoError(t, gm.initializeGameState(ctx))
+
+	err := serverEventQueue.Enqueue(&types.ConnectPlayerEvent{
+		ClientID:           1,
+		CharacterID:        1,
+		CharacterName:      "player-1",
+		CharacterPosition:  kinematic.NewVector(constants.PlayerStartingX, constants.PlayerStartingY),
+		CharacterHitpoints: 100,
+		CharacterZoneID:    levels.Active().StartZone,
+	})
+	assert.NoError(t, err)
+
+	stepsPerTick := int(gameLoopInterval.Seconds() / constants.PlayerStepDuration)
+	stepMillis := gameLoopInterval.Milliseconds() / int64(stepsPerTick)
+	var sequence uint32
+	hashes := make([]uint64, 0, ticks)
+	for tick := 0; tick < ticks; tick++ {
+		for i := 0; i < stepsPerTick; i++ {
+			sequence++
+			update := script(sequence)
+			update.Sequence = sequence
+			update.Timestamp = int64(sequence) * stepMillis
+			update.DeltaTime = constants.PlayerStepDuration
+			payload, err := messages.SerializeClientPlayerUpdate(update)
+			assert.NoError(t, err)
+			err = clientMessageQueue.Enqueue(&messages.Message{
+				ClientID: 1,
+				Type:     messages.MessageTypeClientPlayerUpdate,
+				Payload:  payload,
+			})
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, gm.gameTick(ctx))
+		hashes = append(hashes, gm.zones[levels.Active().StartZone].gameState.Hash())
+	}
+	assert.Equal(t, uint64(ticks), gm.tick)
+	assert.Equal(t, int64(ticks)*gameLoopInterval.Milliseconds(), gm.timestamp)
+	return hashes
+}
+
+func TestGameManager_Deterministic(t *testing.T) {
+	// walk left into the NPCs and attack them
+	script := func(sequence uint32) *messages.ClientPlayerUpdate {
+		if sequence < 180 {
+			return &messages.ClientPlayerUpdate{InputX: -1}
+		}
+		return &messages.ClientPlayerUpdate{InputAttack1: sequence%60 == 0}
+	}
+	ticks := 400
+
+	hashes := runHeadless(t, 42, ticks, script)
+	assert.Equal(t, hashes, runHeadless(t, 42, ticks, script), "runs with the same seed diverged")
+	assert.NotEqual(t, hashes, runHeadless(t, 43, ticks, script), "runs with different seeds did not diverge")
+}
@@ -2,28 +2,54 @@ package game
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
 	"github.com/cbodonnell/flywheel/pkg/game/types"
-	"github.com/cbodonnell/flywheel/pkg/kinematic"
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
 	"github.com/cbodonnell/flywheel/pkg/workers"
-	"github.com/solarlune/resolv"
+)
+
+const (
+	// DefaultShutdownCountdown is how long clients are warned before the server shuts down
+	DefaultShutdownCountdown = 10 * time.Second
+	// MaxCatchUpTicks is how many ticks the game loop runs at once to catch up with the clock,
+	// beyond which the ticks it fell behind by are skipped
+	MaxCatchUpTicks = 5
+	// playerStepEpsilon absorbs the rounding of the player step accumulator
+	playerStepEpsilon = 1e-9
 )
 
 type GameManager struct {
-	gameState            *types.GameState
+	world *levels.World
+	zones map[uint32]*Zone
+	// clientZones maps client IDs to the IDs of the zones their players are in
+	clientZones          map[uint32]uint32
 	clientMessageQueue   queue.Queue
 	serverEventQueue     queue.Queue
 	saveStateChan        chan<- workers.SaveStateRequest
 	broadcastMessageChan chan<- workers.BroadcastMessage
 	gameLoopInterval     time.Duration
 	saveStateInterval    time.Duration
+	// tick is the number of ticks simulated and timestamp is the game time of the last one
+	tick      uint64
+	timestamp int64
+	// seed is the seed of the random number streams of the simulation
+	seed int64
+	// epoch is the server time in milliseconds of tick zero
+	epoch          int64
+	inputValidator *InputValidator
+	// kickClient disconnects a client, or is nil if clients are not kicked.
+	// It is called outside of the game loop.
+	kickClient func(clientID uint32, reason string)
+	// stopChan is closed to stop the game loop and stoppedChan is closed once it has stopped
+	stopChan    chan struct{}
+	stoppedChan chan struct{}
+	stopOnce    sync.Once
 }
 
 // NewGameManagerOptions contains options for creating a new GameManager.
@@ -34,26 +60,74 @@ type NewGameManagerOptions struct {
 	BroadcastMessageChan chan<- workers.BroadcastMessage
 	GameLoopInterval     time.Duration
 	SaveStateInterval    time.Duration
+	// InterestRadius is the distance from a player within which entities are sent to its client.
+	// Defaults to DefaultInterestRadius.
+	InterestRadius float64
+	// KickClient is called to disconnect a client whose inputs are too suspicious.
+	// Suspicious clients are only logged if it is nil.
+	KickClient func(clientID uint32, reason string)
+	// MaxRewind is the furthest back in time hits are rewound to compensate for the latency of a client.
+	// Defaults to DefaultMaxRewind.
+	MaxRewind time.Duration
+	// PvPRules are the rules of combat between players. Players cannot hit each other by default.
+	PvPRules PvPRules
+	// Seed seeds the random number streams of the simulation, so that a game can be replayed.
+	// A seed is picked from the clock if it is zero.
+	Seed int64
 }
 
 func NewGameManager(opts NewGameManagerOptions) *GameManager {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	world := levels.Active()
+	zones := make(map[uint32]*Zone, len(world.Zones))
+	for i, zone := range world.Zones {
+		zones[zone.ID] = NewZone(NewZoneOptions{
+			Zone:                 zone,
+			BroadcastMessageChan: opts.BroadcastMessageChan,
+			GameLoopInterval:     opts.GameLoopInterval,
+			InterestRadius:       opts.InterestRadius,
+			MaxRewind:            opts.MaxRewind,
+			PvPRules:             opts.PvPRules,
+			// each zone draws from a stream of its own so that zones can be simulated concurrently
+			Seed: seed + int64(zone.ID),
+			// and spawns NPCs with IDs of its own for the same reason
+			FirstNPCID:  uint32(i + 1),
+			NPCIDStride: uint32(len(world.Zones)),
+		})
+	}
+
 	return &GameManager{
-		gameState:            types.NewGameState(NewCollisionSpace()),
+		world:                world,
+		zones:                zones,
+		clientZones:          make(map[uint32]uint32),
 		clientMessageQueue:   opts.ClientMessageQueue,
 		serverEventQueue:     opts.ServerEventQueue,
 		saveStateChan:        opts.SaveStateChan,
 		broadcastMessageChan: opts.BroadcastMessageChan,
 		gameLoopInterval:     opts.GameLoopInterval,
 		saveStateInterval:    opts.SaveStateInterval,
+		seed:                 seed,
+		inputValidator:       NewInputValidator(),
+		kickClient:           opts.KickClient,
+		stopChan:             make(chan struct{}),
+		stoppedChan:          make(chan struct{}),
 	}
 }
 
 // Start starts the game loop.
 func (gm *GameManager) Start(ctx context.Context) error {
+	defer close(gm.stoppedChan)
+
 	if err := gm.initializeGameState(ctx); err != nil {
 		return fmt.Errorf("failed to initialize game state: %v", err)
 	}
+	log.Info("Simulating the game with seed %d", gm.seed)
 
+	gm.epoch = time.Now().UnixMilli()
 	gameTicker := time.NewTicker(gm.gameLoopInterval)
 	defer gameTicker.Stop()
 
@@ -64,70 +138,230 @@ func (gm *GameManager) Start(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-gm.stopChan:
+			return nil
 		case t := <-gameTicker.C:
-			err := gm.gameTick(ctx, t)
-			if err != nil {
-				log.Error("Failed to run game tick: %v", err)
-			}
+			gm.runDueTicks(ctx, t)
 			// TODO: server metrics
 			// duration := time.Since(t)
 			// log.Debug("Game tick took %s (%.2f%% of tick rate)", duration, float64(duration)/float64(gm.gameLoopInterval)*100)
 		case <-saveTicker.C:
 			saveRequest := workers.SaveStateRequest{
-				Timestamp: gm.gameState.Timestamp,
+				Timestamp: gm.timestamp,
 				Type:      workers.SaveStateRequestTypeGame,
-				State:     gm.gameState.Copy(),
+				State:     gm.playersState(true),
 			}
 			gm.saveStateChan <- saveRequest
 		}
 	}
 }
 
-func (gm *GameManager) Stop() {
-	// TODO: gracefully stop the game and save the game state
+// Stop gracefully stops the game loop. Clients are warned of the shutdown and the game
+// keeps running for the countdown, after which the game loop is stopped and a final save
+// of the game state is flushed. Stop returns once the game state is saved or ctx is done.
+func (gm *GameManager) Stop(ctx context.Context, countdown time.Duration) error {
+	shutdown := &messages.ServerShutdown{
+		Countdown: uint32(countdown.Milliseconds()),
+		Reason:    "The server is shutting down",
+	}
+	select {
+	case gm.broadcastMessageChan <- workers.BroadcastMessage{
+		Type:    messages.MessageTypeServerShutdown,
+		Message: shutdown,
+	}:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to broadcast server shutdown: %v", ctx.Err())
+	}
+
+	countdownTimer := time.NewTimer(countdown)
+	defer countdownTimer.Stop()
+	select {
+	case <-countdownTimer.C:
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown countdown interrupted: %v", ctx.Err())
+	}
+
+	gm.stopOnce.Do(func() { close(gm.stopChan) })
+	select {
+	case <-gm.stoppedChan:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for game loop to stop: %v", ctx.Err())
+	}
+
+	// the game loop has stopped, so the players can be saved without copying them
+	done := make(chan error, 1)
+	saveRequest := workers.SaveStateRequest{
+		Timestamp: gm.timestamp,
+		Type:      workers.SaveStateRequestTypeGame,
+		State:     gm.playersState(false),
+		Done:      done,
+	}
+	select {
+	case gm.saveStateChan <- saveRequest:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to request final game state save: %v", ctx.Err())
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to save final game state: %v", err)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for final game state save: %v", ctx.Err())
+	}
+
+	return nil
 }
 
 func (gm *GameManager) initializeGameState(_ context.Context) error {
-	npcs := []struct {
-		ID            uint32
-		SpawnPosition kinematic.Vector
-		Flip          bool
-	}{
-		{1, kinematic.NewVector(128-constants.NPCWidth/2, 16), false},
-		{2, kinematic.NewVector(384-constants.NPCWidth/2, 16), false},
-		{3, kinematic.NewVector(896-constants.NPCWidth/2, 16), true},
-		{4, kinematic.NewVector(1152-constants.NPCWidth/2, 16), true},
-	}
-
-	for _, npc := range npcs {
-		wanderRangeMinX := npc.SpawnPosition.X - constants.NPCWanderRange
-		if wanderRangeMinX < 16 {
-			wanderRangeMinX = 16
-		}
-		wanderRangeMaxX := npc.SpawnPosition.X + constants.NPCWanderRange
-		if wanderRangeMaxX > float64(constants.SpaceWidth)-16-constants.NPCWidth {
-			wanderRangeMaxX = float64(constants.SpaceWidth) - 16 - constants.NPCWidth
+	for _, zone := range gm.world.Zones {
+		if err := gm.zones[zone.ID].spawnNPCs(); err != nil {
+			return fmt.Errorf("failed to spawn NPCs: %v", err)
 		}
-		npcState := types.NewNPCState(npc.ID, npc.SpawnPosition, wanderRangeMinX, wanderRangeMaxX, npc.Flip)
-		gm.gameState.NPCs[npc.ID] = npcState
-		gm.gameState.CollisionSpace.Add(npcState.Object)
-		npcState.Spawn()
 	}
 
 	return nil
 }
 
+// playersState returns a game state of the players in every zone to be saved,
+// copying the players if the game loop is running
+func (gm *GameManager) playersState(copyPlayers bool) *types.GameState {
+	gameState := &types.GameState{
+		Tick:      gm.tick,
+		Timestamp: gm.timestamp,
+		Players:   make(map[uint32]*types.PlayerState),
+		NPCs:      make(map[uint32]*types.NPCState),
+	}
+	for _, zone := range gm.zones {
+		for clientID, playerState := range zone.gameState.Players {
+			if copyPlayers {
+				playerState = playerState.Copy()
+			}
+			gameState.Players[clientID] = playerState
+		}
+	}
+	return gameState
+}
+
+// runDueTicks runs the ticks that are due by the time t, so that the simulation
+// steps by tick number while keeping pace with the clock.
+func (gm *GameManager) runDueTicks(ctx context.Context, t time.Time) {
+	due := uint64(time.Duration(t.UnixMilli()-gm.epoch) * time.Millisecond / gm.gameLoopInterval)
+	if due > gm.tick+MaxCatchUpTicks {
+		skipped := due - gm.tick - MaxCatchUpTicks
+		log.Warn("Game loop fell %d ticks behind, skipping %d ticks", due-gm.tick, skipped)
+		gm.epoch += (time.Duration(skipped) * gm.gameLoopInterval).Milliseconds()
+		due -= skipped
+	}
+	for gm.tick < due {
+		if err := gm.gameTick(ctx); err != nil {
+			log.Error("Failed to run game tick %d: %v", gm.tick, err)
+		}
+	}
+}
+
 // gameTick runs one iteration of the game loop.
-func (gm *GameManager) gameTick(_ context.Context, t time.Time) error {
-	gm.gameState.Timestamp = t.UnixMilli()
+// The game time is derived from the tick number so that a game can be replayed.
+func (gm *GameManager) gameTick(_ context.Context) error {
+	gm.tick++
+	gm.timestamp = gm.epoch + (time.Duration(gm.tick) * gm.gameLoopInterval).Milliseconds()
 	gm.processServerEvents()
 	gm.processClientMessages()
-	gm.updateServerObjects(gm.gameLoopInterval.Seconds())
-	gm.broadcastGameState()
+	gm.tickZones()
 
 	return nil
 }
 
+// tickZones runs a tick in every zone concurrently, then moves the players
+// that took a portal to the zones it leads to
+func (gm *GameManager) tickZones() {
+	var wg sync.WaitGroup
+	for _, zone := range gm.zones {
+		wg.Add(1)
+		go func(zone *Zone) {
+			defer wg.Done()
+			zone.tick(gm.tick, gm.timestamp)
+		}(zone)
+	}
+	wg.Wait()
+
+	// move players in order of zone so that a game can be replayed
+	for _, zone := range gm.world.Zones {
+		for _, d := range gm.zones[zone.ID].departures {
+			gm.movePlayer(d.clientID, gm.zones[zone.ID], d.portal)
+		}
+	}
+}
+
+// movePlayer moves a player out of a zone through a portal and
+// tells its client about the zone it arrives in
+func (gm *GameManager) movePlayer(clientID uint32, from *Zone, portal *levels.Portal) {
+	to, ok := gm.zones[portal.Zone]
+	if !ok {
+		log.Error("Portal in zone %d leads to zone %d, which does not exist", from.id, portal.Zone)
+		return
+	}
+	position, ok := to.level.Spawn(portal.Spawn)
+	if !ok {
+		log.Error("Zone %d has no spawn point %s", to.id, portal.Spawn)
+		return
+	}
+
+	playerState, inputBuffer := from.removePlayer(clientID)
+	if playerState == nil {
+		return
+	}
+	from.broadcast(messages.MessageTypeServerPlayerDisconnect, &messages.ServerPlayerDisconnect{
+		ClientID: clientID,
+	})
+
+	playerState.MoveTo(position)
+	to.addPlayer(clientID, playerState, inputBuffer)
+	gm.clientZones[clientID] = to.id
+	log.Debug("Client %d moved from zone %d to zone %d", clientID, from.id, to.id)
+
+	gm.broadcastMessageChan <- workers.BroadcastMessage{
+		ClientID: clientID,
+		Type:     messages.MessageTypeServerZoneChange,
+		Message: &messages.ServerZoneChange{
+			Timestamp:   gm.timestamp,
+			ZoneID:      to.id,
+			PlayerState: PlayerStateUpdateFromState(playerState),
+		},
+	}
+	// the other players in the zone see the player connect
+	playerConnect := &messages.ServerPlayerConnect{
+		ClientID:    clientID,
+		PlayerState: PlayerStateUpdateFromState(playerState),
+		ZoneID:      to.id,
+	}
+	gm.broadcastMessageChan <- workers.BroadcastMessage{
+		ClientIDs: otherClients(to.gameState.PlayerIDs(), clientID),
+		Type:      messages.MessageTypeServerPlayerConnect,
+		Message:   playerConnect,
+	}
+}
+
+// otherClients returns the client IDs other than a client
+func otherClients(clientIDs []uint32, clientID uint32) []uint32 {
+	others := make([]uint32, 0, len(clientIDs))
+	for _, id := range clientIDs {
+		if id != clientID {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+// clientZone returns the zone of the player of a client, or nil if it has none
+func (gm *GameManager) clientZone(clientID uint32) *Zone {
+	zoneID, ok := gm.clientZones[clientID]
+	if !ok {
+		return nil
+	}
+	return gm.zones[zoneID]
+}
+
 // processServerEvents processes all pending connection events in the queue,
 // updates the game state, and notifies connected clients
 func (gm *GameManager) processServerEvents() {
@@ -153,51 +387,57 @@ func (gm *GameManager) processServerEvents() {
 }
 
 func (gm *GameManager) handleConnectPlayerEvent(event *types.ConnectPlayerEvent) error {
-	playerState := types.NewPlayerState(event.CharacterID, event.CharacterName, event.CharacterPosition, event.CharacterFlipH, event.CharacterHitpoints)
-	log.Debug("Client %d connected as %s", event.ClientID, event.CharacterName)
-	// add the player to the game state
-	gm.gameState.Players[event.ClientID] = playerState
-	// add the player object to the collision space
-	gm.gameState.CollisionSpace.Add(playerState.Object)
+	position := event.CharacterPosition
+	zone, ok := gm.zones[event.CharacterZoneID]
+	if !ok {
+		// the character was saved before zones or in a zone that no longer exists
+		if event.CharacterZoneID != 0 {
+			log.Warn("Character %d is in zone %d, which does not exist", event.CharacterID, event.CharacterZoneID)
+		}
+		zone = gm.zones[gm.world.StartZone]
+		position = zone.level.PlayerSpawn()
+	}
+
+	playerState := types.NewPlayerState(event.CharacterID, event.CharacterName, position, event.CharacterFlipH, event.CharacterHitpoints)
+	log.Debug("Client %d connected as %s in zone %d", event.ClientID, event.CharacterName, zone.id)
+	// add the player to the zone and start buffering its inputs
+	zone.addPlayer(event.ClientID, playerState, nil)
+	gm.clientZones[event.ClientID] = zone.id
 
 	playerConnect := &messages.ServerPlayerConnect{
 		ClientID:    event.ClientID,
 		PlayerState: PlayerStateUpdateFromState(playerState),
+		ZoneID:      zone.id,
 	}
-	gm.broadcastMessageChan <- workers.BroadcastMessage{
-		Type:    messages.MessageTypeServerPlayerConnect,
-		Message: playerConnect,
-	}
+	zone.broadcast(messages.MessageTypeServerPlayerConnect, playerConnect)
 
 	return nil
 }
 
 func (gm *GameManager) handleDisconnectPlayerEvent(event *types.DisconnectPlayerEvent) error {
-	// send a request to save the player state before deleting it
+	zone := gm.clientZone(event.ClientID)
+	if zone == nil {
+		return fmt.Errorf("client %d is not in a zone", event.ClientID)
+	}
+	delete(gm.clientZones, event.ClientID)
+	gm.inputValidator.Forget(event.ClientID)
+
+	playerState, _ := zone.removePlayer(event.ClientID)
+	if playerState == nil {
+		return fmt.Errorf("client %d is not in zone %d", event.ClientID, zone.id)
+	}
+	// send a request to save the player state now that it is removed
 	saveRequest := workers.SaveStateRequest{
-		Timestamp: gm.gameState.Timestamp,
+		Timestamp: gm.timestamp,
 		Type:      workers.SaveStateRequestTypePlayer,
-		State:     gm.gameState.Players[event.ClientID].Copy(),
+		State:     playerState.Copy(),
 	}
 	gm.saveStateChan <- saveRequest
-	// remove the player object from the collision space
-	gm.gameState.CollisionSpace.Remove(gm.gameState.Players[event.ClientID].Object)
-	// npc follow target cleanup
-	for _, npcState := range gm.gameState.NPCs {
-		if npcState.FollowTarget == gm.gameState.Players[event.ClientID] {
-			npcState.StopFollowing()
-		}
-	}
-	// delete the player from the game state
-	delete(gm.gameState.Players, event.ClientID)
 
 	playerDisconnect := &messages.ServerPlayerDisconnect{
 		ClientID: event.ClientID,
 	}
-	gm.broadcastMessageChan <- workers.BroadcastMessage{
-		Type:    messages.MessageTypeServerPlayerDisconnect,
-		Message: playerDisconnect,
-	}
+	zone.broadcast(messages.MessageTypeServerPlayerDisconnect, playerDisconnect)
 
 	return nil
 }
@@ -222,6 +462,10 @@ func (gm *GameManager) processClientMessages() {
 			if err := gm.handleClientPlayerUpdate(message); err != nil {
 				log.Error("Failed to handle client player update: %v", err)
 			}
+		case messages.MessageTypeClientSnapshotAck:
+			if err := gm.handleClientSnapshotAck(message); err != nil {
+				log.Error("Failed to handle client snapshot ack: %v", err)
+			}
 		default:
 			log.Error("Unhandled message type: %s", message.Type)
 		}
@@ -229,281 +473,63 @@ func (gm *GameManager) processClientMessages() {
 }
 
 func (gm *GameManager) handleClientPlayerUpdate(message *messages.Message) error {
-	clientPlayerUpdate := &messages.ClientPlayerUpdate{}
-	err := json.Unmarshal(message.Payload, clientPlayerUpdate)
+	clientPlayerUpdate, err := messages.DeserializeClientPlayerUpdate(message.Payload)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal client player update: %v", err)
+		return fmt.Errorf("failed to deserialize client player update: %v", err)
 	}
-	if _, ok := gm.gameState.Players[message.ClientID]; !ok {
+	zone := gm.clientZone(message.ClientID)
+	if zone == nil {
 		log.Warn("Client %d is not in the game state", message.ClientID)
 		return nil
 	}
-	playerState := gm.gameState.Players[message.ClientID]
 
-	if playerState.LastProcessedTimestamp > clientPlayerUpdate.Timestamp {
-		log.Warn("Client %d sent an outdated player update", message.ClientID)
+	err = gm.inputValidator.Validate(message.ClientID, clientPlayerUpdate, gm.timestamp)
+	if _, ok := err.(*ErrOutdatedInput); ok {
+		log.Debug("Client %d sent an outdated player update: %v", message.ClientID, err)
 		return nil
 	}
-
-	playerStateChanged := false
-
-	// check for past updates that have not been processed and apply first
-	for _, pastUpdate := range clientPlayerUpdate.PastUpdates {
-		if playerState.LastProcessedTimestamp >= pastUpdate.Timestamp {
-			continue
-		}
-		log.Warn("Applying past update for client %d - Last processed: %d, past update: %d", message.ClientID, playerState.LastProcessedTimestamp, pastUpdate.Timestamp)
-		if changed := playerState.ApplyInput(pastUpdate); changed {
-			playerStateChanged = true
-		}
-	}
-
-	// TODO: validate the update before applying it
-	if changed := playerState.ApplyInput(clientPlayerUpdate); changed {
-		playerStateChanged = true
+	if gm.inputValidator.ShouldKick(message.ClientID) && gm.kickClient != nil {
+		// kicking a client does network I/O, which must not hold up the tick
+		go gm.kickClient(message.ClientID, fmt.Sprintf("suspicion score of %.1f", gm.inputValidator.Suspicion(message.ClientID)))
+		gm.inputValidator.Forget(message.ClientID)
 	}
-
-	if playerStateChanged {
-		log.Trace("Player %d updated", message.ClientID)
+	if err != nil {
+		return fmt.Errorf("rejected player update from client %d: %v", message.ClientID, err)
 	}
 
-	gm.checkPlayerCollisions(message.ClientID, playerState)
-
-	return nil
-}
-
-// checkPlayerCollisions checks for collisions between a player and other objects in the game.
-func (gm *GameManager) checkPlayerCollisions(clientID uint32, playerState *types.PlayerState) {
-	// do attack hit detection
-	if !playerState.IsAttackHitting {
-		return
+	// buffer the inputs to be applied by the player steps, including
+	// past updates in case the messages that carried them were lost
+	inputBuffer, ok := zone.inputBuffers[message.ClientID]
+	if !ok {
+		inputBuffer = NewInputBuffer()
+		zone.inputBuffers[message.ClientID] = inputBuffer
 	}
-
-	// create an attack hitbox for the player
-	attackHitbox := playerState.Object.Clone()
-
-	attackHitboxWidth, attackHitboxOffset := 0.0, 0.0
-	switch playerState.CurrentAttack {
-	case types.PlayerAttack1:
-		attackHitboxWidth = constants.PlayerAttack1HitboxWidth
-		attackHitboxOffset = constants.PlayerAttack1HitboxOffset
-	case types.PlayerAttack2:
-		attackHitboxWidth = constants.PlayerAttack2HitboxWidth
-		attackHitboxOffset = constants.PlayerAttack2HitboxOffset
-	case types.PlayerAttack3:
-		attackHitboxWidth = constants.PlayerAttack3HitboxWidth
-		attackHitboxOffset = constants.PlayerAttack3HitboxOffset
-	default:
-		log.Warn("Unhandled player attack type: %d", playerState.CurrentAttack)
-	}
-
-	attackHitbox.Size.X = attackHitboxWidth
-	if !playerState.FlipH {
-		attackHitbox.Position.X += attackHitboxOffset
-	} else {
-		attackHitbox.Position.X -= attackHitboxOffset
-	}
-	gm.gameState.CollisionSpace.Add(attackHitbox)
-	defer gm.gameState.CollisionSpace.Remove(attackHitbox)
-
-	// TODO: check for collision and get the ID from the collision shape data
-	for npcID, npcState := range gm.gameState.NPCs {
-		if npcState.IsDead() {
-			continue
-		}
-
-		if !attackHitbox.SharesCells(npcState.Object) {
-			continue
-		}
-
-		// player hit npc
-		log.Debug("Player %d hit NPC %d", clientID, npcID)
-
-		damage := int16(0)
-		switch playerState.CurrentAttack {
-		case types.PlayerAttack1:
-			damage = constants.PlayerAttack1Damage
-		case types.PlayerAttack2:
-			damage = constants.PlayerAttack2Damage
-		case types.PlayerAttack3:
-			damage = constants.PlayerAttack3Damage
-		default:
-			log.Warn("Unhandled player attack type: %d", playerState.CurrentAttack)
-		}
-
-		npcState.TakeDamage(damage)
-
-		npcHit := &messages.ServerNPCHit{
-			NPCID:    npcID,
-			PlayerID: clientID,
-			Damage:   damage,
-		}
-		gm.broadcastMessageChan <- workers.BroadcastMessage{
-			Type:    messages.MessageTypeServerNPCHit,
-			Message: npcHit,
-		}
-
-		if !npcState.IsDead() {
-			if !npcState.IsFollowing() {
-				npcState.StartFollowing(playerState)
-			}
-			continue
-		}
-
-		// player killed npc
-		npcState.Despawn()
-
-		log.Debug("Player %d killed NPC %d", clientID, npcID)
-		npcKill := &messages.ServerNPCKill{
-			NPCID:    npcID,
-			PlayerID: clientID,
-		}
-		gm.broadcastMessageChan <- workers.BroadcastMessage{
-			Type:    messages.MessageTypeServerNPCKill,
-			Message: npcKill,
-		}
+	for _, pastUpdate := range clientPlayerUpdate.PastUpdates {
+		inputBuffer.Add(pastUpdate)
 	}
-}
-
-// updateServerObjects updates server objects (e.g. npcs, items, projectiles, etc.)
-func (gm *GameManager) updateServerObjects(deltaTime float64) {
-	for npcID, npcState := range gm.gameState.NPCs {
-		if npcState.IsAttacking {
-			// npc is attacking
-			if npcState.IsAttackHitting {
-				// npc is hitting with an attack
-				gm.checkNPCAttackHit(npcID, npcState)
-			}
-		} else if !npcState.IsDead() {
-			// npc is not following a player, so check for any players in line of sight
-			gm.checkNPCLineOfSight(npcState)
-		}
+	clientPlayerUpdate.PastUpdates = nil
+	inputBuffer.Add(clientPlayerUpdate)
 
-		npcStateChanged := npcState.Update(deltaTime)
-		if npcStateChanged {
-			log.Trace("NPC %d updated", npcID)
-		}
-	}
+	return nil
 }
 
-func (gm *GameManager) checkNPCAttackHit(npcID uint32, npcState *types.NPCState) {
-	attackHitbox := npcState.Object.Clone()
-	attackHitboxWidth, attackHitboxOffset := 0.0, 0.0
-	switch npcState.CurrentAttack {
-	case types.NPCAttack1:
-		attackHitboxWidth = constants.NPCAttack1HitboxWidth
-		attackHitboxOffset = constants.NPCAttack1HitboxOffset
-	case types.NPCAttack2:
-		attackHitboxWidth = constants.NPCAttack2HitboxWidth
-		attackHitboxOffset = constants.NPCAttack2HitboxOffset
-	case types.NPCAttack3:
-		attackHitboxWidth = constants.NPCAttack3HitboxWidth
-		attackHitboxOffset = constants.NPCAttack3HitboxOffset
-	default:
-		log.Warn("Unhandled NPC attack type: %d", npcState.CurrentAttack)
-	}
-
-	attackHitbox.Size.X = attackHitboxWidth
-	if !npcState.FlipH {
-		attackHitbox.Position.X += attackHitboxOffset
-	} else {
-		attackHitbox.Position.X -= attackHitboxOffset
-	}
-	gm.gameState.CollisionSpace.Add(attackHitbox)
-	defer gm.gameState.CollisionSpace.Remove(attackHitbox)
-
-	for playerID, playerState := range gm.gameState.Players {
-		if playerState.IsDead() {
-			continue
-		}
-
-		if !attackHitbox.SharesCells(playerState.Object) {
-			continue
-		}
-
-		log.Debug("NPC %d hit player %d", npcID, playerID)
-
-		damage := int16(0)
-		switch npcState.CurrentAttack {
-		case types.NPCAttack1:
-			damage = constants.NPCAttack1Damage
-		case types.NPCAttack2:
-			damage = constants.NPCAttack2Damage
-		case types.NPCAttack3:
-			damage = constants.NPCAttack3Damage
-		default:
-			log.Warn("Unhandled NPC attack type: %d", npcState.CurrentAttack)
-		}
-
-		playerState.TakeDamage(damage)
-
-		playerHit := &messages.ServerPlayerHit{
-			PlayerID: playerID,
-			NPCID:    npcID,
-			Damage:   damage,
-		}
-		gm.broadcastMessageChan <- workers.BroadcastMessage{
-			Type:    messages.MessageTypeServerPlayerHit,
-			Message: playerHit,
-		}
-
-		if !playerState.IsDead() {
-			continue
-		}
-
-		log.Debug("NPC %d killed player %d", npcID, playerID)
-		playerKill := &messages.ServerPlayerKill{
-			PlayerID: playerID,
-			NPCID:    npcID,
-		}
-		gm.broadcastMessageChan <- workers.BroadcastMessage{
-			Type:    messages.MessageTypeServerPlayerKill,
-			Message: playerKill,
-		}
+func (gm *GameManager) handleClientSnapshotAck(message *messages.Message) error {
+	clientSnapshotAck, err := messages.DeserializeClientSnapshotAck(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize client snapshot ack: %v", err)
 	}
-}
 
-func (gm *GameManager) checkNPCLineOfSight(npcState *types.NPCState) {
-	flip := 1.0
-	if npcState.FlipH {
-		flip = -1.0
-	}
-	lineOfSight := resolv.NewLine(npcState.Position.X+constants.NPCWidth/2, npcState.Position.Y+constants.NPCHeight/2, npcState.Position.X+constants.NPCWidth/2+flip*constants.NPCLineOfSight, npcState.Position.Y+constants.NPCHeight/2)
-	for _, playerState := range gm.gameState.Players {
-		if playerState.IsDead() {
-			continue
-		}
-		if contact := lineOfSight.Intersection(0, 0, playerState.Object.Shape); contact != nil {
-			npcState.StartFollowing(playerState)
-			break
-		}
+	zone := gm.clientZone(message.ClientID)
+	if zone == nil {
+		log.Warn("Client %d has no snapshots to ack", message.ClientID)
+		return nil
 	}
-}
-
-// broadcastGameState sends the game state to connected clients.
-func (gm *GameManager) broadcastGameState() {
-	for clientID, playerState := range gm.gameState.Players {
-		// TODO: player vs localPlayer updates should be handled differently
-		playerUpdate := &messages.ServerPlayerUpdate{
-			Timestamp:   gm.gameState.Timestamp,
-			ClientID:    clientID,
-			PlayerState: PlayerStateUpdateFromState(playerState),
-		}
-		gm.broadcastMessageChan <- workers.BroadcastMessage{
-			Type:    messages.MessageTypeServerPlayerUpdate,
-			Message: playerUpdate,
-		}
+	clientSnapshots, ok := zone.clientSnapshots[message.ClientID]
+	if !ok {
+		log.Warn("Client %d has no snapshots to ack", message.ClientID)
+		return nil
 	}
+	clientSnapshots.Ack(clientSnapshotAck.Timestamp)
 
-	for npcID, npcState := range gm.gameState.NPCs {
-		npcUpdate := &messages.ServerNPCUpdate{
-			Timestamp: gm.gameState.Timestamp,
-			NPCID:     npcID,
-			NPCState:  NPCStateUpdateFromState(npcState),
-		}
-		gm.broadcastMessageChan <- workers.BroadcastMessage{
-			Type:    messages.MessageTypeServerNPCUpdate,
-			Message: npcUpdate,
-		}
-	}
+	return nil
 }
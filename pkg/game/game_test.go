@@ -1,16 +1,19 @@
 package game
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	mocks "github.com/cbodonnell/flywheel/mocks/github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
 	"github.com/cbodonnell/flywheel/pkg/game/constants"
 	"github.com/cbodonnell/flywheel/pkg/game/types"
 	"github.com/cbodonnell/flywheel/pkg/kinematic"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/workers"
 	"github.com/solarlune/resolv"
 	"github.com/stretchr/testify/assert"
 )
@@ -26,7 +29,9 @@ func TestGameManager_processClientMessages(t *testing.T) {
 		name   string
 		fields fields
 		setup  func()
-		want   *types.GameState
+		// steps is the number of player steps simulated after processing the messages
+		steps int
+		want  *types.GameState
 	}{
 		{
 			name: "basic movement",
@@ -60,22 +65,24 @@ func TestGameManager_processClientMessages(t *testing.T) {
 			setup: func() {
 				testClientPlayerUpdates := []messages.ClientPlayerUpdate{
 					{
+						Sequence:  1,
 						Timestamp: 1,
 						InputX:    0,
 						InputY:    0,
-						DeltaTime: 0.1,
+						DeltaTime: constants.PlayerStepDuration,
 					},
 					{
+						Sequence:  2,
 						Timestamp: 2,
 						InputX:    1,
 						InputY:    0,
-						DeltaTime: 0.1,
+						DeltaTime: constants.PlayerStepDuration,
 					},
 				}
 				testMessages := make([]interface{}, len(testClientPlayerUpdates))
 
 				for i, update := range testClientPlayerUpdates {
-					payload, err := json.Marshal(update)
+					payload, err := messages.SerializeClientPlayerUpdate(&update)
 					if err != nil {
 						t.Fatalf("failed to marshal payload: %v", err)
 					}
@@ -88,18 +95,19 @@ func TestGameManager_processClientMessages(t *testing.T) {
 
 				mockQueue.EXPECT().ReadAllMessages().Return(testMessages, nil).Once()
 			},
+			steps: 2,
 			want: &types.GameState{
 				Players: map[uint32]*types.PlayerState{
 					1: {
 						CharacterID: 1,
 						Name:        "player-1",
 						Position: kinematic.Vector{
-							X: 35,
-							Y: -58.80000000000001,
+							X: 5.833333333333333,
+							Y: -1.6333333333333333,
 						},
 						Velocity: kinematic.Vector{
 							X: 350,
-							Y: -588,
+							Y: -98,
 						},
 						FlipH:             false,
 						IsOnGround:        false,
@@ -144,6 +152,7 @@ func TestGameManager_processClientMessages(t *testing.T) {
 			setup: func() {
 				mockQueue.EXPECT().ReadAllMessages().Return([]interface{}{}, nil).Once()
 			},
+			steps: 0,
 			want: &types.GameState{
 				Players: map[uint32]*types.PlayerState{
 					1: {
@@ -200,22 +209,24 @@ func TestGameManager_processClientMessages(t *testing.T) {
 			setup: func() {
 				testClientPlayerUpdates := []messages.ClientPlayerUpdate{
 					{
+						Sequence:  2,
 						Timestamp: 2,
 						InputX:    0,
 						InputY:    0,
-						DeltaTime: 0.1,
+						DeltaTime: constants.PlayerStepDuration,
 					},
 					{
+						Sequence:  1,
 						Timestamp: 1,
 						InputX:    1,
 						InputY:    0,
-						DeltaTime: 0.1,
+						DeltaTime: constants.PlayerStepDuration,
 					},
 				}
 				testMessages := make([]interface{}, len(testClientPlayerUpdates))
 
 				for i, update := range testClientPlayerUpdates {
-					payload, err := json.Marshal(update)
+					payload, err := messages.SerializeClientPlayerUpdate(&update)
 					if err != nil {
 						t.Fatalf("failed to marshal payload: %v", err)
 					}
@@ -228,6 +239,7 @@ func TestGameManager_processClientMessages(t *testing.T) {
 
 				mockQueue.EXPECT().ReadAllMessages().Return(testMessages, nil).Once()
 			},
+			steps: 2,
 			want: &types.GameState{
 				Players: map[uint32]*types.PlayerState{
 					1: {
@@ -235,11 +247,11 @@ func TestGameManager_processClientMessages(t *testing.T) {
 						Name:        "player-1",
 						Position: kinematic.Vector{
 							X: 0.0,
-							Y: -14.700000000000003,
+							Y: -1.6333333333333333,
 						},
 						Velocity: kinematic.Vector{
 							X: 0,
-							Y: -294,
+							Y: -98,
 						},
 						FlipH:             false,
 						IsOnGround:        false,
@@ -258,11 +270,25 @@ func TestGameManager_processClientMessages(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup()
 			}
+			zone := &Zone{
+				id:           1,
+				gameState:    tt.fields.gameState,
+				inputBuffers: make(map[uint32]*InputBuffer),
+				inPortal:     make(map[uint32]bool),
+			}
 			gm := &GameManager{
 				clientMessageQueue: tt.fields.clientMessageQueue,
-				gameState:          tt.fields.gameState,
+				zones:              map[uint32]*Zone{zone.id: zone},
+				clientZones:        make(map[uint32]uint32),
+				inputValidator:     NewInputValidator(),
+			}
+			for clientID := range tt.fields.gameState.Players {
+				gm.clientZones[clientID] = zone.id
 			}
 			gm.processClientMessages()
+			for i := 0; i < tt.steps; i++ {
+				zone.stepPlayers()
+			}
 			if tt.want != nil {
 				for clientID, wantPlayerState := range tt.want.Players {
 					assert.Equal(t, wantPlayerState.Position, tt.fields.gameState.Players[clientID].Position, fmt.Sprintf("Position for clientID %d", clientID))
@@ -278,3 +304,102 @@ func TestGameManager_processClientMessages(t *testing.T) {
 		})
 	}
 }
+
+func TestGameManager_Stop(t *testing.T) {
+	saveStateChan := make(chan workers.SaveStateRequest, 1)
+	broadcastMessageChan := make(chan workers.BroadcastMessage, 1)
+	gm := NewGameManager(NewGameManagerOptions{
+		ClientMessageQueue:   queue.NewInMemoryQueue(10),
+		ServerEventQueue:     queue.NewInMemoryQueue(10),
+		SaveStateChan:        saveStateChan,
+		BroadcastMessageChan: broadcastMessageChan,
+		GameLoopInterval:     10 * time.Millisecond,
+		SaveStateInterval:    time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	startErrChan := make(chan error, 1)
+	go func() {
+		startErrChan <- gm.Start(ctx)
+	}()
+	stopErrChan := make(chan error, 1)
+	go func() {
+		stopErrChan <- gm.Stop(ctx, 50*time.Millisecond)
+	}()
+
+	// clients are warned of the shutdown with its countdown
+	broadcast := <-broadcastMessageChan
+	assert.Equal(t, messages.MessageTypeServerShutdown, broadcast.Type)
+	assert.Equal(t, uint32(50), broadcast.Message.(*messages.ServerShutdown).Countdown)
+
+	// the game loop is stopped before the final save is requested
+	saveRequest := <-saveStateChan
+	assert.NoError(t, <-startErrChan)
+	assert.Equal(t, workers.SaveStateRequestTypeGame, saveRequest.Type)
+	if assert.NotNil(t, saveRequest.Done) {
+		// Stop waits for the save to complete
+		select {
+		case err := <-stopErrChan:
+			t.Fatalf("Stop returned before the save completed: %v", err)
+		default:
+		}
+		saveRequest.Done <- fmt.Errorf("database unavailable")
+	}
+	assert.Error(t, <-stopErrChan)
+}
+
+func TestAbilityAnimations(t *testing.T) {
+	// every animation abilities can play is one players and npcs have
+	for _, key := range abilities.Animations {
+		assert.Contains(t, types.PlayerAnimationKeys, key)
+		assert.Contains(t, types.NPCAnimationKeys, key)
+	}
+}
+
+func TestGameManager_handleClientPlayerUpdate_Kick(t *testing.T) {
+	// the kick blocks until the test releases it, like a kick of a client that is not reading
+	kicked := make(chan string, 1)
+	release := make(chan struct{})
+	defer close(release)
+	gm := NewGameManager(NewGameManagerOptions{
+		ClientMessageQueue:   queue.NewInMemoryQueue(10),
+		ServerEventQueue:     queue.NewInMemoryQueue(10),
+		SaveStateChan:        make(chan workers.SaveStateRequest, 1),
+		BroadcastMessageChan: make(chan workers.BroadcastMessage, 10),
+		GameLoopInterval:     50 * time.Millisecond,
+		SaveStateInterval:    time.Hour,
+		KickClient: func(clientID uint32, reason string) {
+			kicked <- reason
+			<-release
+		},
+		Seed: 1,
+	})
+	for zoneID := range gm.zones {
+		gm.clientZones[1] = zoneID
+		break
+	}
+	gm.inputValidator.clients[1] = &clientInputState{suspicion: 2 * SuspicionKickThreshold}
+
+	payload, err := messages.SerializeClientPlayerUpdate(&messages.ClientPlayerUpdate{Timestamp: 1, DeltaTime: 0.016})
+	assert.NoError(t, err)
+	handled := make(chan error, 1)
+	go func() {
+		handled <- gm.handleClientPlayerUpdate(&messages.Message{ClientID: 1, Type: messages.MessageTypeClientPlayerUpdate, Payload: payload})
+	}()
+	select {
+	case err := <-handled:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("handling a player update waited for the client to be kicked")
+	}
+
+	select {
+	case reason := <-kicked:
+		assert.Contains(t, reason, "suspicion score")
+	case <-time.After(time.Second):
+		t.Fatal("the client was not kicked")
+	}
+	assert.False(t, gm.inputValidator.ShouldKick(1), "the suspicion of a kicked client is forgotten")
+}
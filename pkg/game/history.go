@@ -0,0 +1,171 @@
+package game
+
+import (
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/solarlune/resolv"
+)
+
+const (
+	// DefaultMaxRewind is the furthest back in time hits are rewound to compensate for the latency of a client
+	DefaultMaxRewind = 250 * time.Millisecond
+)
+
+// historyFrame is the positions of the entities at the end of a game tick
+type historyFrame struct {
+	timestamp int64
+	players   map[uint32]kinematic.Vector
+	npcs      map[uint32]kinematic.Vector
+}
+
+// PositionHistory is a ring buffer of the positions of entities over the last game ticks,
+// used to rewind targets to where a client saw them when checking its hits
+type PositionHistory struct {
+	frames []historyFrame
+	// next is the index of the frame to overwrite next
+	next int
+	size int
+}
+
+// NewPositionHistory creates a PositionHistory that holds the given number of ticks
+func NewPositionHistory(capacity int) *PositionHistory {
+	return &PositionHistory{
+		frames: make([]historyFrame, max(capacity, 1)),
+	}
+}
+
+// Record records the positions of the entities of the game state at its timestamp.
+// Dead NPCs are not recorded since they cannot be hit.
+func (h *PositionHistory) Record(gameState *types.GameState) {
+	frame := historyFrame{
+		timestamp: gameState.Timestamp,
+		players:   make(map[uint32]kinematic.Vector, len(gameState.Players)),
+		npcs:      make(map[uint32]kinematic.Vector, len(gameState.NPCs)),
+	}
+	for clientID, playerState := range gameState.Players {
+		frame.players[clientID] = playerState.Position
+	}
+	for npcID, npcState := range gameState.NPCs {
+		if !npcState.IsDead() {
+			frame.npcs[npcID] = npcState.Position
+		}
+	}
+
+	h.frames[h.next] = frame
+	h.next = (h.next + 1) % len(h.frames)
+	h.size = min(h.size+1, len(h.frames))
+}
+
+// PlayerPosition returns the position of a player at a time, interpolated
+// between the recorded ticks the same way clients render other entities
+func (h *PositionHistory) PlayerPosition(clientID uint32, t int64) (kinematic.Vector, bool) {
+	return h.position(t, func(frame historyFrame) (kinematic.Vector, bool) {
+		position, ok := frame.players[clientID]
+		return position, ok
+	})
+}
+
+// NPCPosition returns the position of an NPC at a time, interpolated
+// between the recorded ticks the same way clients render other entities
+func (h *PositionHistory) NPCPosition(npcID uint32, t int64) (kinematic.Vector, bool) {
+	return h.position(t, func(frame historyFrame) (kinematic.Vector, bool) {
+		position, ok := frame.npcs[npcID]
+		return position, ok
+	})
+}
+
+// position finds the recorded ticks around a time and interpolates the position of an entity between them.
+// It returns false if the time is not within the history or the entity is missing from either tick.
+func (h *PositionHistory) position(t int64, lookup func(frame historyFrame) (kinematic.Vector, bool)) (kinematic.Vector, bool) {
+	// walk back from the newest frame to the first one at or before the time
+	for i := 1; i < h.size; i++ {
+		to := h.frames[(h.next-i+len(h.frames))%len(h.frames)]
+		from := h.frames[(h.next-i-1+len(h.frames))%len(h.frames)]
+		if from.timestamp > t {
+			continue
+		}
+		if to.timestamp < t {
+			return kinematic.Vector{}, false
+		}
+
+		fromPosition, ok := lookup(from)
+		if !ok {
+			return kinematic.Vector{}, false
+		}
+		toPosition, ok := lookup(to)
+		if !ok {
+			return kinematic.Vector{}, false
+		}
+		factor := 0.0
+		if to.timestamp > from.timestamp {
+			factor = float64(t-from.timestamp) / float64(to.timestamp-from.timestamp)
+		}
+		return kinematic.Vector{
+			X: fromPosition.X + (toPosition.X-fromPosition.X)*factor,
+			Y: fromPosition.Y + (toPosition.Y-fromPosition.Y)*factor,
+		}, true
+	}
+	return kinematic.Vector{}, false
+}
+
+// Newest returns the timestamp of the newest recorded tick, or zero if none are recorded
+func (h *PositionHistory) Newest() int64 {
+	if h.size == 0 {
+		return 0
+	}
+	return h.frames[(h.next-1+len(h.frames))%len(h.frames)].timestamp
+}
+
+// rewindObject moves an object in its collision space to a position
+// and returns a function that moves it back
+func rewindObject(object *resolv.Object, position kinematic.Vector) (restore func()) {
+	original := object.Position
+	object.Position.X = position.X
+	object.Position.Y = position.Y
+	object.Update()
+	return func() {
+		object.Position = original
+		object.Update()
+	}
+}
+
+// rewindNPC moves an NPC to where it was at a time, if the time is
+// within the position history, and returns a function that moves it back
+func (z *Zone) rewindNPC(npcID uint32, npcState *types.NPCState, t int64) (restore func()) {
+	if z.positionHistory == nil || t >= z.gameState.Timestamp {
+		return func() {}
+	}
+	position, ok := z.positionHistory.NPCPosition(npcID, t)
+	if !ok {
+		return func() {}
+	}
+	return rewindObject(npcState.Object, position)
+}
+
+// rewindPlayer moves a player to where it was at a time, if the time is
+// within the position history, and returns a function that moves it back
+func (z *Zone) rewindPlayer(clientID uint32, playerState *types.PlayerState, t int64) (restore func()) {
+	if z.positionHistory == nil || t >= z.gameState.Timestamp {
+		return func() {}
+	}
+	position, ok := z.positionHistory.PlayerPosition(clientID, t)
+	if !ok {
+		return func() {}
+	}
+	return rewindObject(playerState.Object, position)
+}
+
+// renderTime returns the time at which a client rendered other entities when it generated an
+// input with the given timestamp, limited to the max rewind before the current game time.
+// Clients stamp their inputs with their estimate of the server time and render other entities
+// the interpolation offset behind it.
+func (z *Zone) renderTime(inputTimestamp int64) int64 {
+	if inputTimestamp == 0 {
+		return z.gameState.Timestamp
+	}
+	renderTime := inputTimestamp - constants.InterpolationOffset
+	return max(z.gameState.Timestamp-z.maxRewind.Milliseconds(), min(z.gameState.Timestamp, renderTime))
+}
@@ -0,0 +1,98 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/solarlune/resolv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionHistory_NPCPosition(t *testing.T) {
+	h := NewPositionHistory(3)
+	gameState := types.NewGameState(resolv.NewSpace(100, 100, 10, 10), 1)
+	npcState := types.NewNPCState(1, npcs.Active().DefaultArchetype(), kinematic.NewVector(0, 0), 0, 100, false)
+	npcState.Spawn(gameState.RNG)
+	gameState.NPCs[1] = npcState
+
+	for i := int64(0); i < 4; i++ {
+		gameState.Timestamp = 1000 + i*50
+		npcState.Position = kinematic.NewVector(float64(i*10), 0)
+		h.Record(gameState)
+	}
+	assert.Equal(t, int64(1150), h.Newest())
+
+	// positions are interpolated between ticks
+	position, ok := h.NPCPosition(1, 1075)
+	assert.True(t, ok)
+	assert.Equal(t, kinematic.NewVector(15, 0), position)
+	position, ok = h.NPCPosition(1, 1150)
+	assert.True(t, ok)
+	assert.Equal(t, kinematic.NewVector(30, 0), position)
+
+	// the oldest tick was overwritten, and times after the newest tick are not in the history
+	_, ok = h.NPCPosition(1, 1025)
+	assert.False(t, ok)
+	_, ok = h.NPCPosition(1, 1175)
+	assert.False(t, ok)
+	_, ok = h.NPCPosition(2, 1075)
+	assert.False(t, ok)
+}
+
+func TestZone_checkPlayerCollisions_LagCompensation(t *testing.T) {
+	broadcastMessageChan := make(chan workers.BroadcastMessage, 10)
+	zone := NewZone(NewZoneOptions{
+		Zone:                 levels.Active().Start(),
+		BroadcastMessageChan: broadcastMessageChan,
+		GameLoopInterval:     50 * time.Millisecond,
+	})
+	zone.gameState.CollisionSpace = resolv.NewSpace(constants.SpaceWidth, constants.SpaceHeight, constants.CellWidth, constants.CellHeight)
+
+	playerState := types.NewPlayerState(1, "player-1", kinematic.NewVector(100, 100), false, constants.PlayerHitpoints)
+	zone.gameState.Players[1] = playerState
+	zone.gameState.CollisionSpace.Add(playerState.Object)
+	npcState := types.NewNPCState(1, npcs.Active().DefaultArchetype(), kinematic.NewVector(100+constants.PlayerWidth, 100), 0, float64(constants.SpaceWidth), false)
+	npcState.Spawn(zone.gameState.RNG)
+	zone.gameState.NPCs[1] = npcState
+	zone.gameState.CollisionSpace.Add(npcState.Object)
+
+	// the npc was in front of the player until it moved away in the last tick
+	for i := int64(0); i < 4; i++ {
+		zone.gameState.Timestamp = 1000 + i*50
+		zone.positionHistory.Record(zone.gameState)
+	}
+	zone.gameState.Timestamp = 1200
+	npcState.Position.X += 4 * constants.PlayerWidth
+	npcState.Object.Position.X = npcState.Position.X
+	npcState.Object.Update()
+
+	playerState.IsAttackHitting = true
+	playerState.CurrentAbility = abilities.Active().PlayerAbility(0)
+
+	// an attack from a client without latency misses
+	playerState.LastProcessedTimestamp = 1200 + constants.InterpolationOffset
+	zone.checkPlayerCollisions(1, playerState)
+	assert.Len(t, broadcastMessageChan, 0)
+
+	// an attack from a client that rendered the npc in front of the player hits,
+	// and the npc is moved back to where it is
+	playerState.LastProcessedTimestamp = 1150 + constants.InterpolationOffset
+	zone.checkPlayerCollisions(1, playerState)
+	if assert.Len(t, broadcastMessageChan, 1) {
+		assert.Equal(t, messages.MessageTypeServerNPCHit, (<-broadcastMessageChan).Type)
+	}
+	assert.Equal(t, npcState.Position.X, npcState.Object.Position.X)
+
+	// attacks are not rewound further than the max rewind
+	zone.gameState.Timestamp = 1150 + DefaultMaxRewind.Milliseconds() + 50
+	zone.checkPlayerCollisions(1, playerState)
+	assert.Len(t, broadcastMessageChan, 0)
+}
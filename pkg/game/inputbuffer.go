@@ -0,0 +1,128 @@
+package game
+
+import (
+	"github.com/cbodonnell/flywheel/pkg/messages"
+)
+
+const (
+	// InputBufferTargetSize is the number of inputs buffered before a client's inputs are consumed,
+	// which absorbs the jitter in the arrival of the inputs at the cost of a little latency
+	InputBufferTargetSize = 2
+	// MaxInputBufferSize is the maximum number of buffered inputs, beyond which the oldest are skipped
+	MaxInputBufferSize = 12
+	// InputBufferResetDistance is how far behind the next sequence an input must be
+	// for the buffer to assume the client restarted its sequence
+	InputBufferResetDistance = 60
+)
+
+// InputBuffer orders the inputs of a client by sequence number so the server can consume
+// exactly one per simulation step. The last input is repeated when the next one is missing,
+// and the buffer refills to its target size after running dry before consuming again.
+type InputBuffer struct {
+	inputs map[uint32]*messages.ClientPlayerUpdate
+	// nextSequence is the sequence of the next input to consume
+	nextSequence uint32
+	// started is set once the first input is consumed
+	started bool
+	// buffering is set while the buffer refills to its target size
+	buffering bool
+	lastInput *messages.ClientPlayerUpdate
+}
+
+// NewInputBuffer creates a new InputBuffer
+func NewInputBuffer() *InputBuffer {
+	return &InputBuffer{
+		inputs:    make(map[uint32]*messages.ClientPlayerUpdate),
+		buffering: true,
+	}
+}
+
+// Add buffers an input. Inputs that were already consumed or buffered are dropped.
+func (b *InputBuffer) Add(input *messages.ClientPlayerUpdate) {
+	if b.started && input.Sequence < b.nextSequence {
+		if b.nextSequence-input.Sequence < InputBufferResetDistance {
+			return
+		}
+		// the client restarted its sequence, such as after resuming its session
+		b.inputs = make(map[uint32]*messages.ClientPlayerUpdate)
+		b.started = false
+		b.buffering = true
+	}
+	if _, ok := b.inputs[input.Sequence]; ok {
+		return
+	}
+	b.inputs[input.Sequence] = input
+
+	for len(b.inputs) > MaxInputBufferSize {
+		// skip ahead so the client's inputs are not delayed any further
+		oldest := b.oldestSequence()
+		delete(b.inputs, oldest)
+		if b.started && b.nextSequence <= oldest {
+			b.nextSequence = oldest + 1
+		}
+	}
+}
+
+// Next returns the input for the next simulation step and whether it was received from the client.
+// When the next input has not been received, the last input is repeated without its one-shot actions.
+func (b *InputBuffer) Next() (*messages.ClientPlayerUpdate, bool) {
+	if b.buffering {
+		if len(b.inputs) < InputBufferTargetSize {
+			return b.repeatLastInput(), false
+		}
+		b.buffering = false
+		if !b.started {
+			b.nextSequence = b.oldestSequence()
+			b.started = true
+		}
+	}
+
+	input, ok := b.inputs[b.nextSequence]
+	if !ok {
+		if len(b.inputs) == 0 {
+			// the buffer ran dry, so wait for it to refill
+			b.buffering = true
+		} else {
+			// the input was lost, so skip it
+			b.nextSequence++
+		}
+		return b.repeatLastInput(), false
+	}
+
+	delete(b.inputs, b.nextSequence)
+	b.nextSequence++
+	b.lastInput = input
+	return input, true
+}
+
+// Len returns the number of buffered inputs
+func (b *InputBuffer) Len() int {
+	return len(b.inputs)
+}
+
+// repeatLastInput returns a copy of the last input that holds its movement
+// but not its jump, attacks or respawn, which must not be performed twice
+func (b *InputBuffer) repeatLastInput() *messages.ClientPlayerUpdate {
+	if b.lastInput == nil {
+		return &messages.ClientPlayerUpdate{}
+	}
+	return &messages.ClientPlayerUpdate{
+		Sequence:  b.lastInput.Sequence,
+		Timestamp: b.lastInput.Timestamp,
+		InputX:    b.lastInput.InputX,
+		InputY:    b.lastInput.InputY,
+	}
+}
+
+// oldestSequence returns the lowest buffered sequence
+func (b *InputBuffer) oldestSequence() uint32 {
+	first := true
+	oldest := uint32(0)
+	for sequence := range b.inputs {
+		if first || sequence < oldest {
+			oldest = sequence
+			first = false
+		}
+	}
+	return oldest
+}
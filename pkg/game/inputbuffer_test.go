@@ -0,0 +1,77 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputBuffer_Next(t *testing.T) {
+	b := NewInputBuffer()
+
+	// nothing is consumed until the buffer reaches its target size
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 2, Timestamp: 20, InputX: 1})
+	input, received := b.Next()
+	assert.False(t, received)
+	assert.Equal(t, &messages.ClientPlayerUpdate{}, input)
+
+	// inputs are consumed in order of sequence
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 1, Timestamp: 10, InputX: -1, InputJump: true})
+	input, received = b.Next()
+	assert.True(t, received)
+	assert.Equal(t, uint32(1), input.Sequence)
+	input, received = b.Next()
+	assert.True(t, received)
+	assert.Equal(t, uint32(2), input.Sequence)
+
+	// consumed inputs are dropped
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 1, Timestamp: 10})
+	assert.Equal(t, 0, b.Len())
+
+	// the last input is repeated without its one-shot actions while the buffer refills
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 3, Timestamp: 30, InputX: 1, InputAttack1: true})
+	input, received = b.Next()
+	assert.True(t, received)
+	input, received = b.Next()
+	assert.False(t, received)
+	assert.Equal(t, &messages.ClientPlayerUpdate{Sequence: 3, Timestamp: 30, InputX: 1}, input)
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 4, Timestamp: 40})
+	_, received = b.Next()
+	assert.False(t, received)
+
+	// a lost input is skipped once later inputs are buffered
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 6, Timestamp: 60})
+	input, received = b.Next()
+	assert.True(t, received)
+	assert.Equal(t, uint32(4), input.Sequence)
+	_, received = b.Next()
+	assert.False(t, received)
+	input, received = b.Next()
+	assert.True(t, received)
+	assert.Equal(t, uint32(6), input.Sequence)
+}
+
+func TestInputBuffer_Overflow(t *testing.T) {
+	b := NewInputBuffer()
+	first := uint32(InputBufferResetDistance)
+	for i := first; i < first+MaxInputBufferSize+5; i++ {
+		b.Add(&messages.ClientPlayerUpdate{Sequence: i})
+	}
+	assert.Equal(t, MaxInputBufferSize, b.Len())
+
+	// the oldest inputs are skipped
+	input, received := b.Next()
+	assert.True(t, received)
+	assert.Equal(t, first+5, input.Sequence)
+
+	// inputs slightly behind are late, but a restarted sequence resets the buffer
+	b.Add(&messages.ClientPlayerUpdate{Sequence: first + 1})
+	assert.Equal(t, MaxInputBufferSize-1, b.Len())
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 1})
+	b.Add(&messages.ClientPlayerUpdate{Sequence: 2})
+	assert.Equal(t, 2, b.Len())
+	input, received = b.Next()
+	assert.True(t, received)
+	assert.Equal(t, uint32(1), input.Sequence)
+}
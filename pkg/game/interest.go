@@ -0,0 +1,155 @@
+package game
+
+import (
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/solarlune/resolv"
+)
+
+const (
+	// DefaultInterestRadius is the default distance from a player within which entities are sent to its client
+	DefaultInterestRadius = 480.0
+)
+
+// View is the set of entities visible to a client
+type View struct {
+	Players map[uint32]bool
+	NPCs    map[uint32]bool
+}
+
+func newView() *View {
+	return &View{
+		Players: make(map[uint32]bool),
+		NPCs:    make(map[uint32]bool),
+	}
+}
+
+// Filter returns a copy of a snapshot with only the entities in the view
+func (v *View) Filter(snapshot *messages.ServerGameUpdate) *messages.ServerGameUpdate {
+	filtered := &messages.ServerGameUpdate{
+		Timestamp:         snapshot.Timestamp,
+		BaselineTimestamp: snapshot.BaselineTimestamp,
+		Players:           make(map[uint32]*messages.PlayerStateUpdate, len(v.Players)),
+		NPCs:              make(map[uint32]*messages.NPCStateUpdate, len(v.NPCs)),
+	}
+	for clientID, playerState := range snapshot.Players {
+		if v.Players[clientID] {
+			filtered.Players[clientID] = playerState
+		}
+	}
+	for npcID, npcState := range snapshot.NPCs {
+		if v.NPCs[npcID] {
+			filtered.NPCs[npcID] = npcState
+		}
+	}
+	return filtered
+}
+
+// ViewChange is an entity entering or leaving the view of a client
+type ViewChange struct {
+	EntityType messages.EntityType
+	EntityID   uint32
+	Entered    bool
+}
+
+// InterestManager tracks which entities are within the interest radius of each player.
+// The collision space grid is used to find candidate entities near a player,
+// which are then filtered by their distance from the player.
+type InterestManager struct {
+	radius float64
+	views  map[uint32]*View
+}
+
+// NewInterestManager creates a new InterestManager with the given interest radius.
+func NewInterestManager(radius float64) *InterestManager {
+	return &InterestManager{
+		radius: radius,
+		views:  make(map[uint32]*View),
+	}
+}
+
+// View returns the current view of a client, or nil if the client has no player.
+func (im *InterestManager) View(clientID uint32) *View {
+	return im.views[clientID]
+}
+
+// Update recomputes the view of every player in the game state and
+// returns the entities that entered or left each client's view.
+func (im *InterestManager) Update(state *types.GameState) map[uint32][]ViewChange {
+	playerIDs := make(map[*resolv.Object]uint32, len(state.Players))
+	for clientID, playerState := range state.Players {
+		playerIDs[playerState.Object] = clientID
+	}
+	npcIDs := make(map[*resolv.Object]uint32, len(state.NPCs))
+	for npcID, npcState := range state.NPCs {
+		npcIDs[npcState.Object] = npcID
+	}
+
+	changes := make(map[uint32][]ViewChange)
+	for clientID, playerState := range state.Players {
+		center := kinematic.NewVector(playerState.Position.X+constants.PlayerWidth/2, playerState.Position.Y+constants.PlayerHeight/2)
+
+		view := newView()
+		// a player can always see itself
+		view.Players[clientID] = true
+
+		nearby := state.CollisionSpace.CheckWorld(center.X-im.radius, center.Y-im.radius, im.radius*2, im.radius*2, types.CollisionSpaceTagPlayer, types.CollisionSpaceTagNPC)
+		for _, obj := range nearby {
+			if center.DistanceFrom(objectCenter(obj)) > im.radius {
+				continue
+			}
+			if id, ok := playerIDs[obj]; ok {
+				view.Players[id] = true
+			} else if id, ok := npcIDs[obj]; ok {
+				view.NPCs[id] = true
+			}
+		}
+
+		previous, ok := im.views[clientID]
+		if !ok {
+			previous = newView()
+		}
+		changes[clientID] = diffViews(previous, view)
+		im.views[clientID] = view
+	}
+
+	for clientID := range im.views {
+		if _, ok := state.Players[clientID]; !ok {
+			delete(im.views, clientID)
+		}
+	}
+
+	return changes
+}
+
+func objectCenter(obj *resolv.Object) kinematic.Vector {
+	return kinematic.NewVector(obj.Position.X+obj.Size.X/2, obj.Position.Y+obj.Size.Y/2)
+}
+
+// diffViews returns the entities that entered or left a view
+func diffViews(previous *View, current *View) []ViewChange {
+	var changes []ViewChange
+	for id := range current.Players {
+		if !previous.Players[id] {
+			changes = append(changes, ViewChange{EntityType: messages.EntityTypePlayer, EntityID: id, Entered: true})
+		}
+	}
+	for id := range previous.Players {
+		if !current.Players[id] {
+			changes = append(changes, ViewChange{EntityType: messages.EntityTypePlayer, EntityID: id, Entered: false})
+		}
+	}
+	for id := range current.NPCs {
+		if !previous.NPCs[id] {
+			changes = append(changes, ViewChange{EntityType: messages.EntityTypeNPC, EntityID: id, Entered: true})
+		}
+	}
+	for id := range previous.NPCs {
+		if !current.NPCs[id] {
+			changes = append(changes, ViewChange{EntityType: messages.EntityTypeNPC, EntityID: id, Entered: false})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,64 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterestManager_Update(t *testing.T) {
+	state := types.NewGameState(NewCollisionSpace(levels.Default()), 1)
+	near := types.NewPlayerState(1, "near", kinematic.NewVector(100, 16), false, 100)
+	far := types.NewPlayerState(2, "far", kinematic.NewVector(1100, 16), false, 100)
+	npc := types.NewNPCState(1, npcs.Active().DefaultArchetype(), kinematic.NewVector(200, 16), 100, 300, false)
+	state.Players[1] = near
+	state.Players[2] = far
+	state.NPCs[1] = npc
+	state.CollisionSpace.Add(near.Object, far.Object, npc.Object)
+
+	im := NewInterestManager(300)
+	changes := im.Update(state)
+
+	assert.ElementsMatch(t, []ViewChange{
+		{EntityType: messages.EntityTypePlayer, EntityID: 1, Entered: true},
+		{EntityType: messages.EntityTypeNPC, EntityID: 1, Entered: true},
+	}, changes[1])
+	assert.ElementsMatch(t, []ViewChange{
+		{EntityType: messages.EntityTypePlayer, EntityID: 2, Entered: true},
+	}, changes[2])
+
+	snapshot := ServerGameUpdateFromState(state)
+	filtered := im.View(2).Filter(snapshot)
+	assert.Len(t, filtered.Players, 1)
+	assert.Contains(t, filtered.Players, uint32(2))
+	assert.Empty(t, filtered.NPCs)
+
+	// the far player walks over to the near player
+	far.Position = kinematic.NewVector(150, 16)
+	far.Object.Position.X = far.Position.X
+	far.Object.Update()
+	changes = im.Update(state)
+
+	assert.ElementsMatch(t, []ViewChange{
+		{EntityType: messages.EntityTypePlayer, EntityID: 2, Entered: true},
+	}, changes[1])
+	assert.ElementsMatch(t, []ViewChange{
+		{EntityType: messages.EntityTypePlayer, EntityID: 1, Entered: true},
+		{EntityType: messages.EntityTypeNPC, EntityID: 1, Entered: true},
+	}, changes[2])
+
+	// and back again
+	far.Position = kinematic.NewVector(1100, 16)
+	far.Object.Position.X = far.Position.X
+	far.Object.Update()
+	changes = im.Update(state)
+
+	assert.ElementsMatch(t, []ViewChange{
+		{EntityType: messages.EntityTypePlayer, EntityID: 2, Entered: false},
+	}, changes[1])
+}
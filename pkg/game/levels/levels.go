@@ -0,0 +1,470 @@
+// Package levels loads the levels of the game from maps made with the Tiled map editor,
+// and the world of zones they are played in.
+//
+// Maps are orthogonal and finite, in the JSON (.tmj) or TMX (.tmx) format, with tilesets
+// embedded or in external .tsj or .tsx files. Tile layers are drawn by the client, and
+// object layers are recognized by their names:
+//
+//   - collision: rectangles that are solid on all sides
+//   - platforms: rectangles that can be jumped through from below
+//   - ladders: rectangles that can be climbed, one collision cell wide
+//   - spawns: points where players spawn, named "player" or as portals refer to them
+//   - npcs: points or rectangles where NPCs spawn, with optional properties: a string
+//     "archetype" of the NPCs, a bool "flip" to face left, a float "wanderRange",
+//     an int "population" of NPCs to keep alive, a float "respawnTime" in seconds,
+//     an int "maxSpawns" to stop spawning after, and a bool "wave" to respawn
+//     the whole population at once after all of it has died
+//   - portals: rectangles that move players to the zone of their int "zone" property,
+//     at the spawn point of their optional string "spawn" property
+//
+// Other object layers are ignored. Points mark the bottom center of what spawns there.
+package levels
+
+import (
+	"embed"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+)
+
+//go:embed maps
+var defaultMaps embed.FS
+
+// DefaultMap is the path of the default map in the embedded maps
+const DefaultMap = "maps/default.tmj"
+
+const (
+	// LayerCollision is the name of the object layer of solid rectangles
+	LayerCollision = "collision"
+	// LayerPlatforms is the name of the object layer of platforms
+	LayerPlatforms = "platforms"
+	// LayerLadders is the name of the object layer of ladders
+	LayerLadders = "ladders"
+	// LayerSpawns is the name of the object layer of player spawn points
+	LayerSpawns = "spawns"
+	// LayerNPCs is the name of the object layer of NPC spawners
+	LayerNPCs = "npcs"
+	// LayerPortals is the name of the object layer of portals
+	LayerPortals = "portals"
+
+	// PlayerSpawnName is the name of the spawn point of players
+	PlayerSpawnName = "player"
+)
+
+const (
+	// TileFlipHorizontal is set in a GID when the tile is flipped horizontally
+	TileFlipHorizontal uint32 = 0x80000000
+	// TileFlipVertical is set in a GID when the tile is flipped vertically
+	TileFlipVertical uint32 = 0x40000000
+	// TileFlipDiagonal is set in a GID when the tile is flipped diagonally
+	TileFlipDiagonal uint32 = 0x20000000
+	// tileFlipMask masks the flip flags and the hexagonal rotation flag of a GID
+	tileFlipMask = TileFlipHorizontal | TileFlipVertical | TileFlipDiagonal | 0x10000000
+)
+
+// Level is a level of the game.
+// Positions are in pixels in the game's coordinates, where y points up from the bottom of
+// the level, except for the tiles, which are drawn from the top left like in Tiled.
+type Level struct {
+	// Width is the width of the level in pixels
+	Width int
+	// Height is the height of the level in pixels
+	Height int
+	// TileWidth is the width of a tile of the map in pixels
+	TileWidth int
+	// TileHeight is the height of a tile of the map in pixels
+	TileHeight int
+	// Columns is the width of the map in tiles
+	Columns int
+	// Rows is the height of the map in tiles
+	Rows int
+	// Tilesets are the tilesets of the map in order of their first GID
+	Tilesets []*Tileset
+	// TileLayers are the tile layers of the map in draw order
+	TileLayers []*TileLayer
+	// Solids are the rectangles that are solid on all sides
+	Solids []Rect
+	// Platforms are the rectangles that can be jumped through from below
+	Platforms []Rect
+	// Ladders are the rectangles that can be climbed
+	Ladders []Rect
+	// SpawnPoints are the points where players spawn
+	SpawnPoints []SpawnPoint
+	// NPCSpawners are the points and areas where NPCs spawn
+	NPCSpawners []NPCSpawner
+	// Portals are the rectangles that move players to another zone
+	Portals []Portal
+
+	// fsys is the file system the map and its tilesets were read from
+	fsys fs.FS
+}
+
+// Rect is a rectangle with its position at the bottom left
+type Rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// SpawnPoint is a point where players spawn
+type SpawnPoint struct {
+	Name string
+	// Position is the bottom center of the spawned player
+	Position kinematic.Vector
+}
+
+// NPCSpawner is a point or area where NPCs spawn
+type NPCSpawner struct {
+	// ID is the ID of the object of the spawner in the map
+	ID uint32
+	// Archetype is the ID of the archetype of the NPCs, or empty for the default archetype
+	Archetype string
+	// Position is the bottom center of the spawner
+	Position kinematic.Vector
+	// Width is the width of the area NPCs spawn in, or zero if they spawn at the position
+	Width float64
+	// FlipH is whether the NPCs face left when they spawn
+	FlipH bool
+	// WanderRange is how far the NPCs wander from where they spawn
+	WanderRange float64
+	// Population is the number of NPCs the spawner keeps alive
+	Population int
+	// RespawnTime is how long in seconds after one of its NPCs despawns the spawner replaces it,
+	// or after all of them despawn that it spawns the next wave
+	RespawnTime float64
+	// MaxSpawns is the most NPCs the spawner spawns, or zero for no limit
+	MaxSpawns int
+	// Wave is whether the spawner respawns its whole population at once after all of it has despawned
+	Wave bool
+}
+
+// Portal is a rectangle that moves players who enter it to a spawn point in a zone
+type Portal struct {
+	Rect
+	// Zone is the ID of the zone the portal leads to
+	Zone uint32
+	// Spawn is the name of the spawn point in the zone the portal leads to
+	Spawn string
+}
+
+// Tileset is an image of tiles
+type Tileset struct {
+	// FirstGID is the GID of the first tile of the tileset
+	FirstGID    uint32
+	Name        string
+	TileWidth   int
+	TileHeight  int
+	Spacing     int
+	Margin      int
+	TileCount   int
+	Columns     int
+	ImageWidth  int
+	ImageHeight int
+	// Image is the path of the image of the tileset in the file system of the level
+	Image string
+}
+
+// TileLayer is a layer of tiles
+type TileLayer struct {
+	Name    string
+	Visible bool
+	Opacity float64
+	// OffsetX and OffsetY offset the layer from the top left of the map in pixels
+	OffsetX float64
+	OffsetY float64
+	// ZIndex is the draw order of the layer, from its "zIndex" property or its order in the map
+	ZIndex int
+	// Tiles are the GIDs of the tiles, including their flip flags, row by row from the top left.
+	// A GID of zero is an empty tile.
+	Tiles []uint32
+}
+
+// Load loads a level from a Tiled map in a file system
+func Load(fsys fs.FS, name string) (*Level, error) {
+	m, err := readMap(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	level, err := newLevel(m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid map %s: %v", name, err)
+	}
+	level.fsys = fsys
+	return level, nil
+}
+
+// LoadFile loads a level from a Tiled map file.
+// Tilesets and their images are read relative to the directory of the map.
+func LoadFile(path string) (*Level, error) {
+	return Load(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
+
+// Default returns the level of the default map embedded in the build
+func Default() *Level {
+	level, err := Load(defaultMaps, DefaultMap)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default level: %v", err))
+	}
+	return level
+}
+
+// ReadFile reads a file of the level, such as the image of a tileset
+func (l *Level) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(l.fsys, name)
+}
+
+// PlayerSpawn returns the position of a player spawned at the player spawn point of the level,
+// or at the default starting position if the level has none
+func (l *Level) PlayerSpawn() kinematic.Vector {
+	if position, ok := l.Spawn(PlayerSpawnName); ok {
+		return position
+	}
+	return kinematic.NewVector(constants.PlayerStartingX, constants.PlayerStartingY)
+}
+
+// Spawn returns the position of a player spawned at a named spawn point of the level
+func (l *Level) Spawn(name string) (kinematic.Vector, bool) {
+	for _, spawn := range l.SpawnPoints {
+		if spawn.Name == name {
+			return kinematic.NewVector(spawn.Position.X-constants.PlayerWidth/2, spawn.Position.Y), true
+		}
+	}
+	return kinematic.Vector{}, false
+}
+
+// Tile returns the tileset of a GID and the bounds of its tile in the image of the tileset
+func (l *Level) Tile(gid uint32) (*Tileset, image.Rectangle, bool) {
+	gid &^= tileFlipMask
+	if gid == 0 {
+		return nil, image.Rectangle{}, false
+	}
+	for i := len(l.Tilesets) - 1; i >= 0; i-- {
+		tileset := l.Tilesets[i]
+		if gid < tileset.FirstGID {
+			continue
+		}
+		id := int(gid - tileset.FirstGID)
+		if id >= tileset.TileCount {
+			return nil, image.Rectangle{}, false
+		}
+		x := tileset.Margin + (id%tileset.Columns)*(tileset.TileWidth+tileset.Spacing)
+		y := tileset.Margin + (id/tileset.Columns)*(tileset.TileHeight+tileset.Spacing)
+		return tileset, image.Rect(x, y, x+tileset.TileWidth, y+tileset.TileHeight), true
+	}
+	return nil, image.Rectangle{}, false
+}
+
+// newLevel creates a level from a decoded map
+func newLevel(m *mapData) (*Level, error) {
+	if m.orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported orientation %q", m.orientation)
+	}
+	if m.infinite {
+		return nil, fmt.Errorf("infinite maps are not supported")
+	}
+	if m.width <= 0 || m.height <= 0 || m.tileWidth <= 0 || m.tileHeight <= 0 {
+		return nil, fmt.Errorf("map must have a positive size")
+	}
+
+	level := &Level{
+		Width:      m.width * m.tileWidth,
+		Height:     m.height * m.tileHeight,
+		TileWidth:  m.tileWidth,
+		TileHeight: m.tileHeight,
+		Columns:    m.width,
+		Rows:       m.height,
+	}
+
+	for _, t := range m.tilesets {
+		if t.image == "" || t.columns <= 0 || t.tileWidth <= 0 || t.tileHeight <= 0 {
+			return nil, fmt.Errorf("tileset %q must have an image with at least one column of tiles", t.name)
+		}
+		level.Tilesets = append(level.Tilesets, &Tileset{
+			FirstGID:    t.firstGID,
+			Name:        t.name,
+			TileWidth:   t.tileWidth,
+			TileHeight:  t.tileHeight,
+			Spacing:     t.spacing,
+			Margin:      t.margin,
+			TileCount:   t.tileCount,
+			Columns:     t.columns,
+			ImageWidth:  t.imageWidth,
+			ImageHeight: t.imageHeight,
+			Image:       t.image,
+		})
+	}
+	sort.Slice(level.Tilesets, func(i, j int) bool {
+		return level.Tilesets[i].FirstGID < level.Tilesets[j].FirstGID
+	})
+
+	if err := level.addLayers(m.layers, 0, 0, true); err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+// addLayers adds the layers of a map or group to the level,
+// offset and hidden by the groups they are in
+func (l *Level) addLayers(layers []*layerData, offsetX, offsetY float64, visible bool) error {
+	for _, layer := range layers {
+		layerOffsetX, layerOffsetY := offsetX+layer.offsetX, offsetY+layer.offsetY
+		switch layer.kind {
+		case layerKindTiles:
+			if len(layer.tiles) != l.Columns*l.Rows {
+				return fmt.Errorf("layer %q has %d tiles instead of %d", layer.name, len(layer.tiles), l.Columns*l.Rows)
+			}
+			zIndex := len(l.TileLayers)
+			if value, ok := layer.properties["zIndex"]; ok {
+				var err error
+				if zIndex, err = strconv.Atoi(value); err != nil {
+					return fmt.Errorf("invalid zIndex of layer %q: %v", layer.name, err)
+				}
+			}
+			l.TileLayers = append(l.TileLayers, &TileLayer{
+				Name:    layer.name,
+				Visible: visible && layer.visible,
+				Opacity: layer.opacity,
+				OffsetX: layerOffsetX,
+				OffsetY: layerOffsetY,
+				ZIndex:  zIndex,
+				Tiles:   layer.tiles,
+			})
+		case layerKindObjects:
+			for _, object := range layer.objects {
+				if err := l.addObject(strings.ToLower(layer.name), object, layerOffsetX, layerOffsetY); err != nil {
+					return fmt.Errorf("invalid object %d in layer %q: %v", object.id, layer.name, err)
+				}
+			}
+		case layerKindGroup:
+			if err := l.addLayers(layer.layers, layerOffsetX, layerOffsetY, visible && layer.visible); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addObject adds an object of an object layer to the level,
+// ignoring objects in layers the game does not use
+func (l *Level) addObject(layer string, object *objectData, offsetX, offsetY float64) error {
+	switch layer {
+	case LayerCollision, LayerPlatforms, LayerLadders, LayerSpawns, LayerNPCs, LayerPortals:
+	default:
+		return nil
+	}
+	if object.rotation != 0 {
+		return fmt.Errorf("rotated objects are not supported")
+	}
+	x, y := object.x+offsetX, object.y+offsetY
+	if object.gid != 0 {
+		// tile objects are positioned by their bottom left
+		y -= object.height
+	}
+
+	switch layer {
+	case LayerCollision, LayerPlatforms, LayerLadders, LayerPortals:
+		if object.point || object.width <= 0 || object.height <= 0 {
+			return fmt.Errorf("must be a rectangle")
+		}
+		rect := Rect{
+			X:      x,
+			Y:      float64(l.Height) - y - object.height,
+			Width:  object.width,
+			Height: object.height,
+		}
+		switch layer {
+		case LayerCollision:
+			l.Solids = append(l.Solids, rect)
+		case LayerPlatforms:
+			l.Platforms = append(l.Platforms, rect)
+		case LayerLadders:
+			l.Ladders = append(l.Ladders, rect)
+		case LayerPortals:
+			portal := Portal{Rect: rect, Spawn: PlayerSpawnName}
+			zone, err := strconv.ParseUint(object.properties["zone"], 10, 32)
+			if err != nil || zone == 0 {
+				return fmt.Errorf("portal must have a positive zone")
+			}
+			portal.Zone = uint32(zone)
+			if spawn, ok := object.properties["spawn"]; ok && spawn != "" {
+				portal.Spawn = spawn
+			}
+			l.Portals = append(l.Portals, portal)
+		}
+	case LayerSpawns:
+		l.SpawnPoints = append(l.SpawnPoints, SpawnPoint{
+			Name:     object.name,
+			Position: l.bottomCenter(x, y, object),
+		})
+	case LayerNPCs:
+		spawner := NPCSpawner{
+			ID:          object.id,
+			Archetype:   object.properties["archetype"],
+			Position:    l.bottomCenter(x, y, object),
+			WanderRange: constants.NPCWanderRange,
+			Population:  1,
+			RespawnTime: constants.NPCRespawnTime,
+		}
+		if !object.point && object.gid == 0 {
+			spawner.Width = object.width
+		}
+		if value, ok := object.properties["flip"]; ok {
+			flip, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid flip: %v", err)
+			}
+			spawner.FlipH = flip
+		}
+		if value, ok := object.properties["wanderRange"]; ok {
+			wanderRange, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid wanderRange: %v", err)
+			}
+			spawner.WanderRange = wanderRange
+		}
+		if value, ok := object.properties["population"]; ok {
+			population, err := strconv.Atoi(value)
+			if err != nil || population <= 0 {
+				return fmt.Errorf("population must be a positive integer")
+			}
+			spawner.Population = population
+		}
+		if value, ok := object.properties["respawnTime"]; ok {
+			respawnTime, err := strconv.ParseFloat(value, 64)
+			if err != nil || respawnTime < 0 {
+				return fmt.Errorf("respawnTime must be a number that is not negative")
+			}
+			spawner.RespawnTime = respawnTime
+		}
+		if value, ok := object.properties["maxSpawns"]; ok {
+			maxSpawns, err := strconv.Atoi(value)
+			if err != nil || maxSpawns < 0 {
+				return fmt.Errorf("maxSpawns must be an integer that is not negative")
+			}
+			spawner.MaxSpawns = maxSpawns
+		}
+		if value, ok := object.properties["wave"]; ok {
+			wave, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid wave: %v", err)
+			}
+			spawner.Wave = wave
+		}
+		l.NPCSpawners = append(l.NPCSpawners, spawner)
+	}
+	return nil
+}
+
+// bottomCenter returns the bottom center of an object with its top left at x and y in the map
+func (l *Level) bottomCenter(x, y float64, object *objectData) kinematic.Vector {
+	return kinematic.NewVector(x+object.width/2, float64(l.Height)-y-object.height)
+}
@@ -0,0 +1,217 @@
+package levels
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	level := Default()
+
+	assert.Equal(t, 1280, level.Width)
+	assert.Equal(t, 480, level.Height)
+	assert.Len(t, level.Solids, 4)
+	assert.Len(t, level.Platforms, 3)
+	assert.Equal(t, []Rect{{X: 952, Y: 96, Width: 16, Height: 208}}, level.Ladders)
+	assert.Equal(t, kinematic.NewVector(constants.PlayerStartingX, 208), level.PlayerSpawn())
+	if assert.Len(t, level.NPCSpawners, 4) {
+		assert.Equal(t, NPCSpawner{ID: 3, Position: kinematic.NewVector(896, 16), FlipH: true, WanderRange: constants.NPCWanderRange, Population: 1, RespawnTime: constants.NPCRespawnTime}, level.NPCSpawners[2])
+	}
+
+	for _, layer := range level.TileLayers {
+		assert.Len(t, layer.Tiles, level.Columns*level.Rows)
+	}
+	tileset, bounds, ok := level.Tile(4 | TileFlipHorizontal)
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(48, 0, 64, 16), bounds)
+	_, err := level.ReadFile(tileset.Image)
+	assert.NoError(t, err)
+}
+
+// testTMX is a 4x3 map with 8x8 tiles
+const testTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" renderorder="right-down" width="4" height="3" tilewidth="8" tileheight="8" infinite="0">
+ <tileset firstgid="1" source="tilesets/tiles.tsx"/>
+ <layer id="1" name="csv" width="4" height="3">
+  <properties>
+   <property name="zIndex" type="int" value="12"/>
+  </properties>
+  <data encoding="csv">
+1,1,1,1,
+0,0,0,0,
+2,2,2,2
+</data>
+ </layer>
+ <group id="2" name="group" offsetx="4" visible="0">
+  <layer id="3" name="base64" width="4" height="3" opacity="0.5">
+   <data encoding="base64" compression="zlib">%s</data>
+  </layer>
+ </group>
+ <objectgroup id="4" name="Collision">
+  <object id="5" x="0" y="16" width="32" height="8"/>
+ </objectgroup>
+ <objectgroup id="6" name="platforms" offsety="-8">
+  <object id="7" gid="2" x="8" y="16" width="8" height="8"/>
+ </objectgroup>
+ <objectgroup id="8" name="spawns">
+  <object id="9" name="player" x="16" y="16">
+   <point/>
+  </object>
+ </objectgroup>
+ <objectgroup id="10" name="npcs">
+  <object id="11" x="24" y="16">
+   <properties>
+    <property name="flip" type="bool" value="true"/>
+    <property name="wanderRange" type="float" value="10.5"/>
+   </properties>
+   <point/>
+  </object>
+  <object id="14" x="0" y="8" width="16" height="8">
+   <properties>
+    <property name="archetype" value="skeleton-warrior"/>
+    <property name="population" type="int" value="3"/>
+    <property name="respawnTime" type="float" value="2.5"/>
+    <property name="maxSpawns" type="int" value="6"/>
+    <property name="wave" type="bool" value="true"/>
+   </properties>
+  </object>
+ </objectgroup>
+ <objectgroup id="12" name="decoration">
+  <object id="13" x="0" y="0" width="1" height="1" rotation="45"/>
+ </objectgroup>
+</map>`
+
+const testTSX = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="tiles" tilewidth="8" tileheight="8" spacing="1" margin="1" tilecount="4" columns="2">
+ <image source="../images/tiles.png" width="19" height="19"/>
+</tileset>`
+
+func zlibTiles(t *testing.T, tiles []uint32) string {
+	var raw bytes.Buffer
+	assert.NoError(t, binary.Write(&raw, binary.LittleEndian, tiles))
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	_, err := w.Write(raw.Bytes())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return base64.StdEncoding.EncodeToString(compressed.Bytes())
+}
+
+func TestLoad_TMX(t *testing.T) {
+	base64Tiles := []uint32{0, 0, 0, 0, 3 | TileFlipVertical, 0, 0, 0, 0, 0, 0, 4}
+	fsys := fstest.MapFS{
+		"maps/test.tmx":           {Data: []byte(fmt.Sprintf(testTMX, zlibTiles(t, base64Tiles)))},
+		"maps/tilesets/tiles.tsx": {Data: []byte(testTSX)},
+		"maps/images/tiles.png":   {Data: []byte("png")},
+	}
+
+	level, err := Load(fsys, "maps/test.tmx")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 32, level.Width)
+	assert.Equal(t, 24, level.Height)
+	if assert.Len(t, level.Tilesets, 1) {
+		assert.Equal(t, "maps/images/tiles.png", level.Tilesets[0].Image)
+		b, err := level.ReadFile(level.Tilesets[0].Image)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("png"), b)
+	}
+	_, bounds, ok := level.Tile(4)
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(10, 10, 18, 18), bounds)
+	_, _, ok = level.Tile(5)
+	assert.False(t, ok)
+
+	if assert.Len(t, level.TileLayers, 2) {
+		assert.Equal(t, &TileLayer{Name: "csv", Visible: true, Opacity: 1, ZIndex: 12, Tiles: []uint32{1, 1, 1, 1, 0, 0, 0, 0, 2, 2, 2, 2}}, level.TileLayers[0])
+		assert.Equal(t, &TileLayer{Name: "base64", Visible: false, Opacity: 0.5, OffsetX: 4, ZIndex: 1, Tiles: base64Tiles}, level.TileLayers[1])
+	}
+
+	// object positions are flipped so that y points up from the bottom of the level
+	assert.Equal(t, []Rect{{X: 0, Y: 0, Width: 32, Height: 8}}, level.Solids)
+	assert.Equal(t, []Rect{{X: 8, Y: 16, Width: 8, Height: 8}}, level.Platforms)
+	assert.Equal(t, []SpawnPoint{{Name: "player", Position: kinematic.NewVector(16, 8)}}, level.SpawnPoints)
+	assert.Equal(t, kinematic.NewVector(16-constants.PlayerWidth/2, 8), level.PlayerSpawn())
+	assert.Equal(t, []NPCSpawner{
+		{ID: 11, Position: kinematic.NewVector(24, 8), FlipH: true, WanderRange: 10.5, Population: 1, RespawnTime: constants.NPCRespawnTime},
+		{ID: 14, Archetype: "skeleton-warrior", Position: kinematic.NewVector(8, 8), Width: 16, WanderRange: constants.NPCWanderRange, Population: 3, RespawnTime: 2.5, MaxSpawns: 6, Wave: true},
+	}, level.NPCSpawners)
+}
+
+func TestLoad_JSON(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test.tmj": {Data: []byte(`{
+			"orientation": "orthogonal", "width": 2, "height": 1, "tilewidth": 16, "tileheight": 16,
+			"tilesets": [{"firstgid": 1, "name": "tiles", "tilewidth": 16, "tileheight": 16, "tilecount": 1, "columns": 1, "image": "tiles.png"}],
+			"layers": [
+				{"type": "tilelayer", "name": "tiles", "visible": true, "opacity": 1, "encoding": "base64", "data": "AQAAAAAAAIA="},
+				{"type": "objectgroup", "name": "ladders", "visible": true, "opacity": 1, "objects": [{"id": 1, "x": 0, "y": 0, "width": 16, "height": 16}]}
+			]
+		}`)},
+	}
+
+	level, err := Load(fsys, "test.tmj")
+	assert.NoError(t, err)
+	assert.Equal(t, "tiles.png", level.Tilesets[0].Image)
+	assert.Equal(t, []uint32{1, TileFlipHorizontal}, level.TileLayers[0].Tiles)
+	assert.Equal(t, []Rect{{X: 0, Y: 0, Width: 16, Height: 16}}, level.Ladders)
+	assert.Equal(t, kinematic.NewVector(constants.PlayerStartingX, constants.PlayerStartingY), level.PlayerSpawn())
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	const layers = `"layers": [{"type": "objectgroup", "name": "%s", "visible": true, "objects": [%s]}]`
+	tests := []struct {
+		name string
+		json string
+	}{
+		{
+			name: "isometric",
+			json: `{"orientation": "isometric", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16}`,
+		},
+		{
+			name: "infinite",
+			json: `{"orientation": "orthogonal", "infinite": true, "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16}`,
+		},
+		{
+			name: "missing tiles",
+			json: `{"orientation": "orthogonal", "width": 2, "height": 1, "tilewidth": 16, "tileheight": 16, "layers": [{"type": "tilelayer", "name": "tiles", "data": [1]}]}`,
+		},
+		{
+			name: "missing tileset",
+			json: `{"orientation": "orthogonal", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16, "tilesets": [{"firstgid": 1, "source": "missing.tsj"}]}`,
+		},
+		{
+			name: "collision point",
+			json: `{"orientation": "orthogonal", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16, ` + fmt.Sprintf(layers, "collision", `{"id": 1, "point": true}`) + `}`,
+		},
+		{
+			name: "rotated platform",
+			json: `{"orientation": "orthogonal", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16, ` + fmt.Sprintf(layers, "platforms", `{"id": 1, "width": 16, "height": 16, "rotation": 90}`) + `}`,
+		},
+		{
+			name: "invalid npc property",
+			json: `{"orientation": "orthogonal", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16, ` + fmt.Sprintf(layers, "npcs", `{"id": 1, "point": true, "properties": [{"name": "flip", "type": "string", "value": "left"}]}`) + `}`,
+		},
+		{
+			name: "empty npc population",
+			json: `{"orientation": "orthogonal", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16, ` + fmt.Sprintf(layers, "npcs", `{"id": 1, "point": true, "properties": [{"name": "population", "type": "int", "value": 0}]}`) + `}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(fstest.MapFS{"test.tmj": {Data: []byte(tt.json)}}, "test.tmj")
+			assert.Error(t, err)
+		})
+	}
+}
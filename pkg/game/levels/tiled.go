@@ -0,0 +1,629 @@
+package levels
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// mapData is a Tiled map decoded from either the JSON or the TMX format
+type mapData struct {
+	orientation string
+	infinite    bool
+	width       int
+	height      int
+	tileWidth   int
+	tileHeight  int
+	tilesets    []*tilesetData
+	layers      []*layerData
+}
+
+// tilesetData is a Tiled tileset, with its image path resolved within the file system of the map
+type tilesetData struct {
+	firstGID uint32
+	// source is the path of an external tileset, relative to the map
+	source      string
+	name        string
+	tileWidth   int
+	tileHeight  int
+	spacing     int
+	margin      int
+	tileCount   int
+	columns     int
+	image       string
+	imageWidth  int
+	imageHeight int
+}
+
+// layerData is a tile layer, object group or group of layers of a Tiled map
+type layerData struct {
+	kind       string
+	name       string
+	visible    bool
+	opacity    float64
+	offsetX    float64
+	offsetY    float64
+	properties map[string]string
+	tiles      []uint32
+	objects    []*objectData
+	layers     []*layerData
+}
+
+// objectData is an object of a Tiled object group
+type objectData struct {
+	id         uint32
+	name       string
+	class      string
+	x          float64
+	y          float64
+	width      float64
+	height     float64
+	rotation   float64
+	gid        uint32
+	point      bool
+	properties map[string]string
+}
+
+const (
+	layerKindTiles   = "tilelayer"
+	layerKindObjects = "objectgroup"
+	layerKindGroup   = "group"
+)
+
+// readMap reads a Tiled map in the JSON (.tmj, .json) or TMX (.tmx) format
+func readMap(fsys fs.FS, name string) (*mapData, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map: %v", err)
+	}
+
+	var m *mapData
+	switch path.Ext(name) {
+	case ".tmj", ".json":
+		m, err = decodeJSONMap(b)
+	case ".tmx":
+		m, err = decodeTMXMap(b)
+	default:
+		return nil, fmt.Errorf("unsupported map format %q", path.Ext(name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode map: %v", err)
+	}
+
+	dir := path.Dir(name)
+	for i, tileset := range m.tilesets {
+		if tileset.source == "" {
+			tileset.image = path.Join(dir, tileset.image)
+			continue
+		}
+		// the image of an external tileset is relative to the tileset
+		external, err := readTileset(fsys, path.Join(dir, tileset.source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tileset %s: %v", tileset.source, err)
+		}
+		external.firstGID = tileset.firstGID
+		m.tilesets[i] = external
+	}
+	return m, nil
+}
+
+// readTileset reads an external Tiled tileset in the JSON (.tsj, .json) or TSX (.tsx) format
+func readTileset(fsys fs.FS, name string) (*tilesetData, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var tileset *tilesetData
+	switch path.Ext(name) {
+	case ".tsj", ".json":
+		var t jsonTileset
+		if err := json.Unmarshal(b, &t); err != nil {
+			return nil, err
+		}
+		tileset = t.data()
+	case ".tsx":
+		var t tmxTileset
+		if err := xml.Unmarshal(b, &t); err != nil {
+			return nil, err
+		}
+		tileset = t.data()
+	default:
+		return nil, fmt.Errorf("unsupported tileset format %q", path.Ext(name))
+	}
+	if tileset.image == "" {
+		return nil, fmt.Errorf("tileset has no image")
+	}
+	tileset.image = path.Join(path.Dir(name), tileset.image)
+	return tileset, nil
+}
+
+// decodeTiles decodes the GIDs of the tiles of a layer from its CSV or base64 encoded data
+func decodeTiles(encoding, compression, data string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var tiles []uint32
+		for _, field := range strings.Split(data, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile %q: %v", field, err)
+			}
+			tiles = append(tiles, uint32(gid))
+		}
+		return tiles, nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64: %v", err)
+		}
+		if b, err = decompressTiles(compression, b); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %v", compression, err)
+		}
+		if len(b)%4 != 0 {
+			return nil, fmt.Errorf("tile data is %d bytes, which is not a multiple of 4", len(b))
+		}
+		tiles := make([]uint32, len(b)/4)
+		for i := range tiles {
+			tiles[i] = binary.LittleEndian.Uint32(b[i*4:])
+		}
+		return tiles, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+func decompressTiles(compression string, b []byte) ([]byte, error) {
+	var r io.Reader
+	switch compression {
+	case "":
+		return b, nil
+	case "zlib":
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported compression")
+	}
+	return io.ReadAll(r)
+}
+
+// JSON format
+
+type jsonMap struct {
+	Orientation string         `json:"orientation"`
+	Infinite    bool           `json:"infinite"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	TileWidth   int            `json:"tilewidth"`
+	TileHeight  int            `json:"tileheight"`
+	Tilesets    []*jsonTileset `json:"tilesets"`
+	Layers      []*jsonLayer   `json:"layers"`
+}
+
+type jsonTileset struct {
+	FirstGID    uint32 `json:"firstgid"`
+	Source      string `json:"source"`
+	Name        string `json:"name"`
+	TileWidth   int    `json:"tilewidth"`
+	TileHeight  int    `json:"tileheight"`
+	Spacing     int    `json:"spacing"`
+	Margin      int    `json:"margin"`
+	TileCount   int    `json:"tilecount"`
+	Columns     int    `json:"columns"`
+	Image       string `json:"image"`
+	ImageWidth  int    `json:"imagewidth"`
+	ImageHeight int    `json:"imageheight"`
+}
+
+type jsonLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Visible     bool            `json:"visible"`
+	Opacity     float64         `json:"opacity"`
+	OffsetX     float64         `json:"offsetx"`
+	OffsetY     float64         `json:"offsety"`
+	Properties  []jsonProperty  `json:"properties"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Data        json.RawMessage `json:"data"`
+	Objects     []*jsonObject   `json:"objects"`
+	Layers      []*jsonLayer    `json:"layers"`
+}
+
+type jsonObject struct {
+	ID         uint32         `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Rotation   float64        `json:"rotation"`
+	GID        uint32         `json:"gid"`
+	Point      bool           `json:"point"`
+	Properties []jsonProperty `json:"properties"`
+}
+
+type jsonProperty struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+func decodeJSONMap(b []byte) (*mapData, error) {
+	var jm jsonMap
+	if err := json.Unmarshal(b, &jm); err != nil {
+		return nil, err
+	}
+
+	m := &mapData{
+		orientation: jm.Orientation,
+		infinite:    jm.Infinite,
+		width:       jm.Width,
+		height:      jm.Height,
+		tileWidth:   jm.TileWidth,
+		tileHeight:  jm.TileHeight,
+	}
+	for _, t := range jm.Tilesets {
+		m.tilesets = append(m.tilesets, t.data())
+	}
+	layers, err := decodeJSONLayers(jm.Layers)
+	if err != nil {
+		return nil, err
+	}
+	m.layers = layers
+	return m, nil
+}
+
+// data returns the tileset, or a reference to an external tileset named by its source
+func (t *jsonTileset) data() *tilesetData {
+	if t.Source != "" {
+		return &tilesetData{firstGID: t.FirstGID, source: t.Source}
+	}
+	return &tilesetData{
+		firstGID:    t.FirstGID,
+		name:        t.Name,
+		tileWidth:   t.TileWidth,
+		tileHeight:  t.TileHeight,
+		spacing:     t.Spacing,
+		margin:      t.Margin,
+		tileCount:   t.TileCount,
+		columns:     t.Columns,
+		image:       t.Image,
+		imageWidth:  t.ImageWidth,
+		imageHeight: t.ImageHeight,
+	}
+}
+
+func decodeJSONLayers(jsonLayers []*jsonLayer) ([]*layerData, error) {
+	var layers []*layerData
+	for _, jl := range jsonLayers {
+		layer := &layerData{
+			kind:       jl.Type,
+			name:       jl.Name,
+			visible:    jl.Visible,
+			opacity:    jl.Opacity,
+			offsetX:    jl.OffsetX,
+			offsetY:    jl.OffsetY,
+			properties: jsonProperties(jl.Properties),
+		}
+		switch jl.Type {
+		case layerKindTiles:
+			tiles, err := decodeJSONTiles(jl)
+			if err != nil {
+				return nil, fmt.Errorf("invalid data in layer %q: %v", jl.Name, err)
+			}
+			layer.tiles = tiles
+		case layerKindObjects:
+			for _, jo := range jl.Objects {
+				class := jo.Class
+				if class == "" {
+					class = jo.Type
+				}
+				layer.objects = append(layer.objects, &objectData{
+					id:         jo.ID,
+					name:       jo.Name,
+					class:      class,
+					x:          jo.X,
+					y:          jo.Y,
+					width:      jo.Width,
+					height:     jo.Height,
+					rotation:   jo.Rotation,
+					gid:        jo.GID,
+					point:      jo.Point,
+					properties: jsonProperties(jo.Properties),
+				})
+			}
+		case layerKindGroup:
+			children, err := decodeJSONLayers(jl.Layers)
+			if err != nil {
+				return nil, err
+			}
+			layer.layers = children
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+func decodeJSONTiles(jl *jsonLayer) ([]uint32, error) {
+	if jl.Encoding == "base64" {
+		var data string
+		if err := json.Unmarshal(jl.Data, &data); err != nil {
+			return nil, err
+		}
+		return decodeTiles(jl.Encoding, jl.Compression, data)
+	}
+	if len(jl.Data) == 0 {
+		return nil, fmt.Errorf("missing data (infinite maps are not supported)")
+	}
+	var tiles []uint32
+	if err := json.Unmarshal(jl.Data, &tiles); err != nil {
+		return nil, err
+	}
+	return tiles, nil
+}
+
+func jsonProperties(properties []jsonProperty) map[string]string {
+	if len(properties) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(properties))
+	for _, p := range properties {
+		m[p.Name] = fmt.Sprint(p.Value)
+	}
+	return m
+}
+
+// TMX format
+
+type tmxMap struct {
+	Orientation string       `xml:"orientation,attr"`
+	Infinite    int          `xml:"infinite,attr"`
+	Width       int          `xml:"width,attr"`
+	Height      int          `xml:"height,attr"`
+	TileWidth   int          `xml:"tilewidth,attr"`
+	TileHeight  int          `xml:"tileheight,attr"`
+	Tilesets    []tmxTileset `xml:"tileset"`
+	// Layers are the layers of any kind, in order
+	Layers []tmxLayer `xml:",any"`
+}
+
+type tmxTileset struct {
+	FirstGID   uint32 `xml:"firstgid,attr"`
+	Source     string `xml:"source,attr"`
+	Name       string `xml:"name,attr"`
+	TileWidth  int    `xml:"tilewidth,attr"`
+	TileHeight int    `xml:"tileheight,attr"`
+	Spacing    int    `xml:"spacing,attr"`
+	Margin     int    `xml:"margin,attr"`
+	TileCount  int    `xml:"tilecount,attr"`
+	Columns    int    `xml:"columns,attr"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+		Width  int    `xml:"width,attr"`
+		Height int    `xml:"height,attr"`
+	} `xml:"image"`
+}
+
+type tmxLayer struct {
+	XMLName    xml.Name
+	Name       string        `xml:"name,attr"`
+	Visible    string        `xml:"visible,attr"`
+	Opacity    string        `xml:"opacity,attr"`
+	OffsetX    float64       `xml:"offsetx,attr"`
+	OffsetY    float64       `xml:"offsety,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Data       *struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Content     string `xml:",chardata"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+		Chunks []struct{} `xml:"chunk"`
+	} `xml:"data"`
+	Objects []tmxObject `xml:"object"`
+	Layers  []tmxLayer  `xml:",any"`
+}
+
+type tmxObject struct {
+	ID         uint32        `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Point      *struct{}     `xml:"point"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxProperty struct {
+	Name    string `xml:"name,attr"`
+	Value   string `xml:"value,attr"`
+	Content string `xml:",chardata"`
+}
+
+// tmxLayerKinds maps the TMX element names of layers to their kinds
+var tmxLayerKinds = map[string]string{
+	"layer":       layerKindTiles,
+	"objectgroup": layerKindObjects,
+	"group":       layerKindGroup,
+	"imagelayer":  "imagelayer",
+}
+
+func decodeTMXMap(b []byte) (*mapData, error) {
+	var tm tmxMap
+	if err := xml.Unmarshal(b, &tm); err != nil {
+		return nil, err
+	}
+
+	m := &mapData{
+		orientation: tm.Orientation,
+		infinite:    tm.Infinite != 0,
+		width:       tm.Width,
+		height:      tm.Height,
+		tileWidth:   tm.TileWidth,
+		tileHeight:  tm.TileHeight,
+	}
+	for _, t := range tm.Tilesets {
+		m.tilesets = append(m.tilesets, t.data())
+	}
+	layers, err := decodeTMXLayers(tm.Layers)
+	if err != nil {
+		return nil, err
+	}
+	m.layers = layers
+	return m, nil
+}
+
+// data returns the tileset, or a reference to an external tileset named by its source
+func (t *tmxTileset) data() *tilesetData {
+	if t.Source != "" {
+		return &tilesetData{firstGID: t.FirstGID, source: t.Source}
+	}
+	return &tilesetData{
+		firstGID:    t.FirstGID,
+		name:        t.Name,
+		tileWidth:   t.TileWidth,
+		tileHeight:  t.TileHeight,
+		spacing:     t.Spacing,
+		margin:      t.Margin,
+		tileCount:   t.TileCount,
+		columns:     t.Columns,
+		image:       t.Image.Source,
+		imageWidth:  t.Image.Width,
+		imageHeight: t.Image.Height,
+	}
+}
+
+func decodeTMXLayers(tmxLayers []tmxLayer) ([]*layerData, error) {
+	var layers []*layerData
+	for _, tl := range tmxLayers {
+		kind, ok := tmxLayerKinds[tl.XMLName.Local]
+		if !ok {
+			// not a layer, such as the editor settings or properties of the map
+			continue
+		}
+		layer := &layerData{
+			kind:       kind,
+			name:       tl.Name,
+			visible:    tl.Visible != "0",
+			opacity:    1,
+			offsetX:    tl.OffsetX,
+			offsetY:    tl.OffsetY,
+			properties: tmxProperties(tl.Properties),
+		}
+		if tl.Opacity != "" {
+			opacity, err := strconv.ParseFloat(tl.Opacity, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid opacity of layer %q: %v", tl.Name, err)
+			}
+			layer.opacity = opacity
+		}
+		switch kind {
+		case layerKindTiles:
+			tiles, err := decodeTMXTiles(&tl)
+			if err != nil {
+				return nil, fmt.Errorf("invalid data in layer %q: %v", tl.Name, err)
+			}
+			layer.tiles = tiles
+		case layerKindObjects:
+			for _, to := range tl.Objects {
+				class := to.Class
+				if class == "" {
+					class = to.Type
+				}
+				layer.objects = append(layer.objects, &objectData{
+					id:         to.ID,
+					name:       to.Name,
+					class:      class,
+					x:          to.X,
+					y:          to.Y,
+					width:      to.Width,
+					height:     to.Height,
+					rotation:   to.Rotation,
+					gid:        to.GID,
+					point:      to.Point != nil,
+					properties: tmxProperties(to.Properties),
+				})
+			}
+		case layerKindGroup:
+			children, err := decodeTMXLayers(tl.Layers)
+			if err != nil {
+				return nil, err
+			}
+			layer.layers = children
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+func decodeTMXTiles(tl *tmxLayer) ([]uint32, error) {
+	if tl.Data == nil || len(tl.Data.Chunks) > 0 {
+		return nil, fmt.Errorf("missing data (infinite maps are not supported)")
+	}
+	if tl.Data.Encoding == "" {
+		// the deprecated XML encoding with an element per tile
+		tiles := make([]uint32, len(tl.Data.Tiles))
+		for i, tile := range tl.Data.Tiles {
+			tiles[i] = tile.GID
+		}
+		return tiles, nil
+	}
+	return decodeTiles(tl.Data.Encoding, tl.Data.Compression, tl.Data.Content)
+}
+
+func tmxProperties(properties []tmxProperty) map[string]string {
+	if len(properties) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(properties))
+	for _, p := range properties {
+		value := p.Value
+		if value == "" {
+			// multiline string properties are stored as the content of the element
+			value = p.Content
+		}
+		m[p.Name] = value
+	}
+	return m
+}
@@ -0,0 +1,141 @@
+package levels
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+)
+
+// DefaultWorldFile is the path of the default world in the embedded maps
+const DefaultWorldFile = "maps/world.json"
+
+// World is the zones of the game
+type World struct {
+	// StartZone is the ID of the zone new players start in
+	StartZone uint32
+	// Zones are the zones of the world in ascending order of their IDs
+	Zones []*Zone
+}
+
+// Zone is an area of the world with a level of its own
+type Zone struct {
+	// ID is the ID of the zone, which is saved with the players in it
+	ID    uint32
+	Name  string
+	Level *Level
+}
+
+type jsonWorld struct {
+	StartZone uint32      `json:"startZone"`
+	Zones     []*jsonZone `json:"zones"`
+}
+
+type jsonZone struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+	// Map is the path of the Tiled map of the zone relative to the world file
+	Map string `json:"map"`
+}
+
+// LoadWorld loads a world from a JSON file of its zones in a file system,
+// along with the maps of the zones
+func LoadWorld(fsys fs.FS, name string) (*World, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read world %s: %v", name, err)
+	}
+	var w jsonWorld
+	if err := json.Unmarshal(b, &w); err != nil {
+		return nil, fmt.Errorf("failed to decode world %s: %v", name, err)
+	}
+
+	world := &World{StartZone: w.StartZone}
+	for _, z := range w.Zones {
+		if z.ID == 0 {
+			return nil, fmt.Errorf("zone %q must have a positive ID", z.Name)
+		}
+		if world.Zone(z.ID) != nil {
+			return nil, fmt.Errorf("duplicate zone %d", z.ID)
+		}
+		level, err := Load(fsys, path.Join(path.Dir(name), z.Map))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load zone %d: %v", z.ID, err)
+		}
+		world.Zones = append(world.Zones, &Zone{
+			ID:    z.ID,
+			Name:  z.Name,
+			Level: level,
+		})
+	}
+	sort.Slice(world.Zones, func(i, j int) bool {
+		return world.Zones[i].ID < world.Zones[j].ID
+	})
+
+	if world.Zone(world.StartZone) == nil {
+		return nil, fmt.Errorf("start zone %d does not exist", world.StartZone)
+	}
+	for _, zone := range world.Zones {
+		for _, portal := range zone.Level.Portals {
+			to := world.Zone(portal.Zone)
+			if to == nil {
+				return nil, fmt.Errorf("portal in zone %d leads to zone %d, which does not exist", zone.ID, portal.Zone)
+			}
+			if _, ok := to.Level.Spawn(portal.Spawn); !ok {
+				return nil, fmt.Errorf("portal in zone %d leads to spawn point %q, which zone %d does not have", zone.ID, portal.Spawn, to.ID)
+			}
+		}
+	}
+	return world, nil
+}
+
+// LoadWorldFile loads a world from a JSON file of its zones.
+// The maps of the zones are read relative to the directory of the file.
+func LoadWorldFile(path string) (*World, error) {
+	return LoadWorld(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
+
+// DefaultWorld returns the world embedded in the build
+func DefaultWorld() *World {
+	world, err := LoadWorld(defaultMaps, DefaultWorldFile)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default world: %v", err))
+	}
+	return world
+}
+
+var active atomic.Pointer[World]
+
+func init() {
+	active.Store(DefaultWorld())
+}
+
+// Active returns the world the game is played in
+func Active() *World {
+	return active.Load()
+}
+
+// SetActive replaces the world the game is played in.
+// It must be called before the game starts, and the client and server must use the same world.
+func SetActive(world *World) {
+	active.Store(world)
+}
+
+// Zone returns the zone with an ID, or nil if the world has none
+func (w *World) Zone(id uint32) *Zone {
+	for _, zone := range w.Zones {
+		if zone.ID == id {
+			return zone
+		}
+	}
+	return nil
+}
+
+// Start returns the zone new players start in
+func (w *World) Start() *Zone {
+	return w.Zone(w.StartZone)
+}
@@ -0,0 +1,91 @@
+package levels
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultWorld(t *testing.T) {
+	world := DefaultWorld()
+
+	assert.Equal(t, uint32(1), world.StartZone)
+	if assert.Len(t, world.Zones, 2) {
+		assert.Equal(t, "Meadow", world.Zones[0].Name)
+		assert.Equal(t, "Cave", world.Zones[1].Name)
+	}
+	assert.Same(t, world.Zones[0], world.Start())
+	assert.Nil(t, world.Zone(3))
+
+	if assert.Len(t, world.Start().Level.Portals, 1) {
+		portal := world.Start().Level.Portals[0]
+		assert.Equal(t, Portal{Rect: Rect{X: 1216, Y: 16, Width: 32, Height: 64}, Zone: 2, Spawn: "player"}, portal)
+	}
+	position, ok := world.Zone(2).Level.Spawn("player")
+	assert.True(t, ok)
+	assert.Equal(t, kinematic.NewVector(96, 16), position)
+}
+
+func TestLoadWorld_Invalid(t *testing.T) {
+	const portalMap = `{"orientation": "orthogonal", "width": 4, "height": 4, "tilewidth": 16, "tileheight": 16, "layers": [
+		{"type": "objectgroup", "name": "portals", "visible": true, "objects": [{"id": 1, "width": 16, "height": 32, "properties": [%s]}]},
+		{"type": "objectgroup", "name": "spawns", "visible": true, "objects": [{"id": 2, "name": "player", "point": true}]}
+	]}`
+	tests := []struct {
+		name     string
+		world    string
+		portal   string
+		wantLoad bool
+	}{
+		{
+			name:     "valid",
+			world:    `{"startZone": 1, "zones": [{"id": 1, "map": "a.tmj"}, {"id": 2, "map": "b.tmj"}]}`,
+			portal:   `{"name": "zone", "type": "int", "value": 2}`,
+			wantLoad: true,
+		},
+		{
+			name:   "missing start zone",
+			world:  `{"startZone": 3, "zones": [{"id": 1, "map": "a.tmj"}, {"id": 2, "map": "b.tmj"}]}`,
+			portal: `{"name": "zone", "type": "int", "value": 2}`,
+		},
+		{
+			name:   "duplicate zone",
+			world:  `{"startZone": 1, "zones": [{"id": 1, "map": "a.tmj"}, {"id": 1, "map": "b.tmj"}]}`,
+			portal: `{"name": "zone", "type": "int", "value": 1}`,
+		},
+		{
+			name:   "portal to missing zone",
+			world:  `{"startZone": 1, "zones": [{"id": 1, "map": "a.tmj"}, {"id": 2, "map": "b.tmj"}]}`,
+			portal: `{"name": "zone", "type": "int", "value": 3}`,
+		},
+		{
+			name:   "portal to missing spawn point",
+			world:  `{"startZone": 1, "zones": [{"id": 1, "map": "a.tmj"}, {"id": 2, "map": "b.tmj"}]}`,
+			portal: `{"name": "zone", "type": "int", "value": 2}, {"name": "spawn", "type": "string", "value": "cave"}`,
+		},
+		{
+			name:   "portal without zone",
+			world:  `{"startZone": 1, "zones": [{"id": 1, "map": "a.tmj"}, {"id": 2, "map": "b.tmj"}]}`,
+			portal: ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"maps/world.json": {Data: []byte(tt.world)},
+				"maps/a.tmj":      {Data: []byte(fmt.Sprintf(portalMap, tt.portal))},
+				"maps/b.tmj":      {Data: []byte(fmt.Sprintf(portalMap, `{"name": "zone", "type": "int", "value": 1}`))},
+			}
+			_, err := LoadWorld(fsys, "maps/world.json")
+			if tt.wantLoad {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package npcs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/behaviors"
+)
+
+//go:embed npcs.json
+var defaultArchetypes []byte
+
+// Archetype is a kind of NPC
+type Archetype struct {
+	// ID uniquely identifies the archetype
+	ID string `json:"id"`
+	// Name is the name shown above the NPCs of the archetype
+	Name string `json:"name"`
+	// Sprite is the key of the animations the client draws the NPCs of the archetype with
+	Sprite string `json:"sprite"`
+	// Hitpoints is the amount of hitpoints the NPCs spawn with
+	Hitpoints int16 `json:"hitpoints"`
+	// Speed is the speed at which the NPCs move
+	Speed float64 `json:"speed"`
+	// LineOfSight is how far ahead the NPCs see players
+	LineOfSight float64 `json:"lineOfSight"`
+	// AttackRange is how close in front of the NPCs players have to be for them to attack
+	AttackRange float64 `json:"attackRange"`
+	// Behavior is the ID of the behavior that decides what the NPCs do,
+	// or empty for the default behavior
+	Behavior string `json:"behavior"`
+	// Abilities are the IDs of the abilities the NPCs choose from when they attack,
+	// or empty for the abilities of NPCs in the set of abilities
+	Abilities []string `json:"abilities"`
+}
+
+// Set is a set of NPC archetypes
+type Set struct {
+	// Archetypes are the archetypes of the set
+	Archetypes []*Archetype `json:"archetypes"`
+	// Default is the ID of the archetype of NPCs spawned without one
+	Default string `json:"default"`
+
+	byID map[string]*Archetype
+}
+
+// Parse parses and validates a set of NPC archetypes from JSON
+func Parse(b []byte) (*Set, error) {
+	set := &Set{}
+	if err := json.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal npc archetypes: %v", err)
+	}
+
+	set.byID = make(map[string]*Archetype, len(set.Archetypes))
+	for _, archetype := range set.Archetypes {
+		if err := archetype.validate(); err != nil {
+			return nil, fmt.Errorf("invalid archetype %q: %v", archetype.ID, err)
+		}
+		if _, ok := set.byID[archetype.ID]; ok {
+			return nil, fmt.Errorf("duplicate archetype %q", archetype.ID)
+		}
+		set.byID[archetype.ID] = archetype
+	}
+
+	if _, ok := set.byID[set.Default]; !ok {
+		return nil, fmt.Errorf("unknown default archetype %q", set.Default)
+	}
+	return set, nil
+}
+
+// LoadFile loads a set of NPC archetypes from a JSON file
+func LoadFile(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read npc archetypes file: %v", err)
+	}
+	return Parse(b)
+}
+
+// Default returns the set of NPC archetypes embedded in the build
+func Default() *Set {
+	set, err := Parse(defaultArchetypes)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default npc archetypes: %v", err))
+	}
+	return set
+}
+
+func (a *Archetype) validate() error {
+	switch {
+	case a.ID == "":
+		return fmt.Errorf("missing id")
+	case a.Sprite == "":
+		return fmt.Errorf("missing sprite")
+	case a.Hitpoints <= 0:
+		return fmt.Errorf("hitpoints must be positive")
+	case a.Speed < 0:
+		return fmt.Errorf("speed must not be negative")
+	case a.LineOfSight < 0 || a.AttackRange < 0:
+		return fmt.Errorf("line of sight and attack range must not be negative")
+	}
+	return nil
+}
+
+// Validate checks that the abilities of the archetypes are in a set of abilities
+func (s *Set) Validate(abilitySet *abilities.Set) error {
+	for _, archetype := range s.Archetypes {
+		for _, id := range archetype.Abilities {
+			if _, ok := abilitySet.Get(id); !ok {
+				return fmt.Errorf("archetype %q has unknown ability %q", archetype.ID, id)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateBehaviors checks that the behaviors of the archetypes are in a set of behaviors
+func (s *Set) ValidateBehaviors(behaviorSet *behaviors.Set) error {
+	for _, archetype := range s.Archetypes {
+		if archetype.Behavior == "" {
+			continue
+		}
+		if _, ok := behaviorSet.Get(archetype.Behavior); !ok {
+			return fmt.Errorf("archetype %q has unknown behavior %q", archetype.ID, archetype.Behavior)
+		}
+	}
+	return nil
+}
+
+// Get returns the archetype with an ID
+func (s *Set) Get(id string) (*Archetype, bool) {
+	archetype, ok := s.byID[id]
+	return archetype, ok
+}
+
+// DefaultArchetype returns the archetype of NPCs spawned without one
+func (s *Set) DefaultArchetype() *Archetype {
+	return s.byID[s.Default]
+}
+
+var active atomic.Pointer[Set]
+
+func init() {
+	active.Store(Default())
+}
+
+// Active returns the set of NPC archetypes used by the game
+func Active() *Set {
+	return active.Load()
+}
+
+// SetActive replaces the set of NPC archetypes used by the game.
+// It must be called before the game starts, and the client and server must use the same set.
+func SetActive(set *Set) {
+	active.Store(set)
+}
+
+// BehaviorFrom returns the behavior that decides what the NPCs of the archetype do in a set of behaviors
+func (a *Archetype) BehaviorFrom(behaviorSet *behaviors.Set) *behaviors.Behavior {
+	if behavior, ok := behaviorSet.Get(a.Behavior); ok {
+		return behavior
+	}
+	return behaviorSet.DefaultBehavior()
+}
+
+// AbilitiesFrom returns the abilities the NPCs of the archetype choose from in a set of abilities
+func (a *Archetype) AbilitiesFrom(abilitySet *abilities.Set) []*abilities.Ability {
+	if len(a.Abilities) == 0 {
+		return abilitySet.NPCAbilities()
+	}
+	resolved := make([]*abilities.Ability, 0, len(a.Abilities))
+	for _, id := range a.Abilities {
+		if ability, ok := abilitySet.Get(id); ok {
+			resolved = append(resolved, ability)
+		}
+	}
+	return resolved
+}
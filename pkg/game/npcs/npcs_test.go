@@ -0,0 +1,107 @@
+package npcs
+
+import (
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/behaviors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	set := Default()
+
+	assert.NoError(t, set.Validate(abilities.Default()))
+	assert.NoError(t, set.ValidateBehaviors(behaviors.Default()))
+	assert.Equal(t, "skeleton", set.DefaultArchetype().ID)
+	for _, archetype := range set.Archetypes {
+		got, ok := set.Get(archetype.ID)
+		assert.True(t, ok)
+		assert.Same(t, archetype, got)
+		assert.Len(t, archetype.AbilitiesFrom(abilities.Default()), len(archetype.Abilities))
+		assert.Equal(t, archetype.Behavior, archetype.BehaviorFrom(behaviors.Default()).ID)
+	}
+	_, ok := set.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			json: `{"archetypes": [{"id": "a", "sprite": "s", "hitpoints": 10}], "default": "a"}`,
+		},
+		{
+			name:    "invalid json",
+			json:    `{"archetypes": [`,
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			json:    `{"archetypes": [{"sprite": "s", "hitpoints": 10}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no hitpoints",
+			json:    `{"archetypes": [{"id": "a", "sprite": "s"}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			json:    `{"archetypes": [{"id": "a", "sprite": "s", "hitpoints": 10}, {"id": "a", "sprite": "s", "hitpoints": 10}], "default": "a"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown default",
+			json:    `{"archetypes": [{"id": "a", "sprite": "s", "hitpoints": 10}], "default": "b"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Parse([]byte(tt.json))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "a", set.DefaultArchetype().ID)
+		})
+	}
+}
+
+func TestSet_Validate(t *testing.T) {
+	set, err := Parse([]byte(`{"archetypes": [{"id": "a", "sprite": "s", "hitpoints": 10, "abilities": ["missing"]}], "default": "a"}`))
+	assert.NoError(t, err)
+	assert.Error(t, set.Validate(abilities.Default()))
+
+	// archetypes without abilities of their own use the abilities of NPCs
+	set.Archetypes[0].Abilities = nil
+	assert.NoError(t, set.Validate(abilities.Default()))
+	assert.Equal(t, abilities.Default().NPC, abilityIDs(set.Archetypes[0].AbilitiesFrom(abilities.Default())))
+}
+
+func TestSet_ValidateBehaviors(t *testing.T) {
+	behaviorSet := behaviors.Default()
+	set, err := Parse([]byte(`{"archetypes": [{"id": "a", "sprite": "s", "hitpoints": 10, "behavior": "missing"}], "default": "a"}`))
+	assert.NoError(t, err)
+	assert.Error(t, set.ValidateBehaviors(behaviorSet))
+
+	// archetypes without a behavior of their own use the default behavior
+	set.Archetypes[0].Behavior = ""
+	assert.NoError(t, set.ValidateBehaviors(behaviorSet))
+	assert.Same(t, behaviorSet.DefaultBehavior(), set.Archetypes[0].BehaviorFrom(behaviorSet))
+}
+
+func abilityIDs(resolved []*abilities.Ability) []string {
+	ids := make([]string, 0, len(resolved))
+	for _, ability := range resolved {
+		ids = append(ids, ability.ID)
+	}
+	return ids
+}
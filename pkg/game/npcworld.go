@@ -0,0 +1,59 @@
+package game
+
+import (
+	"github.com/cbodonnell/flywheel/pkg/game/behaviors"
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/solarlune/resolv"
+)
+
+// npcWorld is what the behaviors of the NPCs of a zone sense of it
+type npcWorld struct {
+	gameState *types.GameState
+}
+
+var _ behaviors.World = &npcWorld{}
+
+func (w *npcWorld) Target(id uint32) (behaviors.Target, bool) {
+	playerState, ok := w.gameState.Players[id]
+	if !ok || playerState.IsDead() {
+		return behaviors.Target{}, false
+	}
+	return behaviors.Target{ID: id, Position: playerState.Position}, true
+}
+
+func (w *npcWorld) Targets(position kinematic.Vector, distance float64) []behaviors.Target {
+	var targets []behaviors.Target
+	for _, playerID := range w.gameState.PlayerIDs() {
+		playerState := w.gameState.Players[playerID]
+		if playerState.IsDead() || position.DistanceFrom(playerState.Position) > distance {
+			continue
+		}
+		targets = append(targets, behaviors.Target{ID: playerID, Position: playerState.Position})
+	}
+	return targets
+}
+
+func (w *npcWorld) InLineOfSight(position kinematic.Vector, target behaviors.Target) bool {
+	lineOfSight := resolv.NewLine(position.X+constants.NPCWidth/2, position.Y+constants.NPCHeight/2, target.Position.X+constants.PlayerWidth/2, target.Position.Y+constants.PlayerHeight/2)
+	for _, obj := range w.gameState.CollisionSpace.Objects() {
+		if !obj.HasTags(types.CollisionSpaceTagLevel) && !obj.HasTags(types.CollisionSpaceTagPlatform) {
+			continue
+		}
+		if contact := lineOfSight.Intersection(0, 0, obj.Shape); contact != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *npcWorld) Alert(position kinematic.Vector, distance float64, targetID uint32) {
+	for _, npcID := range w.gameState.NPCIDs() {
+		npcState := w.gameState.NPCs[npcID]
+		if npcState.IsDead() || position.DistanceFrom(npcState.Position) > distance {
+			continue
+		}
+		npcState.Memory.Provoke(targetID)
+	}
+}
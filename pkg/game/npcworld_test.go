@@ -0,0 +1,79 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/stretchr/testify/assert"
+)
+
+// newBehaviorTestZone creates a zone with a flat floor, an aggressive skeleton facing right at x=400,
+// two defensive skeleton warriors at x=900 and a player standing at x=560
+func newBehaviorTestZone(t *testing.T) (*Zone, *types.PlayerState) {
+	level := &levels.Level{
+		Width:  1280,
+		Height: 480,
+		Solids: []levels.Rect{{X: 0, Y: 0, Width: 1280, Height: 16}},
+		NPCSpawners: []levels.NPCSpawner{
+			{ID: 1, Archetype: "skeleton", Position: kinematic.NewVector(400, 16), WanderRange: 1, Population: 1, RespawnTime: 10},
+			{ID: 2, Archetype: "skeleton-warrior", Position: kinematic.NewVector(900, 16), WanderRange: 1, Population: 2, RespawnTime: 10},
+		},
+	}
+	zone := NewZone(NewZoneOptions{
+		Zone:                 &levels.Zone{ID: 1, Level: level},
+		BroadcastMessageChan: make(chan workers.BroadcastMessage, 10000),
+		GameLoopInterval:     50 * time.Millisecond,
+		Seed:                 1,
+	})
+	assert.NoError(t, zone.spawnNPCs())
+
+	playerState := types.NewPlayerState(1, "player-1", kinematic.NewVector(560, 16), false, 100)
+	zone.addPlayer(1, playerState, nil)
+	return zone, playerState
+}
+
+func TestZone_NPCBehavior_Aggressive(t *testing.T) {
+	zone, playerState := newBehaviorTestZone(t)
+	skeleton := zone.gameState.NPCs[1]
+
+	// the skeleton sees the player ahead of it, walks up to it and attacks it
+	for tick := 0; tick < 200 && playerState.Hitpoints == 100; tick++ {
+		zone.updateServerObjects(0.05)
+	}
+	assert.Less(t, playerState.Hitpoints, int16(100))
+	assert.True(t, skeleton.Memory.HasTarget)
+	assert.Equal(t, uint32(1), skeleton.Memory.TargetID)
+	assert.Greater(t, skeleton.Position.X, 400.0)
+
+	// the warriors are not aggressive, so they leave the player alone
+	assert.False(t, zone.gameState.NPCs[2].Memory.HasTarget)
+	assert.False(t, zone.gameState.NPCs[3].Memory.HasTarget)
+
+	// and the skeleton forgets the player once it leaves
+	zone.removePlayer(1)
+	assert.False(t, skeleton.Memory.HasTarget)
+}
+
+func TestZone_NPCBehavior_CallForHelp(t *testing.T) {
+	zone, playerState := newBehaviorTestZone(t)
+	// keep the skeleton out of the fight
+	zone.gameState.NPCs[1].Hitpoints = 0
+
+	// a warrior hit by the player calls the other warrior for help
+	zone.gameState.NPCs[2].Memory.Provoke(1)
+	zone.updateServerObjects(0.05)
+	assert.True(t, zone.gameState.NPCs[3].Memory.HasTarget)
+	assert.Equal(t, uint32(1), zone.gameState.NPCs[3].Memory.TargetID)
+
+	// and both walk over to fight the player
+	for tick := 0; tick < 200 && playerState.Hitpoints == 100; tick++ {
+		zone.updateServerObjects(0.05)
+	}
+	assert.Less(t, playerState.Hitpoints, int16(100))
+	assert.Less(t, zone.gameState.NPCs[2].Position.X, 900.0)
+	assert.Less(t, zone.gameState.NPCs[3].Position.X, 900.0)
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/solarlune/resolv"
+)
+
+// PvPRules are the rules of combat between players
+type PvPRules struct {
+	// Enabled allows the attacks of players to hit other players
+	Enabled bool
+	// Teams is the number of teams players are split into by their character ID.
+	// If zero, players are not on teams and have no allies.
+	Teams uint32
+	// FriendlyFire allows the attacks of players to hit players on the same team
+	FriendlyFire bool
+}
+
+// Team returns the team of a character, where zero is no team
+func (r PvPRules) Team(characterID int32) uint32 {
+	if r.Teams == 0 {
+		return 0
+	}
+	return uint32(characterID)%r.Teams + 1
+}
+
+// CanHit returns true if the attacks of a player can hit another player
+func (r PvPRules) CanHit(attacker *types.PlayerState, target *types.PlayerState) bool {
+	if !r.Enabled || attacker == target {
+		return false
+	}
+	if r.FriendlyFire {
+		return true
+	}
+	team := r.Team(attacker.CharacterID)
+	return team == 0 || team != r.Team(target.CharacterID)
+}
+
+// checkPlayerPvPHits checks for hits of a player's attack hitbox on other players,
+// rewinding them to the render time of the attacker's client
+func (z *Zone) checkPlayerPvPHits(clientID uint32, playerState *types.PlayerState, attackHitbox *resolv.Object, renderTime int64) {
+	for _, targetID := range z.gameState.PlayerIDs() {
+		targetState := z.gameState.Players[targetID]
+		if targetState.IsDead() || !z.pvpRules.CanHit(playerState, targetState) {
+			continue
+		}
+
+		restore := z.rewindPlayer(targetID, targetState, renderTime)
+		hit := attackHitbox.SharesCells(targetState.Object)
+		restore()
+		if !hit {
+			continue
+		}
+
+		// player hit player
+		log.Debug("Player %d hit player %d", clientID, targetID)
+
+		damage := playerState.CurrentAbility.Damage
+		targetState.TakeDamage(damage)
+
+		playerHit := &messages.ServerPlayerHit{
+			PlayerID:     targetID,
+			AttackerType: messages.EntityTypePlayer,
+			AttackerID:   clientID,
+			Damage:       damage,
+		}
+		z.broadcast(messages.MessageTypeServerPlayerHit, playerHit)
+
+		if !targetState.IsDead() {
+			continue
+		}
+
+		// player killed player
+		log.Debug("Player %d killed player %d", clientID, targetID)
+		playerKill := &messages.ServerPlayerKill{
+			PlayerID:     targetID,
+			AttackerType: messages.EntityTypePlayer,
+			AttackerID:   clientID,
+		}
+		z.broadcast(messages.MessageTypeServerPlayerKill, playerKill)
+	}
+}
@@ -0,0 +1,124 @@
+package game
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/solarlune/resolv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPvPRules_CanHit(t *testing.T) {
+	player := func(characterID int32) *types.PlayerState {
+		return types.NewPlayerState(characterID, "player", kinematic.NewVector(0, 0), false, constants.PlayerHitpoints)
+	}
+	attacker := player(1)
+
+	tests := []struct {
+		name   string
+		rules  PvPRules
+		target *types.PlayerState
+		want   bool
+	}{
+		{name: "disabled", rules: PvPRules{}, target: player(2), want: false},
+		{name: "enabled", rules: PvPRules{Enabled: true}, target: player(2), want: true},
+		{name: "self", rules: PvPRules{Enabled: true}, target: attacker, want: false},
+		{name: "same team", rules: PvPRules{Enabled: true, Teams: 2}, target: player(3), want: false},
+		{name: "other team", rules: PvPRules{Enabled: true, Teams: 2}, target: player(2), want: true},
+		{name: "friendly fire", rules: PvPRules{Enabled: true, Teams: 2, FriendlyFire: true}, target: player(3), want: true},
+		{name: "friendly fire on self", rules: PvPRules{Enabled: true, Teams: 2, FriendlyFire: true}, target: attacker, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rules.CanHit(attacker, tt.target))
+		})
+	}
+}
+
+func TestPvPRules_Team(t *testing.T) {
+	assert.Zero(t, PvPRules{}.Team(1))
+	rules := PvPRules{Teams: 3}
+	teams := make(map[uint32]int)
+	for characterID := int32(1); characterID <= 30; characterID++ {
+		teams[rules.Team(characterID)]++
+	}
+	assert.Equal(t, map[uint32]int{1: 10, 2: 10, 3: 10}, teams, "characters are split evenly into teams numbered from one")
+}
+
+func TestGameManager_PvP(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   PvPRules
+		targets []int32
+		// hit are the targets that are hit by the attack
+		hit []int32
+	}{
+		{name: "disabled", rules: PvPRules{}, targets: []int32{2, 3}, hit: nil},
+		{name: "no teams", rules: PvPRules{Enabled: true}, targets: []int32{2, 3}, hit: []int32{2, 3}},
+		{name: "teams", rules: PvPRules{Enabled: true, Teams: 2}, targets: []int32{2, 3}, hit: []int32{2}},
+		{name: "friendly fire", rules: PvPRules{Enabled: true, Teams: 2, FriendlyFire: true}, targets: []int32{2, 3}, hit: []int32{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broadcastMessageChan := make(chan workers.BroadcastMessage, 10)
+			gm := NewGameManager(NewGameManagerOptions{
+				ClientMessageQueue:   queue.NewInMemoryQueue(10),
+				ServerEventQueue:     queue.NewInMemoryQueue(10),
+				SaveStateChan:        make(chan workers.SaveStateRequest, 1),
+				BroadcastMessageChan: broadcastMessageChan,
+				GameLoopInterval:     50 * time.Millisecond,
+				SaveStateInterval:    time.Hour,
+				PvPRules:             tt.rules,
+				Seed:                 1,
+			})
+			zone := gm.zones[levels.Active().Start().ID]
+			zone.gameState.CollisionSpace = resolv.NewSpace(constants.SpaceWidth, constants.SpaceHeight, constants.CellWidth, constants.CellHeight)
+			zone.gameState.Timestamp = 1000
+
+			// the attacker is character 1, and each target is a client whose ID is its character ID
+			ability := abilities.Active().PlayerAbility(0)
+			attacker := types.NewPlayerState(1, "attacker", kinematic.NewVector(100, 100), false, constants.PlayerHitpoints)
+			zone.gameState.Players[1] = attacker
+			zone.gameState.CollisionSpace.Add(attacker.Object)
+			for _, characterID := range tt.targets {
+				target := types.NewPlayerState(characterID, "target", kinematic.NewVector(100+constants.PlayerWidth, 100), false, ability.Damage)
+				zone.gameState.Players[uint32(characterID)] = target
+				zone.gameState.CollisionSpace.Add(target.Object)
+			}
+
+			attacker.IsAttackHitting = true
+			attacker.CurrentAbility = ability
+			attacker.LastProcessedTimestamp = 1000 + constants.InterpolationOffset
+			zone.checkPlayerCollisions(1, attacker)
+
+			assert.False(t, attacker.IsDead())
+			for _, characterID := range tt.targets {
+				assert.Equal(t, slices.Contains(tt.hit, characterID), zone.gameState.Players[uint32(characterID)].IsDead(), "target %d", characterID)
+			}
+
+			// each target hit is also killed, and both are attributed to the attacker
+			if !assert.Len(t, broadcastMessageChan, 2*len(tt.hit)) {
+				return
+			}
+			for _, characterID := range tt.hit {
+				hit := <-broadcastMessageChan
+				assert.Equal(t, messages.MessageTypeServerPlayerHit, hit.Type)
+				assert.Equal(t, &messages.ServerPlayerHit{PlayerID: uint32(characterID), AttackerType: messages.EntityTypePlayer, AttackerID: 1, Damage: ability.Damage}, hit.Message)
+				kill := <-broadcastMessageChan
+				assert.Equal(t, messages.MessageTypeServerPlayerKill, kill.Type)
+				assert.Equal(t, &messages.ServerPlayerKill{PlayerID: uint32(characterID), AttackerType: messages.EntityTypePlayer, AttackerID: 1}, kill.Message)
+			}
+		})
+	}
+}
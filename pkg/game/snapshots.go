@@ -0,0 +1,73 @@
+package game
+
+import (
+	"github.com/cbodonnell/flywheel/pkg/messages"
+)
+
+const (
+	// SnapshotHistorySize is the number of snapshots kept per client to use as delta baselines
+	SnapshotHistorySize = 32
+	// MaxBaselineAge is the maximum age in milliseconds of a baseline before a full snapshot is sent instead
+	MaxBaselineAge = 1000
+)
+
+// SnapshotHistory keeps the most recent full snapshots sent to or received by a client.
+type SnapshotHistory struct {
+	snapshots []*messages.ServerGameUpdate
+	next      int
+}
+
+// NewSnapshotHistory creates a new SnapshotHistory that holds up to size snapshots.
+func NewSnapshotHistory(size int) *SnapshotHistory {
+	return &SnapshotHistory{
+		snapshots: make([]*messages.ServerGameUpdate, size),
+	}
+}
+
+// Add adds a full snapshot to the history, replacing the oldest one if the history is full.
+func (h *SnapshotHistory) Add(snapshot *messages.ServerGameUpdate) {
+	h.snapshots[h.next] = snapshot
+	h.next = (h.next + 1) % len(h.snapshots)
+}
+
+// Get returns the snapshot with the given timestamp, or nil if it is not in the history.
+func (h *SnapshotHistory) Get(timestamp int64) *messages.ServerGameUpdate {
+	for _, snapshot := range h.snapshots {
+		if snapshot != nil && snapshot.Timestamp == timestamp {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// ClientSnapshots tracks the snapshots sent to a client and the last one it acknowledged.
+type ClientSnapshots struct {
+	history            *SnapshotHistory
+	lastAckedTimestamp int64
+}
+
+// NewClientSnapshots creates a new ClientSnapshots.
+func NewClientSnapshots() *ClientSnapshots {
+	return &ClientSnapshots{
+		history: NewSnapshotHistory(SnapshotHistorySize),
+	}
+}
+
+// Ack records that the client received the snapshot with the given timestamp.
+func (c *ClientSnapshots) Ack(timestamp int64) {
+	if timestamp > c.lastAckedTimestamp {
+		c.lastAckedTimestamp = timestamp
+	}
+}
+
+// Next records a full snapshot as sent to the client and returns the update to send,
+// which is a delta against the last acknowledged snapshot if it is recent enough
+// and a full snapshot otherwise.
+func (c *ClientSnapshots) Next(snapshot *messages.ServerGameUpdate) *messages.ServerGameUpdate {
+	var baseline *messages.ServerGameUpdate
+	if c.lastAckedTimestamp != 0 && snapshot.Timestamp-c.lastAckedTimestamp <= MaxBaselineAge {
+		baseline = c.history.Get(c.lastAckedTimestamp)
+	}
+	c.history.Add(snapshot)
+	return snapshot.Delta(baseline)
+}
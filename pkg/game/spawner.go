@@ -0,0 +1,86 @@
+package game
+
+import (
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
+)
+
+// Spawner keeps the population of NPCs of a spawner of a level in a zone
+type Spawner struct {
+	levels.NPCSpawner
+	archetype *npcs.Archetype
+	// npcCount is the number of NPCs of the spawner in the zone, dead or alive
+	npcCount int
+	// spawnCount is the number of NPCs the spawner has spawned
+	spawnCount int
+	// timers are the time left in seconds before each pending respawn,
+	// which in wave mode respawns the whole population
+	timers []float64
+}
+
+func NewSpawner(spawner levels.NPCSpawner, archetype *npcs.Archetype) *Spawner {
+	return &Spawner{
+		NPCSpawner: spawner,
+		archetype:  archetype,
+	}
+}
+
+// Archetype returns the archetype of the NPCs of the spawner
+func (s *Spawner) Archetype() *npcs.Archetype {
+	return s.archetype
+}
+
+// Initial returns the number of NPCs the spawner spawns when the zone starts
+func (s *Spawner) Initial() int {
+	return s.limit(s.Population)
+}
+
+// Spawned records that the spawner spawned an NPC
+func (s *Spawner) Spawned() {
+	s.npcCount++
+	s.spawnCount++
+}
+
+// Despawned records that an NPC of the spawner despawned and schedules its replacement
+func (s *Spawner) Despawned() {
+	s.npcCount--
+	if s.IsExhausted() {
+		return
+	}
+	// a wave respawns once all of its NPCs have despawned
+	if !s.Wave || s.npcCount == 0 {
+		s.timers = append(s.timers, s.RespawnTime)
+	}
+}
+
+// Update advances the respawn timers by the time passed and returns the number of NPCs to spawn
+func (s *Spawner) Update(deltaTime float64) int {
+	due := 0
+	pending := s.timers[:0]
+	for _, left := range s.timers {
+		if left -= deltaTime; left > 0 {
+			pending = append(pending, left)
+			continue
+		}
+		if s.Wave {
+			due += s.Population
+		} else {
+			due++
+		}
+	}
+	s.timers = pending
+	return s.limit(due)
+}
+
+// IsExhausted returns true if the spawner has spawned as many NPCs as it may
+func (s *Spawner) IsExhausted() bool {
+	return s.MaxSpawns > 0 && s.spawnCount >= s.MaxSpawns
+}
+
+// limit returns how many of a number of NPCs the spawner may still spawn
+func (s *Spawner) limit(count int) int {
+	if s.MaxSpawns > 0 {
+		count = min(count, s.MaxSpawns-s.spawnCount)
+	}
+	return max(count, 0)
+}
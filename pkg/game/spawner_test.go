@@ -0,0 +1,134 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpawner(t *testing.T) {
+	tests := []struct {
+		name    string
+		spawner levels.NPCSpawner
+		// despawn is the number of NPCs that despawn before the spawner is updated
+		despawn   int
+		wantFirst int
+		wantDue   int
+	}{
+		{
+			name:      "replaces each NPC",
+			spawner:   levels.NPCSpawner{Population: 3, RespawnTime: 1},
+			despawn:   2,
+			wantFirst: 3,
+			wantDue:   2,
+		},
+		{
+			name:      "waits for the whole wave",
+			spawner:   levels.NPCSpawner{Population: 3, RespawnTime: 1, Wave: true},
+			despawn:   2,
+			wantFirst: 3,
+			wantDue:   0,
+		},
+		{
+			name:      "respawns the whole wave",
+			spawner:   levels.NPCSpawner{Population: 3, RespawnTime: 1, Wave: true},
+			despawn:   3,
+			wantFirst: 3,
+			wantDue:   3,
+		},
+		{
+			name:      "stops at the spawn cap",
+			spawner:   levels.NPCSpawner{Population: 3, RespawnTime: 1, MaxSpawns: 4},
+			despawn:   3,
+			wantFirst: 3,
+			wantDue:   1,
+		},
+		{
+			name:      "caps the first spawn",
+			spawner:   levels.NPCSpawner{Population: 3, RespawnTime: 1, MaxSpawns: 2},
+			despawn:   2,
+			wantFirst: 2,
+			wantDue:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spawner := NewSpawner(tt.spawner, npcs.Active().DefaultArchetype())
+			first := spawner.Initial()
+			assert.Equal(t, tt.wantFirst, first)
+			for i := 0; i < first; i++ {
+				spawner.Spawned()
+			}
+			for i := 0; i < tt.despawn; i++ {
+				spawner.Despawned()
+			}
+
+			// nothing respawns before the respawn time is up
+			assert.Equal(t, 0, spawner.Update(0.5))
+			assert.Equal(t, tt.wantDue, spawner.Update(0.5))
+			assert.Equal(t, 0, spawner.Update(1))
+		})
+	}
+}
+
+func TestZone_RespawnNPC(t *testing.T) {
+	level := &levels.Level{
+		Width:  1280,
+		Height: 480,
+		Solids: []levels.Rect{{X: 0, Y: 0, Width: 1280, Height: 16}},
+		NPCSpawners: []levels.NPCSpawner{{
+			ID:          7,
+			Archetype:   "skeleton-warrior",
+			Position:    kinematic.NewVector(640, 16),
+			Width:       256,
+			WanderRange: constants.NPCWanderRange,
+			Population:  2,
+			RespawnTime: 1,
+		}},
+	}
+	zone := NewZone(NewZoneOptions{
+		Zone:                 &levels.Zone{ID: 1, Level: level},
+		BroadcastMessageChan: make(chan workers.BroadcastMessage, 100),
+		GameLoopInterval:     50 * time.Millisecond,
+		Seed:                 1,
+	})
+	assert.NoError(t, zone.spawnNPCs())
+
+	// NPCs are given IDs by the zone, and spawn within the area of their spawner
+	if assert.Equal(t, []uint32{1, 2}, zone.gameState.NPCIDs()) {
+		for _, npcState := range zone.gameState.NPCs {
+			assert.Equal(t, "skeleton-warrior", npcState.Archetype.ID)
+			assert.Equal(t, npcState.Archetype.Hitpoints, npcState.Hitpoints)
+			assert.GreaterOrEqual(t, npcState.Position.X, 640-128.0)
+			assert.LessOrEqual(t, npcState.Position.X, 640+128-constants.NPCWidth)
+		}
+	}
+
+	// a dead NPC despawns after its corpse time and is replaced with a new ID after the respawn time
+	zone.gameState.NPCs[1].TakeDamage(zone.gameState.NPCs[1].Hitpoints)
+	zone.updateServerObjects(constants.NPCCorpseTime)
+	assert.Equal(t, []uint32{2}, zone.gameState.NPCIDs())
+	zone.updateServerObjects(1)
+	assert.Equal(t, []uint32{2, 3}, zone.gameState.NPCIDs())
+	assert.False(t, zone.gameState.NPCs[3].IsDead())
+}
+
+func TestZone_SpawnNPCs_UnknownArchetype(t *testing.T) {
+	level := &levels.Level{
+		Width:       1280,
+		Height:      480,
+		NPCSpawners: []levels.NPCSpawner{{ID: 1, Archetype: "missing", Population: 1}},
+	}
+	zone := NewZone(NewZoneOptions{
+		Zone:                 &levels.Zone{ID: 1, Level: level},
+		BroadcastMessageChan: make(chan workers.BroadcastMessage, 100),
+	})
+	assert.Error(t, zone.spawnNPCs())
+}
@@ -9,6 +9,7 @@ type ConnectPlayerEvent struct {
 	CharacterPosition  kinematic.Vector
 	CharacterFlipH     bool
 	CharacterHitpoints int16
+	CharacterZoneID    uint32
 }
 
 type DisconnectPlayerEvent struct {
@@ -1,6 +1,15 @@
 package types
 
-import "github.com/solarlune/resolv"
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/solarlune/resolv"
+)
 
 const (
 	CollisionSpaceTagPlayer   string = "player"
@@ -8,9 +17,12 @@ const (
 	CollisionSpaceTagLevel    string = "level"
 	CollisionSpaceTagPlatform string = "platform"
 	CollisionSpaceTagLadder   string = "ladder"
+	CollisionSpaceTagPortal   string = "portal"
 )
 
 type GameState struct {
+	// Tick is the number of ticks simulated
+	Tick uint64
 	// Timestamp is the time at which the game state was generated
 	Timestamp int64
 	// Players maps client IDs to player states
@@ -19,19 +31,24 @@ type GameState struct {
 	NPCs map[uint32]*NPCState
 	// CollisionSpace is a resolv.Space used for collision detection
 	CollisionSpace *resolv.Space
+	// RNG is the random number stream drawn from by the simulation.
+	// It is seeded so that a game can be replayed from the same inputs.
+	RNG *rand.Rand
 }
 
-func NewGameState(collisionSpace *resolv.Space) *GameState {
+func NewGameState(collisionSpace *resolv.Space, seed int64) *GameState {
 	return &GameState{
 		Timestamp:      0,
 		Players:        make(map[uint32]*PlayerState),
 		NPCs:           make(map[uint32]*NPCState),
 		CollisionSpace: collisionSpace,
+		RNG:            rand.New(rand.NewSource(seed)),
 	}
 }
 
 func (g *GameState) Copy() *GameState {
 	newGameState := &GameState{
+		Tick:      g.Tick,
 		Timestamp: g.Timestamp,
 		Players:   make(map[uint32]*PlayerState),
 		NPCs:      make(map[uint32]*NPCState),
@@ -64,3 +81,87 @@ func (g *GameState) AddNPC(id uint32, state *NPCState) {
 func (g *GameState) RemoveNPC(id uint32) {
 	delete(g.NPCs, id)
 }
+
+// PlayerIDs returns the client IDs of the players in ascending order.
+// The simulation iterates in this order so that it does not depend on map order.
+func (g *GameState) PlayerIDs() []uint32 {
+	ids := make([]uint32, 0, len(g.Players))
+	for id := range g.Players {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// NPCIDs returns the IDs of the NPCs in ascending order
+func (g *GameState) NPCIDs() []uint32 {
+	ids := make([]uint32, 0, len(g.NPCs))
+	for id := range g.NPCs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Hash returns a hash of the simulated state of the game,
+// used to check that two simulations have not diverged
+func (g *GameState) Hash() uint64 {
+	h := stateHasher{fnv.New64a()}
+	h.uint(g.Tick)
+	for _, id := range g.PlayerIDs() {
+		p := g.Players[id]
+		h.uint(uint64(id))
+		h.vector(p.Position.X, p.Position.Y)
+		h.vector(p.Velocity.X, p.Velocity.Y)
+		h.bool(p.FlipH, p.IsOnGround, p.IsOnLadder, p.IsAttacking)
+		h.float(p.AttackTimeLeft)
+		h.uint(uint64(p.Animation), uint64(p.AnimationSequence), uint64(p.Hitpoints))
+	}
+	for _, id := range g.NPCIDs() {
+		n := g.NPCs[id]
+		h.uint(uint64(id))
+		h.vector(n.Position.X, n.Position.Y)
+		h.vector(n.Velocity.X, n.Velocity.Y)
+		h.bool(n.FlipH, n.IsOnGround, n.IsAttacking)
+		h.bool(n.Memory.HasTarget, n.Memory.CalledForHelp, n.Memory.Leashing, n.Memory.Patrolling)
+		h.float(n.AttackTimeLeft, n.Memory.IdleTimeLeft, n.Memory.PatrolX, n.deadTime)
+		h.uint(uint64(n.Memory.TargetID), uint64(n.Animation), uint64(n.AnimationSequence), uint64(n.Hitpoints))
+		if n.CurrentAbility != nil {
+			h.Write([]byte(n.CurrentAbility.ID))
+		}
+	}
+	return h.Sum64()
+}
+
+// stateHasher writes the fields of the game state to a hash
+type stateHasher struct {
+	hash.Hash64
+}
+
+func (h stateHasher) uint(values ...uint64) {
+	var b [8]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(b[:], v)
+		h.Write(b[:])
+	}
+}
+
+func (h stateHasher) float(values ...float64) {
+	for _, v := range values {
+		h.uint(math.Float64bits(v))
+	}
+}
+
+func (h stateHasher) vector(x, y float64) {
+	h.float(x, y)
+}
+
+func (h stateHasher) bool(values ...bool) {
+	for _, v := range values {
+		if v {
+			h.uint(1)
+		} else {
+			h.uint(0)
+		}
+	}
+}
@@ -3,13 +3,18 @@ package types
 import (
 	"math/rand"
 
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/behaviors"
 	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
 	"github.com/cbodonnell/flywheel/pkg/kinematic"
 	"github.com/solarlune/resolv"
 )
 
 type NPCState struct {
 	ID                uint32
+	Archetype         *npcs.Archetype
+	Behavior          *behaviors.Behavior
 	SpawnPosition     kinematic.Vector
 	SpawnFlip         bool
 	WanderRangeMin    kinematic.Vector
@@ -24,19 +29,20 @@ type NPCState struct {
 	ResetAnimation    bool
 	Hitpoints         int16
 
-	respawnTime float64
+	// deadTime is how long the NPC has been dead
+	deadTime float64
 
-	mode         NPCMode
-	IdleTimeLeft float64
-	WanderTarget *kinematic.Vector
-	FollowTarget *PlayerState
+	// Memory is what the behavior of the NPC remembers between ticks
+	Memory behaviors.Memory
+	// Intent is what the behavior of the NPC decided it does
+	Intent behaviors.Intent
 
-	IsInAttackRange bool
 	IsAttacking     bool
-	CurrentAttack   NPCAttack
+	CurrentAbility  *abilities.Ability
 	AttackTimeLeft  float64
 	IsAttackHitting bool
 	DidAttackHit    bool
+	Cooldowns       abilities.Cooldowns
 }
 
 type NPCAnimation uint8
@@ -50,28 +56,24 @@ const (
 	NPCAnimationAttack3
 )
 
-type NPCMode uint8
-
-const (
-	NPCModeIdle NPCMode = iota
-	NPCModeWander
-	NPCModeFollow
-)
-
-type NPCAttack uint8
-
-const (
-	NPCAttack1 NPCAttack = iota
-	NPCAttack2
-	NPCAttack3
-)
+// NPCAnimationKeys maps the animation keys used by abilities to NPC animations
+var NPCAnimationKeys = map[string]NPCAnimation{
+	"idle":    NPCAnimationIdle,
+	"walk":    NPCAnimationWalk,
+	"dead":    NPCAnimationDead,
+	"attack1": NPCAnimationAttack1,
+	"attack2": NPCAnimationAttack2,
+	"attack3": NPCAnimationAttack3,
+}
 
-func NewNPCState(id uint32, spawnPosition kinematic.Vector, wanderRangeMinX, wanderRangeMaxX float64, flip bool) *NPCState {
+func NewNPCState(id uint32, archetype *npcs.Archetype, spawnPosition kinematic.Vector, wanderRangeMinX, wanderRangeMaxX float64, flip bool) *NPCState {
 	object := resolv.NewObject(spawnPosition.X, spawnPosition.Y, constants.NPCWidth, constants.NPCHeight, CollisionSpaceTagNPC)
 	object.SetShape(resolv.NewRectangle(0, 0, constants.NPCWidth, constants.NPCHeight))
 
 	return &NPCState{
 		ID:            id,
+		Archetype:     archetype,
+		Behavior:      archetype.BehaviorFrom(behaviors.Active()),
 		SpawnPosition: spawnPosition,
 		SpawnFlip:     flip,
 		WanderRangeMin: kinematic.Vector{
@@ -99,14 +101,16 @@ func (n *NPCState) Equals(other *NPCState) bool {
 
 func (n *NPCState) Copy() *NPCState {
 	return &NPCState{
+		Archetype:         n.Archetype,
 		Position:          n.Position,
 		Velocity:          n.Velocity,
 		IsOnGround:        n.IsOnGround,
 		IsAttacking:       n.IsAttacking,
-		CurrentAttack:     n.CurrentAttack,
+		CurrentAbility:    n.CurrentAbility,
 		AttackTimeLeft:    n.AttackTimeLeft,
 		IsAttackHitting:   n.IsAttackHitting,
 		DidAttackHit:      n.DidAttackHit,
+		Cooldowns:         n.Cooldowns.Copy(),
 		Animation:         n.Animation,
 		FlipH:             n.FlipH,
 		AnimationSequence: n.AnimationSequence,
@@ -114,36 +118,55 @@ func (n *NPCState) Copy() *NPCState {
 	}
 }
 
-func (n *NPCState) RespawnTime() float64 {
-	return n.respawnTime
-}
-
-// Update updates the NPC state based on the current state and the time passed
-// and returns whether the state has changed
-func (n *NPCState) Update(deltaTime float64) (changed bool) {
+// Update updates the NPC state based on the current state, what it senses of the world
+// and the time passed, drawing its random decisions from rng, and returns whether the state has changed
+func (n *NPCState) Update(deltaTime float64, world behaviors.World, rng *rand.Rand) (changed bool) {
 	previousState := n.Copy()
-	n.UpdateRespawn(deltaTime)
-	n.UpdateAttack(deltaTime)
+	n.UpdateDespawn(deltaTime)
+	n.UpdateBehavior(deltaTime, world, rng)
+	n.UpdateAttack(deltaTime, rng)
 	n.UpdateXPosition(deltaTime)
 	n.UpdateYPosition(deltaTime)
 	n.UpdateFlipH()
-	n.UpdateFollowing()
-	n.UpdateWandering(deltaTime)
 	n.UpdateAnimation()
 	return !n.Equals(previousState)
 }
 
-func (n *NPCState) UpdateRespawn(deltaTime float64) {
-	if !n.IsDead() {
+// UpdateBehavior runs the behavior of the NPC to decide what it does next
+func (n *NPCState) UpdateBehavior(deltaTime float64, world behaviors.World, rng *rand.Rand) {
+	if n.IsDead() || n.IsAttacking {
+		// an npc finishes its attack before it decides anything else
+		n.Intent = behaviors.Intent{}
 		return
 	}
 
-	if n.RespawnTime() > 0 {
-		n.DecrementRespawnTime(deltaTime)
-		return
+	ctx := &behaviors.Context{
+		Agent: behaviors.Agent{
+			Position:     n.Position,
+			FlipH:        n.FlipH,
+			Hitpoints:    n.Hitpoints,
+			MaxHitpoints: n.Archetype.Hitpoints,
+			Home:         n.SpawnPosition,
+			PatrolMinX:   n.WanderRangeMin.X,
+			PatrolMaxX:   n.WanderRangeMax.X,
+			LineOfSight:  n.Archetype.LineOfSight,
+			AttackRange:  n.Archetype.AttackRange,
+		},
+		Memory:    &n.Memory,
+		World:     world,
+		RNG:       rng,
+		DeltaTime: deltaTime,
 	}
+	n.Behavior.Tick(ctx)
+	n.Intent = ctx.Intent
+}
 
-	n.Spawn()
+// UpdateDespawn counts down the time before a dead NPC despawns
+func (n *NPCState) UpdateDespawn(deltaTime float64) {
+	if !n.IsDead() {
+		return
+	}
+	n.deadTime += deltaTime
 }
 
 func (n *NPCState) UpdateYPosition(deltaTime float64) {
@@ -174,27 +197,26 @@ func (n *NPCState) UpdateFlipH() {
 		n.FlipH = false
 	} else if n.Velocity.X < 0 {
 		n.FlipH = true
+	} else if n.Intent.Face {
+		// turn to where the behavior wants the npc to face while it stands still
+		if n.Intent.FaceX > n.Position.X {
+			n.FlipH = false
+		} else if n.Intent.FaceX < n.Position.X {
+			n.FlipH = true
+		}
 	}
 }
 
-func (n *NPCState) UpdateAttack(deltaTime float64) {
+func (n *NPCState) UpdateAttack(deltaTime float64, rng *rand.Rand) {
+	n.Cooldowns.Update(deltaTime)
+
 	if n.IsAttacking {
 		beforeIsAttacking := n.IsAttacking
 
 		if n.AttackTimeLeft > 0 {
 			n.AttackTimeLeft -= deltaTime
 			if !n.DidAttackHit {
-				attackHitTime := 0.0
-				switch n.CurrentAttack {
-				case NPCAttack1:
-					attackHitTime = constants.NPCAttack1Duration - constants.NPCAttack1ChannelTime
-				case NPCAttack2:
-					attackHitTime = constants.NPCAttack2Duration - constants.NPCAttack2ChannelTime
-				case NPCAttack3:
-					attackHitTime = constants.NPCAttack3Duration - constants.NPCAttack3ChannelTime
-				}
-
-				if n.AttackTimeLeft <= attackHitTime {
+				if n.AttackTimeLeft <= n.CurrentAbility.HitTime() {
 					// register the hit only once
 					n.IsAttackHitting = true
 					n.DidAttackHit = true
@@ -206,6 +228,7 @@ func (n *NPCState) UpdateAttack(deltaTime float64) {
 			n.IsAttacking = false
 			n.IsAttackHitting = false
 			n.DidAttackHit = false
+			n.Cooldowns.Start(n.CurrentAbility)
 		}
 
 		// Reset the animation sequence if the player is no longer attacking
@@ -214,54 +237,31 @@ func (n *NPCState) UpdateAttack(deltaTime float64) {
 		}
 	}
 
-	if !n.IsAttacking && n.IsInAttackRange {
-		n.IsAttacking = true
-		// randomly choose an attack
-		attack := rand.Intn(3)
-		switch attack {
-		case 0:
-			n.CurrentAttack = NPCAttack1
-			n.AttackTimeLeft = constants.NPCAttack1Duration
-		case 1:
-			n.CurrentAttack = NPCAttack2
-			n.AttackTimeLeft = constants.NPCAttack2Duration
-		case 2:
-			n.CurrentAttack = NPCAttack3
-			n.AttackTimeLeft = constants.NPCAttack3Duration
+	if !n.IsAttacking && n.Intent.Attack {
+		// randomly choose an ability that is ready
+		var ready []*abilities.Ability
+		for _, ability := range n.Archetype.AbilitiesFrom(abilities.Active()) {
+			if n.Cooldowns.Ready(ability) {
+				ready = append(ready, ability)
+			}
+		}
+		if len(ready) > 0 {
+			n.IsAttacking = true
+			n.CurrentAbility = ready[rng.Intn(len(ready))]
+			n.AttackTimeLeft = n.CurrentAbility.Duration
 		}
 	}
 }
 
 func (n *NPCState) UpdateXPosition(deltaTime float64) {
 	var dx, vx float64
-	if !n.IsAttacking && !n.IsDead() {
-		if n.IsFollowing() {
-			if n.FollowTarget.Position.X < n.Position.X {
-				vx = -constants.NPCSpeed
-			} else if n.FollowTarget.Position.X > n.Position.X {
-				vx = constants.NPCSpeed
-			}
-			dx = kinematic.Displacement(vx, deltaTime, 0)
-			vx = kinematic.FinalVelocity(vx, deltaTime, 0)
-
-			if n.FollowTarget.Position.X < n.Position.X && n.Position.X+dx < n.FollowTarget.Position.X ||
-				n.FollowTarget.Position.X > n.Position.X && n.Position.X+dx > n.FollowTarget.Position.X {
-				// handle edge case where npc is directly on top of player and oscillates
-				dx, vx = 0, 0
-			}
-		} else if n.IsWandering() {
-			// move towards the wander target
-			dx = kinematic.MoveTowards(constants.NPCSpeed, deltaTime, 0, n.WanderTarget.X, n.Position.X)
-			vx = kinematic.FinalVelocity(constants.NPCSpeed, deltaTime, 0)
-			if dx < 0 {
-				vx = -vx
-			}
-		} else {
-			// idle for some period of time before wandering
-			n.IdleTimeLeft -= deltaTime
-			if n.IdleTimeLeft <= 0 {
-				n.StartWandering()
-			}
+	if !n.IsAttacking && !n.IsDead() && n.Intent.Move {
+		// move towards where the behavior wants the npc to go
+		dx = kinematic.MoveTowards(n.Archetype.Speed, deltaTime, 0, n.Intent.MoveX, n.Position.X)
+		if dx > 0 {
+			vx = kinematic.FinalVelocity(n.Archetype.Speed, deltaTime, 0)
+		} else if dx < 0 {
+			vx = -kinematic.FinalVelocity(n.Archetype.Speed, deltaTime, 0)
 		}
 	}
 
@@ -282,13 +282,10 @@ func (n *NPCState) UpdateAnimation() {
 	beforeAnimation := n.Animation
 
 	if n.IsAttacking {
-		switch n.CurrentAttack {
-		case NPCAttack1:
+		if animation, ok := NPCAnimationKeys[n.CurrentAbility.Animation]; ok {
+			n.Animation = animation
+		} else {
 			n.Animation = NPCAnimationAttack1
-		case NPCAttack2:
-			n.Animation = NPCAnimationAttack2
-		case NPCAttack3:
-			n.Animation = NPCAnimationAttack3
 		}
 	} else {
 		if n.IsDead() {
@@ -317,21 +314,21 @@ func (n *NPCState) IsDead() bool {
 	return n.Hitpoints <= 0
 }
 
-func (n *NPCState) DecrementRespawnTime(deltaTime float64) {
-	n.respawnTime -= deltaTime
+// IsDespawned returns true if the NPC has been dead for long enough to be removed from the game
+func (n *NPCState) IsDespawned() bool {
+	return n.IsDead() && n.deadTime >= constants.NPCCorpseTime
 }
 
-func (n *NPCState) Spawn() {
-	n.respawnTime = 0
-	n.mode = NPCModeIdle
-	n.IdleTimeLeft = rand.Float64() * constants.NPCMaxIdleTime
-	n.FollowTarget = nil
+func (n *NPCState) Spawn(rng *rand.Rand) {
+	n.deadTime = 0
+	n.Memory = behaviors.Memory{IdleTimeLeft: rng.Float64() * constants.NPCMaxIdleTime}
+	n.Intent = behaviors.Intent{}
 
 	n.Position = kinematic.NewVector(n.SpawnPosition.X, n.SpawnPosition.Y)
 	n.Velocity = kinematic.ZeroVector()
 	n.IsOnGround = false
 
-	n.Hitpoints = constants.NPCHitpoints
+	n.Hitpoints = n.Archetype.Hitpoints
 
 	n.FlipH = n.SpawnFlip
 	n.Animation = NPCAnimationIdle
@@ -339,111 +336,13 @@ func (n *NPCState) Spawn() {
 	n.ResetAnimation = false
 
 	n.IsAttacking = false
-	n.CurrentAttack = NPCAttack1
+	n.CurrentAbility = nil
 	n.AttackTimeLeft = 0
 	n.IsAttackHitting = false
 	n.DidAttackHit = false
+	n.Cooldowns = nil
 
 	n.Object.Position.X = n.Position.X
 	n.Object.Position.Y = n.Position.Y
 	n.Object.Update()
 }
-
-func (n *NPCState) Despawn() {
-	n.respawnTime = constants.NPCRespawnTime
-}
-
-func (n *NPCState) IsWandering() bool {
-	return n.mode == NPCModeWander
-}
-
-func (n *NPCState) StartWandering() {
-	n.mode = NPCModeWander
-	n.WanderTarget = &kinematic.Vector{
-		X: rand.Float64()*(n.WanderRangeMax.X-n.WanderRangeMin.X) + n.WanderRangeMin.X,
-		Y: n.Position.Y,
-	}
-}
-
-func (n *NPCState) StopWandering() {
-	n.mode = NPCModeIdle
-	n.WanderTarget = nil
-	n.IdleTimeLeft = rand.Float64() * constants.NPCMaxIdleTime
-}
-
-func (n *NPCState) StartFollowing(target *PlayerState) {
-	n.mode = NPCModeFollow
-	n.FollowTarget = target
-}
-
-func (n *NPCState) StopFollowing() {
-	n.FollowTarget = nil
-	n.IsInAttackRange = false
-	n.StartWandering()
-}
-
-func (n *NPCState) IsFollowing() bool {
-	return n.mode == NPCModeFollow
-}
-
-func (n *NPCState) UpdateFollowing() {
-	if !n.IsFollowing() {
-		return
-	}
-
-	if n.IsDead() {
-		n.StopFollowing()
-		return
-	}
-
-	if n.FollowTarget.IsDead() {
-		n.StopFollowing()
-		return
-	}
-
-	// check if the npc is too far from the player
-	if n.Position.DistanceFrom(n.FollowTarget.Position) > 2*constants.NPCLineOfSight {
-		n.StopFollowing()
-		return
-	}
-
-	// check if the npc has a direct line of sight to the player
-	lineOfSight := resolv.NewLine(n.Position.X+constants.NPCWidth/2, n.Position.Y+constants.NPCHeight/2, n.FollowTarget.Position.X+constants.PlayerWidth/2, n.FollowTarget.Position.Y+constants.PlayerHeight/2)
-	for _, obj := range n.Object.Space.Objects() {
-		if !obj.HasTags(CollisionSpaceTagLevel) && !obj.HasTags(CollisionSpaceTagPlatform) {
-			continue
-		}
-		if contact := lineOfSight.Intersection(0, 0, obj.Shape); contact != nil {
-			n.StopFollowing()
-			return
-		}
-	}
-
-	// check if the target is in front of the npc and within attack range
-	flip := 1.0
-	if n.FlipH {
-		flip = -1.0
-	}
-	xDistance := n.FollowTarget.Position.X - n.Position.X
-	if flip*xDistance > 0 && flip*xDistance < constants.NPCAttackRange {
-		n.IsInAttackRange = true
-	} else {
-		n.IsInAttackRange = false
-	}
-}
-
-func (n *NPCState) UpdateWandering(deltaTime float64) {
-	if !n.IsWandering() {
-		return
-	}
-
-	if n.IsDead() {
-		n.StopWandering()
-		return
-	}
-
-	if n.Position.Equals(*n.WanderTarget) {
-		n.StopWandering()
-		return
-	}
-}
@@ -1,7 +1,9 @@
 package types
 
 import (
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
 	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
 	"github.com/cbodonnell/flywheel/pkg/kinematic"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/solarlune/resolv"
@@ -20,24 +22,19 @@ type PlayerState struct {
 	LadderPosition           *kinematic.Vector
 	DismountedLadderPosition *kinematic.Vector
 	IsAttacking              bool
-	CurrentAttack            PlayerAttack
+	CurrentAbility           *abilities.Ability
 	AttackTimeLeft           float64
 	IsAttackHitting          bool
 	DidAttackHit             bool
+	Cooldowns                abilities.Cooldowns
 	Animation                PlayerAnimation
 	AnimationSequence        uint8
 	ResetAnimation           bool
 	Hitpoints                int16
+	// ZoneID is the ID of the zone the player is in
+	ZoneID uint32
 }
 
-type PlayerAttack uint8
-
-const (
-	PlayerAttack1 PlayerAttack = iota
-	PlayerAttack2
-	PlayerAttack3
-)
-
 type PlayerAnimation uint8
 
 const (
@@ -53,6 +50,20 @@ const (
 	PlayerAnimationDead
 )
 
+// PlayerAnimationKeys maps the animation keys used by abilities to player animations
+var PlayerAnimationKeys = map[string]PlayerAnimation{
+	"idle":         PlayerAnimationIdle,
+	"run":          PlayerAnimationRun,
+	"jump":         PlayerAnimationJump,
+	"ladder-idle":  PlayerAnimationLadderIdle,
+	"ladder-climb": PlayerAnimationLadderClimb,
+	"fall":         PlayerAnimationFall,
+	"attack1":      PlayerAnimationAttack1,
+	"attack2":      PlayerAnimationAttack2,
+	"attack3":      PlayerAnimationAttack3,
+	"dead":         PlayerAnimationDead,
+}
+
 func NewPlayerState(characterID int32, name string, position kinematic.Vector, flipH bool, hitpoints int16) *PlayerState {
 	object := resolv.NewObject(position.X, position.Y, constants.PlayerWidth, constants.PlayerHeight, CollisionSpaceTagPlayer)
 	object.SetShape(resolv.NewRectangle(0, 0, constants.PlayerWidth, constants.PlayerHeight))
@@ -97,13 +108,15 @@ func (p *PlayerState) Copy() *PlayerState {
 		IsOnGround:             p.IsOnGround,
 		IsOnLadder:             p.IsOnLadder,
 		IsAttacking:            p.IsAttacking,
-		CurrentAttack:          p.CurrentAttack,
+		CurrentAbility:         p.CurrentAbility,
 		AttackTimeLeft:         p.AttackTimeLeft,
 		IsAttackHitting:        p.IsAttackHitting,
 		DidAttackHit:           p.DidAttackHit,
+		Cooldowns:              p.Cooldowns.Copy(),
 		Animation:              p.Animation,
 		AnimationSequence:      p.AnimationSequence,
 		Hitpoints:              p.Hitpoints,
+		ZoneID:                 p.ZoneID,
 	}
 }
 
@@ -129,29 +142,25 @@ func (p *PlayerState) UpdateRespawn(clientPlayerUpdate *messages.ClientPlayerUpd
 	}
 
 	if clientPlayerUpdate.InputRespawn {
-		p.Respawn(kinematic.NewVector(constants.PlayerStartingX, constants.PlayerStartingY))
+		zone := levels.Active().Zone(p.ZoneID)
+		if zone == nil {
+			zone = levels.Active().Start()
+		}
+		p.Respawn(zone.Level.PlayerSpawn())
 	}
 }
 
 // UpdateAttack updates the player's attack state
 func (p *PlayerState) UpdateAttack(clientPlayerUpdate *messages.ClientPlayerUpdate) {
+	p.Cooldowns.Update(clientPlayerUpdate.DeltaTime)
+
 	if p.IsAttacking {
 		beforeIsAttacking := p.IsAttacking
 
 		if p.AttackTimeLeft > 0 {
 			p.AttackTimeLeft -= clientPlayerUpdate.DeltaTime
 			if !p.DidAttackHit {
-				attackHitTime := 0.0
-				switch p.CurrentAttack {
-				case PlayerAttack1:
-					attackHitTime = constants.PlayerAttack1Duration - constants.PlayerAttack1ChannelTime
-				case PlayerAttack2:
-					attackHitTime = constants.PlayerAttack2Duration - constants.PlayerAttack2ChannelTime
-				case PlayerAttack3:
-					attackHitTime = constants.PlayerAttack3Duration - constants.PlayerAttack3ChannelTime
-				}
-
-				if p.AttackTimeLeft <= attackHitTime {
+				if p.AttackTimeLeft <= p.CurrentAbility.HitTime() {
 					// register the hit only once
 					p.IsAttackHitting = true
 					p.DidAttackHit = true
@@ -163,6 +172,7 @@ func (p *PlayerState) UpdateAttack(clientPlayerUpdate *messages.ClientPlayerUpda
 			p.IsAttacking = false
 			p.IsAttackHitting = false
 			p.DidAttackHit = false
+			p.Cooldowns.Start(p.CurrentAbility)
 		}
 
 		// Reset the animation sequence if the player is no longer attacking
@@ -172,19 +182,17 @@ func (p *PlayerState) UpdateAttack(clientPlayerUpdate *messages.ClientPlayerUpda
 	}
 
 	if !p.IsAttacking && !p.IsDead() && !p.IsOnLadder {
-		switch {
-		case clientPlayerUpdate.InputAttack1:
-			p.IsAttacking = true
-			p.CurrentAttack = PlayerAttack1
-			p.AttackTimeLeft = constants.PlayerAttack1Duration
-		case clientPlayerUpdate.InputAttack2:
-			p.IsAttacking = true
-			p.CurrentAttack = PlayerAttack2
-			p.AttackTimeLeft = constants.PlayerAttack2Duration
-		case clientPlayerUpdate.InputAttack3:
+		// use the ability bound to the first attack input pressed that is ready
+		set := abilities.Active()
+		for slot, pressed := range []bool{clientPlayerUpdate.InputAttack1, clientPlayerUpdate.InputAttack2, clientPlayerUpdate.InputAttack3} {
+			ability := set.PlayerAbility(slot)
+			if !pressed || ability == nil || !p.Cooldowns.Ready(ability) {
+				continue
+			}
 			p.IsAttacking = true
-			p.CurrentAttack = PlayerAttack3
-			p.AttackTimeLeft = constants.PlayerAttack3Duration
+			p.CurrentAbility = ability
+			p.AttackTimeLeft = ability.Duration
+			break
 		}
 	}
 }
@@ -342,13 +350,10 @@ func (p *PlayerState) UpdateAnimation() {
 	beforeAnimation := p.Animation
 
 	if p.IsAttacking {
-		switch p.CurrentAttack {
-		case PlayerAttack1:
+		if animation, ok := PlayerAnimationKeys[p.CurrentAbility.Animation]; ok {
+			p.Animation = animation
+		} else {
 			p.Animation = PlayerAnimationAttack1
-		case PlayerAttack2:
-			p.Animation = PlayerAnimationAttack2
-		case PlayerAttack3:
-			p.Animation = PlayerAnimationAttack3
 		}
 	} else {
 		if p.IsDead() {
@@ -395,6 +400,19 @@ func (p *PlayerState) IsDead() bool {
 	return p.Hitpoints <= 0
 }
 
+// MoveTo moves the player to a position at rest, off any ladder
+func (p *PlayerState) MoveTo(position kinematic.Vector) {
+	p.Position = position
+	p.Velocity = kinematic.ZeroVector()
+	p.IsOnLadder = false
+	p.LadderPosition = nil
+	p.DismountedLadderPosition = nil
+
+	p.Object.Position.X = p.Position.X
+	p.Object.Position.Y = p.Position.Y
+	p.Object.Update()
+}
+
 // Respawns the player at the given position
 func (p *PlayerState) Respawn(position kinematic.Vector) {
 	p.Position = position
@@ -402,10 +420,11 @@ func (p *PlayerState) Respawn(position kinematic.Vector) {
 	p.Hitpoints = constants.PlayerHitpoints
 
 	p.IsAttacking = false
-	p.CurrentAttack = PlayerAttack1
+	p.CurrentAbility = nil
 	p.AttackTimeLeft = 0
 	p.IsAttackHitting = false
 	p.DidAttackHit = false
+	p.Cooldowns = nil
 
 	p.Animation = PlayerAnimationIdle
 	p.FlipH = false
@@ -1,6 +1,7 @@
 package game
 
 import (
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
 	"github.com/cbodonnell/flywheel/pkg/game/types"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 )
@@ -82,11 +83,19 @@ func NPCStateUpdateFromState(state *types.NPCState) *messages.NPCStateUpdate {
 		Animation:         uint8(state.Animation),
 		AnimationSequence: state.AnimationSequence,
 		Hitpoints:         state.Hitpoints,
+		Archetype:         state.Archetype.ID,
 	}
 }
 
+// NPCStateFromServerUpdate returns the NPC state of an update, with the default archetype
+// if the archetype of the update is not in the active set
 func NPCStateFromServerUpdate(update *messages.NPCStateUpdate) *types.NPCState {
+	archetype, ok := npcs.Active().Get(update.Archetype)
+	if !ok {
+		archetype = npcs.Active().DefaultArchetype()
+	}
 	return &types.NPCState{
+		Archetype:         archetype,
 		Position:          update.Position,
 		Velocity:          update.Velocity,
 		FlipH:             update.FlipH,
@@ -0,0 +1,245 @@
+package game
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+)
+
+const (
+	// MaxInputDeltaTime is the longest frame in seconds a client can simulate in one update
+	MaxInputDeltaTime = 0.1
+	// MaxDeltaTimeBurst is how many seconds of simulation a client can bank while its updates
+	// are delayed, beyond which the time simulated by a client cannot outpace the server clock
+	MaxDeltaTimeBurst = 1.0
+	// MaxTimestampDrift is how far in milliseconds the timestamps of a client can run ahead
+	// of the server clock, relative to the clock offset measured at its first update
+	MaxTimestampDrift = 1000
+	// SuspicionDecayRate is how much the suspicion score of a client decays per second
+	SuspicionDecayRate = 1.0
+	// SuspicionLogThreshold is the suspicion score above which a client is logged as suspicious
+	SuspicionLogThreshold = 10.0
+	// SuspicionKickThreshold is the suspicion score above which a client is kicked
+	SuspicionKickThreshold = 50.0
+)
+
+// InputViolation is a way in which a client player update breaks the rules of the game
+type InputViolation int
+
+const (
+	// InputViolationInvalidInput is an input that is not a number
+	InputViolationInvalidInput InputViolation = iota
+	// InputViolationInputOutOfRange is an axis input outside of [-1, 1]
+	InputViolationInputOutOfRange
+	// InputViolationDeltaTime is a negative delta time or one longer than MaxInputDeltaTime
+	InputViolationDeltaTime
+	// InputViolationSpeedHack is a delta time that exceeds the time elapsed on the server
+	InputViolationSpeedHack
+	// InputViolationFutureTimestamp is a timestamp too far ahead of the server clock
+	InputViolationFutureTimestamp
+	// InputViolationPastUpdates is too many past updates or past updates out of order
+	InputViolationPastUpdates
+)
+
+var inputViolationNames = map[InputViolation]string{
+	InputViolationInvalidInput:    "InvalidInput",
+	InputViolationInputOutOfRange: "InputOutOfRange",
+	InputViolationDeltaTime:       "DeltaTime",
+	InputViolationSpeedHack:       "SpeedHack",
+	InputViolationFutureTimestamp: "FutureTimestamp",
+	InputViolationPastUpdates:     "PastUpdates",
+}
+
+func (v InputViolation) String() string {
+	if name, ok := inputViolationNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("InputViolation(%d)", int(v))
+}
+
+// inputViolationWeights is how much each violation adds to the suspicion score of a client.
+// Violations that an honest client can cause, such as frame hitches, weigh the least.
+var inputViolationWeights = map[InputViolation]float64{
+	InputViolationInvalidInput:    10,
+	InputViolationInputOutOfRange: 5,
+	InputViolationDeltaTime:       1,
+	InputViolationSpeedHack:       1,
+	InputViolationFutureTimestamp: 5,
+	InputViolationPastUpdates:     5,
+}
+
+// ErrInvalidInput is returned when a client player update is rejected
+type ErrInvalidInput struct {
+	Violation InputViolation
+	Reason    string
+}
+
+func (e *ErrInvalidInput) Error() string {
+	return fmt.Sprintf("invalid input (%s): %s", e.Violation, e.Reason)
+}
+
+// ErrOutdatedInput is returned when a client player update is not newer than the last one received.
+// Updates that arrive late or twice are dropped without adding to the suspicion of the client.
+type ErrOutdatedInput struct {
+	Timestamp       int64
+	LatestTimestamp int64
+}
+
+func (e *ErrOutdatedInput) Error() string {
+	return fmt.Sprintf("update at %d is not newer than the update at %d", e.Timestamp, e.LatestTimestamp)
+}
+
+// InputValidator validates the player updates of clients before they are applied.
+// Out of range inputs are clamped, the time simulated by each client is capped to
+// the time elapsed on the server, and updates that cannot be trusted are rejected.
+// Every violation adds to a per-client suspicion score that decays over time.
+type InputValidator struct {
+	clients map[uint32]*clientInputState
+}
+
+// clientInputState is the validation state of a client
+type clientInputState struct {
+	// clockOffset is the difference between the client's timestamps and the server clock at its first update
+	clockOffset int64
+	// deltaTimeBudget is the number of seconds the client can still simulate
+	deltaTimeBudget float64
+	// suspicion is the suspicion score of the client
+	suspicion float64
+	// suspicious is set once the client is logged as suspicious
+	suspicious bool
+	// lastUpdate is the server time of the client's last update
+	lastUpdate int64
+	// latestTimestamp is the timestamp of the newest update received from the client
+	latestTimestamp int64
+}
+
+// NewInputValidator creates a new InputValidator
+func NewInputValidator() *InputValidator {
+	return &InputValidator{
+		clients: make(map[uint32]*clientInputState),
+	}
+}
+
+// Validate validates a player update received from a client at the server time now in milliseconds.
+// Past updates that were already received are removed, and inputs and delta times are clamped
+// in place so the update can be buffered. An ErrOutdatedInput is returned if the update is not
+// newer than the last one received, and an ErrInvalidInput if it must be rejected.
+func (v *InputValidator) Validate(clientID uint32, update *messages.ClientPlayerUpdate, now int64) error {
+	state, ok := v.clients[clientID]
+	if !ok {
+		state = &clientInputState{
+			clockOffset:     update.Timestamp - now,
+			deltaTimeBudget: MaxDeltaTimeBurst,
+			lastUpdate:      now,
+		}
+		v.clients[clientID] = state
+	}
+	elapsed := float64(now-state.lastUpdate) / 1000
+	if elapsed > 0 {
+		state.deltaTimeBudget = min(MaxDeltaTimeBurst, state.deltaTimeBudget+elapsed)
+		state.suspicion = max(0, state.suspicion-elapsed*SuspicionDecayRate)
+		state.lastUpdate = now
+	}
+
+	if update.Timestamp <= state.latestTimestamp {
+		return &ErrOutdatedInput{Timestamp: update.Timestamp, LatestTimestamp: state.latestTimestamp}
+	}
+	if drift := update.Timestamp - now - state.clockOffset; drift > MaxTimestampDrift {
+		return v.reject(clientID, state, InputViolationFutureTimestamp, fmt.Sprintf("timestamp is %dms ahead of the server clock", drift))
+	}
+
+	if len(update.PastUpdates) > messages.MaxPreviousUpdates {
+		v.record(clientID, state, InputViolationPastUpdates, fmt.Sprintf("%d past updates", len(update.PastUpdates)))
+		update.PastUpdates = update.PastUpdates[len(update.PastUpdates)-messages.MaxPreviousUpdates:]
+	}
+	pastUpdates := update.PastUpdates[:0]
+	previousTimestamp := state.latestTimestamp
+	for _, pastUpdate := range update.PastUpdates {
+		if pastUpdate.Timestamp <= state.latestTimestamp {
+			continue
+		}
+		if pastUpdate.Timestamp <= previousTimestamp || pastUpdate.Timestamp >= update.Timestamp {
+			v.record(clientID, state, InputViolationPastUpdates, "past updates out of order")
+			continue
+		}
+		pastUpdates = append(pastUpdates, pastUpdate)
+		previousTimestamp = pastUpdate.Timestamp
+	}
+	update.PastUpdates = pastUpdates
+
+	for _, pastUpdate := range update.PastUpdates {
+		if err := v.validateInputs(clientID, state, pastUpdate); err != nil {
+			return err
+		}
+	}
+	if err := v.validateInputs(clientID, state, update); err != nil {
+		return err
+	}
+	state.latestTimestamp = update.Timestamp
+	return nil
+}
+
+// validateInputs clamps the inputs and delta time of an update
+func (v *InputValidator) validateInputs(clientID uint32, state *clientInputState, update *messages.ClientPlayerUpdate) error {
+	for _, input := range []float64{update.InputX, update.InputY, update.DeltaTime} {
+		if math.IsNaN(input) || math.IsInf(input, 0) {
+			return v.reject(clientID, state, InputViolationInvalidInput, "input is not a number")
+		}
+	}
+
+	if update.InputX < -1 || update.InputX > 1 || update.InputY < -1 || update.InputY > 1 {
+		v.record(clientID, state, InputViolationInputOutOfRange, fmt.Sprintf("input (%f, %f)", update.InputX, update.InputY))
+		update.InputX = max(-1, min(1, update.InputX))
+		update.InputY = max(-1, min(1, update.InputY))
+	}
+
+	if update.DeltaTime < 0 || update.DeltaTime > MaxInputDeltaTime {
+		v.record(clientID, state, InputViolationDeltaTime, fmt.Sprintf("delta time %fs", update.DeltaTime))
+		update.DeltaTime = max(0, min(MaxInputDeltaTime, update.DeltaTime))
+	}
+
+	if update.DeltaTime > state.deltaTimeBudget {
+		v.record(clientID, state, InputViolationSpeedHack, fmt.Sprintf("delta time %fs exceeds the %fs elapsed", update.DeltaTime, state.deltaTimeBudget))
+		update.DeltaTime = state.deltaTimeBudget
+	}
+	state.deltaTimeBudget -= update.DeltaTime
+
+	return nil
+}
+
+// record adds a violation to the suspicion score of a client
+func (v *InputValidator) record(clientID uint32, state *clientInputState, violation InputViolation, reason string) {
+	state.suspicion += inputViolationWeights[violation]
+	log.Debug("Client %d input violation %s: %s (suspicion %.1f)", clientID, violation, reason, state.suspicion)
+
+	if state.suspicion > SuspicionLogThreshold && !state.suspicious {
+		log.Warn("Client %d is suspicious with a score of %.1f after a %s violation: %s", clientID, state.suspicion, violation, reason)
+	}
+	state.suspicious = state.suspicion > SuspicionLogThreshold
+}
+
+// reject records a violation and returns an error for the rejected update
+func (v *InputValidator) reject(clientID uint32, state *clientInputState, violation InputViolation, reason string) error {
+	v.record(clientID, state, violation, reason)
+	return &ErrInvalidInput{Violation: violation, Reason: reason}
+}
+
+// Suspicion returns the suspicion score of a client
+func (v *InputValidator) Suspicion(clientID uint32) float64 {
+	if state, ok := v.clients[clientID]; ok {
+		return state.suspicion
+	}
+	return 0
+}
+
+// ShouldKick returns true if the suspicion score of a client is above the kick threshold
+func (v *InputValidator) ShouldKick(clientID uint32) bool {
+	return v.Suspicion(clientID) > SuspicionKickThreshold
+}
+
+// Forget removes the validation state of a client
+func (v *InputValidator) Forget(clientID uint32) {
+	delete(v.clients, clientID)
+}
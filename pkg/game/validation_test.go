@@ -0,0 +1,100 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputValidator_Validate(t *testing.T) {
+	v := NewInputValidator()
+
+	// inputs out of range are clamped
+	update := &messages.ClientPlayerUpdate{Timestamp: 1000, InputX: 5, InputY: -2, DeltaTime: 1}
+	assert.NoError(t, v.Validate(1, update, 0))
+	assert.Equal(t, 1.0, update.InputX)
+	assert.Equal(t, -1.0, update.InputY)
+	assert.Equal(t, MaxInputDeltaTime, update.DeltaTime)
+	assert.Greater(t, v.Suspicion(1), 0.0)
+
+	// inputs that are not numbers are rejected
+	update = &messages.ClientPlayerUpdate{Timestamp: 1016, InputX: math.NaN(), DeltaTime: 0.016}
+	assert.Error(t, v.Validate(1, update, 16))
+
+	// timestamps too far ahead of the server clock are rejected, relative to the clock offset at the first update
+	update = &messages.ClientPlayerUpdate{Timestamp: 1032 + MaxTimestampDrift, DeltaTime: 0.016}
+	assert.NoError(t, v.Validate(1, update, 32))
+	update = &messages.ClientPlayerUpdate{Timestamp: 1048 + MaxTimestampDrift + 1, DeltaTime: 0.016}
+	err := v.Validate(1, update, 48)
+	if assert.IsType(t, &ErrInvalidInput{}, err) {
+		assert.Equal(t, InputViolationFutureTimestamp, err.(*ErrInvalidInput).Violation)
+	}
+
+	// updates that are not newer than the last one received are outdated, which is not suspicious
+	suspicion := v.Suspicion(1)
+	update = &messages.ClientPlayerUpdate{Timestamp: 1032 + MaxTimestampDrift, DeltaTime: 0.016}
+	assert.IsType(t, &ErrOutdatedInput{}, v.Validate(1, update, 48))
+	assert.Equal(t, suspicion, v.Suspicion(1))
+
+	v.Forget(1)
+	assert.Equal(t, 0.0, v.Suspicion(1))
+}
+
+func TestInputValidator_PastUpdates(t *testing.T) {
+	v := NewInputValidator()
+	assert.NoError(t, v.Validate(1, &messages.ClientPlayerUpdate{Timestamp: 20, DeltaTime: 0.01}, 0))
+
+	update := &messages.ClientPlayerUpdate{
+		Timestamp: 40,
+		DeltaTime: 0.01,
+		PastUpdates: []*messages.ClientPlayerUpdate{
+			{Timestamp: 10, DeltaTime: 0.01},
+			{Timestamp: 20, DeltaTime: 0.01},
+			{Timestamp: 30, DeltaTime: 0.01},
+		},
+	}
+	assert.NoError(t, v.Validate(1, update, 0))
+	// only the most recent past updates that were not received are kept
+	if assert.Len(t, update.PastUpdates, 1) {
+		assert.Equal(t, int64(30), update.PastUpdates[0].Timestamp)
+	}
+	assert.Equal(t, inputViolationWeights[InputViolationPastUpdates], v.Suspicion(1))
+
+	// past updates after the update are dropped
+	update = &messages.ClientPlayerUpdate{
+		Timestamp:   50,
+		DeltaTime:   0.01,
+		PastUpdates: []*messages.ClientPlayerUpdate{{Timestamp: 60, DeltaTime: 0.01}},
+	}
+	assert.NoError(t, v.Validate(1, update, 0))
+	assert.Empty(t, update.PastUpdates)
+}
+
+func TestInputValidator_SpeedHack(t *testing.T) {
+	v := NewInputValidator()
+
+	// an honest client simulates as much time as elapses on the server
+	now := int64(0)
+	for i := 0; i < 600; i++ {
+		now += 16
+		update := &messages.ClientPlayerUpdate{Timestamp: now, DeltaTime: 0.016}
+		assert.NoError(t, v.Validate(1, update, now))
+		assert.Equal(t, 0.016, update.DeltaTime)
+	}
+	assert.Equal(t, 0.0, v.Suspicion(1))
+	assert.False(t, v.ShouldKick(1))
+
+	// a client simulating twice as fast is capped once its burst is spent and eventually kicked
+	total := 0.0
+	for i := 0; i < 1200 && !v.ShouldKick(1); i++ {
+		now += 16
+		update := &messages.ClientPlayerUpdate{Timestamp: now, DeltaTime: 0.032}
+		assert.NoError(t, v.Validate(1, update, now))
+		total += update.DeltaTime
+	}
+	assert.True(t, v.ShouldKick(1))
+	elapsed := float64(now-600*16) / 1000
+	assert.LessOrEqual(t, total, elapsed+MaxDeltaTimeBurst+1e-9)
+}
@@ -0,0 +1,495 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/abilities"
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/levels"
+	"github.com/cbodonnell/flywheel/pkg/game/npcs"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/solarlune/resolv"
+)
+
+// Zone simulates an area of the world with a game state, collision space and NPCs of its own.
+// Players only see and interact with the entities in their zone, and messages about a zone
+// are only broadcast to the clients of its players.
+type Zone struct {
+	id                   uint32
+	level                *levels.Level
+	gameState            *types.GameState
+	broadcastMessageChan chan<- workers.BroadcastMessage
+	gameLoopInterval     time.Duration
+	// clientSnapshots maps client IDs to the snapshots sent to each client
+	clientSnapshots map[uint32]*ClientSnapshots
+	interestManager *InterestManager
+	// inputBuffers maps client IDs to the inputs buffered for each player
+	inputBuffers map[uint32]*InputBuffer
+	// playerStepAccumulator is the game time not yet simulated by player steps
+	playerStepAccumulator float64
+	// positionHistory is the positions of entities over the last ticks, used for lag compensation
+	positionHistory *PositionHistory
+	// maxRewind is the furthest back in time hits are rewound
+	maxRewind time.Duration
+	pvpRules  PvPRules
+	// inPortal is the set of clients whose players were in a portal after their last step,
+	// so that players only take a portal when they step into it
+	inPortal map[uint32]bool
+	// departures are the players that took a portal during the current tick
+	departures []departure
+	// spawners keep the populations of NPCs of the level
+	spawners []*Spawner
+	// npcSpawners maps NPC IDs to the spawners that spawned them
+	npcSpawners map[uint32]*Spawner
+	// nextNPCID is the ID of the next NPC spawned in the zone, and npcIDStride is added to it after each spawn
+	nextNPCID   uint32
+	npcIDStride uint32
+}
+
+// departure is a player taking a portal out of a zone
+type departure struct {
+	clientID uint32
+	portal   *levels.Portal
+}
+
+// NewZoneOptions contains options for creating a new Zone.
+type NewZoneOptions struct {
+	Zone                 *levels.Zone
+	BroadcastMessageChan chan<- workers.BroadcastMessage
+	GameLoopInterval     time.Duration
+	// InterestRadius is the distance from a player within which entities are sent to its client.
+	// Defaults to DefaultInterestRadius.
+	InterestRadius float64
+	// MaxRewind is the furthest back in time hits are rewound to compensate for the latency of a client.
+	// Defaults to DefaultMaxRewind.
+	MaxRewind time.Duration
+	PvPRules  PvPRules
+	// Seed seeds the random number stream of the zone
+	Seed int64
+	// FirstNPCID is the ID of the first NPC spawned in the zone and NPCIDStride is how far apart the IDs
+	// of its NPCs are, so that zones can spawn NPCs concurrently without their IDs colliding.
+	// Default to 1 and 1.
+	FirstNPCID  uint32
+	NPCIDStride uint32
+}
+
+func NewZone(opts NewZoneOptions) *Zone {
+	interestRadius := opts.InterestRadius
+	if interestRadius == 0 {
+		interestRadius = DefaultInterestRadius
+	}
+	maxRewind := opts.MaxRewind
+	if maxRewind == 0 {
+		maxRewind = DefaultMaxRewind
+	}
+	firstNPCID := opts.FirstNPCID
+	if firstNPCID == 0 {
+		firstNPCID = 1
+	}
+	npcIDStride := opts.NPCIDStride
+	if npcIDStride == 0 {
+		npcIDStride = 1
+	}
+	// keep enough ticks to interpolate at the max rewind
+	historySize := 2
+	if opts.GameLoopInterval > 0 {
+		historySize += int(maxRewind / opts.GameLoopInterval)
+	}
+
+	return &Zone{
+		id:                   opts.Zone.ID,
+		level:                opts.Zone.Level,
+		gameState:            types.NewGameState(NewCollisionSpace(opts.Zone.Level), opts.Seed),
+		broadcastMessageChan: opts.BroadcastMessageChan,
+		gameLoopInterval:     opts.GameLoopInterval,
+		clientSnapshots:      make(map[uint32]*ClientSnapshots),
+		interestManager:      NewInterestManager(interestRadius),
+		inputBuffers:         make(map[uint32]*InputBuffer),
+		positionHistory:      NewPositionHistory(historySize),
+		maxRewind:            maxRewind,
+		pvpRules:             opts.PvPRules,
+		inPortal:             make(map[uint32]bool),
+		npcSpawners:          make(map[uint32]*Spawner),
+		nextNPCID:            firstNPCID,
+		npcIDStride:          npcIDStride,
+	}
+}
+
+// spawnNPCs creates the spawners of the level of the zone and spawns their initial NPCs
+func (z *Zone) spawnNPCs() error {
+	for _, levelSpawner := range z.level.NPCSpawners {
+		archetype := npcs.Active().DefaultArchetype()
+		if levelSpawner.Archetype != "" {
+			var ok bool
+			if archetype, ok = npcs.Active().Get(levelSpawner.Archetype); !ok {
+				return fmt.Errorf("spawner %d in zone %d has unknown archetype %q", levelSpawner.ID, z.id, levelSpawner.Archetype)
+			}
+		}
+		spawner := NewSpawner(levelSpawner, archetype)
+		z.spawners = append(z.spawners, spawner)
+		for i := 0; i < spawner.Initial(); i++ {
+			z.spawnNPC(spawner)
+		}
+	}
+	return nil
+}
+
+// spawnNPC spawns an NPC of a spawner with the next NPC ID of the zone,
+// at a random position in the area of the spawner
+func (z *Zone) spawnNPC(spawner *Spawner) {
+	x := spawner.Position.X - constants.NPCWidth/2
+	if spawner.Width > constants.NPCWidth {
+		x += (z.gameState.RNG.Float64() - 0.5) * (spawner.Width - constants.NPCWidth)
+	}
+	spawnPosition := kinematic.NewVector(x, spawner.Position.Y)
+	// keep the npc within the walls at the edges of the level
+	wanderRangeMinX := max(spawnPosition.X-spawner.WanderRange, float64(constants.CellWidth))
+	wanderRangeMaxX := min(spawnPosition.X+spawner.WanderRange, float64(z.level.Width-constants.CellWidth)-constants.NPCWidth)
+
+	npcID := z.nextNPCID
+	z.nextNPCID += z.npcIDStride
+	npcState := types.NewNPCState(npcID, spawner.Archetype(), spawnPosition, wanderRangeMinX, wanderRangeMaxX, spawner.FlipH)
+	z.gameState.NPCs[npcID] = npcState
+	z.gameState.CollisionSpace.Add(npcState.Object)
+	npcState.Spawn(z.gameState.RNG)
+	z.npcSpawners[npcID] = spawner
+	spawner.Spawned()
+	log.Debug("Spawned %s NPC %d in zone %d", spawner.Archetype().ID, npcID, z.id)
+}
+
+// despawnNPC removes an NPC from the zone and lets its spawner replace it
+func (z *Zone) despawnNPC(npcID uint32) {
+	npcState, ok := z.gameState.NPCs[npcID]
+	if !ok {
+		return
+	}
+	z.gameState.CollisionSpace.Remove(npcState.Object)
+	delete(z.gameState.NPCs, npcID)
+	if spawner, ok := z.npcSpawners[npcID]; ok {
+		delete(z.npcSpawners, npcID)
+		spawner.Despawned()
+	}
+	log.Debug("Despawned NPC %d in zone %d", npcID, z.id)
+}
+
+// updateSpawners spawns the NPCs that are due to respawn
+// and despawns the NPCs that have been dead for long enough
+func (z *Zone) updateSpawners(deltaTime float64) {
+	// respawn timers started this tick only start counting down next tick
+	for _, spawner := range z.spawners {
+		for due := spawner.Update(deltaTime); due > 0; due-- {
+			z.spawnNPC(spawner)
+		}
+	}
+	for _, npcID := range z.gameState.NPCIDs() {
+		if z.gameState.NPCs[npcID].IsDespawned() {
+			z.despawnNPC(npcID)
+		}
+	}
+}
+
+// addPlayer adds a player to the zone with the inputs buffered for it, if any
+func (z *Zone) addPlayer(clientID uint32, playerState *types.PlayerState, inputBuffer *InputBuffer) {
+	if inputBuffer == nil {
+		inputBuffer = NewInputBuffer()
+	}
+	playerState.ZoneID = z.id
+	z.gameState.Players[clientID] = playerState
+	z.gameState.CollisionSpace.Add(playerState.Object)
+	// start tracking the snapshots sent to the client
+	z.clientSnapshots[clientID] = NewClientSnapshots()
+	z.inputBuffers[clientID] = inputBuffer
+	// a player that arrives in a portal has to step out of it before it can take it
+	z.inPortal[clientID] = playerState.Object.Check(0, 0, types.CollisionSpaceTagPortal) != nil
+}
+
+// removePlayer removes a player from the zone and returns its state and buffered inputs
+func (z *Zone) removePlayer(clientID uint32) (*types.PlayerState, *InputBuffer) {
+	playerState, ok := z.gameState.Players[clientID]
+	if !ok {
+		return nil, nil
+	}
+	z.gameState.CollisionSpace.Remove(playerState.Object)
+	// npcs stop fighting a player that leaves the zone
+	for _, npcID := range z.gameState.NPCIDs() {
+		npcState := z.gameState.NPCs[npcID]
+		if npcState.Memory.HasTarget && npcState.Memory.TargetID == clientID {
+			npcState.Memory.Forget()
+		}
+	}
+	inputBuffer := z.inputBuffers[clientID]
+	delete(z.gameState.Players, clientID)
+	delete(z.clientSnapshots, clientID)
+	delete(z.inputBuffers, clientID)
+	delete(z.inPortal, clientID)
+	return playerState, inputBuffer
+}
+
+// broadcast sends a message to the clients of the players in the zone
+func (z *Zone) broadcast(messageType messages.MessageType, message interface{}) {
+	z.broadcastMessageChan <- workers.BroadcastMessage{
+		ClientIDs: z.gameState.PlayerIDs(),
+		Type:      messageType,
+		Message:   message,
+	}
+}
+
+// tick runs one tick of the game loop in the zone
+func (z *Zone) tick(tick uint64, timestamp int64) {
+	z.gameState.Tick = tick
+	z.gameState.Timestamp = timestamp
+	z.departures = z.departures[:0]
+	z.updateServerObjects(z.gameLoopInterval.Seconds())
+	z.positionHistory.Record(z.gameState)
+	z.broadcastGameState()
+}
+
+// checkPortal has a player take a portal when it steps into one
+func (z *Zone) checkPortal(clientID uint32, playerState *types.PlayerState) {
+	collision := playerState.Object.Check(0, 0, types.CollisionSpaceTagPortal)
+	wasInPortal := z.inPortal[clientID]
+	z.inPortal[clientID] = collision != nil
+	if collision == nil || wasInPortal || playerState.IsDead() {
+		return
+	}
+	for _, obj := range collision.ObjectsByTags(types.CollisionSpaceTagPortal) {
+		portal, ok := obj.Data.(*levels.Portal)
+		if !ok {
+			continue
+		}
+		log.Debug("Player %d took a portal in zone %d to zone %d", clientID, z.id, portal.Zone)
+		z.departures = append(z.departures, departure{clientID: clientID, portal: portal})
+		return
+	}
+}
+
+// checkPlayerCollisions checks for collisions between a player and other objects in the game.
+func (z *Zone) checkPlayerCollisions(clientID uint32, playerState *types.PlayerState) {
+	// do attack hit detection
+	if !playerState.IsAttackHitting {
+		return
+	}
+
+	// create an attack hitbox for the player
+	attackHitbox := newAbilityHitbox(playerState.Object, playerState.CurrentAbility, playerState.FlipH)
+	z.gameState.CollisionSpace.Add(attackHitbox)
+	defer z.gameState.CollisionSpace.Remove(attackHitbox)
+
+	// check the hit against where the npcs were rendered by the attacker's client
+	renderTime := z.renderTime(playerState.LastProcessedTimestamp)
+
+	// TODO: check for collision and get the ID from the collision shape data
+	for _, npcID := range z.gameState.NPCIDs() {
+		npcState := z.gameState.NPCs[npcID]
+		if npcState.IsDead() {
+			continue
+		}
+
+		restore := z.rewindNPC(npcID, npcState, renderTime)
+		hit := attackHitbox.SharesCells(npcState.Object)
+		restore()
+		if !hit {
+			continue
+		}
+
+		// player hit npc
+		log.Debug("Player %d hit NPC %d", clientID, npcID)
+
+		damage := playerState.CurrentAbility.Damage
+		npcState.TakeDamage(damage)
+
+		npcHit := &messages.ServerNPCHit{
+			NPCID:    npcID,
+			PlayerID: clientID,
+			Damage:   damage,
+		}
+		z.broadcast(messages.MessageTypeServerNPCHit, npcHit)
+
+		if !npcState.IsDead() {
+			// whether the npc fights back is up to its behavior
+			npcState.Memory.Provoke(clientID)
+			continue
+		}
+
+		// player killed npc
+		log.Debug("Player %d killed NPC %d", clientID, npcID)
+		npcKill := &messages.ServerNPCKill{
+			NPCID:    npcID,
+			PlayerID: clientID,
+		}
+		z.broadcast(messages.MessageTypeServerNPCKill, npcKill)
+	}
+
+	if z.pvpRules.Enabled {
+		z.checkPlayerPvPHits(clientID, playerState, attackHitbox, renderTime)
+	}
+}
+
+// newAbilityHitbox creates the hitbox of an ability used by the owner of an object
+func newAbilityHitbox(object *resolv.Object, ability *abilities.Ability, flipH bool) *resolv.Object {
+	hitbox := object.Clone()
+	hitbox.Size.X = ability.Hitbox.Width
+	if ability.Hitbox.Height > 0 {
+		hitbox.Size.Y = ability.Hitbox.Height
+	}
+	if !flipH {
+		hitbox.Position.X += ability.Hitbox.OffsetX
+	} else {
+		// mirror the hitbox about the middle of the object
+		hitbox.Position.X += object.Size.X - ability.Hitbox.OffsetX - ability.Hitbox.Width
+	}
+	hitbox.Position.Y += ability.Hitbox.OffsetY
+	return hitbox
+}
+
+// updateServerObjects updates server objects (e.g. players, npcs, items, projectiles, etc.)
+func (z *Zone) updateServerObjects(deltaTime float64) {
+	// players are simulated at a fixed timestep so that the server and the
+	// client's prediction advance them by the same steps
+	z.playerStepAccumulator += deltaTime
+	for z.playerStepAccumulator >= constants.PlayerStepDuration-playerStepEpsilon {
+		z.stepPlayers()
+		z.playerStepAccumulator -= constants.PlayerStepDuration
+	}
+
+	world := &npcWorld{gameState: z.gameState}
+	for _, npcID := range z.gameState.NPCIDs() {
+		npcState := z.gameState.NPCs[npcID]
+		if npcState.IsAttacking && npcState.IsAttackHitting {
+			// npc is hitting with an attack
+			z.checkNPCAttackHit(npcID, npcState)
+		}
+
+		npcStateChanged := npcState.Update(deltaTime, world, z.gameState.RNG)
+		if npcStateChanged {
+			log.Trace("NPC %d updated", npcID)
+		}
+	}
+
+	z.updateSpawners(deltaTime)
+}
+
+// stepPlayers advances every player by one fixed timestep, applying
+// the next buffered input of its client, or repeating its last one
+func (z *Zone) stepPlayers() {
+	for _, clientID := range z.gameState.PlayerIDs() {
+		playerState := z.gameState.Players[clientID]
+		inputBuffer, ok := z.inputBuffers[clientID]
+		if !ok {
+			inputBuffer = NewInputBuffer()
+			z.inputBuffers[clientID] = inputBuffer
+		}
+		input, received := inputBuffer.Next()
+		if !received {
+			log.Trace("Repeating the last input of client %d with %d buffered", clientID, inputBuffer.Len())
+		}
+
+		// the server's timestep is authoritative, whatever the client simulated
+		step := *input
+		step.DeltaTime = constants.PlayerStepDuration
+		if changed := playerState.ApplyInput(&step); changed {
+			log.Trace("Player %d updated", clientID)
+		}
+
+		z.checkPlayerCollisions(clientID, playerState)
+		z.checkPortal(clientID, playerState)
+	}
+}
+
+func (z *Zone) checkNPCAttackHit(npcID uint32, npcState *types.NPCState) {
+	attackHitbox := newAbilityHitbox(npcState.Object, npcState.CurrentAbility, npcState.FlipH)
+	z.gameState.CollisionSpace.Add(attackHitbox)
+	defer z.gameState.CollisionSpace.Remove(attackHitbox)
+
+	for _, playerID := range z.gameState.PlayerIDs() {
+		playerState := z.gameState.Players[playerID]
+		if playerState.IsDead() {
+			continue
+		}
+
+		if !attackHitbox.SharesCells(playerState.Object) {
+			continue
+		}
+
+		log.Debug("NPC %d hit player %d", npcID, playerID)
+
+		damage := npcState.CurrentAbility.Damage
+		playerState.TakeDamage(damage)
+
+		playerHit := &messages.ServerPlayerHit{
+			PlayerID:     playerID,
+			AttackerType: messages.EntityTypeNPC,
+			AttackerID:   npcID,
+			Damage:       damage,
+		}
+		z.broadcast(messages.MessageTypeServerPlayerHit, playerHit)
+
+		if !playerState.IsDead() {
+			continue
+		}
+
+		log.Debug("NPC %d killed player %d", npcID, playerID)
+		playerKill := &messages.ServerPlayerKill{
+			PlayerID:     playerID,
+			AttackerType: messages.EntityTypeNPC,
+			AttackerID:   npcID,
+		}
+		z.broadcast(messages.MessageTypeServerPlayerKill, playerKill)
+	}
+}
+
+// broadcastGameState sends the game state to connected clients.
+// Each client only receives the entities in its view, as a delta
+// against the last snapshot it acknowledged.
+func (z *Zone) broadcastGameState() {
+	snapshot := ServerGameUpdateFromState(z.gameState)
+	viewChanges := z.interestManager.Update(z.gameState)
+
+	for clientID := range z.gameState.Players {
+		for _, change := range viewChanges[clientID] {
+			z.broadcastViewChange(clientID, change)
+		}
+
+		clientSnapshots, ok := z.clientSnapshots[clientID]
+		if !ok {
+			log.Warn("Client %d has no snapshot history", clientID)
+			continue
+		}
+
+		view := z.interestManager.View(clientID)
+		z.broadcastMessageChan <- workers.BroadcastMessage{
+			ClientID: clientID,
+			Type:     messages.MessageTypeServerGameUpdate,
+			Message:  clientSnapshots.Next(view.Filter(snapshot)),
+		}
+	}
+}
+
+// broadcastViewChange notifies a client that an entity entered or left its view
+func (z *Zone) broadcastViewChange(clientID uint32, change ViewChange) {
+	if change.Entered {
+		z.broadcastMessageChan <- workers.BroadcastMessage{
+			ClientID: clientID,
+			Type:     messages.MessageTypeServerEntityEnterView,
+			Message: &messages.ServerEntityEnterView{
+				EntityType: change.EntityType,
+				EntityID:   change.EntityID,
+			},
+		}
+		return
+	}
+
+	z.broadcastMessageChan <- workers.BroadcastMessage{
+		ClientID: clientID,
+		Type:     messages.MessageTypeServerEntityLeaveView,
+		Message: &messages.ServerEntityLeaveView{
+			EntityType: change.EntityType,
+			EntityID:   change.EntityID,
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/game/constants"
+	"github.com/cbodonnell/flywheel/pkg/game/types"
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/workers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGameManager_Portal(t *testing.T) {
+	gameLoopInterval := 50 * time.Millisecond
+	clientMessageQueue := queue.NewInMemoryQueue(1000)
+	serverEventQueue := queue.NewInMemoryQueue(10)
+	broadcastMessageChan := make(chan workers.BroadcastMessage, 1000)
+	gm := NewGameManager(NewGameManagerOptions{
+		ClientMessageQueue:   clientMessageQueue,
+		ServerEventQueue:     serverEventQueue,
+		SaveStateChan:        make(chan workers.SaveStateRequest, 1),
+		BroadcastMessageChan: broadcastMessageChan,
+		GameLoopInterval:     gameLoopInterval,
+		SaveStateInterval:    time.Hour,
+		Seed:                 1,
+	})
+	ctx := context.Background()
+	assert.NoError(t, gm.initializeGameState(ctx))
+
+	// start just left of the portal of the meadow that leads to the cave
+	err := serverEventQueue.Enqueue(&types.ConnectPlayerEvent{
+		ClientID:           1,
+		CharacterID:        1,
+		CharacterName:      "player-1",
+		CharacterPosition:  kinematic.NewVector(1140, 16),
+		CharacterHitpoints: 50,
+		CharacterZoneID:    1,
+	})
+	assert.NoError(t, err)
+
+	stepsPerTick := int(gameLoopInterval.Seconds() / constants.PlayerStepDuration)
+	stepMillis := gameLoopInterval.Milliseconds() / int64(stepsPerTick)
+	var sequence uint32
+	var zoneChange *messages.ServerZoneChange
+	for tick := 0; tick < 20; tick++ {
+		for i := 0; i < stepsPerTick; i++ {
+			sequence++
+			payload, err := messages.SerializeClientPlayerUpdate(&messages.ClientPlayerUpdate{
+				Sequence:  sequence,
+				Timestamp: int64(sequence) * stepMillis,
+				InputX:    1,
+				DeltaTime: constants.PlayerStepDuration,
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, clientMessageQueue.Enqueue(&messages.Message{
+				ClientID: 1,
+				Type:     messages.MessageTypeClientPlayerUpdate,
+				Payload:  payload,
+			}))
+		}
+		assert.NoError(t, gm.gameTick(ctx))
+
+		for len(broadcastMessageChan) > 0 {
+			broadcast := <-broadcastMessageChan
+			if broadcast.Type == messages.MessageTypeServerZoneChange {
+				assert.Equal(t, uint32(1), broadcast.ClientID)
+				zoneChange = broadcast.Message.(*messages.ServerZoneChange)
+				assert.Equal(t, gm.timestamp, zoneChange.Timestamp)
+			}
+		}
+	}
+
+	// the player arrives at the spawn point of the cave, as it was when it left the meadow
+	if assert.NotNil(t, zoneChange) {
+		assert.Equal(t, uint32(2), zoneChange.ZoneID)
+		assert.Equal(t, int16(50), zoneChange.PlayerState.Hitpoints)
+	}
+	assert.Equal(t, uint32(2), gm.clientZones[1])
+	assert.NotContains(t, gm.zones[1].gameState.Players, uint32(1))
+	if assert.Contains(t, gm.zones[2].gameState.Players, uint32(1)) {
+		playerState := gm.zones[2].gameState.Players[1]
+		assert.Equal(t, uint32(2), playerState.ZoneID)
+		assert.Equal(t, int16(50), playerState.Hitpoints)
+		assert.Same(t, gm.zones[2].gameState.CollisionSpace, playerState.Object.Space)
+	}
+}
+
+func TestGameManager_UniqueNPCIDs(t *testing.T) {
+	gm := NewGameManager(NewGameManagerOptions{
+		ClientMessageQueue:   queue.NewInMemoryQueue(10),
+		ServerEventQueue:     queue.NewInMemoryQueue(10),
+		SaveStateChan:        make(chan workers.SaveStateRequest, 1),
+		BroadcastMessageChan: make(chan workers.BroadcastMessage, 1000),
+		GameLoopInterval:     50 * time.Millisecond,
+		SaveStateInterval:    time.Hour,
+		Seed:                 1,
+	})
+	assert.NoError(t, gm.initializeGameState(context.Background()))
+
+	// every zone spawns more npcs, as spawners do when their npcs die
+	for _, zone := range gm.zones {
+		for _, spawner := range zone.spawners {
+			zone.spawnNPC(spawner)
+		}
+	}
+
+	zoneIDs := make(map[uint32]uint32)
+	for zoneID, zone := range gm.zones {
+		assert.NotEmpty(t, zone.gameState.NPCs)
+		for npcID := range zone.gameState.NPCs {
+			if otherZoneID, ok := zoneIDs[npcID]; ok {
+				t.Errorf("npc %d is in zones %d and %d", npcID, otherZoneID, zoneID)
+			}
+			zoneIDs[npcID] = zoneID
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package messages
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	captureWriter io.Writer
+	captureErr    error
+	captureMu     sync.Mutex
+	// capturing lets messages skip the lock when nothing is being captured
+	capturing atomic.Bool
+)
+
+// CaptureMessages writes every message serialized by SerializeMessage to a writer,
+// as frames of the message before it is compressed, to be used as samples for
+// BuildCompressionDictionary. Capturing stops at the first failed write.
+func CaptureMessages(w io.Writer) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	captureWriter, captureErr = w, nil
+	capturing.Store(w != nil)
+}
+
+// StopCapturingMessages stops capturing messages and returns the error
+// that stopped the capture early, if any
+func StopCapturingMessages() error {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	err := captureErr
+	captureWriter, captureErr = nil, nil
+	capturing.Store(false)
+	return err
+}
+
+// captureMessage writes a serialized message to the capture writer, if there is one.
+// Serialization does not fail with the capture, which is only a debugging aid.
+func captureMessage(b []byte) {
+	if !capturing.Load() {
+		return
+	}
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if captureWriter == nil {
+		return
+	}
+	if err := WriteFrame(captureWriter, b); err != nil {
+		captureWriter, captureErr = nil, fmt.Errorf("failed to write captured message: %v", err)
+		capturing.Store(false)
+	}
+}
+
+// ReadCapturedMessages reads the messages written by CaptureMessages.
+// A capture cut off mid-message, such as by a killed server, ends at its last whole message.
+func ReadCapturedMessages(r io.Reader) ([][]byte, error) {
+	var samples [][]byte
+	for {
+		b, err := ReadFrame(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read captured message %d: %v", len(samples), err)
+		}
+		samples = append(samples, b)
+	}
+}
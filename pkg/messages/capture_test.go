@@ -0,0 +1,87 @@
+package messages
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestCaptureMessages(t *testing.T) {
+	msgs := benchmarkMessages(t)
+
+	var buf bytes.Buffer
+	CaptureMessages(&buf)
+	for _, msg := range msgs {
+		_, err := SerializeMessage(msg)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, StopCapturingMessages())
+
+	// messages serialized after the capture stops are not captured
+	_, err := SerializeMessage(msgs[0])
+	assert.NoError(t, err)
+
+	samples, err := ReadCapturedMessages(&buf)
+	if !assert.NoError(t, err) || !assert.Len(t, samples, len(msgs)) {
+		return
+	}
+	for i, msg := range msgs {
+		want, err := SerializeMessageFlatbuffer(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, want, samples[i])
+	}
+}
+
+func TestCaptureMessages_WriteError(t *testing.T) {
+	msg := benchmarkMessages(t)[0]
+
+	CaptureMessages(failingWriter{})
+	// serialization does not fail with the capture
+	_, err := SerializeMessage(msg)
+	assert.NoError(t, err)
+	_, err = SerializeMessage(msg)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, StopCapturingMessages(), "disk full")
+	assert.NoError(t, StopCapturingMessages())
+}
+
+func TestReadCapturedMessages(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteFrame(&buf, []byte("first")))
+	assert.NoError(t, WriteFrame(&buf, []byte("second")))
+	b := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		capture []byte
+		want    [][]byte
+		wantErr bool
+	}{
+		{name: "empty", capture: nil, want: nil},
+		{name: "whole", capture: b, want: [][]byte{[]byte("first"), []byte("second")}},
+		{name: "truncated message", capture: b[:len(b)-1], want: [][]byte{[]byte("first")}},
+		{name: "truncated header", capture: b[:FrameHeaderSize-1], want: nil},
+		{name: "not a capture", capture: bytes.Repeat([]byte{0xff}, 16), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := ReadCapturedMessages(bytes.NewReader(tt.capture))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, samples)
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package messages
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionDictionaryID is the ID of dictionaries built by BuildCompressionDictionary
+	CompressionDictionaryID = 1
+	// MaxCompressionDictionaryHistory is the maximum size of the history of a built dictionary
+	MaxCompressionDictionaryHistory = 32 << 10
+	// MinCompressionDictionarySamples is the minimum number of samples needed to build a dictionary
+	MinCompressionDictionarySamples = 1000
+	// MinCompressionDictionarySize is the minimum total size of the distinct samples needed to build a dictionary
+	MinCompressionDictionarySize = 64 << 10
+)
+
+// Codec compresses and decompresses serialized messages with zstd.
+// Encoders and decoders are expensive to create, so they are pooled and
+// reused across messages with EncodeAll and DecodeAll.
+type Codec struct {
+	encoderOptions []zstd.EOption
+	decoderOptions []zstd.DOption
+	encoders       sync.Pool
+	decoders       sync.Pool
+	// dictionaryChecksum identifies the dictionary of the codec, or is 0 without one
+	dictionaryChecksum uint32
+}
+
+// NewCodecOptions are the options for creating a new Codec
+type NewCodecOptions struct {
+	// Dictionary is an optional zstd dictionary used to compress messages.
+	// Peers must use the same dictionary, though a codec with a dictionary
+	// can still decompress messages compressed without one.
+	Dictionary []byte
+}
+
+// NewCodec creates a new Codec
+func NewCodec(opts NewCodecOptions) (*Codec, error) {
+	c := &Codec{
+		encoderOptions: []zstd.EOption{
+			zstd.WithEncoderLevel(zstd.SpeedFastest),
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithLowerEncoderMem(true),
+		},
+		decoderOptions: []zstd.DOption{
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(MaxFrameSize),
+		},
+		dictionaryChecksum: DictionaryChecksum(opts.Dictionary),
+	}
+	if opts.Dictionary != nil {
+		c.encoderOptions = append(c.encoderOptions, zstd.WithEncoderDict(opts.Dictionary))
+		c.decoderOptions = append(c.decoderOptions, zstd.WithDecoderDicts(opts.Dictionary))
+	}
+
+	// create the first encoder and decoder up front so invalid options are reported here
+	encoder, err := c.getEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %v", err)
+	}
+	c.encoders.Put(encoder)
+	decoder, err := c.getDecoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %v", err)
+	}
+	c.decoders.Put(decoder)
+
+	return c, nil
+}
+
+// Compress appends the compressed src to dst and returns the result
+func (c *Codec) Compress(dst, src []byte) ([]byte, error) {
+	encoder, err := c.getEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %v", err)
+	}
+	defer c.encoders.Put(encoder)
+	return encoder.EncodeAll(src, dst), nil
+}
+
+// Decompress appends the decompressed src to dst and returns the result
+func (c *Codec) Decompress(dst, src []byte) ([]byte, error) {
+	decoder, err := c.getDecoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %v", err)
+	}
+	defer c.decoders.Put(decoder)
+	b, err := decoder.DecodeAll(src, dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %v", err)
+	}
+	return b, nil
+}
+
+// DictionaryChecksum returns the checksum of the dictionary of the codec, or 0 without one
+func (c *Codec) DictionaryChecksum() uint32 {
+	return c.dictionaryChecksum
+}
+
+func (c *Codec) getEncoder() (*zstd.Encoder, error) {
+	if encoder, ok := c.encoders.Get().(*zstd.Encoder); ok {
+		return encoder, nil
+	}
+	return zstd.NewWriter(nil, c.encoderOptions...)
+}
+
+func (c *Codec) getDecoder() (*zstd.Decoder, error) {
+	if decoder, ok := c.decoders.Get().(*zstd.Decoder); ok {
+		return decoder, nil
+	}
+	return zstd.NewReader(nil, c.decoderOptions...)
+}
+
+// DictionaryChecksum returns the checksum that identifies a dictionary, or 0 for no dictionary.
+// Every dictionary built by BuildCompressionDictionary has the same zstd ID,
+// so peers compare the checksum of its content instead.
+func DictionaryChecksum(dict []byte) uint32 {
+	if len(dict) == 0 {
+		return 0
+	}
+	if checksum := crc32.ChecksumIEEE(dict); checksum != 0 {
+		return checksum
+	}
+	return 1
+}
+
+// ErrCompressionDictionaryMismatch is returned when a peer compresses messages with a different dictionary
+type ErrCompressionDictionaryMismatch struct {
+	Checksum     uint32
+	PeerChecksum uint32
+}
+
+func (e *ErrCompressionDictionaryMismatch) Error() string {
+	return fmt.Sprintf("compression dictionary %08x does not match the peer's dictionary %08x", e.Checksum, e.PeerChecksum)
+}
+
+var (
+	defaultCodec   *Codec
+	defaultCodecMu sync.RWMutex
+	// handshakeCodec never uses a dictionary, so peers with different
+	// dictionaries can still log in and be told of the mismatch
+	handshakeCodec *Codec
+)
+
+func init() {
+	codec, err := NewCodec(NewCodecOptions{})
+	if err != nil {
+		panic(fmt.Sprintf("failed to create default codec: %v", err))
+	}
+	defaultCodec = codec
+	handshakeCodec = codec
+}
+
+// SetCompressionDictionary sets the dictionary used by SerializeMessage and DeserializeMessage.
+// A nil dictionary disables the use of a dictionary.
+func SetCompressionDictionary(dict []byte) error {
+	codec, err := NewCodec(NewCodecOptions{Dictionary: dict})
+	if err != nil {
+		return fmt.Errorf("failed to create codec: %v", err)
+	}
+	defaultCodecMu.Lock()
+	defaultCodec = codec
+	defaultCodecMu.Unlock()
+	return nil
+}
+
+// LoadCompressionDictionary reads a dictionary from a file and sets it as
+// the dictionary used by SerializeMessage and DeserializeMessage.
+func LoadCompressionDictionary(path string) error {
+	dict, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read dictionary: %v", err)
+	}
+	return SetCompressionDictionary(dict)
+}
+
+// CompressionDictionaryChecksum returns the checksum of the dictionary used by
+// SerializeMessage and DeserializeMessage, or 0 without one
+func CompressionDictionaryChecksum() uint32 {
+	return getDefaultCodec().DictionaryChecksum()
+}
+
+// CheckCompressionDictionary returns an error if a peer's dictionary checksum,
+// sent in the login handshake, does not match the dictionary in use
+func CheckCompressionDictionary(peerChecksum uint32) error {
+	checksum := CompressionDictionaryChecksum()
+	if checksum != peerChecksum {
+		return &ErrCompressionDictionaryMismatch{Checksum: checksum, PeerChecksum: peerChecksum}
+	}
+	return nil
+}
+
+func getDefaultCodec() *Codec {
+	defaultCodecMu.RLock()
+	defer defaultCodecMu.RUnlock()
+	return defaultCodec
+}
+
+// BuildCompressionDictionary builds a zstd dictionary from sample serialized messages,
+// such as those captured from a running server with CaptureMessages.
+// The most recent samples are used as the dictionary history. An error is returned
+// if there are too few samples, or too little distinct data, to build a dictionary from.
+func BuildCompressionDictionary(samples [][]byte) ([]byte, error) {
+	if err := validateDictionarySamples(samples); err != nil {
+		return nil, err
+	}
+
+	start, size := len(samples), 0
+	for start > 0 && size+len(samples[start-1]) <= MaxCompressionDictionaryHistory {
+		start--
+		size += len(samples[start])
+	}
+	history := make([]byte, 0, size)
+	for _, sample := range samples[start:] {
+		history = append(history, sample...)
+	}
+
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       CompressionDictionaryID,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    zstd.SpeedFastest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dictionary: %v", err)
+	}
+	return dict, nil
+}
+
+// validateDictionarySamples checks that there are enough samples for zstd.BuildDict,
+// which panics when the samples have too few sequences to build a dictionary from
+func validateDictionarySamples(samples [][]byte) error {
+	if len(samples) < MinCompressionDictionarySamples {
+		return fmt.Errorf("%d samples to build dictionary from, need at least %d", len(samples), MinCompressionDictionarySamples)
+	}
+	distinct := make(map[string]struct{}, len(samples))
+	size := 0
+	for _, sample := range samples {
+		if _, ok := distinct[string(sample)]; ok {
+			continue
+		}
+		distinct[string(sample)] = struct{}{}
+		size += len(sample)
+	}
+	if size < MinCompressionDictionarySize {
+		return fmt.Errorf("%d bytes of distinct samples to build dictionary from, need at least %d", size, MinCompressionDictionarySize)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package messages
+
+import "fmt"
+
+// PlayerStateField is a bitmask of the fields of a PlayerStateUpdate
+type PlayerStateField uint16
+
+const (
+	PlayerStateFieldLastProcessedTimestamp PlayerStateField = 1 << iota
+	PlayerStateFieldCharacterID
+	PlayerStateFieldName
+	PlayerStateFieldPosition
+	PlayerStateFieldVelocity
+	PlayerStateFieldFlipH
+	PlayerStateFieldIsOnGround
+	PlayerStateFieldIsOnLadder
+	PlayerStateFieldIsAttacking
+	PlayerStateFieldAnimation
+	PlayerStateFieldAnimationSequence
+	PlayerStateFieldHitpoints
+
+	// PlayerStateFieldsAll is the set of all fields of a PlayerStateUpdate
+	PlayerStateFieldsAll = PlayerStateFieldHitpoints<<1 - 1
+)
+
+// NPCStateField is a bitmask of the fields of an NPCStateUpdate
+type NPCStateField uint16
+
+const (
+	NPCStateFieldPosition NPCStateField = 1 << iota
+	NPCStateFieldVelocity
+	NPCStateFieldFlipH
+	NPCStateFieldIsOnGround
+	NPCStateFieldAnimation
+	NPCStateFieldAnimationSequence
+	NPCStateFieldHitpoints
+	NPCStateFieldArchetype
+
+	// NPCStateFieldsAll is the set of all fields of an NPCStateUpdate
+	NPCStateFieldsAll = NPCStateFieldArchetype<<1 - 1
+)
+
+// Delta returns a delta snapshot of the update against a baseline snapshot.
+// Every entity in the update is present in the delta, with only the fields that
+// changed since the baseline set, and entities missing from the delta were removed.
+// If the baseline is nil, the update itself is returned as a full snapshot.
+func (u *ServerGameUpdate) Delta(baseline *ServerGameUpdate) *ServerGameUpdate {
+	if baseline == nil {
+		return u
+	}
+
+	delta := &ServerGameUpdate{
+		Timestamp:         u.Timestamp,
+		BaselineTimestamp: baseline.Timestamp,
+		Players:           make(map[uint32]*PlayerStateUpdate, len(u.Players)),
+		NPCs:              make(map[uint32]*NPCStateUpdate, len(u.NPCs)),
+	}
+
+	for clientID, playerState := range u.Players {
+		fields := PlayerStateFieldsAll
+		if baselineState, ok := baseline.Players[clientID]; ok {
+			fields = playerState.Diff(baselineState)
+		}
+		playerDelta := *playerState
+		playerDelta.Fields = fields
+		delta.Players[clientID] = &playerDelta
+	}
+
+	for npcID, npcState := range u.NPCs {
+		fields := NPCStateFieldsAll
+		if baselineState, ok := baseline.NPCs[npcID]; ok {
+			fields = npcState.Diff(baselineState)
+		}
+		npcDelta := *npcState
+		npcDelta.Fields = fields
+		delta.NPCs[npcID] = &npcDelta
+	}
+
+	return delta
+}
+
+// Resolve returns the full snapshot described by a delta snapshot and its baseline.
+// If the update is already a full snapshot, it is returned as is.
+func (u *ServerGameUpdate) Resolve(baseline *ServerGameUpdate) (*ServerGameUpdate, error) {
+	if u.BaselineTimestamp == 0 {
+		return u, nil
+	}
+
+	if baseline == nil || baseline.Timestamp != u.BaselineTimestamp {
+		return nil, fmt.Errorf("missing baseline snapshot %d", u.BaselineTimestamp)
+	}
+
+	resolved := &ServerGameUpdate{
+		Timestamp: u.Timestamp,
+		Players:   make(map[uint32]*PlayerStateUpdate, len(u.Players)),
+		NPCs:      make(map[uint32]*NPCStateUpdate, len(u.NPCs)),
+	}
+
+	for clientID, playerDelta := range u.Players {
+		playerState := &PlayerStateUpdate{}
+		if baselineState, ok := baseline.Players[clientID]; ok {
+			*playerState = *baselineState
+		} else if playerDelta.Fields != PlayerStateFieldsAll {
+			return nil, fmt.Errorf("partial delta for player %d missing from baseline", clientID)
+		}
+		playerState.Apply(playerDelta)
+		resolved.Players[clientID] = playerState
+	}
+
+	for npcID, npcDelta := range u.NPCs {
+		npcState := &NPCStateUpdate{}
+		if baselineState, ok := baseline.NPCs[npcID]; ok {
+			*npcState = *baselineState
+		} else if npcDelta.Fields != NPCStateFieldsAll {
+			return nil, fmt.Errorf("partial delta for NPC %d missing from baseline", npcID)
+		}
+		npcState.Apply(npcDelta)
+		resolved.NPCs[npcID] = npcState
+	}
+
+	return resolved, nil
+}
+
+// Diff returns the fields of the player state that differ from another player state
+func (p *PlayerStateUpdate) Diff(other *PlayerStateUpdate) PlayerStateField {
+	var fields PlayerStateField
+	if p.LastProcessedTimestamp != other.LastProcessedTimestamp {
+		fields |= PlayerStateFieldLastProcessedTimestamp
+	}
+	if p.CharacterID != other.CharacterID {
+		fields |= PlayerStateFieldCharacterID
+	}
+	if p.Name != other.Name {
+		fields |= PlayerStateFieldName
+	}
+	if !p.Position.Equals(other.Position) {
+		fields |= PlayerStateFieldPosition
+	}
+	if !p.Velocity.Equals(other.Velocity) {
+		fields |= PlayerStateFieldVelocity
+	}
+	if p.FlipH != other.FlipH {
+		fields |= PlayerStateFieldFlipH
+	}
+	if p.IsOnGround != other.IsOnGround {
+		fields |= PlayerStateFieldIsOnGround
+	}
+	if p.IsOnLadder != other.IsOnLadder {
+		fields |= PlayerStateFieldIsOnLadder
+	}
+	if p.IsAttacking != other.IsAttacking {
+		fields |= PlayerStateFieldIsAttacking
+	}
+	if p.Animation != other.Animation {
+		fields |= PlayerStateFieldAnimation
+	}
+	if p.AnimationSequence != other.AnimationSequence {
+		fields |= PlayerStateFieldAnimationSequence
+	}
+	if p.Hitpoints != other.Hitpoints {
+		fields |= PlayerStateFieldHitpoints
+	}
+	return fields
+}
+
+// Apply copies the fields present in a delta onto the player state
+func (p *PlayerStateUpdate) Apply(delta *PlayerStateUpdate) {
+	if delta.Fields&PlayerStateFieldLastProcessedTimestamp != 0 {
+		p.LastProcessedTimestamp = delta.LastProcessedTimestamp
+	}
+	if delta.Fields&PlayerStateFieldCharacterID != 0 {
+		p.CharacterID = delta.CharacterID
+	}
+	if delta.Fields&PlayerStateFieldName != 0 {
+		p.Name = delta.Name
+	}
+	if delta.Fields&PlayerStateFieldPosition != 0 {
+		p.Position = delta.Position
+	}
+	if delta.Fields&PlayerStateFieldVelocity != 0 {
+		p.Velocity = delta.Velocity
+	}
+	if delta.Fields&PlayerStateFieldFlipH != 0 {
+		p.FlipH = delta.FlipH
+	}
+	if delta.Fields&PlayerStateFieldIsOnGround != 0 {
+		p.IsOnGround = delta.IsOnGround
+	}
+	if delta.Fields&PlayerStateFieldIsOnLadder != 0 {
+		p.IsOnLadder = delta.IsOnLadder
+	}
+	if delta.Fields&PlayerStateFieldIsAttacking != 0 {
+		p.IsAttacking = delta.IsAttacking
+	}
+	if delta.Fields&PlayerStateFieldAnimation != 0 {
+		p.Animation = delta.Animation
+	}
+	if delta.Fields&PlayerStateFieldAnimationSequence != 0 {
+		p.AnimationSequence = delta.AnimationSequence
+	}
+	if delta.Fields&PlayerStateFieldHitpoints != 0 {
+		p.Hitpoints = delta.Hitpoints
+	}
+	p.Fields = 0
+}
+
+// Diff returns the fields of the NPC state that differ from another NPC state
+func (n *NPCStateUpdate) Diff(other *NPCStateUpdate) NPCStateField {
+	var fields NPCStateField
+	if !n.Position.Equals(other.Position) {
+		fields |= NPCStateFieldPosition
+	}
+	if !n.Velocity.Equals(other.Velocity) {
+		fields |= NPCStateFieldVelocity
+	}
+	if n.FlipH != other.FlipH {
+		fields |= NPCStateFieldFlipH
+	}
+	if n.IsOnGround != other.IsOnGround {
+		fields |= NPCStateFieldIsOnGround
+	}
+	if n.Animation != other.Animation {
+		fields |= NPCStateFieldAnimation
+	}
+	if n.AnimationSequence != other.AnimationSequence {
+		fields |= NPCStateFieldAnimationSequence
+	}
+	if n.Hitpoints != other.Hitpoints {
+		fields |= NPCStateFieldHitpoints
+	}
+	if n.Archetype != other.Archetype {
+		fields |= NPCStateFieldArchetype
+	}
+	return fields
+}
+
+// Apply copies the fields present in a delta onto the NPC state
+func (n *NPCStateUpdate) Apply(delta *NPCStateUpdate) {
+	if delta.Fields&NPCStateFieldPosition != 0 {
+		n.Position = delta.Position
+	}
+	if delta.Fields&NPCStateFieldVelocity != 0 {
+		n.Velocity = delta.Velocity
+	}
+	if delta.Fields&NPCStateFieldFlipH != 0 {
+		n.FlipH = delta.FlipH
+	}
+	if delta.Fields&NPCStateFieldIsOnGround != 0 {
+		n.IsOnGround = delta.IsOnGround
+	}
+	if delta.Fields&NPCStateFieldAnimation != 0 {
+		n.Animation = delta.Animation
+	}
+	if delta.Fields&NPCStateFieldAnimationSequence != 0 {
+		n.AnimationSequence = delta.AnimationSequence
+	}
+	if delta.Fields&NPCStateFieldHitpoints != 0 {
+		n.Hitpoints = delta.Hitpoints
+	}
+	if delta.Fields&NPCStateFieldArchetype != 0 {
+		n.Archetype = delta.Archetype
+	}
+	n.Fields = 0
+}
@@ -0,0 +1,58 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerGameUpdate_DeltaResolve(t *testing.T) {
+	baseline := &ServerGameUpdate{
+		Timestamp: 1,
+		Players: map[uint32]*PlayerStateUpdate{
+			1: {CharacterID: 1, Name: "player-1", Position: kinematic.NewVector(0, 0), Hitpoints: 100},
+			2: {CharacterID: 2, Name: "player-2", Position: kinematic.NewVector(10, 0), Hitpoints: 100},
+		},
+		NPCs: map[uint32]*NPCStateUpdate{
+			1: {Position: kinematic.NewVector(5, 5), Hitpoints: 50},
+		},
+	}
+	current := &ServerGameUpdate{
+		Timestamp: 2,
+		Players: map[uint32]*PlayerStateUpdate{
+			1: {CharacterID: 1, Name: "player-1", Position: kinematic.NewVector(1, 0), Hitpoints: 100},
+			3: {CharacterID: 3, Name: "player-3", Position: kinematic.NewVector(20, 0), Hitpoints: 100},
+		},
+		NPCs: map[uint32]*NPCStateUpdate{
+			1: {Position: kinematic.NewVector(5, 5), Hitpoints: 50},
+		},
+	}
+
+	delta := current.Delta(baseline)
+	assert.Equal(t, int64(1), delta.BaselineTimestamp)
+	assert.Equal(t, PlayerStateFieldPosition, delta.Players[1].Fields)
+	assert.Equal(t, PlayerStateFieldsAll, delta.Players[3].Fields)
+	assert.NotContains(t, delta.Players, uint32(2))
+	assert.Equal(t, NPCStateField(0), delta.NPCs[1].Fields)
+
+	// the delta survives serialization with only the changed fields
+	b, err := SerializeGameState(delta)
+	assert.NoError(t, err)
+	received, err := DeserializeGameState(b)
+	assert.NoError(t, err)
+	assert.Empty(t, received.Players[1].Name)
+
+	resolved, err := received.Resolve(baseline)
+	assert.NoError(t, err)
+	assert.Equal(t, current, resolved)
+
+	_, err = received.Resolve(nil)
+	assert.Error(t, err)
+
+	// a full snapshot resolves to itself
+	full := current.Delta(nil)
+	resolved, err = full.Resolve(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, current, resolved)
+}
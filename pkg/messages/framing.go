@@ -0,0 +1,95 @@
+package messages
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// FrameVersion is the version of the framing protocol used on stream transports
+	FrameVersion uint8 = 1
+	// FrameHeaderSize is the size of a frame header (4 byte length prefix + 1 byte version)
+	FrameHeaderSize = 5
+	// MaxFrameSize is the maximum size of a frame payload
+	MaxFrameSize = 1 << 20
+)
+
+// ErrFrameTooLarge is returned when a frame exceeds MaxFrameSize
+type ErrFrameTooLarge struct {
+	Size uint32
+}
+
+func (e *ErrFrameTooLarge) Error() string {
+	return fmt.Sprintf("frame size %d exceeds maximum of %d", e.Size, MaxFrameSize)
+}
+
+// ErrUnsupportedFrameVersion is returned when a frame has an unknown version
+type ErrUnsupportedFrameVersion struct {
+	Version uint8
+}
+
+func (e *ErrUnsupportedFrameVersion) Error() string {
+	return fmt.Sprintf("unsupported frame version %d", e.Version)
+}
+
+// EncodeFrame prefixes a payload with a frame header.
+// The frame is laid out as a big-endian uint32 payload length,
+// followed by the frame version and the payload itself.
+func EncodeFrame(payload []byte) ([]byte, error) {
+	if len(payload) > MaxFrameSize {
+		return nil, &ErrFrameTooLarge{Size: uint32(len(payload))}
+	}
+
+	frame := make([]byte, FrameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	frame[4] = FrameVersion
+	copy(frame[FrameHeaderSize:], payload)
+
+	return frame, nil
+}
+
+// WriteFrame writes a payload to a stream as a single frame.
+// The frame is written with a single call to Write so that frames
+// written concurrently to the same connection are not interleaved.
+func WriteFrame(w io.Writer, payload []byte) error {
+	frame, err := EncodeFrame(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode frame: %v", err)
+	}
+
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReadFrame reads the next frame from a stream and returns its payload.
+// It blocks until a full frame is available, so the reader should be
+// buffered to handle partial reads and multiple frames per read.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, FrameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > MaxFrameSize {
+		return nil, &ErrFrameTooLarge{Size: size}
+	}
+
+	if version := header[4]; version != FrameVersion {
+		return nil, &ErrUnsupportedFrameVersion{Version: version}
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return payload, nil
+}
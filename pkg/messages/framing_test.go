@@ -0,0 +1,83 @@
+package messages
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFrame(t *testing.T) {
+	large := bytes.Repeat([]byte{0xab}, TCPMessageBufferSize*3+7)
+
+	tests := []struct {
+		name     string
+		payloads [][]byte
+		reader   func(r io.Reader) io.Reader
+	}{
+		{
+			name:     "single frame",
+			payloads: [][]byte{[]byte("hello")},
+			reader:   func(r io.Reader) io.Reader { return r },
+		},
+		{
+			name:     "coalesced frames",
+			payloads: [][]byte{[]byte("first"), []byte("second"), {}, []byte("third")},
+			reader:   func(r io.Reader) io.Reader { return r },
+		},
+		{
+			name:     "split frames",
+			payloads: [][]byte{[]byte("first"), []byte("second")},
+			reader:   iotest.OneByteReader,
+		},
+		{
+			name:     "frame larger than buffer",
+			payloads: [][]byte{[]byte("before"), large, []byte("after")},
+			reader:   iotest.HalfReader,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := bytes.NewBuffer(nil)
+			for _, payload := range tt.payloads {
+				if err := WriteFrame(stream, payload); err != nil {
+					t.Fatalf("WriteFrame() error = %v", err)
+				}
+			}
+
+			r := bufio.NewReaderSize(tt.reader(stream), TCPMessageBufferSize)
+			for _, want := range tt.payloads {
+				got, err := ReadFrame(r)
+				if err != nil {
+					t.Fatalf("ReadFrame() error = %v", err)
+				}
+				assert.Equal(t, want, got)
+			}
+
+			_, err := ReadFrame(r)
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	frame, err := EncodeFrame([]byte("payload"))
+	if err != nil {
+		t.Fatalf("EncodeFrame() error = %v", err)
+	}
+
+	badVersion := append([]byte{}, frame...)
+	badVersion[4] = FrameVersion + 1
+	_, err = ReadFrame(bytes.NewReader(badVersion))
+	assert.IsType(t, &ErrUnsupportedFrameVersion{}, err)
+
+	tooLarge := []byte{0xff, 0xff, 0xff, 0xff, FrameVersion}
+	_, err = ReadFrame(bytes.NewReader(tooLarge))
+	assert.IsType(t, &ErrFrameTooLarge{}, err)
+
+	_, err = ReadFrame(bytes.NewReader(frame[:len(frame)-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
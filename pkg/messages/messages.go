@@ -2,15 +2,18 @@ package messages
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/cbodonnell/flywheel/pkg/version"
 )
 
 const (
 	// UDPMessageBufferSize represents the maximum size of a message
 	UDPMessageBufferSize = 508
 
-	// TCPMessageBufferSize represents the maximum size of a message
+	// TCPMessageBufferSize represents the size of the buffer used to read from a TCP stream.
+	// Messages larger than this are read across multiple reads (see ReadFrame).
 	TCPMessageBufferSize = 1460
 )
 
@@ -35,28 +38,44 @@ const (
 	MessageTypeServerNPCKill
 	MessageTypeServerPlayerHit
 	MessageTypeServerPlayerKill
+	MessageTypeClientSnapshotAck
+	MessageTypeServerEntityEnterView
+	MessageTypeServerEntityLeaveView
+	MessageTypeServerShutdown
+	MessageTypeServerZoneChange
 )
 
+var messageTypeNames = [...]string{
+	"ClientLogin",
+	"ServerLoginSuccess",
+	"ServerLoginFailure",
+	"ClientPing",
+	"ServerPong",
+	"ClientPlayerUpdate",
+	"ServerGameUpdate",
+	"ServerPlayerUpdate",
+	"ServerNPCUpdate",
+	"ClientSyncTime",
+	"ServerSyncTime",
+	"ServerPlayerConnect",
+	"ServerPlayerDisconnect",
+	"ServerNPCHit",
+	"ServerNPCKill",
+	"ServerPlayerHit",
+	"ServerPlayerKill",
+	"ClientSnapshotAck",
+	"ServerEntityEnterView",
+	"ServerEntityLeaveView",
+	"ServerShutdown",
+	"ServerZoneChange",
+}
+
 func (m MessageType) String() string {
-	return [...]string{
-		"ClientLogin",
-		"ServerLoginSuccess",
-		"ServerLoginFailure",
-		"ClientPing",
-		"ServerPong",
-		"ClientPlayerUpdate",
-		"ServerGameUpdate",
-		"ServerPlayerUpdate",
-		"ServerNPCUpdate",
-		"ClientSyncTime",
-		"ServerSyncTime",
-		"ServerPlayerConnect",
-		"ServerPlayerDisconnect",
-		"ServerNPCHit",
-		"ServerNPCKill",
-		"ServerPlayerHit",
-		"ServerPlayerKill",
-	}[m]
+	// messages from a peer with a newer protocol may have unknown types
+	if int(m) >= len(messageTypeNames) {
+		return fmt.Sprintf("MessageType(%d)", uint8(m))
+	}
+	return messageTypeNames[m]
 }
 
 // Message represents a generic message for serialization/deserialization
@@ -69,14 +88,69 @@ type Message struct {
 type ClientLogin struct {
 	Token       string `json:"token"`
 	CharacterID int32  `json:"characterID"`
+	// ProtocolVersion is the protocol version spoken by the client
+	ProtocolVersion uint16 `json:"protocolVersion"`
+	// Capabilities is the set of protocol capabilities supported by the client
+	Capabilities version.Capability `json:"capabilities"`
+	// ClientVersion is the build version of the client
+	ClientVersion string `json:"clientVersion"`
+	// PublicKey is the client's X25519 public key used to derive the UDP session keys
+	PublicKey []byte `json:"publicKey"`
+	// ResumeClientID is the ID of a suspended session to resume instead of logging in
+	ResumeClientID uint32 `json:"resumeClientID"`
+	// ResumeToken is the token issued by the server for the session to resume
+	ResumeToken string `json:"resumeToken"`
+	// CompressionDictionaryChecksum identifies the compression dictionary of the client, or is zero if it has none
+	CompressionDictionaryChecksum uint32 `json:"compressionDictionaryChecksum"`
 }
 
 type ServerLoginSuccess struct {
 	ClientID uint32 `json:"clientID"`
+	// ProtocolVersion is the protocol version spoken by the server
+	ProtocolVersion uint16 `json:"protocolVersion"`
+	// Capabilities is the set of protocol capabilities supported by both the server and the client
+	Capabilities version.Capability `json:"capabilities"`
+	// ServerVersion is the build version of the server
+	ServerVersion string `json:"serverVersion"`
+	// PublicKey is the server's X25519 public key used to derive the UDP session keys.
+	// It is empty when a session is resumed, since the session keys are unchanged.
+	PublicKey []byte `json:"publicKey"`
+	// ResumeToken is the token used to resume the session after a disconnect
+	ResumeToken string `json:"resumeToken"`
+	// ResumeWindow is how long in milliseconds the session can be resumed after a disconnect
+	ResumeWindow uint32 `json:"resumeWindow"`
+	// CompressionDictionaryChecksum identifies the compression dictionary of the server, or is zero if it has none
+	CompressionDictionaryChecksum uint32 `json:"compressionDictionaryChecksum"`
 }
 
+// LoginFailureCode identifies why a login failed
+type LoginFailureCode uint8
+
+const (
+	// LoginFailureCodeUnspecified is a login failure without a more specific code
+	LoginFailureCodeUnspecified LoginFailureCode = iota
+	// LoginFailureCodeUpdateRequired is a login failure due to the client's protocol being incompatible
+	LoginFailureCodeUpdateRequired
+	// LoginFailureCodeResumeFailed is a login failure due to the session to resume having expired
+	LoginFailureCodeResumeFailed
+	// LoginFailureCodeServerShuttingDown is a login failure due to the server shutting down
+	LoginFailureCodeServerShuttingDown
+	// LoginFailureCodeBanned is a login failure due to the user being banned
+	LoginFailureCodeBanned
+	// LoginFailureCodeKicked is sent to a logged in client that the server kicked, just before it drops the connection
+	LoginFailureCodeKicked
+	// LoginFailureCodeCompressionDictionaryMismatch is a login failure due to the client compressing
+	// messages with a different dictionary than the server
+	LoginFailureCodeCompressionDictionaryMismatch
+)
+
 type ServerLoginFailure struct {
-	Reason string `json:"reason"`
+	Reason string           `json:"reason"`
+	Code   LoginFailureCode `json:"code"`
+	// ProtocolVersion is the protocol version spoken by the server
+	ProtocolVersion uint16 `json:"protocolVersion"`
+	// ServerVersion is the build version of the server
+	ServerVersion string `json:"serverVersion"`
 }
 
 const (
@@ -88,7 +162,10 @@ const (
 // TODO: split this into PlayerInput and ClientPlayerUpdate to differentiate the game and message types
 // with a type field to differentiate between different input types (move, jump, fire, etc.)
 type ClientPlayerUpdate struct {
-	// Timestamp is the time at which the update was generated by the client
+	// Sequence is the number of the update, incremented by one for every fixed timestep simulated by the client
+	Sequence uint32 `json:"sequence"`
+	// Timestamp is the client's estimate of the server time at which the update was generated.
+	// Timestamps increase with every update so they identify the updates acknowledged by the server.
 	Timestamp int64 `json:"timestamp"`
 	// InputX is the x-axis input from the client ranging from -1 to 1
 	InputX float64 `json:"inputX"`
@@ -119,12 +196,26 @@ func (cpu *ClientPlayerUpdate) InputDismount() bool {
 type ServerGameUpdate struct {
 	// Timestamp is the time at which the update was generated by the server
 	Timestamp int64 `json:"timestamp"`
+	// BaselineTimestamp is the timestamp of the snapshot this update is a delta of,
+	// or 0 if the update is a full snapshot (see ServerGameUpdate.Delta)
+	BaselineTimestamp int64 `json:"baselineTimestamp"`
+	// Part is the index of this part of an update split across datagrams
+	Part uint8 `json:"part"`
+	// PartCount is the number of parts the update was split into, or 0 if it was not split
+	PartCount uint8 `json:"partCount"`
 	// Players maps client IDs to player states
 	Players map[uint32]*PlayerStateUpdate `json:"players"`
 	// NPCs maps enemy IDs to NPC states
 	NPCs map[uint32]*NPCStateUpdate `json:"npcs"`
 }
 
+// ClientSnapshotAck is a message sent by the client to acknowledge a ServerGameUpdate,
+// so the server can use it as the baseline for delta snapshots
+type ClientSnapshotAck struct {
+	// Timestamp is the timestamp of the acknowledged snapshot
+	Timestamp int64 `json:"timestamp"`
+}
+
 // ServerPlayerUpdate is a message sent by the server to update clients on a player's state
 type ServerPlayerUpdate struct {
 	// Timestamp is the time at which the update was generated by the server
@@ -171,6 +262,8 @@ type PlayerStateUpdate struct {
 	AnimationSequence uint8 `json:"animationSequence"`
 	// Hitpoints is the current hitpoints of the player
 	Hitpoints int16 `json:"hitpoints"`
+	// Fields is the set of fields present in a delta snapshot
+	Fields PlayerStateField `json:"fields"`
 }
 
 // NPCStateUpdate is a message sent by the server to update clients on an NPC's state
@@ -189,6 +282,10 @@ type NPCStateUpdate struct {
 	AnimationSequence uint8 `json:"animationSequence"`
 	// Hitpoints is the current hitpoints of the NPC
 	Hitpoints int16 `json:"hitpoints"`
+	// Archetype is the ID of the archetype of the NPC
+	Archetype string `json:"archetype"`
+	// Fields is the set of fields present in a delta snapshot
+	Fields NPCStateField `json:"fields"`
 }
 
 // ClientSyncTime is a message sent by the client to request a time sync with the server
@@ -211,6 +308,8 @@ type ServerPlayerConnect struct {
 	ClientID uint32 `json:"clientID"`
 	// PlayerState is the state of the player that has connected
 	PlayerState *PlayerStateUpdate `json:"playerState"`
+	// ZoneID is the zone the player has connected in
+	ZoneID uint32 `json:"zoneID"`
 }
 
 // ServerPlayerDisconnect is a message sent by the server to notify clients that a player has disconnected
@@ -241,8 +340,10 @@ type ServerNPCKill struct {
 type ServerPlayerHit struct {
 	// PlayerID is the ID of the player that has been hit
 	PlayerID uint32 `json:"playerID"`
-	// NPCID is the ID of the NPC that hit the player
-	NPCID uint32 `json:"npcID"`
+	// AttackerType is the type of the entity that hit the player
+	AttackerType EntityType `json:"attackerType"`
+	// AttackerID is the client ID of the player or the ID of the NPC that hit the player
+	AttackerID uint32 `json:"attackerID"`
 	// Damage is the amount of damage dealt to the player
 	Damage int16 `json:"damage"`
 }
@@ -251,6 +352,50 @@ type ServerPlayerHit struct {
 type ServerPlayerKill struct {
 	// PlayerID is the ID of the player that has been killed
 	PlayerID uint32 `json:"playerID"`
-	// NPCID is the ID of the NPC that killed the player
-	NPCID uint32 `json:"npcID"`
+	// AttackerType is the type of the entity that killed the player
+	AttackerType EntityType `json:"attackerType"`
+	// AttackerID is the client ID of the player or the ID of the NPC that killed the player
+	AttackerID uint32 `json:"attackerID"`
+}
+
+// EntityType identifies the kind of entity a message refers to
+type EntityType uint8
+
+const (
+	EntityTypePlayer EntityType = iota
+	EntityTypeNPC
+)
+
+// ServerEntityEnterView is a message sent by the server to notify a client that an entity has entered its view
+type ServerEntityEnterView struct {
+	// EntityType is the type of the entity
+	EntityType EntityType `json:"entityType"`
+	// EntityID is the client ID of a player or the ID of an NPC
+	EntityID uint32 `json:"entityID"`
+}
+
+// ServerEntityLeaveView is a message sent by the server to notify a client that an entity has left its view
+type ServerEntityLeaveView struct {
+	// EntityType is the type of the entity
+	EntityType EntityType `json:"entityType"`
+	// EntityID is the client ID of a player or the ID of an NPC
+	EntityID uint32 `json:"entityID"`
+}
+
+// ServerShutdown is a message sent by the server to notify clients that it is shutting down
+type ServerShutdown struct {
+	// Countdown is how long in milliseconds until the server stops the game
+	Countdown uint32 `json:"countdown"`
+	// Reason is an optional reason for the shutdown
+	Reason string `json:"reason"`
+}
+
+// ServerZoneChange is a message sent by the server to notify a client that its player has moved to another zone
+type ServerZoneChange struct {
+	// Timestamp is the time of the last game update of the zone the player left
+	Timestamp int64 `json:"timestamp"`
+	// ZoneID is the zone the player has moved to
+	ZoneID uint32 `json:"zoneID"`
+	// PlayerState is the state of the player in the zone it has moved to
+	PlayerState *PlayerStateUpdate `json:"playerState"`
 }
@@ -0,0 +1,202 @@
+package messages
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// GameStatePartSize is the maximum size of a serialized ServerGameUpdate part.
+	// It leaves room in UDPMessageBufferSize for the message envelope and compression frame.
+	GameStatePartSize = UDPMessageBufferSize - 64
+	// MaxGameStateParts is the maximum number of parts a ServerGameUpdate can be split into
+	MaxGameStateParts = 255
+	// MaxPendingGameStates is the maximum number of incomplete updates kept by a GameStateAssembler
+	MaxPendingGameStates = 8
+)
+
+// SerializeGameStateParts serializes a game update into as few parts as possible
+// that are each at most maxSize bytes. Every part has the timestamp and baseline of
+// the update and a subset of its entities, and is merged back into the full update
+// by a GameStateAssembler. An update that fits in maxSize is returned as a single part.
+func SerializeGameStateParts(state *ServerGameUpdate, maxSize int) ([][]byte, error) {
+	b, err := SerializeGameState(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize game state: %v", err)
+	}
+	if len(b) <= maxSize {
+		return [][]byte{b}, nil
+	}
+
+	newPart := func() *ServerGameUpdate {
+		return &ServerGameUpdate{
+			Timestamp:         state.Timestamp,
+			BaselineTimestamp: state.BaselineTimestamp,
+			// reserve room for the part index and count, which are set once all parts are filled
+			Part:      MaxGameStateParts - 1,
+			PartCount: MaxGameStateParts,
+			Players:   make(map[uint32]*PlayerStateUpdate),
+			NPCs:      make(map[uint32]*NPCStateUpdate),
+		}
+	}
+
+	parts := []*ServerGameUpdate{newPart()}
+	// add adds an entity to the last part, or to a new part if the last part would no longer fit
+	add := func(set func(*ServerGameUpdate), unset func(*ServerGameUpdate)) error {
+		part := parts[len(parts)-1]
+		set(part)
+		b, err := SerializeGameState(part)
+		if err != nil {
+			return fmt.Errorf("failed to serialize game state part: %v", err)
+		}
+		if len(b) <= maxSize || len(part.Players)+len(part.NPCs) == 1 {
+			return nil
+		}
+		unset(part)
+
+		if len(parts) == MaxGameStateParts {
+			return fmt.Errorf("game state needs more than %d parts", MaxGameStateParts)
+		}
+		part = newPart()
+		set(part)
+		parts = append(parts, part)
+		return nil
+	}
+
+	for _, clientID := range sortedKeys(state.Players) {
+		playerState := state.Players[clientID]
+		err := add(
+			func(p *ServerGameUpdate) { p.Players[clientID] = playerState },
+			func(p *ServerGameUpdate) { delete(p.Players, clientID) },
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add player %d: %v", clientID, err)
+		}
+	}
+
+	for _, npcID := range sortedKeys(state.NPCs) {
+		npcState := state.NPCs[npcID]
+		err := add(
+			func(p *ServerGameUpdate) { p.NPCs[npcID] = npcState },
+			func(p *ServerGameUpdate) { delete(p.NPCs, npcID) },
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add NPC %d: %v", npcID, err)
+		}
+	}
+
+	payloads := make([][]byte, 0, len(parts))
+	for i, part := range parts {
+		part.Part = uint8(i)
+		part.PartCount = uint8(len(parts))
+		b, err := SerializeGameState(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize game state part %d: %v", i, err)
+		}
+		if len(b) > maxSize {
+			// only happens when a single entity is larger than maxSize
+			return nil, fmt.Errorf("game state part %d is %d bytes, larger than %d", i, len(b), maxSize)
+		}
+		payloads = append(payloads, b)
+	}
+
+	return payloads, nil
+}
+
+func sortedKeys[V any](m map[uint32]V) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// GameStateAssembler merges the parts of game updates split by SerializeGameStateParts.
+// Updates older than the last completed update are dropped, as are their parts.
+type GameStateAssembler struct {
+	pending       map[int64]*pendingGameState
+	lastCompleted int64
+}
+
+type pendingGameState struct {
+	update   *ServerGameUpdate
+	received map[uint8]bool
+}
+
+// NewGameStateAssembler creates a new GameStateAssembler
+func NewGameStateAssembler() *GameStateAssembler {
+	return &GameStateAssembler{
+		pending: make(map[int64]*pendingGameState),
+	}
+}
+
+// Add adds a part of an update and returns the full update once all of its parts
+// have been received, or nil if the update is incomplete or stale.
+func (a *GameStateAssembler) Add(part *ServerGameUpdate) *ServerGameUpdate {
+	if part.Timestamp <= a.lastCompleted {
+		return nil
+	}
+
+	if part.PartCount <= 1 {
+		a.complete(part.Timestamp)
+		return part
+	}
+
+	pending, ok := a.pending[part.Timestamp]
+	if !ok {
+		a.evict()
+		pending = &pendingGameState{
+			update: &ServerGameUpdate{
+				Timestamp:         part.Timestamp,
+				BaselineTimestamp: part.BaselineTimestamp,
+				Players:           make(map[uint32]*PlayerStateUpdate),
+				NPCs:              make(map[uint32]*NPCStateUpdate),
+			},
+			received: make(map[uint8]bool),
+		}
+		a.pending[part.Timestamp] = pending
+	}
+
+	if pending.received[part.Part] {
+		return nil
+	}
+	pending.received[part.Part] = true
+	for clientID, playerState := range part.Players {
+		pending.update.Players[clientID] = playerState
+	}
+	for npcID, npcState := range part.NPCs {
+		pending.update.NPCs[npcID] = npcState
+	}
+
+	if len(pending.received) < int(part.PartCount) {
+		return nil
+	}
+
+	a.complete(part.Timestamp)
+	return pending.update
+}
+
+// complete records an update as completed and drops any older pending updates
+func (a *GameStateAssembler) complete(timestamp int64) {
+	a.lastCompleted = timestamp
+	for t := range a.pending {
+		if t <= timestamp {
+			delete(a.pending, t)
+		}
+	}
+}
+
+// evict drops the oldest pending update if there are too many
+func (a *GameStateAssembler) evict() {
+	if len(a.pending) < MaxPendingGameStates {
+		return
+	}
+	oldest := int64(0)
+	for t := range a.pending {
+		if oldest == 0 || t < oldest {
+			oldest = t
+		}
+	}
+	delete(a.pending, oldest)
+}
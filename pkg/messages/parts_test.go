@@ -0,0 +1,63 @@
+package messages
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeGameStateParts(t *testing.T) {
+	state := &ServerGameUpdate{
+		Timestamp: 2,
+		Players:   make(map[uint32]*PlayerStateUpdate),
+		NPCs:      make(map[uint32]*NPCStateUpdate),
+	}
+	for i := uint32(1); i <= 10; i++ {
+		state.Players[i] = &PlayerStateUpdate{
+			CharacterID: int32(i),
+			Name:        fmt.Sprintf("player-%d", i),
+			Position:    kinematic.NewVector(float64(i), 16),
+			Hitpoints:   100,
+		}
+		state.NPCs[i] = &NPCStateUpdate{
+			Position:  kinematic.NewVector(float64(i)*10, 16),
+			Hitpoints: 50,
+		}
+	}
+
+	payloads, err := SerializeGameStateParts(state, GameStatePartSize)
+	assert.NoError(t, err)
+	assert.Greater(t, len(payloads), 1)
+
+	parts := make([]*ServerGameUpdate, 0, len(payloads))
+	for _, b := range payloads {
+		assert.LessOrEqual(t, len(b), GameStatePartSize)
+		part, err := DeserializeGameState(b)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(len(payloads)), part.PartCount)
+		parts = append(parts, part)
+	}
+
+	// parts are reassembled regardless of arrival order
+	assembler := NewGameStateAssembler()
+	var assembled *ServerGameUpdate
+	for i := len(parts) - 1; i >= 0; i-- {
+		assert.Nil(t, assembled)
+		assembled = assembler.Add(parts[i])
+	}
+	assert.Equal(t, state, assembled)
+
+	// parts of completed or older updates are dropped
+	assert.Nil(t, assembler.Add(parts[0]))
+	assert.Nil(t, assembler.Add(&ServerGameUpdate{Timestamp: 1}))
+
+	// a small update is a single part
+	payloads, err = SerializeGameStateParts(&ServerGameUpdate{Timestamp: 3}, GameStatePartSize)
+	assert.NoError(t, err)
+	assert.Len(t, payloads, 1)
+	single, err := DeserializeGameState(payloads[0])
+	assert.NoError(t, err)
+	assert.NotNil(t, assembler.Add(single))
+}
@@ -0,0 +1,378 @@
+package messages
+
+import (
+	"fmt"
+
+	payloadsfb "github.com/cbodonnell/flywheel/flatbuffers/payloads"
+	"github.com/cbodonnell/flywheel/pkg/version"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// ClientPing and ServerPong messages have no payload.
+
+// recoverMalformed turns the panic raised by flatbuffer accessors on a malformed buffer into an error
+func recoverMalformed(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("malformed payload: %v", r)
+	}
+}
+
+func SerializeClientLogin(login *ClientLogin) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	token := builder.CreateString(login.Token)
+	clientVersion := builder.CreateString(login.ClientVersion)
+	publicKey := builder.CreateByteVector(login.PublicKey)
+	resumeToken := builder.CreateString(login.ResumeToken)
+	payloadsfb.ClientLoginStart(builder)
+	payloadsfb.ClientLoginAddToken(builder, token)
+	payloadsfb.ClientLoginAddCharacterId(builder, login.CharacterID)
+	payloadsfb.ClientLoginAddProtocolVersion(builder, login.ProtocolVersion)
+	payloadsfb.ClientLoginAddCapabilities(builder, uint32(login.Capabilities))
+	payloadsfb.ClientLoginAddClientVersion(builder, clientVersion)
+	payloadsfb.ClientLoginAddPublicKey(builder, publicKey)
+	payloadsfb.ClientLoginAddResumeClientId(builder, login.ResumeClientID)
+	payloadsfb.ClientLoginAddResumeToken(builder, resumeToken)
+	payloadsfb.ClientLoginAddCompressionDictionaryChecksum(builder, login.CompressionDictionaryChecksum)
+	builder.Finish(payloadsfb.ClientLoginEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeClientLogin(b []byte) (_ *ClientLogin, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsClientLogin(b, 0)
+	return &ClientLogin{
+		Token:                         string(fb.Token()),
+		CharacterID:                   fb.CharacterId(),
+		ProtocolVersion:               fb.ProtocolVersion(),
+		Capabilities:                  version.Capability(fb.Capabilities()),
+		ClientVersion:                 string(fb.ClientVersion()),
+		PublicKey:                     fb.PublicKeyBytes(),
+		ResumeClientID:                fb.ResumeClientId(),
+		ResumeToken:                   string(fb.ResumeToken()),
+		CompressionDictionaryChecksum: fb.CompressionDictionaryChecksum(),
+	}, nil
+}
+
+func SerializeServerLoginSuccess(success *ServerLoginSuccess) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	serverVersion := builder.CreateString(success.ServerVersion)
+	publicKey := builder.CreateByteVector(success.PublicKey)
+	resumeToken := builder.CreateString(success.ResumeToken)
+	payloadsfb.ServerLoginSuccessStart(builder)
+	payloadsfb.ServerLoginSuccessAddClientId(builder, success.ClientID)
+	payloadsfb.ServerLoginSuccessAddProtocolVersion(builder, success.ProtocolVersion)
+	payloadsfb.ServerLoginSuccessAddCapabilities(builder, uint32(success.Capabilities))
+	payloadsfb.ServerLoginSuccessAddServerVersion(builder, serverVersion)
+	payloadsfb.ServerLoginSuccessAddPublicKey(builder, publicKey)
+	payloadsfb.ServerLoginSuccessAddResumeToken(builder, resumeToken)
+	payloadsfb.ServerLoginSuccessAddResumeWindow(builder, success.ResumeWindow)
+	payloadsfb.ServerLoginSuccessAddCompressionDictionaryChecksum(builder, success.CompressionDictionaryChecksum)
+	builder.Finish(payloadsfb.ServerLoginSuccessEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerLoginSuccess(b []byte) (_ *ServerLoginSuccess, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerLoginSuccess(b, 0)
+	return &ServerLoginSuccess{
+		ClientID:                      fb.ClientId(),
+		ProtocolVersion:               fb.ProtocolVersion(),
+		Capabilities:                  version.Capability(fb.Capabilities()),
+		ServerVersion:                 string(fb.ServerVersion()),
+		PublicKey:                     fb.PublicKeyBytes(),
+		ResumeToken:                   string(fb.ResumeToken()),
+		ResumeWindow:                  fb.ResumeWindow(),
+		CompressionDictionaryChecksum: fb.CompressionDictionaryChecksum(),
+	}, nil
+}
+
+func SerializeServerLoginFailure(failure *ServerLoginFailure) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	reason := builder.CreateString(failure.Reason)
+	serverVersion := builder.CreateString(failure.ServerVersion)
+	payloadsfb.ServerLoginFailureStart(builder)
+	payloadsfb.ServerLoginFailureAddReason(builder, reason)
+	payloadsfb.ServerLoginFailureAddCode(builder, byte(failure.Code))
+	payloadsfb.ServerLoginFailureAddProtocolVersion(builder, failure.ProtocolVersion)
+	payloadsfb.ServerLoginFailureAddServerVersion(builder, serverVersion)
+	builder.Finish(payloadsfb.ServerLoginFailureEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerLoginFailure(b []byte) (_ *ServerLoginFailure, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerLoginFailure(b, 0)
+	return &ServerLoginFailure{
+		Reason:          string(fb.Reason()),
+		Code:            LoginFailureCode(fb.Code()),
+		ProtocolVersion: fb.ProtocolVersion(),
+		ServerVersion:   string(fb.ServerVersion()),
+	}, nil
+}
+
+func SerializeClientPlayerUpdate(update *ClientPlayerUpdate) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	builder.Finish(serializeClientPlayerUpdateFlatbuffer(builder, update))
+	return builder.FinishedBytes(), nil
+}
+
+func serializeClientPlayerUpdateFlatbuffer(builder *flatbuffers.Builder, update *ClientPlayerUpdate) flatbuffers.UOffsetT {
+	var pastUpdates flatbuffers.UOffsetT
+	if len(update.PastUpdates) > 0 {
+		offsets := make([]flatbuffers.UOffsetT, len(update.PastUpdates))
+		for i, pastUpdate := range update.PastUpdates {
+			offsets[i] = serializeClientPlayerUpdateFlatbuffer(builder, pastUpdate)
+		}
+		payloadsfb.ClientPlayerUpdateStartPastUpdatesVector(builder, len(offsets))
+		for i := len(offsets) - 1; i >= 0; i-- {
+			builder.PrependUOffsetT(offsets[i])
+		}
+		pastUpdates = builder.EndVector(len(offsets))
+	}
+
+	payloadsfb.ClientPlayerUpdateStart(builder)
+	payloadsfb.ClientPlayerUpdateAddSequence(builder, update.Sequence)
+	payloadsfb.ClientPlayerUpdateAddTimestamp(builder, update.Timestamp)
+	payloadsfb.ClientPlayerUpdateAddInputX(builder, update.InputX)
+	payloadsfb.ClientPlayerUpdateAddInputY(builder, update.InputY)
+	payloadsfb.ClientPlayerUpdateAddInputJump(builder, update.InputJump)
+	payloadsfb.ClientPlayerUpdateAddInputAttack1(builder, update.InputAttack1)
+	payloadsfb.ClientPlayerUpdateAddInputAttack2(builder, update.InputAttack2)
+	payloadsfb.ClientPlayerUpdateAddInputAttack3(builder, update.InputAttack3)
+	payloadsfb.ClientPlayerUpdateAddInputRespawn(builder, update.InputRespawn)
+	payloadsfb.ClientPlayerUpdateAddDeltaTime(builder, update.DeltaTime)
+	if len(update.PastUpdates) > 0 {
+		payloadsfb.ClientPlayerUpdateAddPastUpdates(builder, pastUpdates)
+	}
+	return payloadsfb.ClientPlayerUpdateEnd(builder)
+}
+
+func DeserializeClientPlayerUpdate(b []byte) (_ *ClientPlayerUpdate, err error) {
+	defer recoverMalformed(&err)
+	return clientPlayerUpdateFlatbufferToClientPlayerUpdate(payloadsfb.GetRootAsClientPlayerUpdate(b, 0)), nil
+}
+
+func clientPlayerUpdateFlatbufferToClientPlayerUpdate(fb *payloadsfb.ClientPlayerUpdate) *ClientPlayerUpdate {
+	update := &ClientPlayerUpdate{
+		Sequence:     fb.Sequence(),
+		Timestamp:    fb.Timestamp(),
+		InputX:       fb.InputX(),
+		InputY:       fb.InputY(),
+		InputJump:    fb.InputJump(),
+		InputAttack1: fb.InputAttack1(),
+		InputAttack2: fb.InputAttack2(),
+		InputAttack3: fb.InputAttack3(),
+		InputRespawn: fb.InputRespawn(),
+		DeltaTime:    fb.DeltaTime(),
+	}
+	if n := fb.PastUpdatesLength(); n > 0 {
+		update.PastUpdates = make([]*ClientPlayerUpdate, n)
+		for i := 0; i < n; i++ {
+			pastUpdate := &payloadsfb.ClientPlayerUpdate{}
+			fb.PastUpdates(pastUpdate, i)
+			update.PastUpdates[i] = clientPlayerUpdateFlatbufferToClientPlayerUpdate(pastUpdate)
+		}
+	}
+	return update
+}
+
+func SerializeClientSnapshotAck(ack *ClientSnapshotAck) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ClientSnapshotAckStart(builder)
+	payloadsfb.ClientSnapshotAckAddTimestamp(builder, ack.Timestamp)
+	builder.Finish(payloadsfb.ClientSnapshotAckEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeClientSnapshotAck(b []byte) (_ *ClientSnapshotAck, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsClientSnapshotAck(b, 0)
+	return &ClientSnapshotAck{
+		Timestamp: fb.Timestamp(),
+	}, nil
+}
+
+func SerializeClientSyncTime(syncTime *ClientSyncTime) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ClientSyncTimeStart(builder)
+	payloadsfb.ClientSyncTimeAddTimestamp(builder, syncTime.Timestamp)
+	builder.Finish(payloadsfb.ClientSyncTimeEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeClientSyncTime(b []byte) (_ *ClientSyncTime, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsClientSyncTime(b, 0)
+	return &ClientSyncTime{
+		Timestamp: fb.Timestamp(),
+	}, nil
+}
+
+func SerializeServerSyncTime(syncTime *ServerSyncTime) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerSyncTimeStart(builder)
+	payloadsfb.ServerSyncTimeAddTimestamp(builder, syncTime.Timestamp)
+	payloadsfb.ServerSyncTimeAddClientTimestamp(builder, syncTime.ClientTimestamp)
+	builder.Finish(payloadsfb.ServerSyncTimeEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerSyncTime(b []byte) (_ *ServerSyncTime, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerSyncTime(b, 0)
+	return &ServerSyncTime{
+		Timestamp:       fb.Timestamp(),
+		ClientTimestamp: fb.ClientTimestamp(),
+	}, nil
+}
+
+func SerializeServerPlayerDisconnect(disconnect *ServerPlayerDisconnect) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerPlayerDisconnectStart(builder)
+	payloadsfb.ServerPlayerDisconnectAddClientId(builder, disconnect.ClientID)
+	builder.Finish(payloadsfb.ServerPlayerDisconnectEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerPlayerDisconnect(b []byte) (_ *ServerPlayerDisconnect, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerPlayerDisconnect(b, 0)
+	return &ServerPlayerDisconnect{
+		ClientID: fb.ClientId(),
+	}, nil
+}
+
+func SerializeServerNPCHit(hit *ServerNPCHit) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerNPCHitStart(builder)
+	payloadsfb.ServerNPCHitAddNpcId(builder, hit.NPCID)
+	payloadsfb.ServerNPCHitAddPlayerId(builder, hit.PlayerID)
+	payloadsfb.ServerNPCHitAddDamage(builder, hit.Damage)
+	builder.Finish(payloadsfb.ServerNPCHitEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerNPCHit(b []byte) (_ *ServerNPCHit, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerNPCHit(b, 0)
+	return &ServerNPCHit{
+		NPCID:    fb.NpcId(),
+		PlayerID: fb.PlayerId(),
+		Damage:   fb.Damage(),
+	}, nil
+}
+
+func SerializeServerNPCKill(kill *ServerNPCKill) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerNPCKillStart(builder)
+	payloadsfb.ServerNPCKillAddNpcId(builder, kill.NPCID)
+	payloadsfb.ServerNPCKillAddPlayerId(builder, kill.PlayerID)
+	builder.Finish(payloadsfb.ServerNPCKillEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerNPCKill(b []byte) (_ *ServerNPCKill, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerNPCKill(b, 0)
+	return &ServerNPCKill{
+		NPCID:    fb.NpcId(),
+		PlayerID: fb.PlayerId(),
+	}, nil
+}
+
+func SerializeServerPlayerHit(hit *ServerPlayerHit) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerPlayerHitStart(builder)
+	payloadsfb.ServerPlayerHitAddPlayerId(builder, hit.PlayerID)
+	payloadsfb.ServerPlayerHitAddAttackerId(builder, hit.AttackerID)
+	payloadsfb.ServerPlayerHitAddDamage(builder, hit.Damage)
+	payloadsfb.ServerPlayerHitAddAttackerType(builder, byte(hit.AttackerType))
+	builder.Finish(payloadsfb.ServerPlayerHitEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerPlayerHit(b []byte) (_ *ServerPlayerHit, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerPlayerHit(b, 0)
+	return &ServerPlayerHit{
+		PlayerID:     fb.PlayerId(),
+		AttackerType: EntityType(fb.AttackerType()),
+		AttackerID:   fb.AttackerId(),
+		Damage:       fb.Damage(),
+	}, nil
+}
+
+func SerializeServerPlayerKill(kill *ServerPlayerKill) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerPlayerKillStart(builder)
+	payloadsfb.ServerPlayerKillAddPlayerId(builder, kill.PlayerID)
+	payloadsfb.ServerPlayerKillAddAttackerId(builder, kill.AttackerID)
+	payloadsfb.ServerPlayerKillAddAttackerType(builder, byte(kill.AttackerType))
+	builder.Finish(payloadsfb.ServerPlayerKillEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerPlayerKill(b []byte) (_ *ServerPlayerKill, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerPlayerKill(b, 0)
+	return &ServerPlayerKill{
+		PlayerID:     fb.PlayerId(),
+		AttackerType: EntityType(fb.AttackerType()),
+		AttackerID:   fb.AttackerId(),
+	}, nil
+}
+
+func SerializeServerEntityEnterView(enter *ServerEntityEnterView) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerEntityEnterViewStart(builder)
+	payloadsfb.ServerEntityEnterViewAddEntityType(builder, byte(enter.EntityType))
+	payloadsfb.ServerEntityEnterViewAddEntityId(builder, enter.EntityID)
+	builder.Finish(payloadsfb.ServerEntityEnterViewEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerEntityEnterView(b []byte) (_ *ServerEntityEnterView, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerEntityEnterView(b, 0)
+	return &ServerEntityEnterView{
+		EntityType: EntityType(fb.EntityType()),
+		EntityID:   fb.EntityId(),
+	}, nil
+}
+
+func SerializeServerEntityLeaveView(leave *ServerEntityLeaveView) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	payloadsfb.ServerEntityLeaveViewStart(builder)
+	payloadsfb.ServerEntityLeaveViewAddEntityType(builder, byte(leave.EntityType))
+	payloadsfb.ServerEntityLeaveViewAddEntityId(builder, leave.EntityID)
+	builder.Finish(payloadsfb.ServerEntityLeaveViewEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerEntityLeaveView(b []byte) (_ *ServerEntityLeaveView, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerEntityLeaveView(b, 0)
+	return &ServerEntityLeaveView{
+		EntityType: EntityType(fb.EntityType()),
+		EntityID:   fb.EntityId(),
+	}, nil
+}
+
+func SerializeServerShutdown(shutdown *ServerShutdown) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	reason := builder.CreateString(shutdown.Reason)
+	payloadsfb.ServerShutdownStart(builder)
+	payloadsfb.ServerShutdownAddCountdown(builder, shutdown.Countdown)
+	payloadsfb.ServerShutdownAddReason(builder, reason)
+	builder.Finish(payloadsfb.ServerShutdownEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerShutdown(b []byte) (_ *ServerShutdown, err error) {
+	defer recoverMalformed(&err)
+	fb := payloadsfb.GetRootAsServerShutdown(b, 0)
+	return &ServerShutdown{
+		Countdown: fb.Countdown(),
+		Reason:    string(fb.Reason()),
+	}, nil
+}
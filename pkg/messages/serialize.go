@@ -1,45 +1,48 @@
 package messages
 
 import (
-	"bytes"
 	"fmt"
-	"io"
 
 	gamestatefb "github.com/cbodonnell/flywheel/flatbuffers/gamestate"
 	messagefb "github.com/cbodonnell/flywheel/flatbuffers/message"
 	flatbuffers "github.com/google/flatbuffers/go"
-	"github.com/klauspost/compress/zstd"
 )
 
+// SerializeMessage serializes and compresses a message
 func SerializeMessage(m *Message) ([]byte, error) {
 	b, err := SerializeMessageFlatbuffer(m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize message: %v", err)
 	}
+	captureMessage(b)
 
-	compressed := bytes.NewBuffer(nil)
-	compWriter, err := zstd.NewWriter(compressed, zstd.WithEncoderLevel(zstd.SpeedFastest))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create zstd writer: %v", err)
+	codec := getDefaultCodec()
+	if isHandshakeMessage(m.Type) {
+		codec = handshakeCodec
 	}
-	if _, err := compWriter.Write(b); err != nil {
+	compressed, err := codec.Compress(nil, b)
+	if err != nil {
 		return nil, fmt.Errorf("failed to compress message: %v", err)
 	}
-	if err := compWriter.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close gzip writer: %v", err)
-	}
 
-	return compressed.Bytes(), nil
+	return compressed, nil
 }
 
-func DeserializeMessage(data []byte) (*Message, error) {
-	compReader, err := zstd.NewReader(bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create zstd reader: %v", err)
+// isHandshakeMessage returns whether messages of a type are part of the login handshake,
+// which are compressed without a dictionary since the peers have not agreed on one yet
+func isHandshakeMessage(t MessageType) bool {
+	switch t {
+	case MessageTypeClientLogin, MessageTypeServerLoginSuccess, MessageTypeServerLoginFailure:
+		return true
 	}
-	b, err := io.ReadAll(compReader)
+	return false
+}
+
+// DeserializeMessage decompresses and deserializes a message
+func DeserializeMessage(data []byte) (*Message, error) {
+	b, err := getDefaultCodec().Decompress(nil, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read decompressed message: %v", err)
+		return nil, fmt.Errorf("failed to decompress message: %v", err)
 	}
 
 	message, err := DeserializeMessageFlatbuffer(b)
@@ -66,7 +69,8 @@ func SerializeMessageFlatbuffer(m *Message) ([]byte, error) {
 	return b, nil
 }
 
-func DeserializeMessageFlatbuffer(b []byte) (*Message, error) {
+func DeserializeMessageFlatbuffer(b []byte) (_ *Message, err error) {
+	defer recoverMalformed(&err)
 	message := &Message{}
 	messageFlatbuffer := messagefb.GetRootAsMessage(b, 0)
 	message.ClientID = messageFlatbuffer.ClientId()
@@ -93,9 +97,16 @@ func DeserializeGameState(b []byte) (*ServerGameUpdate, error) {
 }
 
 func SerializeGameStateFlatbuffer(builder *flatbuffers.Builder, state *ServerGameUpdate) flatbuffers.UOffsetT {
+	// delta snapshots only include the fields that changed since the baseline
+	isDelta := state.BaselineTimestamp != 0
+
 	playerStateKVs := make([]flatbuffers.UOffsetT, 0, len(state.Players))
 	for k, v := range state.Players {
-		playerState := SerializePlayerStateFlatbuffer(builder, v)
+		fields := PlayerStateFieldsAll
+		if isDelta {
+			fields = v.Fields
+		}
+		playerState := serializePlayerStateFlatbuffer(builder, v, fields, isDelta)
 
 		gamestatefb.PlayerStateKeyValueStart(builder)
 		gamestatefb.PlayerStateKeyValueAddKey(builder, k)
@@ -112,7 +123,11 @@ func SerializeGameStateFlatbuffer(builder *flatbuffers.Builder, state *ServerGam
 
 	npcStateKVs := make([]flatbuffers.UOffsetT, 0, len(state.NPCs))
 	for k, v := range state.NPCs {
-		npcState := SerializeNPCStateFlatbuffer(builder, v)
+		fields := NPCStateFieldsAll
+		if isDelta {
+			fields = v.Fields
+		}
+		npcState := serializeNPCStateFlatbuffer(builder, v, fields, isDelta)
 
 		gamestatefb.NPCStateKeyValueStart(builder)
 		gamestatefb.NPCStateKeyValueAddKey(builder, k)
@@ -131,70 +146,151 @@ func SerializeGameStateFlatbuffer(builder *flatbuffers.Builder, state *ServerGam
 	gamestatefb.GameStateAddTimestamp(builder, state.Timestamp)
 	gamestatefb.GameStateAddPlayers(builder, playerStates)
 	gamestatefb.GameStateAddNpcs(builder, npcStates)
+	gamestatefb.GameStateAddBaselineTimestamp(builder, state.BaselineTimestamp)
+	gamestatefb.GameStateAddPart(builder, state.Part)
+	gamestatefb.GameStateAddPartCount(builder, state.PartCount)
 	gameState := gamestatefb.GameStateEnd(builder)
 
 	return gameState
 }
 
 func SerializePlayerStateFlatbuffer(builder *flatbuffers.Builder, state *PlayerStateUpdate) flatbuffers.UOffsetT {
-	name := builder.CreateString(state.Name)
+	return serializePlayerStateFlatbuffer(builder, state, PlayerStateFieldsAll, false)
+}
 
-	gamestatefb.PositionStart(builder)
-	gamestatefb.PositionAddX(builder, state.Position.X)
-	gamestatefb.PositionAddY(builder, state.Position.Y)
-	position := gamestatefb.PositionEnd(builder)
+// serializePlayerStateFlatbuffer serializes the given fields of a player state,
+// and the field mask itself if the state is part of a delta snapshot
+func serializePlayerStateFlatbuffer(builder *flatbuffers.Builder, state *PlayerStateUpdate, fields PlayerStateField, isDelta bool) flatbuffers.UOffsetT {
+	var name, position, velocity flatbuffers.UOffsetT
+	if fields&PlayerStateFieldName != 0 {
+		name = builder.CreateString(state.Name)
+	}
+
+	if fields&PlayerStateFieldPosition != 0 {
+		gamestatefb.PositionStart(builder)
+		gamestatefb.PositionAddX(builder, state.Position.X)
+		gamestatefb.PositionAddY(builder, state.Position.Y)
+		position = gamestatefb.PositionEnd(builder)
+	}
 
-	gamestatefb.VelocityStart(builder)
-	gamestatefb.VelocityAddX(builder, state.Velocity.X)
-	gamestatefb.VelocityAddY(builder, state.Velocity.Y)
-	velocity := gamestatefb.VelocityEnd(builder)
+	if fields&PlayerStateFieldVelocity != 0 {
+		gamestatefb.VelocityStart(builder)
+		gamestatefb.VelocityAddX(builder, state.Velocity.X)
+		gamestatefb.VelocityAddY(builder, state.Velocity.Y)
+		velocity = gamestatefb.VelocityEnd(builder)
+	}
 
 	gamestatefb.PlayerStateStart(builder)
-	gamestatefb.PlayerStateAddLastProcessedTimestamp(builder, state.LastProcessedTimestamp)
-	gamestatefb.PlayerStateAddCharacterId(builder, state.CharacterID)
-	gamestatefb.PlayerStateAddName(builder, name)
-	gamestatefb.PlayerStateAddPosition(builder, position)
-	gamestatefb.PlayerStateAddVelocity(builder, velocity)
-	gamestatefb.PlayerStateAddFlipH(builder, state.FlipH)
-	gamestatefb.PlayerStateAddIsOnGround(builder, state.IsOnGround)
-	gamestatefb.PlayerStateAddIsOnLadder(builder, state.IsOnLadder)
-	gamestatefb.PlayerStateAddIsAttacking(builder, state.IsAttacking)
-	gamestatefb.PlayerStateAddAnimation(builder, byte(state.Animation))
-	gamestatefb.PlayerStateAddAnimationSequence(builder, state.AnimationSequence)
-	gamestatefb.PlayerStateAddHitpoints(builder, state.Hitpoints)
+	if fields&PlayerStateFieldLastProcessedTimestamp != 0 {
+		gamestatefb.PlayerStateAddLastProcessedTimestamp(builder, state.LastProcessedTimestamp)
+	}
+	if fields&PlayerStateFieldCharacterID != 0 {
+		gamestatefb.PlayerStateAddCharacterId(builder, state.CharacterID)
+	}
+	if fields&PlayerStateFieldName != 0 {
+		gamestatefb.PlayerStateAddName(builder, name)
+	}
+	if fields&PlayerStateFieldPosition != 0 {
+		gamestatefb.PlayerStateAddPosition(builder, position)
+	}
+	if fields&PlayerStateFieldVelocity != 0 {
+		gamestatefb.PlayerStateAddVelocity(builder, velocity)
+	}
+	if fields&PlayerStateFieldFlipH != 0 {
+		gamestatefb.PlayerStateAddFlipH(builder, state.FlipH)
+	}
+	if fields&PlayerStateFieldIsOnGround != 0 {
+		gamestatefb.PlayerStateAddIsOnGround(builder, state.IsOnGround)
+	}
+	if fields&PlayerStateFieldIsOnLadder != 0 {
+		gamestatefb.PlayerStateAddIsOnLadder(builder, state.IsOnLadder)
+	}
+	if fields&PlayerStateFieldIsAttacking != 0 {
+		gamestatefb.PlayerStateAddIsAttacking(builder, state.IsAttacking)
+	}
+	if fields&PlayerStateFieldAnimation != 0 {
+		gamestatefb.PlayerStateAddAnimation(builder, byte(state.Animation))
+	}
+	if fields&PlayerStateFieldAnimationSequence != 0 {
+		gamestatefb.PlayerStateAddAnimationSequence(builder, state.AnimationSequence)
+	}
+	if fields&PlayerStateFieldHitpoints != 0 {
+		gamestatefb.PlayerStateAddHitpoints(builder, state.Hitpoints)
+	}
+	if isDelta {
+		gamestatefb.PlayerStateAddFields(builder, uint16(fields))
+	}
 	playerState := gamestatefb.PlayerStateEnd(builder)
 
 	return playerState
 }
 
 func SerializeNPCStateFlatbuffer(builder *flatbuffers.Builder, state *NPCStateUpdate) flatbuffers.UOffsetT {
-	gamestatefb.PositionStart(builder)
-	gamestatefb.PositionAddX(builder, state.Position.X)
-	gamestatefb.PositionAddY(builder, state.Position.Y)
-	position := gamestatefb.PositionEnd(builder)
+	return serializeNPCStateFlatbuffer(builder, state, NPCStateFieldsAll, false)
+}
+
+// serializeNPCStateFlatbuffer serializes the given fields of an NPC state,
+// and the field mask itself if the state is part of a delta snapshot
+func serializeNPCStateFlatbuffer(builder *flatbuffers.Builder, state *NPCStateUpdate, fields NPCStateField, isDelta bool) flatbuffers.UOffsetT {
+	var archetype, position, velocity flatbuffers.UOffsetT
+	if fields&NPCStateFieldArchetype != 0 {
+		archetype = builder.CreateString(state.Archetype)
+	}
+	if fields&NPCStateFieldPosition != 0 {
+		gamestatefb.PositionStart(builder)
+		gamestatefb.PositionAddX(builder, state.Position.X)
+		gamestatefb.PositionAddY(builder, state.Position.Y)
+		position = gamestatefb.PositionEnd(builder)
+	}
 
-	gamestatefb.VelocityStart(builder)
-	gamestatefb.VelocityAddX(builder, state.Velocity.X)
-	gamestatefb.VelocityAddY(builder, state.Velocity.Y)
-	velocity := gamestatefb.VelocityEnd(builder)
+	if fields&NPCStateFieldVelocity != 0 {
+		gamestatefb.VelocityStart(builder)
+		gamestatefb.VelocityAddX(builder, state.Velocity.X)
+		gamestatefb.VelocityAddY(builder, state.Velocity.Y)
+		velocity = gamestatefb.VelocityEnd(builder)
+	}
 
 	gamestatefb.NPCStateStart(builder)
-	gamestatefb.NPCStateAddPosition(builder, position)
-	gamestatefb.NPCStateAddVelocity(builder, velocity)
-	gamestatefb.NPCStateAddFlipH(builder, state.FlipH)
-	gamestatefb.NPCStateAddIsOnGround(builder, state.IsOnGround)
-	gamestatefb.NPCStateAddAnimation(builder, byte(state.Animation))
-	gamestatefb.NPCStateAddAnimationSequence(builder, state.AnimationSequence)
-	gamestatefb.NPCStateAddHitpoints(builder, state.Hitpoints)
+	if fields&NPCStateFieldPosition != 0 {
+		gamestatefb.NPCStateAddPosition(builder, position)
+	}
+	if fields&NPCStateFieldVelocity != 0 {
+		gamestatefb.NPCStateAddVelocity(builder, velocity)
+	}
+	if fields&NPCStateFieldFlipH != 0 {
+		gamestatefb.NPCStateAddFlipH(builder, state.FlipH)
+	}
+	if fields&NPCStateFieldIsOnGround != 0 {
+		gamestatefb.NPCStateAddIsOnGround(builder, state.IsOnGround)
+	}
+	if fields&NPCStateFieldAnimation != 0 {
+		gamestatefb.NPCStateAddAnimation(builder, byte(state.Animation))
+	}
+	if fields&NPCStateFieldAnimationSequence != 0 {
+		gamestatefb.NPCStateAddAnimationSequence(builder, state.AnimationSequence)
+	}
+	if fields&NPCStateFieldHitpoints != 0 {
+		gamestatefb.NPCStateAddHitpoints(builder, state.Hitpoints)
+	}
+	if fields&NPCStateFieldArchetype != 0 {
+		gamestatefb.NPCStateAddArchetype(builder, archetype)
+	}
+	if isDelta {
+		gamestatefb.NPCStateAddFields(builder, uint16(fields))
+	}
 	npcState := gamestatefb.NPCStateEnd(builder)
 
 	return npcState
 }
 
-func DeserializeGameStateFlatbuffer(b []byte) (*ServerGameUpdate, error) {
+func DeserializeGameStateFlatbuffer(b []byte) (_ *ServerGameUpdate, err error) {
+	defer recoverMalformed(&err)
 	gameState := &ServerGameUpdate{}
 	gameStateFlatbuffer := gamestatefb.GetRootAsGameState(b, 0)
 	gameState.Timestamp = gameStateFlatbuffer.Timestamp()
+	gameState.BaselineTimestamp = gameStateFlatbuffer.BaselineTimestamp()
+	gameState.Part = gameStateFlatbuffer.Part()
+	gameState.PartCount = gameStateFlatbuffer.PartCount()
 	players := make(map[uint32]*PlayerStateUpdate)
 	for i := 0; i < gameStateFlatbuffer.PlayersLength(); i++ {
 		playerStateKV := &gamestatefb.PlayerStateKeyValue{}
@@ -223,10 +319,15 @@ func PlayerStateFlatbufferToPlayerStateUpdate(fb *gamestatefb.PlayerState) *Play
 	playerState.LastProcessedTimestamp = fb.LastProcessedTimestamp()
 	playerState.CharacterID = fb.CharacterId()
 	playerState.Name = string(fb.Name())
-	playerState.Position.X = fb.Position(nil).X()
-	playerState.Position.Y = fb.Position(nil).Y()
-	playerState.Velocity.X = fb.Velocity(nil).X()
-	playerState.Velocity.Y = fb.Velocity(nil).Y()
+	// position and velocity are omitted from delta snapshots when unchanged
+	if position := fb.Position(nil); position != nil {
+		playerState.Position.X = position.X()
+		playerState.Position.Y = position.Y()
+	}
+	if velocity := fb.Velocity(nil); velocity != nil {
+		playerState.Velocity.X = velocity.X()
+		playerState.Velocity.Y = velocity.Y()
+	}
 	playerState.FlipH = fb.FlipH()
 	playerState.IsOnGround = fb.IsOnGround()
 	playerState.IsOnLadder = fb.IsOnLadder()
@@ -234,21 +335,29 @@ func PlayerStateFlatbufferToPlayerStateUpdate(fb *gamestatefb.PlayerState) *Play
 	playerState.Animation = fb.Animation()
 	playerState.AnimationSequence = fb.AnimationSequence()
 	playerState.Hitpoints = fb.Hitpoints()
+	playerState.Fields = PlayerStateField(fb.Fields())
 
 	return playerState
 }
 
 func NPCStateFlatbufferToNPCStateUpdate(fb *gamestatefb.NPCState) *NPCStateUpdate {
 	npcState := &NPCStateUpdate{}
-	npcState.Position.X = fb.Position(nil).X()
-	npcState.Position.Y = fb.Position(nil).Y()
-	npcState.Velocity.X = fb.Velocity(nil).X()
-	npcState.Velocity.Y = fb.Velocity(nil).Y()
+	// position and velocity are omitted from delta snapshots when unchanged
+	if position := fb.Position(nil); position != nil {
+		npcState.Position.X = position.X()
+		npcState.Position.Y = position.Y()
+	}
+	if velocity := fb.Velocity(nil); velocity != nil {
+		npcState.Velocity.X = velocity.X()
+		npcState.Velocity.Y = velocity.Y()
+	}
 	npcState.FlipH = fb.FlipH()
 	npcState.IsOnGround = fb.IsOnGround()
 	npcState.Animation = fb.Animation()
 	npcState.AnimationSequence = fb.AnimationSequence()
 	npcState.Hitpoints = fb.Hitpoints()
+	npcState.Archetype = string(fb.Archetype())
+	npcState.Fields = NPCStateField(fb.Fields())
 
 	return npcState
 }
@@ -265,7 +374,8 @@ func SerializeServerPlayerUpdate(update *ServerPlayerUpdate) ([]byte, error) {
 	return builder.FinishedBytes(), nil
 }
 
-func DeserializeServerPlayerUpdate(b []byte) (*ServerPlayerUpdate, error) {
+func DeserializeServerPlayerUpdate(b []byte) (_ *ServerPlayerUpdate, err error) {
+	defer recoverMalformed(&err)
 	serverPlayerUpdate := &ServerPlayerUpdate{}
 	serverPlayerUpdateFlatbuffer := gamestatefb.GetRootAsServerPlayerUpdate(b, 0)
 	serverPlayerUpdate.Timestamp = serverPlayerUpdateFlatbuffer.Timestamp()
@@ -286,7 +396,8 @@ func SerializeServerNPCUpdate(update *ServerNPCUpdate) ([]byte, error) {
 	return builder.FinishedBytes(), nil
 }
 
-func DeserializeServerNPCUpdate(b []byte) (*ServerNPCUpdate, error) {
+func DeserializeServerNPCUpdate(b []byte) (_ *ServerNPCUpdate, err error) {
+	defer recoverMalformed(&err)
 	serverNPCUpdate := &ServerNPCUpdate{}
 	serverNPCUpdateFlatbuffer := gamestatefb.GetRootAsServerNPCUpdate(b, 0)
 	serverNPCUpdate.Timestamp = serverNPCUpdateFlatbuffer.Timestamp()
@@ -294,3 +405,51 @@ func DeserializeServerNPCUpdate(b []byte) (*ServerNPCUpdate, error) {
 	serverNPCUpdate.NPCState = NPCStateFlatbufferToNPCStateUpdate(serverNPCUpdateFlatbuffer.NpcState(nil))
 	return serverNPCUpdate, nil
 }
+
+func SerializeServerPlayerConnect(connect *ServerPlayerConnect) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	playerState := SerializePlayerStateFlatbuffer(builder, connect.PlayerState)
+	gamestatefb.ServerPlayerConnectStart(builder)
+	gamestatefb.ServerPlayerConnectAddClientId(builder, connect.ClientID)
+	gamestatefb.ServerPlayerConnectAddPlayerState(builder, playerState)
+	gamestatefb.ServerPlayerConnectAddZoneId(builder, connect.ZoneID)
+	serverPlayerConnect := gamestatefb.ServerPlayerConnectEnd(builder)
+	builder.Finish(serverPlayerConnect)
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerPlayerConnect(b []byte) (_ *ServerPlayerConnect, err error) {
+	defer recoverMalformed(&err)
+	serverPlayerConnect := &ServerPlayerConnect{}
+	serverPlayerConnectFlatbuffer := gamestatefb.GetRootAsServerPlayerConnect(b, 0)
+	serverPlayerConnect.ClientID = serverPlayerConnectFlatbuffer.ClientId()
+	if playerState := serverPlayerConnectFlatbuffer.PlayerState(nil); playerState != nil {
+		serverPlayerConnect.PlayerState = PlayerStateFlatbufferToPlayerStateUpdate(playerState)
+	}
+	serverPlayerConnect.ZoneID = serverPlayerConnectFlatbuffer.ZoneId()
+	return serverPlayerConnect, nil
+}
+
+func SerializeServerZoneChange(zoneChange *ServerZoneChange) ([]byte, error) {
+	builder := flatbuffers.NewBuilder(0)
+	playerState := SerializePlayerStateFlatbuffer(builder, zoneChange.PlayerState)
+	gamestatefb.ServerZoneChangeStart(builder)
+	gamestatefb.ServerZoneChangeAddTimestamp(builder, zoneChange.Timestamp)
+	gamestatefb.ServerZoneChangeAddZoneId(builder, zoneChange.ZoneID)
+	gamestatefb.ServerZoneChangeAddPlayerState(builder, playerState)
+	builder.Finish(gamestatefb.ServerZoneChangeEnd(builder))
+	return builder.FinishedBytes(), nil
+}
+
+func DeserializeServerZoneChange(b []byte) (_ *ServerZoneChange, err error) {
+	defer recoverMalformed(&err)
+	fb := gamestatefb.GetRootAsServerZoneChange(b, 0)
+	zoneChange := &ServerZoneChange{
+		Timestamp: fb.Timestamp(),
+		ZoneID:    fb.ZoneId(),
+	}
+	if playerState := fb.PlayerState(nil); playerState != nil {
+		zoneChange.PlayerState = PlayerStateFlatbufferToPlayerStateUpdate(playerState)
+	}
+	return zoneChange, nil
+}
@@ -0,0 +1,298 @@
+package messages
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cbodonnell/flywheel/pkg/kinematic"
+	"github.com/stretchr/testify/assert"
+)
+
+func benchmarkPlayerState(i int) *PlayerStateUpdate {
+	return &PlayerStateUpdate{
+		LastProcessedTimestamp: 1700000000000 + int64(i),
+		CharacterID:            int32(i),
+		Name:                   fmt.Sprintf("player-%d", i),
+		Position:               kinematic.NewVector(float64(i*32), 240),
+		Velocity:               kinematic.NewVector(120, 0),
+		IsOnGround:             true,
+		Animation:              1,
+		Hitpoints:              100,
+	}
+}
+
+func benchmarkNPCState(i int) *NPCStateUpdate {
+	return &NPCStateUpdate{
+		Position:   kinematic.NewVector(float64(i*48), 320),
+		Velocity:   kinematic.NewVector(-60, 0),
+		FlipH:      true,
+		IsOnGround: true,
+		Animation:  2,
+		Hitpoints:  50,
+	}
+}
+
+func benchmarkGameState(players, npcs int) *ServerGameUpdate {
+	state := &ServerGameUpdate{
+		Timestamp: 1700000000000,
+		Players:   make(map[uint32]*PlayerStateUpdate, players),
+		NPCs:      make(map[uint32]*NPCStateUpdate, npcs),
+	}
+	for i := 1; i <= players; i++ {
+		state.Players[uint32(i)] = benchmarkPlayerState(i)
+	}
+	for i := 1; i <= npcs; i++ {
+		state.NPCs[uint32(i)] = benchmarkNPCState(i)
+	}
+	return state
+}
+
+// benchmarkMessages returns a representative message of every message type
+func benchmarkMessages(tb testing.TB) []*Message {
+	var msgs []*Message
+	for _, tc := range payloadTestCases() {
+		payload, err := tc.serialize()
+		if err != nil {
+			tb.Fatalf("failed to serialize %s payload: %v", tc.messageType, err)
+		}
+		msgs = append(msgs, &Message{ClientID: 1, Type: tc.messageType, Payload: payload})
+	}
+	return msgs
+}
+
+// dictionarySamples returns serialized messages resembling a few seconds of traffic
+func dictionarySamples(tb testing.TB) [][]byte {
+	var samples [][]byte
+	for i := 0; i < 100; i++ {
+		for _, msg := range benchmarkMessages(tb) {
+			if msg.Type == MessageTypeServerGameUpdate {
+				state := benchmarkGameState(8, 16)
+				state.Timestamp += int64(i) * 50
+				for _, npcState := range state.NPCs {
+					npcState.Position.X += float64(i)
+				}
+				payload, err := SerializeGameState(state)
+				if err != nil {
+					tb.Fatal(err)
+				}
+				msg.Payload = payload
+			}
+			sample, err := SerializeMessageFlatbuffer(msg)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func BenchmarkSerializeMessage(b *testing.B) {
+	for _, msg := range benchmarkMessages(b) {
+		b.Run(msg.Type.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := SerializeMessage(msg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+			}
+		})
+	}
+}
+
+func BenchmarkDeserializeMessage(b *testing.B) {
+	for _, msg := range benchmarkMessages(b) {
+		data, err := SerializeMessage(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(msg.Type.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := DeserializeMessage(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSerializeMessageParallel(b *testing.B) {
+	msg := &Message{ClientID: 1, Type: MessageTypeServerGameUpdate}
+	payload, err := SerializeGameState(benchmarkGameState(8, 16))
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg.Payload = payload
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := SerializeMessage(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCodecDictionary(b *testing.B) {
+	samples := dictionarySamples(b)
+	dict, err := BuildCompressionDictionary(samples)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		dict []byte
+	}{
+		{name: "NoDictionary"},
+		{name: "Dictionary", dict: dict},
+	} {
+		codec, err := NewCodec(NewCodecOptions{Dictionary: tc.dict})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var dst []byte
+			compressedSize := 0
+			for i := 0; i < b.N; i++ {
+				sample := samples[i%len(samples)]
+				dst, err = codec.Compress(dst[:0], sample)
+				if err != nil {
+					b.Fatal(err)
+				}
+				compressedSize += len(dst)
+			}
+			b.ReportMetric(float64(compressedSize)/float64(b.N), "compressed-bytes/op")
+		})
+	}
+}
+
+func TestCodecDictionary(t *testing.T) {
+	samples := dictionarySamples(t)
+	dict, err := BuildCompressionDictionary(samples)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	withDict, err := NewCodec(NewCodecOptions{Dictionary: dict})
+	assert.NoError(t, err)
+	withoutDict, err := NewCodec(NewCodecOptions{})
+	assert.NoError(t, err)
+
+	for _, sample := range samples {
+		compressed, err := withDict.Compress(nil, sample)
+		assert.NoError(t, err)
+		decompressed, err := withDict.Decompress(nil, compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, sample, decompressed)
+
+		// a codec with a dictionary can still read messages compressed without one
+		compressed, err = withoutDict.Compress(nil, sample)
+		assert.NoError(t, err)
+		decompressed, err = withDict.Decompress(nil, compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, sample, decompressed)
+	}
+
+	_, err = NewCodec(NewCodecOptions{Dictionary: []byte("not a dictionary")})
+	assert.Error(t, err)
+}
+
+func TestCheckCompressionDictionary(t *testing.T) {
+	dict, err := BuildCompressionDictionary(dictionarySamples(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Zero(t, DictionaryChecksum(nil))
+	assert.NotZero(t, DictionaryChecksum(dict))
+
+	assert.NoError(t, CheckCompressionDictionary(0))
+	assert.IsType(t, &ErrCompressionDictionaryMismatch{}, CheckCompressionDictionary(DictionaryChecksum(dict)))
+
+	if !assert.NoError(t, SetCompressionDictionary(dict)) {
+		return
+	}
+	defer SetCompressionDictionary(nil)
+	assert.Equal(t, DictionaryChecksum(dict), CompressionDictionaryChecksum())
+	assert.NoError(t, CheckCompressionDictionary(DictionaryChecksum(dict)))
+	assert.IsType(t, &ErrCompressionDictionaryMismatch{}, CheckCompressionDictionary(0))
+}
+
+func TestSerializeMessage_Handshake(t *testing.T) {
+	dict, err := BuildCompressionDictionary(dictionarySamples(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, SetCompressionDictionary(dict)) {
+		return
+	}
+	defer SetCompressionDictionary(nil)
+
+	withoutDict, err := NewCodec(NewCodecOptions{})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		messageType MessageType
+		handshake   bool
+	}{
+		{messageType: MessageTypeClientLogin, handshake: true},
+		{messageType: MessageTypeServerLoginSuccess, handshake: true},
+		{messageType: MessageTypeServerLoginFailure, handshake: true},
+		{messageType: MessageTypeServerGameUpdate, handshake: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.messageType.String(), func(t *testing.T) {
+			b, err := SerializeMessage(&Message{ClientID: 1, Type: tt.messageType, Payload: []byte("payload")})
+			assert.NoError(t, err)
+
+			// a peer without the dictionary can only read the handshake
+			_, err = withoutDict.Decompress(nil, b)
+			if tt.handshake {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			msg, err := DeserializeMessage(b)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.messageType, msg.Type)
+		})
+	}
+}
+
+func TestBuildCompressionDictionary_InvalidSamples(t *testing.T) {
+	samples := dictionarySamples(t)
+	small := make([][]byte, MinCompressionDictionarySamples)
+	for i := range small {
+		small[i] = []byte(fmt.Sprintf("sample %d", i))
+	}
+	identical := make([][]byte, 5000)
+	for i := range identical {
+		identical[i] = samples[0]
+	}
+
+	tests := []struct {
+		name    string
+		samples [][]byte
+	}{
+		{name: "no samples"},
+		{name: "one sample", samples: samples[:1]},
+		{name: "too few samples", samples: samples[:100]},
+		{name: "identical samples", samples: identical},
+		{name: "too little data", samples: small},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildCompressionDictionary(tt.samples)
+			assert.Error(t, err)
+		})
+	}
+}
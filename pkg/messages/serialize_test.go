@@ -55,6 +55,40 @@ func TestSerializeDeserializeGameState(t *testing.T) {
 							IsOnGround: true,
 							Animation:  0,
 							Hitpoints:  100,
+							Archetype:  "skeleton",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Delta game state",
+			args: args{
+				state: &ServerGameUpdate{
+					Timestamp:         2,
+					BaselineTimestamp: 1,
+					Players: map[uint32]*PlayerStateUpdate{
+						1: {
+							Position: kinematic.Vector{
+								X: 1.0,
+								Y: 0.0,
+							},
+							Hitpoints: 90,
+							Fields:    PlayerStateFieldPosition | PlayerStateFieldHitpoints,
+						},
+					},
+					NPCs: map[uint32]*NPCStateUpdate{
+						1: {
+							Fields: 0,
+						},
+						2: {
+							Velocity: kinematic.Vector{
+								X: 0.0,
+								Y: -1.0,
+							},
+							FlipH:  true,
+							Fields: NPCStateFieldsAll,
 						},
 					},
 				},
@@ -80,3 +114,29 @@ func TestSerializeDeserializeGameState(t *testing.T) {
 		})
 	}
 }
+
+func TestDeserializeServerPlayerConnect_Malformed(t *testing.T) {
+	valid, err := SerializeServerPlayerConnect(&ServerPlayerConnect{
+		ClientID: 1,
+		PlayerState: &PlayerStateUpdate{
+			Position: kinematic.NewVector(1, 2),
+		},
+		ZoneID: 2,
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{name: "empty", b: nil},
+		{name: "root offset out of range", b: []byte{0xff, 0xff, 0xff, 0x7f}},
+		{name: "truncated", b: valid[:len(valid)/2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DeserializeServerPlayerConnect(tt.b)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package network
 
 import (
+	"context"
+	crand "crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"net"
 	"sync"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/transport"
+	"github.com/cbodonnell/flywheel/pkg/version"
 )
 
 const (
@@ -12,6 +21,16 @@ const (
 	ClientIDMaxRetries = 1024
 	// ConnectionEventChannelSize represents the size of the client event channel
 	ConnectionEventChannelSize = 1024
+	// ResumeTokenSize is the number of random bytes in a resume token
+	ResumeTokenSize = 32
+	// DefaultReconnectGracePeriod is how long a client whose TCP connection dropped can resume its session
+	DefaultReconnectGracePeriod = 30 * time.Second
+	// DefaultIdleTimeout is how long a client can go without TCP or UDP traffic before it is disconnected
+	DefaultIdleTimeout = 30 * time.Second
+	// ReapInterval is how often clients are checked for idleness
+	ReapInterval = time.Second
+	// KickWriteTimeout is how long the server waits to tell a kicked client why before dropping its connection
+	KickWriteTimeout = time.Second
 )
 
 // Client represents a connected client
@@ -20,6 +39,39 @@ type Client struct {
 	TCPConn    net.Conn
 	UDPAddress *net.UDPAddr
 	UserID     string
+	// Endpoint tracks the UDP channel state for the client
+	Endpoint *transport.Endpoint
+	// Capabilities are the protocol capabilities negotiated with the client at login
+	Capabilities version.Capability
+	// ResumeToken is the token the client presents to resume its session after its TCP connection drops
+	ResumeToken string
+	// LastSeenTCP and LastSeenUDP are when traffic was last received from the client on each transport
+	LastSeenTCP time.Time
+	LastSeenUDP time.Time
+	// resumeTimer disconnects the client if its suspended session is not resumed in time
+	resumeTimer *time.Timer
+	// rateLimiter limits the messages of the client, or is nil if rate limits are disabled
+	rateLimiter *ClientRateLimiter
+}
+
+// copy returns a copy of the client that is safe to use without holding the clients lock
+func (c *Client) copy() *Client {
+	copy := &Client{
+		ID:           c.ID,
+		TCPConn:      c.TCPConn,
+		Endpoint:     c.Endpoint,
+		Capabilities: c.Capabilities,
+		LastSeenTCP:  c.LastSeenTCP,
+		LastSeenUDP:  c.LastSeenUDP,
+	}
+	if c.UDPAddress != nil {
+		copy.UDPAddress = &net.UDPAddr{
+			IP:   c.UDPAddress.IP,
+			Port: c.UDPAddress.Port,
+			Zone: c.UDPAddress.Zone,
+		}
+	}
+	return copy
 }
 
 // ConnectionEvent represents an event that happened to a client
@@ -42,6 +94,15 @@ type ClientConnectData struct {
 	CharacterID int32
 }
 
+// ErrResumeFailed is returned when a client's session cannot be resumed
+type ErrResumeFailed struct {
+	ClientID uint32
+}
+
+func (e *ErrResumeFailed) Error() string {
+	return fmt.Sprintf("no session to resume for client %d", e.ClientID)
+}
+
 // ClientManager manages connected clients
 type ClientManager struct {
 	clients     map[uint32]*Client
@@ -50,17 +111,60 @@ type ClientManager struct {
 	// UDP connection for broadcasting to clients
 	udpConn             *net.UDPConn
 	connectionEventChan chan<- ConnectionEvent
+	// reconnectGracePeriod is how long a client whose TCP connection dropped
+	// stays connected so it can resume its session
+	reconnectGracePeriod time.Duration
+	// idleTimeout is how long a client can go without traffic before it is reaped
+	idleTimeout time.Duration
+	// rateLimits are the rate limits of clients, or nil if they are disabled
+	rateLimits       *RateLimitOptions
+	rateLimitMetrics RateLimitMetrics
+	// kicks are the times users were recently kicked and bans are when the bans of users end
+	kicks map[string][]time.Time
+	bans  map[string]time.Time
+	// now returns the current time
+	now func() time.Time
+}
+
+// NewClientManagerOptions are the options for creating a new ClientManager
+type NewClientManagerOptions struct {
+	ConnectionEventChan chan<- ConnectionEvent
+	// ReconnectGracePeriod is how long a client whose TCP connection dropped can resume
+	// its session before it is disconnected. Zero disconnects clients immediately.
+	ReconnectGracePeriod time.Duration
+	// IdleTimeout is how long a client can go without TCP or UDP traffic
+	// before it is disconnected by StartReaper. Zero disables reaping.
+	IdleTimeout time.Duration
+	// RateLimits are the rate limits of the messages of each client. Nil disables rate limiting.
+	RateLimits *RateLimitOptions
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
 }
 
 // NewClientManager creates a new ClientManager
-func NewClientManager(connectionEventChan chan<- ConnectionEvent) *ClientManager {
+func NewClientManager(opts NewClientManagerOptions) *ClientManager {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
 	return &ClientManager{
-		clients:             make(map[uint32]*Client),
-		clientUIDs:          make(map[string]uint32),
-		connectionEventChan: connectionEventChan,
+		clients:              make(map[uint32]*Client),
+		clientUIDs:           make(map[string]uint32),
+		connectionEventChan:  opts.ConnectionEventChan,
+		reconnectGracePeriod: opts.ReconnectGracePeriod,
+		idleTimeout:          opts.IdleTimeout,
+		rateLimits:           opts.RateLimits,
+		kicks:                make(map[string][]time.Time),
+		bans:                 make(map[string]time.Time),
+		now:                  now,
 	}
 }
 
+// ReconnectGracePeriod returns how long a client can resume its session after its TCP connection drops
+func (cm *ClientManager) ReconnectGracePeriod() time.Duration {
+	return cm.reconnectGracePeriod
+}
+
 // SetUDPConn sets the UDP listener connection for all clients
 func (cm *ClientManager) SetUDPConn(conn *net.UDPConn) {
 	cm.udpConn = conn
@@ -80,39 +184,66 @@ func (cm *ClientManager) GetClients() []*Client {
 	defer cm.clientsLock.RUnlock()
 	clients := make([]*Client, 0, len(cm.clients))
 	for _, client := range cm.clients {
-		copy := &Client{
-			ID:      client.ID,
-			TCPConn: client.TCPConn,
-		}
-		if client.UDPAddress != nil {
-			copy.UDPAddress = &net.UDPAddr{
-				IP:   client.UDPAddress.IP,
-				Port: client.UDPAddress.Port,
-				Zone: client.UDPAddress.Zone,
-			}
-		}
-		clients = append(clients, copy)
+		clients = append(clients, client.copy())
 	}
 	return clients
 }
 
-// ConnectClient adds a new client to the manager and returns its ID
-func (cm *ClientManager) ConnectClient(tcpConn net.Conn, userID string, characterID int32) (uint32, error) {
+// GetClient returns a copy of a connected client.
+// Returns nil if the client is not found
+func (cm *ClientManager) GetClient(clientID uint32) *Client {
+	cm.clientsLock.RLock()
+	defer cm.clientsLock.RUnlock()
+	client, ok := cm.clients[clientID]
+	if !ok {
+		return nil
+	}
+	return client.copy()
+}
+
+// ConnectClient adds a new client to the manager and returns its ID and resume token.
+// The cipher seals and opens the client's UDP datagrams.
+func (cm *ClientManager) ConnectClient(tcpConn net.Conn, userID string, characterID int32, capabilities version.Capability, cipher *transport.SessionCipher) (uint32, string, error) {
 	cm.clientsLock.Lock()
 	defer cm.clientsLock.Unlock()
 
-	if _, ok := cm.clientUIDs[userID]; ok {
-		return 0, fmt.Errorf("user %s is already connected", userID)
+	now := cm.now()
+	if err := cm.checkBan(userID, now); err != nil {
+		return 0, "", err
+	}
+
+	if existingID, ok := cm.clientUIDs[userID]; ok {
+		if cm.clients[existingID].resumeTimer == nil {
+			return 0, "", fmt.Errorf("user %s is already connected", userID)
+		}
+		// the user logged in again instead of resuming, e.g. after restarting the client
+		log.Info("Disconnecting suspended client %d of user %s for a new login", existingID, userID)
+		cm.disconnectClient(existingID)
 	}
 
 	clientID, err := cm.generateUniqueID(ClientIDMaxRetries)
 	if err != nil {
-		return 0, fmt.Errorf("failed to generate a unique ID: %v", err)
+		return 0, "", fmt.Errorf("failed to generate a unique ID: %v", err)
+	}
+	resumeToken, err := generateResumeToken()
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to generate a resume token: %v", err)
 	}
 	client := &Client{
 		ID:      clientID,
 		TCPConn: tcpConn,
 		UserID:  userID,
+		Endpoint: transport.NewEndpoint(transport.NewEndpointOptions{
+			ClientID: clientID,
+			Cipher:   cipher,
+		}),
+		Capabilities: capabilities,
+		ResumeToken:  resumeToken,
+		LastSeenTCP:  now,
+		LastSeenUDP:  now,
+	}
+	if cm.rateLimits != nil {
+		client.rateLimiter = NewClientRateLimiter(cm.rateLimits)
 	}
 	cm.clients[clientID] = client
 	cm.clientUIDs[userID] = clientID
@@ -127,7 +258,78 @@ func (cm *ClientManager) ConnectClient(tcpConn net.Conn, userID string, characte
 	}
 	cm.connectionEventChan <- event
 
-	return clientID, nil
+	return clientID, resumeToken, nil
+}
+
+// SuspendClient suspends the session of a client whose TCP connection was closed.
+// The client stays connected for the reconnect grace period so it can resume
+// its session with ResumeClient, and is disconnected if it does not.
+func (cm *ClientManager) SuspendClient(clientID uint32, tcpConn net.Conn) {
+	cm.clientsLock.Lock()
+	defer cm.clientsLock.Unlock()
+
+	client, ok := cm.clients[clientID]
+	if !ok || client.TCPConn != tcpConn {
+		// the session was already resumed on another connection
+		return
+	}
+
+	if cm.reconnectGracePeriod <= 0 {
+		cm.disconnectClient(clientID)
+		return
+	}
+
+	client.TCPConn = nil
+	var timer *time.Timer
+	timer = time.AfterFunc(cm.reconnectGracePeriod, func() {
+		cm.clientsLock.Lock()
+		defer cm.clientsLock.Unlock()
+		if client, ok := cm.clients[clientID]; ok && client.resumeTimer == timer {
+			log.Info("Client %d did not resume its session within %s", clientID, cm.reconnectGracePeriod)
+			cm.disconnectClient(clientID)
+		}
+	})
+	client.resumeTimer = timer
+}
+
+// ResumeClient resumes the session of a client on a new TCP connection with the capabilities
+// negotiated on it, and returns a new resume token. The resume token must be the one last issued
+// to the client. The client's UDP endpoint and game state are kept as they were.
+// A client of a banned user is disconnected and an ErrBanned is returned.
+func (cm *ClientManager) ResumeClient(tcpConn net.Conn, clientID uint32, resumeToken string, capabilities version.Capability) (string, error) {
+	cm.clientsLock.Lock()
+	defer cm.clientsLock.Unlock()
+
+	client, ok := cm.clients[clientID]
+	if !ok || subtle.ConstantTimeCompare([]byte(client.ResumeToken), []byte(resumeToken)) != 1 {
+		return "", &ErrResumeFailed{ClientID: clientID}
+	}
+	if err := cm.checkBan(client.UserID, cm.now()); err != nil {
+		// a banned user does not get to keep its session
+		cm.disconnectClient(clientID)
+		return "", err
+	}
+
+	newResumeToken, err := generateResumeToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a resume token: %v", err)
+	}
+
+	if client.resumeTimer != nil {
+		client.resumeTimer.Stop()
+		client.resumeTimer = nil
+	}
+	if client.TCPConn != nil {
+		// the client reconnected before the old connection was seen to be closed
+		client.TCPConn.Close()
+	}
+	client.TCPConn = tcpConn
+	client.Capabilities = capabilities
+	client.ResumeToken = newResumeToken
+	client.LastSeenTCP = cm.now()
+	client.LastSeenUDP = cm.now()
+
+	return newResumeToken, nil
 }
 
 // GetClientIDByTCPConn returns the ID of a client by its TCP connection.
@@ -147,11 +349,19 @@ func (cm *ClientManager) GetClientIDByTCPConn(conn net.Conn) uint32 {
 func (cm *ClientManager) DisconnectClient(clientID uint32) {
 	cm.clientsLock.Lock()
 	defer cm.clientsLock.Unlock()
+	cm.disconnectClient(clientID)
+}
 
+// disconnectClient removes a client from the manager.
+// The clients lock must be held by the caller.
+func (cm *ClientManager) disconnectClient(clientID uint32) {
 	client, ok := cm.clients[clientID]
 	if !ok {
 		return
 	}
+	if client.resumeTimer != nil {
+		client.resumeTimer.Stop()
+	}
 
 	event := ConnectionEvent{
 		ClientID: client.ID,
@@ -168,12 +378,98 @@ func (cm *ClientManager) SetUDPAddress(clientID uint32, addr *net.UDPAddr) {
 	cm.clientsLock.Lock()
 	defer cm.clientsLock.Unlock()
 
+	client, ok := cm.clients[clientID]
+	if !ok {
+		// the client disconnected after its message was received
+		return
+	}
+
 	// Don't update the UDP address if it's already set to the same value
-	if cm.clients[clientID].UDPAddress != nil && cm.clients[clientID].UDPAddress.String() == addr.String() {
+	if client.UDPAddress != nil && client.UDPAddress.String() == addr.String() {
 		return
 	}
 
-	cm.clients[clientID].UDPAddress = addr
+	client.UDPAddress = addr
+}
+
+// UpdateLastSeenTCP records that traffic was received from a client over TCP
+func (cm *ClientManager) UpdateLastSeenTCP(clientID uint32) {
+	cm.clientsLock.Lock()
+	defer cm.clientsLock.Unlock()
+	if client, ok := cm.clients[clientID]; ok {
+		client.LastSeenTCP = cm.now()
+	}
+}
+
+// UpdateLastSeenUDP records that traffic was received from a client over UDP
+func (cm *ClientManager) UpdateLastSeenUDP(clientID uint32) {
+	cm.clientsLock.Lock()
+	defer cm.clientsLock.Unlock()
+	if client, ok := cm.clients[clientID]; ok {
+		client.LastSeenUDP = cm.now()
+	}
+}
+
+// StartReaper periodically disconnects clients that have not sent TCP or UDP
+// traffic within the idle timeout until the context is done. Suspended clients
+// are left to their reconnect grace period.
+func (cm *ClientManager) StartReaper(ctx context.Context) {
+	if cm.idleTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cm.reapIdleClients(now)
+		}
+	}
+}
+
+// reapIdleClients disconnects clients that have been idle for longer than the idle timeout
+func (cm *ClientManager) reapIdleClients(now time.Time) {
+	cm.clientsLock.Lock()
+	defer cm.clientsLock.Unlock()
+
+	for clientID, client := range cm.clients {
+		if client.resumeTimer != nil {
+			continue
+		}
+		idleTCP, idleUDP := now.Sub(client.LastSeenTCP), now.Sub(client.LastSeenUDP)
+		if idleTCP <= cm.idleTimeout && idleUDP <= cm.idleTimeout {
+			continue
+		}
+		log.Info("Disconnecting idle client %d (last TCP traffic %s ago, last UDP traffic %s ago)", clientID, idleTCP.Round(time.Millisecond), idleUDP.Round(time.Millisecond))
+		if client.TCPConn != nil {
+			client.TCPConn.Close()
+		}
+		cm.disconnectClient(clientID)
+	}
+}
+
+// GetEndpoint returns the UDP endpoint of a client.
+// Returns nil if the client is not found
+func (cm *ClientManager) GetEndpoint(clientID uint32) *transport.Endpoint {
+	cm.clientsLock.RLock()
+	defer cm.clientsLock.RUnlock()
+	client, ok := cm.clients[clientID]
+	if !ok {
+		return nil
+	}
+	return client.Endpoint
+}
+
+// HasCapabilities returns true if a connected client negotiated all of a set of capabilities
+func (cm *ClientManager) HasCapabilities(clientID uint32, capabilities version.Capability) bool {
+	cm.clientsLock.RLock()
+	defer cm.clientsLock.RUnlock()
+	client, ok := cm.clients[clientID]
+	return ok && client.Capabilities.Has(capabilities)
 }
 
 func (cm *ClientManager) Exists(clientID uint32) bool {
@@ -183,6 +479,15 @@ func (cm *ClientManager) Exists(clientID uint32) bool {
 	return ok
 }
 
+// generateResumeToken generates a random resume token
+func generateResumeToken() (string, error) {
+	b := make([]byte, ResumeTokenSize)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // generateUniqueID generates a unique client ID with a maximum number of retries
 // it reads from the clients, so it needs to be locked before calling
 func (cm *ClientManager) generateUniqueID(maxRetries int) (uint32, error) {
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientManager_HasCapabilities(t *testing.T) {
+	cm, _ := newTestClientManager(nil)
+	full, _, err := cm.ConnectClient(nil, "user-1", 1, version.Capabilities, nil)
+	assert.NoError(t, err)
+	// a client that lacks an optional capability can still connect
+	capabilities := version.NegotiateCapabilities(version.Capabilities &^ version.CapabilityDeltaSnapshots)
+	assert.NoError(t, version.CheckCompatibility(version.ProtocolVersion, capabilities))
+	partial, _, err := cm.ConnectClient(nil, "user-2", 2, capabilities, nil)
+	assert.NoError(t, err)
+
+	assert.True(t, cm.HasCapabilities(full, version.CapabilityDeltaSnapshots))
+	assert.False(t, cm.HasCapabilities(partial, version.CapabilityDeltaSnapshots))
+	assert.True(t, cm.HasCapabilities(partial, version.RequiredCapabilities))
+	assert.False(t, cm.HasCapabilities(0, version.RequiredCapabilities), "unknown clients have no capabilities")
+}
+
+func TestClientManager_ResumeClient(t *testing.T) {
+	cm, _ := newTestClientManager(nil)
+	clientID, resumeToken, err := cm.ConnectClient(nil, "user-1", 1, version.Capabilities, nil)
+	assert.NoError(t, err)
+
+	_, err = cm.ResumeClient(nil, clientID, "not the resume token", version.Capabilities)
+	assert.IsType(t, &ErrResumeFailed{}, err)
+
+	// the capabilities negotiated on the new connection replace those of the old one
+	capabilities := version.NegotiateCapabilities(version.Capabilities &^ version.CapabilityDeltaSnapshots)
+	newResumeToken, err := cm.ResumeClient(nil, clientID, resumeToken, capabilities)
+	assert.NoError(t, err)
+	assert.NotEqual(t, resumeToken, newResumeToken)
+	assert.False(t, cm.HasCapabilities(clientID, version.CapabilityDeltaSnapshots))
+
+	// and a resume token can only be used once
+	_, err = cm.ResumeClient(nil, clientID, resumeToken, version.Capabilities)
+	assert.IsType(t, &ErrResumeFailed{}, err)
+}
+
+func TestClientManager_ResumeClient_Banned(t *testing.T) {
+	cm, clock := newTestClientManager(DefaultRateLimitOptions())
+	clientID, resumeToken, err := cm.ConnectClient(nil, "user-1", 1, version.Capabilities, nil)
+	assert.NoError(t, err)
+
+	// the user is banned before its client resumes, such as for a kick of another of its clients
+	cm.clientsLock.Lock()
+	cm.bans["user-1"] = clock.Now().Add(time.Hour)
+	cm.clientsLock.Unlock()
+
+	_, err = cm.ResumeClient(nil, clientID, resumeToken, version.Capabilities)
+	assert.IsType(t, &ErrBanned{}, err)
+	assert.False(t, cm.Exists(clientID), "the session of a banned user is ended")
+}
+
+func TestClientManager_SetUDPAddress(t *testing.T) {
+	cm, _ := newTestClientManager(nil)
+	clientID, _, err := cm.ConnectClient(nil, "user-1", 1, version.Capabilities, nil)
+	assert.NoError(t, err)
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8889}
+
+	cm.SetUDPAddress(clientID, addr)
+	assert.Equal(t, addr, cm.GetClient(clientID).UDPAddress)
+
+	// a client can disconnect between receiving its ping and setting its address
+	cm.DisconnectClient(clientID)
+	assert.NotPanics(t, func() { cm.SetUDPAddress(clientID, addr) })
+}
@@ -0,0 +1,350 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/log"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/queue"
+)
+
+const (
+	// DefaultMaxRateLimitViolations is how many messages a client can have dropped
+	// within the violation window before it is kicked
+	DefaultMaxRateLimitViolations = 100
+	// DefaultRateLimitViolationWindow is the window over which dropped messages are counted
+	DefaultRateLimitViolationWindow = 10 * time.Second
+	// DefaultMaxKicks is the number of kicks within the kick window after which a user is banned
+	DefaultMaxKicks = 3
+	// DefaultKickWindow is the window over which kicks are counted
+	DefaultKickWindow = 10 * time.Minute
+	// DefaultBanDuration is how long a user is banned for
+	DefaultBanDuration = time.Hour
+	// DefaultClientQueueCapacity is the maximum number of queued messages of a client
+	DefaultClientQueueCapacity = 256
+	// DefaultClientMessagesPerTick is the maximum number of messages of a client processed in a game tick
+	DefaultClientMessagesPerTick = 16
+)
+
+// RateLimit is the sustained rate and burst allowed by a token bucket
+type RateLimit struct {
+	// Rate is the number of tokens added to the bucket per second
+	Rate float64
+	// Burst is the number of tokens the bucket holds when full
+	Burst float64
+}
+
+// DefaultRateLimits are the limits of the messages clients send to the server.
+// Clients send player updates every frame at 60 TPS and acknowledge snapshots
+// sent at 20 ticks per second, so the limits leave room for frame hitches.
+var DefaultRateLimits = map[messages.MessageType]RateLimit{
+	messages.MessageTypeClientPing:         {Rate: 5, Burst: 10},
+	messages.MessageTypeClientPlayerUpdate: {Rate: 90, Burst: 180},
+	messages.MessageTypeClientSyncTime:     {Rate: 5, Burst: 20},
+	messages.MessageTypeClientSnapshotAck:  {Rate: 30, Burst: 60},
+}
+
+// DefaultMessageRateLimit is the limit of message types without a limit of their own
+var DefaultMessageRateLimit = RateLimit{Rate: 5, Burst: 10}
+
+// RateLimitOptions configure the rate limits of clients and
+// how clients that repeatedly exceed them are kicked and banned
+type RateLimitOptions struct {
+	// Limits are the limits of each message type
+	Limits map[messages.MessageType]RateLimit
+	// DefaultLimit is the limit of message types missing from Limits
+	DefaultLimit RateLimit
+	// MaxViolations is how many messages a client can have dropped within
+	// the ViolationWindow before it is kicked. Zero disables kicking.
+	MaxViolations   int
+	ViolationWindow time.Duration
+	// MaxKicks is the number of kicks within the KickWindow after which
+	// a user is banned for the BanDuration. Zero disables banning.
+	MaxKicks    int
+	KickWindow  time.Duration
+	BanDuration time.Duration
+}
+
+// DefaultRateLimitOptions returns the default rate limit options
+func DefaultRateLimitOptions() *RateLimitOptions {
+	return &RateLimitOptions{
+		Limits:          DefaultRateLimits,
+		DefaultLimit:    DefaultMessageRateLimit,
+		MaxViolations:   DefaultMaxRateLimitViolations,
+		ViolationWindow: DefaultRateLimitViolationWindow,
+		MaxKicks:        DefaultMaxKicks,
+		KickWindow:      DefaultKickWindow,
+		BanDuration:     DefaultBanDuration,
+	}
+}
+
+// TokenBucket allows events at a sustained rate with bursts up to its size
+type TokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a new full TokenBucket
+func NewTokenBucket(limit RateLimit, now time.Time) *TokenBucket {
+	return &TokenBucket{
+		limit:  limit,
+		tokens: limit.Burst,
+		last:   now,
+	}
+}
+
+// Allow takes a token from the bucket and returns true if one was available
+func (b *TokenBucket) Allow(now time.Time) bool {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.limit.Burst, b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ClientRateLimiter limits the messages of a single client with a token bucket per message type
+type ClientRateLimiter struct {
+	opts    *RateLimitOptions
+	lock    sync.Mutex
+	buckets map[messages.MessageType]*TokenBucket
+	// violations are the times of the messages dropped within the violation window
+	violations []time.Time
+}
+
+// NewClientRateLimiter creates a new ClientRateLimiter
+func NewClientRateLimiter(opts *RateLimitOptions) *ClientRateLimiter {
+	return &ClientRateLimiter{
+		opts:    opts,
+		buckets: make(map[messages.MessageType]*TokenBucket),
+	}
+}
+
+// Allow returns whether a message of the given type is allowed, and whether
+// the client has exceeded its limits often enough that it should be kicked
+func (l *ClientRateLimiter) Allow(messageType messages.MessageType, now time.Time) (allowed bool, kick bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bucket, ok := l.buckets[messageType]
+	if !ok {
+		limit, ok := l.opts.Limits[messageType]
+		if !ok {
+			limit = l.opts.DefaultLimit
+		}
+		bucket = NewTokenBucket(limit, now)
+		l.buckets[messageType] = bucket
+	}
+	if bucket.Allow(now) {
+		return true, false
+	}
+
+	if l.opts.MaxViolations <= 0 {
+		return false, false
+	}
+	l.violations = append(pruneBefore(l.violations, now.Add(-l.opts.ViolationWindow)), now)
+	return false, len(l.violations) > l.opts.MaxViolations
+}
+
+// pruneBefore removes the times before a cutoff from a slice of increasing times
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// NewClientMessageQueue creates a queue for the messages of clients
+// with a sub-queue for each client, so that one client cannot take
+// more than its fair share of the queue or of a game tick.
+func NewClientMessageQueue(clientQueueCapacity int, clientMessagesPerTick int) queue.Queue {
+	return queue.NewPartitionedQueue(queue.NewPartitionedQueueOptions{
+		Partition: func(item interface{}) uint32 {
+			if message, ok := item.(*messages.Message); ok {
+				return message.ClientID
+			}
+			return 0
+		},
+		PartitionCapacity:   clientQueueCapacity,
+		MaxReadPerPartition: clientMessagesPerTick,
+	})
+}
+
+// ErrBanned is returned when a banned user logs in
+type ErrBanned struct {
+	UserID string
+	Until  time.Time
+}
+
+func (e *ErrBanned) Error() string {
+	return fmt.Sprintf("user %s is banned until %s", e.UserID, e.Until.UTC().Format(time.RFC3339))
+}
+
+// RateLimitMetrics counts the messages allowed and dropped by the
+// rate limits of clients and the clients kicked and banned for exceeding them
+type RateLimitMetrics struct {
+	Allowed atomic.Uint64
+	Dropped atomic.Uint64
+	// QueueFull is the number of allowed messages dropped because the client's queue was full
+	QueueFull atomic.Uint64
+	Kicks     atomic.Uint64
+	Bans      atomic.Uint64
+}
+
+func (m *RateLimitMetrics) String() string {
+	return fmt.Sprintf("allowed=%d dropped=%d queue_full=%d kicks=%d bans=%d",
+		m.Allowed.Load(), m.Dropped.Load(), m.QueueFull.Load(), m.Kicks.Load(), m.Bans.Load())
+}
+
+// AllowMessage returns whether a message from a client is within its rate limits.
+// A client that repeatedly exceeds its limits is kicked, and a user whose
+// clients are repeatedly kicked is banned.
+func (cm *ClientManager) AllowMessage(clientID uint32, messageType messages.MessageType) bool {
+	if cm.rateLimits == nil {
+		return true
+	}
+
+	cm.clientsLock.RLock()
+	client, ok := cm.clients[clientID]
+	cm.clientsLock.RUnlock()
+	if !ok {
+		return false
+	}
+
+	allowed, kick := client.rateLimiter.Allow(messageType, cm.now())
+	if allowed {
+		cm.rateLimitMetrics.Allowed.Add(1)
+		return true
+	}
+	cm.rateLimitMetrics.Dropped.Add(1)
+	log.Trace("Dropped %s message from client %d that exceeded its rate limit", messageType, clientID)
+	if kick {
+		cm.KickClient(clientID, fmt.Sprintf("exceeded the rate limit of %s messages", messageType))
+	}
+	return false
+}
+
+// NewConnectionRateLimiter creates a rate limiter for the messages of a connection
+// that has not logged in, or returns nil if rate limits are disabled
+func (cm *ClientManager) NewConnectionRateLimiter() *ClientRateLimiter {
+	if cm.rateLimits == nil {
+		return nil
+	}
+	return NewClientRateLimiter(cm.rateLimits)
+}
+
+// AllowConnectionMessage returns whether a message from a connection that has not logged in
+// is within the rate limits of its limiter, and whether the connection has exceeded them
+// often enough that it should be closed. A nil limiter allows every message.
+func (cm *ClientManager) AllowConnectionMessage(limiter *ClientRateLimiter, messageType messages.MessageType) (allowed bool, kick bool) {
+	if limiter == nil {
+		return true, false
+	}
+
+	allowed, kick = limiter.Allow(messageType, cm.now())
+	if allowed {
+		cm.rateLimitMetrics.Allowed.Add(1)
+		return true, false
+	}
+	cm.rateLimitMetrics.Dropped.Add(1)
+	if kick {
+		cm.rateLimitMetrics.Kicks.Add(1)
+	}
+	return false, kick
+}
+
+// KickClient disconnects a client, then tells it why it was kicked and closes its connection.
+// The client is told in the background so that a client that stopped reading cannot block the caller.
+// When rate limits are enabled, a user that is kicked too often is banned.
+func (cm *ClientManager) KickClient(clientID uint32, reason string) {
+	cm.clientsLock.Lock()
+	client, ok := cm.clients[clientID]
+	if !ok {
+		cm.clientsLock.Unlock()
+		return
+	}
+	log.Warn("Kicking client %d of user %s: %s", clientID, client.UserID, reason)
+	cm.rateLimitMetrics.Kicks.Add(1)
+	conn := client.TCPConn
+	cm.disconnectClient(clientID)
+	cm.recordKick(client.UserID)
+	cm.clientsLock.Unlock()
+
+	if conn != nil {
+		go notifyKicked(conn, clientID, reason)
+	}
+}
+
+// recordKick counts a kick of a user and bans the user if it is kicked too often.
+// The clients lock must be held by the caller.
+func (cm *ClientManager) recordKick(userID string) {
+	if cm.rateLimits == nil || cm.rateLimits.MaxKicks <= 0 {
+		return
+	}
+	now := cm.now()
+	kicks := append(pruneBefore(cm.kicks[userID], now.Add(-cm.rateLimits.KickWindow)), now)
+	if len(kicks) < cm.rateLimits.MaxKicks {
+		cm.kicks[userID] = kicks
+		return
+	}
+	delete(cm.kicks, userID)
+	cm.bans[userID] = now.Add(cm.rateLimits.BanDuration)
+	cm.rateLimitMetrics.Bans.Add(1)
+	log.Warn("Banned user %s for %s after %d kicks", userID, cm.rateLimits.BanDuration, len(kicks))
+}
+
+// notifyKicked tells a kicked client why it was kicked, then closes its connection
+func notifyKicked(conn net.Conn, clientID uint32, reason string) {
+	defer conn.Close()
+	// don't let a client that stopped reading keep its connection open
+	if err := conn.SetWriteDeadline(time.Now().Add(KickWriteTimeout)); err != nil {
+		log.Warn("Failed to set write deadline for kicked client %d: %v", clientID, err)
+	}
+	if err := sendServerLoginFailure(conn, messages.LoginFailureCodeKicked, reason); err != nil {
+		log.Warn("Failed to tell client %d it was kicked: %v", clientID, err)
+	}
+}
+
+// checkBan returns an ErrBanned if a user is banned.
+// The clients lock must be held by the caller.
+func (cm *ClientManager) checkBan(userID string, now time.Time) error {
+	until, ok := cm.bans[userID]
+	if !ok {
+		return nil
+	}
+	if now.Before(until) {
+		return &ErrBanned{UserID: userID, Until: until}
+	}
+	delete(cm.bans, userID)
+	return nil
+}
+
+// RateLimitMetrics returns the rate limit metrics of the clients
+func (cm *ClientManager) RateLimitMetrics() *RateLimitMetrics {
+	return &cm.rateLimitMetrics
+}
+
+// LogRateLimitMetrics periodically logs the rate limit metrics until the context is done
+func (cm *ClientManager) LogRateLimitMetrics(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Info("Rate limit metrics: %s", cm.RateLimitMetrics())
+		}
+	}
+}
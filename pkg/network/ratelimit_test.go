@@ -0,0 +1,321 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is a clock that only moves when a test advances it
+type testClock struct {
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestClientManager creates a client manager that runs on a test clock
+func newTestClientManager(rateLimits *RateLimitOptions) (*ClientManager, *testClock) {
+	clock := newTestClock()
+	cm := NewClientManager(NewClientManagerOptions{
+		ConnectionEventChan: make(chan ConnectionEvent, 100),
+		RateLimits:          rateLimits,
+		Now:                 clock.Now,
+	})
+	return cm, clock
+}
+
+func TestTokenBucket(t *testing.T) {
+	type step struct {
+		elapsed time.Duration
+		want    bool
+	}
+	tests := []struct {
+		name  string
+		limit RateLimit
+		steps []step
+	}{
+		{
+			name:  "burst",
+			limit: RateLimit{Rate: 1, Burst: 3},
+			steps: []step{{0, true}, {0, true}, {0, true}, {0, false}},
+		},
+		{
+			name:  "refill",
+			limit: RateLimit{Rate: 2, Burst: 1},
+			steps: []step{{0, true}, {0, false}, {250 * time.Millisecond, false}, {250 * time.Millisecond, true}, {0, false}},
+		},
+		{
+			name:  "refill up to burst",
+			limit: RateLimit{Rate: 10, Burst: 2},
+			steps: []step{{0, true}, {0, true}, {time.Minute, true}, {0, true}, {0, false}},
+		},
+		{
+			name:  "clock going backwards",
+			limit: RateLimit{Rate: 1, Burst: 1},
+			steps: []step{{0, true}, {-time.Second, false}, {500 * time.Millisecond, false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			bucket := NewTokenBucket(tt.limit, clock.Now())
+			for i, step := range tt.steps {
+				clock.Advance(step.elapsed)
+				assert.Equal(t, step.want, bucket.Allow(clock.Now()), "step %d", i)
+			}
+		})
+	}
+}
+
+func TestClientRateLimiter_Allow(t *testing.T) {
+	opts := &RateLimitOptions{
+		Limits: map[messages.MessageType]RateLimit{
+			messages.MessageTypeClientPing:         {Rate: 1, Burst: 1},
+			messages.MessageTypeClientPlayerUpdate: {Rate: 1, Burst: 2},
+		},
+		DefaultLimit:    RateLimit{Rate: 1, Burst: 1},
+		MaxViolations:   2,
+		ViolationWindow: 10 * time.Second,
+	}
+	type step struct {
+		elapsed     time.Duration
+		messageType messages.MessageType
+		wantAllowed bool
+		wantKick    bool
+	}
+	tests := []struct {
+		name  string
+		opts  *RateLimitOptions
+		steps []step
+	}{
+		{
+			name: "limits per message type",
+			opts: opts,
+			steps: []step{
+				{0, messages.MessageTypeClientPing, true, false},
+				{0, messages.MessageTypeClientPlayerUpdate, true, false},
+				{0, messages.MessageTypeClientPlayerUpdate, true, false},
+				{0, messages.MessageTypeClientSyncTime, true, false},
+				{0, messages.MessageTypeClientSnapshotAck, true, false},
+			},
+		},
+		{
+			name: "kick after too many violations",
+			opts: opts,
+			steps: []step{
+				{0, messages.MessageTypeClientPing, true, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, true},
+			},
+		},
+		{
+			name: "violations expire",
+			opts: opts,
+			steps: []step{
+				{0, messages.MessageTypeClientPing, true, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{11 * time.Second, messages.MessageTypeClientPing, true, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, true},
+			},
+		},
+		{
+			name: "kicking disabled",
+			opts: &RateLimitOptions{DefaultLimit: RateLimit{Rate: 1, Burst: 1}},
+			steps: []step{
+				{0, messages.MessageTypeClientPing, true, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, false},
+				{0, messages.MessageTypeClientPing, false, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			limiter := NewClientRateLimiter(tt.opts)
+			for i, step := range tt.steps {
+				clock.Advance(step.elapsed)
+				allowed, kick := limiter.Allow(step.messageType, clock.Now())
+				assert.Equal(t, step.wantAllowed, allowed, "step %d", i)
+				assert.Equal(t, step.wantKick, kick, "step %d", i)
+			}
+		})
+	}
+}
+
+func TestClientManager_AllowMessage(t *testing.T) {
+	cm, clock := newTestClientManager(&RateLimitOptions{
+		Limits: map[messages.MessageType]RateLimit{
+			messages.MessageTypeClientPing: {Rate: 1, Burst: 1},
+		},
+		DefaultLimit:    RateLimit{Rate: 10, Burst: 10},
+		MaxViolations:   1,
+		ViolationWindow: time.Second,
+	})
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	clientID, _, err := cm.ConnectClient(serverConn, "user-1", 1, version.Capabilities, nil)
+	assert.NoError(t, err)
+
+	assert.True(t, cm.AllowMessage(clientID, messages.MessageTypeClientPing))
+	assert.False(t, cm.AllowMessage(clientID, messages.MessageTypeClientPing))
+	// other message types have buckets of their own
+	assert.True(t, cm.AllowMessage(clientID, messages.MessageTypeClientPlayerUpdate))
+	clock.Advance(time.Second)
+	assert.True(t, cm.AllowMessage(clientID, messages.MessageTypeClientPing))
+	assert.True(t, cm.Exists(clientID))
+
+	// the client is told why it is kicked before its connection is closed
+	failureChan := make(chan *messages.ServerLoginFailure, 1)
+	go func() {
+		b, err := messages.ReadFrame(clientConn)
+		if !assert.NoError(t, err) {
+			close(failureChan)
+			return
+		}
+		msg, err := messages.DeserializeMessage(b)
+		assert.NoError(t, err)
+		assert.Equal(t, messages.MessageTypeServerLoginFailure, msg.Type)
+		failure, err := messages.DeserializeServerLoginFailure(msg.Payload)
+		assert.NoError(t, err)
+		failureChan <- failure
+	}()
+	assert.False(t, cm.AllowMessage(clientID, messages.MessageTypeClientPing))
+	assert.False(t, cm.AllowMessage(clientID, messages.MessageTypeClientPing))
+	assert.False(t, cm.Exists(clientID))
+	if failure := <-failureChan; assert.NotNil(t, failure) {
+		assert.Equal(t, messages.LoginFailureCodeKicked, failure.Code)
+		assert.Contains(t, failure.Reason, "ClientPing")
+	}
+	_, err = clientConn.Read(make([]byte, 1))
+	assert.Error(t, err, "the connection is closed")
+	assert.Equal(t, uint64(1), cm.RateLimitMetrics().Kicks.Load())
+}
+
+func TestClientManager_KickClient(t *testing.T) {
+	tests := []struct {
+		name string
+		// kicks are the times between the kicks of a user
+		kicks      []time.Duration
+		wantBanned bool
+	}{
+		{
+			name:       "below max kicks",
+			kicks:      []time.Duration{0, time.Minute},
+			wantBanned: false,
+		},
+		{
+			name:       "max kicks within the window",
+			kicks:      []time.Duration{0, time.Minute, time.Minute},
+			wantBanned: true,
+		},
+		{
+			name:       "max kicks spread over more than the window",
+			kicks:      []time.Duration{0, 6 * time.Minute, 6 * time.Minute},
+			wantBanned: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm, clock := newTestClientManager(&RateLimitOptions{
+				DefaultLimit: DefaultMessageRateLimit,
+				MaxKicks:     3,
+				KickWindow:   10 * time.Minute,
+				BanDuration:  time.Hour,
+			})
+			for _, elapsed := range tt.kicks {
+				clock.Advance(elapsed)
+				clientID, _, err := cm.ConnectClient(nil, "user-1", 1, version.Capabilities, nil)
+				if !assert.NoError(t, err) {
+					return
+				}
+				cm.KickClient(clientID, "test")
+				assert.False(t, cm.Exists(clientID))
+			}
+
+			_, _, err := cm.ConnectClient(nil, "user-1", 1, version.Capabilities, nil)
+			if !tt.wantBanned {
+				assert.NoError(t, err)
+				assert.Zero(t, cm.RateLimitMetrics().Bans.Load())
+				return
+			}
+			if assert.IsType(t, &ErrBanned{}, err) {
+				assert.Equal(t, clock.Now().Add(time.Hour), err.(*ErrBanned).Until)
+			}
+			assert.Equal(t, uint64(1), cm.RateLimitMetrics().Bans.Load())
+		})
+	}
+}
+
+func TestClientManager_checkBan(t *testing.T) {
+	cm, clock := newTestClientManager(DefaultRateLimitOptions())
+	cm.bans["user-1"] = clock.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		elapsed    time.Duration
+		userID     string
+		wantBanned bool
+	}{
+		{name: "other user", userID: "user-2", wantBanned: false},
+		{name: "banned", userID: "user-1", wantBanned: true},
+		{name: "just before the ban ends", elapsed: time.Hour - time.Nanosecond, userID: "user-1", wantBanned: true},
+		{name: "once the ban ends", elapsed: time.Nanosecond, userID: "user-1", wantBanned: false},
+	}
+
+	// the cases run in order on the same clock
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.Advance(tt.elapsed)
+			err := cm.checkBan(tt.userID, clock.Now())
+			if tt.wantBanned {
+				assert.IsType(t, &ErrBanned{}, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	assert.NotContains(t, cm.bans, "user-1", "expired bans are removed")
+}
+
+func TestClientManager_KickClient_Unresponsive(t *testing.T) {
+	cm, _ := newTestClientManager(DefaultRateLimitOptions())
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	clientID, _, err := cm.ConnectClient(serverConn, "user-1", 1, version.Capabilities, nil)
+	assert.NoError(t, err)
+
+	// the client never reads the kick notice, which must not hold up the caller or the clients lock
+	kicked := make(chan struct{})
+	go func() {
+		cm.KickClient(clientID, "test")
+		close(kicked)
+	}()
+	select {
+	case <-kicked:
+	case <-time.After(KickWriteTimeout / 2):
+		t.Fatal("KickClient blocked on a client that is not reading")
+	}
+	assert.False(t, cm.Exists(clientID))
+}
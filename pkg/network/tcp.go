@@ -1,16 +1,27 @@
 package network
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
+	"sync/atomic"
 	"time"
 
 	authproviders "github.com/cbodonnell/flywheel/pkg/auth/providers"
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/transport"
+	"github.com/cbodonnell/flywheel/pkg/version"
+)
+
+const (
+	// DefaultTCPReadTimeout is how long the server waits for a message from a client
+	// before it considers the connection dead. Clients sync time over TCP every few seconds.
+	DefaultTCPReadTimeout = 30 * time.Second
 )
 
 // TCPServer represents a TCP server.
@@ -19,18 +30,56 @@ type TCPServer struct {
 	ClientManager *ClientManager
 	MessageQueue  queue.Queue
 	Port          int
+	// TLS is the optional TLS configuration of the listener.
+	// Clients send their ID token at login, so it should be set outside of development.
+	TLS *TLSConfig
+	// ReadTimeout is how long to wait for a message before closing a connection.
+	// Zero disables the timeout.
+	ReadTimeout time.Duration
+	// draining is set once the server stops accepting logins
+	draining atomic.Bool
+}
+
+// ErrServerShuttingDown is returned when a client logs in while the server is shutting down
+type ErrServerShuttingDown struct{}
+
+func (e *ErrServerShuttingDown) Error() string {
+	return "server is shutting down"
+}
+
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+}
+
+// NewTCPServerOptions are the options for creating a new TCPServer
+type NewTCPServerOptions struct {
+	AuthProvider  authproviders.AuthProvider
+	ClientManager *ClientManager
+	MessageQueue  queue.Queue
+	Port          int
+	TLS           *TLSConfig
+	ReadTimeout   time.Duration
 }
 
 // NewTCPServer creates a new TCP server.
-func NewTCPServer(authProvider authproviders.AuthProvider, clientManager *ClientManager, messageQueue queue.Queue, port int) *TCPServer {
+func NewTCPServer(opts NewTCPServerOptions) *TCPServer {
 	return &TCPServer{
-		AuthProvider:  authProvider,
-		ClientManager: clientManager,
-		MessageQueue:  messageQueue,
-		Port:          port,
+		AuthProvider:  opts.AuthProvider,
+		ClientManager: opts.ClientManager,
+		MessageQueue:  opts.MessageQueue,
+		Port:          opts.Port,
+		TLS:           opts.TLS,
+		ReadTimeout:   opts.ReadTimeout,
 	}
 }
 
+// Drain stops the server from accepting logins and session resumes.
+// Clients that are already logged in keep being served.
+func (s *TCPServer) Drain() {
+	s.draining.Store(true)
+}
+
 // Start starts the TCP server.
 func (s *TCPServer) Start() {
 	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf(":%d", s.Port))
@@ -39,15 +88,29 @@ func (s *TCPServer) Start() {
 		return
 	}
 
-	log.Info("TCP server listening on %s", tcpAddr.String())
-
-	tcpListener, err := net.ListenTCP("tcp", tcpAddr)
+	var tcpListener net.Listener
+	tcpListener, err = net.ListenTCP("tcp", tcpAddr)
 	if err != nil {
 		log.Error("Failed to listen on TCP address: %v", err)
 		return
 	}
 	defer tcpListener.Close()
 
+	if s.TLS != nil {
+		cert, err := tls.LoadX509KeyPair(s.TLS.CertFile, s.TLS.KeyFile)
+		if err != nil {
+			log.Error("Failed to load TLS certificate: %v", err)
+			return
+		}
+		tcpListener = tls.NewListener(tcpListener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		log.Info("TCP server listening on %s with TLS", tcpAddr.String())
+	} else {
+		log.Info("TCP server listening on %s", tcpAddr.String())
+	}
+
 	for {
 		conn, err := tcpListener.Accept()
 		if err != nil {
@@ -64,18 +127,29 @@ func (s *TCPServer) handleTCPConnection(conn net.Conn) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	var connectedClientID uint32 // set after login
+	// loginRateLimiter limits the messages of the connection until it logs in
+	loginRateLimiter := s.ClientManager.NewConnectionRateLimiter()
 
 	defer func() {
 		cancel()
 		if connectedClientID != 0 {
-			s.ClientManager.DisconnectClient(connectedClientID)
+			// the client is disconnected if it does not resume its session in time
+			s.ClientManager.SuspendClient(connectedClientID, conn)
 		}
 		conn.Close()
-		log.Info("Client %d disconnected", connectedClientID)
+		log.Info("Client %d connection closed", connectedClientID)
 	}()
 
+	reader := bufio.NewReaderSize(conn, messages.TCPMessageBufferSize)
 	for {
-		message, err := ReadMessageFromTCP(conn)
+		if s.ReadTimeout > 0 {
+			// a half-open connection is closed once the client has been silent for too long
+			if err := conn.SetReadDeadline(time.Now().Add(s.ReadTimeout)); err != nil {
+				log.Error("Failed to set read deadline for client %d: %v", connectedClientID, err)
+				return
+			}
+		}
+		message, err := ReadMessageFromTCP(reader)
 		if err != nil {
 			if _, ok := err.(*ErrConnectionClosed); ok {
 				log.Trace("Connection closed for client %d: %v", connectedClientID, err)
@@ -85,24 +159,64 @@ func (s *TCPServer) handleTCPConnection(conn net.Conn) {
 			continue
 		}
 
-		if message.ClientID == 0 && message.Type != messages.MessageTypeClientLogin {
-			log.Warn("Received message from unknown client that is not a login message")
+		if connectedClientID != 0 {
+			s.ClientManager.UpdateLastSeenTCP(connectedClientID)
+		} else {
+			// connections are rate limited before they log in too, so logins cannot be flooded
+			allowed, kick := s.ClientManager.AllowConnectionMessage(loginRateLimiter, message.Type)
+			if kick {
+				log.Warn("Closing connection that exceeded the rate limit of %s messages before logging in", message.Type)
+				return
+			}
+			if !allowed {
+				continue
+			}
+		}
+
+		// a connection can only log in until it has, and then only send the messages of its own client
+		if connectedClientID == 0 && message.Type != messages.MessageTypeClientLogin {
+			log.Warn("Received %s message from a connection that has not logged in", message.Type)
+			continue
+		}
+		if connectedClientID != 0 && message.ClientID != connectedClientID {
+			log.Warn("Received %s message for client %d from the connection of client %d", message.Type, message.ClientID, connectedClientID)
+			continue
+		}
+
+		if connectedClientID != 0 && !s.ClientManager.AllowMessage(connectedClientID, message.Type) {
 			continue
 		}
 
 		switch message.Type {
 		case messages.MessageTypeClientLogin:
-			clientID, err := s.handleClientLogin(ctx, conn, message)
+			loginSuccess, resumed, err := s.handleClientLogin(ctx, conn, message)
 			if err != nil {
 				log.Error("Failed to handle client login: %v", err)
-				if err := sendServerLoginFailure(conn, err.Error()); err != nil {
+				code := messages.LoginFailureCodeUnspecified
+				switch err.(type) {
+				case *version.ErrIncompatibleProtocol:
+					code = messages.LoginFailureCodeUpdateRequired
+				case *ErrResumeFailed:
+					code = messages.LoginFailureCodeResumeFailed
+				case *ErrServerShuttingDown:
+					code = messages.LoginFailureCodeServerShuttingDown
+				case *ErrBanned:
+					code = messages.LoginFailureCodeBanned
+				case *messages.ErrCompressionDictionaryMismatch:
+					code = messages.LoginFailureCodeCompressionDictionaryMismatch
+				}
+				if err := sendServerLoginFailure(conn, code, err.Error()); err != nil {
 					log.Error("Failed to send server login failure: %v", err)
 				}
 				continue
 			}
-			connectedClientID = clientID
-			log.Info("Client %d connected", connectedClientID)
-			if err := sendServerLoginSuccess(conn, clientID); err != nil {
+			connectedClientID = loginSuccess.ClientID
+			if resumed {
+				log.Info("Client %d resumed its session", connectedClientID)
+			} else {
+				log.Info("Client %d connected", connectedClientID)
+			}
+			if err := sendServerLoginSuccess(conn, loginSuccess); err != nil {
 				log.Error("Failed to send server login success: %v", err)
 				continue
 			}
@@ -112,20 +226,17 @@ func (s *TCPServer) handleTCPConnection(conn net.Conn) {
 			}
 		default:
 			if err := s.MessageQueue.Enqueue(message); err != nil {
+				s.ClientManager.RateLimitMetrics().QueueFull.Add(1)
 				log.Error("Failed to enqueue message: %v", err)
 			}
 		}
 	}
 }
 
-func sendServerLoginSuccess(conn net.Conn, clientID uint32) error {
-	serverLoginSuccess := &messages.ServerLoginSuccess{
-		ClientID: clientID,
-	}
-
-	payload, err := json.Marshal(serverLoginSuccess)
+func sendServerLoginSuccess(conn net.Conn, serverLoginSuccess *messages.ServerLoginSuccess) error {
+	payload, err := messages.SerializeServerLoginSuccess(serverLoginSuccess)
 	if err != nil {
-		return fmt.Errorf("failed to marshal server login success: %v", err)
+		return fmt.Errorf("failed to serialize server login success: %v", err)
 	}
 
 	msg := &messages.Message{
@@ -140,14 +251,17 @@ func sendServerLoginSuccess(conn net.Conn, clientID uint32) error {
 	return nil
 }
 
-func sendServerLoginFailure(conn net.Conn, reason string) error {
+func sendServerLoginFailure(conn net.Conn, code messages.LoginFailureCode, reason string) error {
 	serverLoginFailure := &messages.ServerLoginFailure{
-		Reason: reason,
+		Reason:          reason,
+		Code:            code,
+		ProtocolVersion: version.ProtocolVersion,
+		ServerVersion:   version.Get(),
 	}
 
-	payload, err := json.Marshal(serverLoginFailure)
+	payload, err := messages.SerializeServerLoginFailure(serverLoginFailure)
 	if err != nil {
-		return fmt.Errorf("failed to marshal server login failure: %v", err)
+		return fmt.Errorf("failed to serialize server login failure: %v", err)
 	}
 
 	msg := &messages.Message{
@@ -162,30 +276,99 @@ func sendServerLoginFailure(conn net.Conn, reason string) error {
 	return nil
 }
 
-// handleClientLogin handles a client login message.
-func (s *TCPServer) handleClientLogin(ctx context.Context, conn net.Conn, message *messages.Message) (uint32, error) {
-	clientLogin := &messages.ClientLogin{}
-	if err := json.Unmarshal(message.Payload, clientLogin); err != nil {
-		return 0, fmt.Errorf("failed to unmarshal client login: %v", err)
+// handleClientLogin handles a client login message and returns the login success
+// to send to the connected client, with the protocol capabilities negotiated with it
+// and the server's half of the UDP session key exchange, and whether the client resumed a session.
+// An incompatible client is rejected with a version.ErrIncompatibleProtocol, and a client
+// compressing messages with a different dictionary with a messages.ErrCompressionDictionaryMismatch.
+func (s *TCPServer) handleClientLogin(ctx context.Context, conn net.Conn, message *messages.Message) (*messages.ServerLoginSuccess, bool, error) {
+	if s.draining.Load() {
+		return nil, false, &ErrServerShuttingDown{}
+	}
+
+	clientLogin, err := messages.DeserializeClientLogin(message.Payload)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to deserialize client login: %v", err)
+	}
+
+	if err := version.CheckCompatibility(clientLogin.ProtocolVersion, clientLogin.Capabilities); err != nil {
+		log.Warn("Rejecting client version %q: %v", clientLogin.ClientVersion, err)
+		return nil, false, err
+	}
+
+	if err := messages.CheckCompressionDictionary(clientLogin.CompressionDictionaryChecksum); err != nil {
+		log.Warn("Rejecting client version %q: %v", clientLogin.ClientVersion, err)
+		return nil, false, err
+	}
+
+	if clientLogin.ResumeToken != "" {
+		loginSuccess, err := s.resumeClientSession(conn, clientLogin)
+		return loginSuccess, true, err
 	}
 
 	token, err := s.AuthProvider.VerifyToken(ctx, clientLogin.Token)
 	if err != nil {
-		return 0, fmt.Errorf("failed to verify token: %v", err)
+		return nil, false, fmt.Errorf("failed to verify token: %v", err)
+	}
+
+	privateKey, err := transport.GenerateSessionKeyPair()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate session key pair: %v", err)
+	}
+	cipher, err := transport.NewSessionCipher(transport.RoleServer, privateKey, clientLogin.PublicKey)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create session cipher: %v", err)
 	}
 
-	clientID, err := s.ClientManager.ConnectClient(conn, token.UID, clientLogin.CharacterID)
+	capabilities := version.NegotiateCapabilities(clientLogin.Capabilities)
+	clientID, resumeToken, err := s.ClientManager.ConnectClient(conn, token.UID, clientLogin.CharacterID, capabilities, cipher)
 	if err != nil {
-		return 0, fmt.Errorf("failed to connect client: %v", err)
+		if _, ok := err.(*ErrBanned); ok {
+			return nil, false, err
+		}
+		return nil, false, fmt.Errorf("failed to connect client: %v", err)
 	}
 
-	return clientID, nil
+	return &messages.ServerLoginSuccess{
+		ClientID:        clientID,
+		ProtocolVersion: version.ProtocolVersion,
+		Capabilities:    capabilities,
+		ServerVersion:   version.Get(),
+		PublicKey:       privateKey.PublicKey().Bytes(),
+		ResumeToken:     resumeToken,
+		ResumeWindow:    uint32(s.ClientManager.ReconnectGracePeriod().Milliseconds()),
+
+		CompressionDictionaryChecksum: messages.CompressionDictionaryChecksum(),
+	}, false, nil
+}
+
+// resumeClientSession resumes the session of a client that reconnected after its
+// TCP connection dropped. The resume token authenticates the client in place of
+// its ID token, and the UDP session keys negotiated at login are kept.
+func (s *TCPServer) resumeClientSession(conn net.Conn, clientLogin *messages.ClientLogin) (*messages.ServerLoginSuccess, error) {
+	// the client may have been updated while it was away, so its capabilities are negotiated again
+	capabilities := version.NegotiateCapabilities(clientLogin.Capabilities)
+	resumeToken, err := s.ClientManager.ResumeClient(conn, clientLogin.ResumeClientID, clientLogin.ResumeToken, capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messages.ServerLoginSuccess{
+		ClientID:        clientLogin.ResumeClientID,
+		ProtocolVersion: version.ProtocolVersion,
+		Capabilities:    capabilities,
+		ServerVersion:   version.Get(),
+		ResumeToken:     resumeToken,
+		ResumeWindow:    uint32(s.ClientManager.ReconnectGracePeriod().Milliseconds()),
+
+		CompressionDictionaryChecksum: messages.CompressionDictionaryChecksum(),
+	}, nil
 }
 
 func (s *TCPServer) handleClientSyncTime(conn net.Conn, message *messages.Message) error {
-	clientSyncTime := &messages.ClientSyncTime{}
-	if err := json.Unmarshal(message.Payload, clientSyncTime); err != nil {
-		return fmt.Errorf("failed to unmarshal client sync time: %v", err)
+	clientSyncTime, err := messages.DeserializeClientSyncTime(message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize client sync time: %v", err)
 	}
 
 	serverSyncTime := &messages.ServerSyncTime{
@@ -193,9 +376,9 @@ func (s *TCPServer) handleClientSyncTime(conn net.Conn, message *messages.Messag
 		ClientTimestamp: clientSyncTime.Timestamp,
 	}
 
-	payload, err := json.Marshal(serverSyncTime)
+	payload, err := messages.SerializeServerSyncTime(serverSyncTime)
 	if err != nil {
-		return fmt.Errorf("failed to marshal server sync time: %v", err)
+		return fmt.Errorf("failed to serialize server sync time: %v", err)
 	}
 
 	msg := &messages.Message{
@@ -210,15 +393,14 @@ func (s *TCPServer) handleClientSyncTime(conn net.Conn, message *messages.Messag
 	return nil
 }
 
-// WriteMessageToTCP writes a Message to a TCP connection
+// WriteMessageToTCP writes a Message to a TCP connection as a single frame
 func WriteMessageToTCP(conn net.Conn, msg *messages.Message) error {
 	b, err := messages.SerializeMessage(msg)
 	if err != nil {
 		return fmt.Errorf("failed to serialize message: %v", err)
 	}
 
-	_, err = conn.Write(b)
-	if err != nil {
+	if err := messages.WriteFrame(conn, b); err != nil {
 		return fmt.Errorf("failed to write message to TCP connection: %v", err)
 	}
 
@@ -234,15 +416,17 @@ func (e *ErrConnectionClosed) Error() string {
 	return e.Err.Error()
 }
 
-// ReadMessageFromTCP reads a Message from a TCP connection
-func ReadMessageFromTCP(conn net.Conn) (*messages.Message, error) {
-	buf := make([]byte, messages.TCPMessageBufferSize)
-	n, err := conn.Read(buf)
+// ReadMessageFromTCP reads the next framed Message from a TCP connection.
+// The reader should be buffered and reused for the lifetime of the connection.
+// Any error reading a frame leaves the stream in an unknown state,
+// so it is returned as an ErrConnectionClosed.
+func ReadMessageFromTCP(r io.Reader) (*messages.Message, error) {
+	b, err := messages.ReadFrame(r)
 	if err != nil {
 		return nil, &ErrConnectionClosed{err}
 	}
 
-	msg, err := messages.DeserializeMessage(buf[:n])
+	msg, err := messages.DeserializeMessage(b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to deserialize message: %v", err)
 	}
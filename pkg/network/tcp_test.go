@@ -0,0 +1,162 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	authproviders "github.com/cbodonnell/flywheel/pkg/auth/providers"
+	"github.com/cbodonnell/flywheel/pkg/messages"
+	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/transport"
+	"github.com/cbodonnell/flywheel/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAuthProvider accepts every token as the ID of its user
+type testAuthProvider struct{}
+
+func (testAuthProvider) VerifyToken(ctx context.Context, idToken string) (*authproviders.TokenClaims, error) {
+	return &authproviders.TokenClaims{UID: idToken}, nil
+}
+
+// newTestTCPServer creates a TCP server whose connections are served with newTestTCPConnection
+func newTestTCPServer(cm *ClientManager) *TCPServer {
+	return NewTCPServer(NewTCPServerOptions{
+		AuthProvider:  testAuthProvider{},
+		ClientManager: cm,
+		MessageQueue:  queue.NewInMemoryQueue(100),
+	})
+}
+
+// newTestTCPConnection serves a connection with a TCP server and returns the client's end of it
+func newTestTCPConnection(t *testing.T, s *TCPServer) net.Conn {
+	serverConn, clientConn := net.Pipe()
+	go s.handleTCPConnection(serverConn)
+	t.Cleanup(func() { clientConn.Close() })
+	return clientConn
+}
+
+func sendTestMessage(t *testing.T, conn net.Conn, clientID uint32, messageType messages.MessageType, payload []byte) {
+	msg := &messages.Message{ClientID: clientID, Type: messageType, Payload: payload}
+	if !assert.NoError(t, WriteMessageToTCP(conn, msg)) {
+		t.FailNow()
+	}
+}
+
+func readTestMessage(t *testing.T, conn net.Conn) *messages.Message {
+	b, err := messages.ReadFrame(conn)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	msg, err := messages.DeserializeMessage(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return msg
+}
+
+// loginTestClient logs in a user over a connection and returns the login success
+func loginTestClient(t *testing.T, conn net.Conn, userID string) *messages.ServerLoginSuccess {
+	privateKey, err := transport.GenerateSessionKeyPair()
+	assert.NoError(t, err)
+	payload, err := messages.SerializeClientLogin(&messages.ClientLogin{
+		Token:           userID,
+		CharacterID:     1,
+		ProtocolVersion: version.ProtocolVersion,
+		Capabilities:    version.Capabilities,
+		PublicKey:       privateKey.PublicKey().Bytes(),
+	})
+	assert.NoError(t, err)
+	sendTestMessage(t, conn, 0, messages.MessageTypeClientLogin, payload)
+
+	msg := readTestMessage(t, conn)
+	if !assert.Equal(t, messages.MessageTypeServerLoginSuccess, msg.Type) {
+		t.FailNow()
+	}
+	loginSuccess, err := messages.DeserializeServerLoginSuccess(msg.Payload)
+	assert.NoError(t, err)
+	return loginSuccess
+}
+
+// syncTestClient syncs the time of a client, which returns once the server has
+// handled every message sent before it
+func syncTestClient(t *testing.T, conn net.Conn, clientID uint32) {
+	payload, err := messages.SerializeClientSyncTime(&messages.ClientSyncTime{Timestamp: 1})
+	assert.NoError(t, err)
+	sendTestMessage(t, conn, clientID, messages.MessageTypeClientSyncTime, payload)
+	assert.Equal(t, messages.MessageTypeServerSyncTime, readTestMessage(t, conn).Type)
+}
+
+func TestTCPServer_handleTCPConnection_ClientIDs(t *testing.T) {
+	cm, _ := newTestClientManager(DefaultRateLimitOptions())
+	s := newTestTCPServer(cm)
+	conn := newTestTCPConnection(t, s)
+
+	// messages are dropped until the connection logs in
+	sendTestMessage(t, conn, 1, messages.MessageTypeClientPlayerUpdate, nil)
+	clientID := loginTestClient(t, conn, "user-1").ClientID
+
+	// and then only the messages of its own client are accepted
+	sendTestMessage(t, conn, clientID+1, messages.MessageTypeClientPlayerUpdate, nil)
+	sendTestMessage(t, conn, 0, messages.MessageTypeClientPlayerUpdate, nil)
+	sendTestMessage(t, conn, clientID, messages.MessageTypeClientPlayerUpdate, nil)
+	syncTestClient(t, conn, clientID)
+
+	queued, err := s.MessageQueue.ReadAllMessages()
+	assert.NoError(t, err)
+	if assert.Len(t, queued, 1) {
+		assert.Equal(t, clientID, queued[0].(*messages.Message).ClientID)
+	}
+}
+
+func TestTCPServer_handleTCPConnection_LoginRateLimit(t *testing.T) {
+	cm, _ := newTestClientManager(&RateLimitOptions{
+		DefaultLimit:    RateLimit{Rate: 1, Burst: 2},
+		MaxViolations:   1,
+		ViolationWindow: time.Second,
+	})
+	s := newTestTCPServer(cm)
+	conn := newTestTCPConnection(t, s)
+
+	// the first messages are within the limit, the next two are dropped, and the second drop closes the connection
+	for i := 0; i < 4; i++ {
+		sendTestMessage(t, conn, 1, messages.MessageTypeClientPlayerUpdate, nil)
+	}
+	_, err := conn.Read(make([]byte, 1))
+	assert.Error(t, err, "the connection is closed")
+
+	assert.Equal(t, uint64(2), cm.RateLimitMetrics().Allowed.Load())
+	assert.Equal(t, uint64(2), cm.RateLimitMetrics().Dropped.Load())
+	assert.Equal(t, uint64(1), cm.RateLimitMetrics().Kicks.Load())
+	size, err := s.MessageQueue.Size()
+	assert.NoError(t, err)
+	assert.Zero(t, size, "messages are never enqueued before login")
+}
+
+func TestTCPServer_handleTCPConnection_CompressionDictionaryMismatch(t *testing.T) {
+	cm, _ := newTestClientManager(DefaultRateLimitOptions())
+	s := newTestTCPServer(cm)
+	conn := newTestTCPConnection(t, s)
+
+	payload, err := messages.SerializeClientLogin(&messages.ClientLogin{
+		Token:           "user-1",
+		CharacterID:     1,
+		ProtocolVersion: version.ProtocolVersion,
+		Capabilities:    version.Capabilities,
+
+		CompressionDictionaryChecksum: 1,
+	})
+	assert.NoError(t, err)
+	sendTestMessage(t, conn, 0, messages.MessageTypeClientLogin, payload)
+
+	msg := readTestMessage(t, conn)
+	if !assert.Equal(t, messages.MessageTypeServerLoginFailure, msg.Type) {
+		t.FailNow()
+	}
+	loginFailure, err := messages.DeserializeServerLoginFailure(msg.Payload)
+	assert.NoError(t, err)
+	assert.Equal(t, messages.LoginFailureCodeCompressionDictionaryMismatch, loginFailure.Code)
+	assert.Empty(t, cm.GetClients(), "the client is not connected")
+}
@@ -3,10 +3,19 @@ package network
 import (
 	"fmt"
 	"net"
+	"sync/atomic"
+	"time"
 
 	"github.com/cbodonnell/flywheel/pkg/log"
 	"github.com/cbodonnell/flywheel/pkg/messages"
 	"github.com/cbodonnell/flywheel/pkg/queue"
+	"github.com/cbodonnell/flywheel/pkg/transport"
+	"github.com/cbodonnell/flywheel/pkg/version"
+)
+
+const (
+	// ResendInterval is how often unacked reliable datagrams are checked for resending
+	ResendInterval = 20 * time.Millisecond
 )
 
 // UDPServer represents a UDP server.
@@ -14,6 +23,8 @@ type UDPServer struct {
 	ClientManager *ClientManager
 	MessageQueue  queue.Queue
 	Port          int
+	// draining is set once the server stops accepting new clients
+	draining atomic.Bool
 }
 
 // NewUDPServer creates a new UDP server.
@@ -44,58 +55,141 @@ func (s *UDPServer) Start() {
 
 	s.ClientManager.SetUDPConn(udpConn)
 
+	go s.handleResends(udpConn)
+
 	for {
-		message, addr, err := ReadMessageFromUDP(udpConn)
+		datagram, addr, err := ReadFromUDP(udpConn)
+		if err != nil {
+			log.Error("Failed to read datagram from UDP connection: %v", err)
+			continue
+		}
+
+		header, _, err := transport.DecodeDatagram(datagram)
 		if err != nil {
-			log.Error("Failed to read message from UDP connection: %v", err)
+			log.Warn("Received invalid datagram from %s: %v", addr.String(), err)
 			continue
 		}
 
-		if message.ClientID == 0 {
+		if header.ClientID == 0 {
 			log.Warn("Received UDP message from unknown client, ignoring")
 			continue
 		}
 
-		if !s.ClientManager.Exists(message.ClientID) {
-			log.Warn("Received UDP message from %d, but client is not connected", message.ClientID)
+		endpoint := s.ClientManager.GetEndpoint(header.ClientID)
+		if endpoint == nil {
+			log.Warn("Received UDP message from %d, but client is not connected", header.ClientID)
+			continue
+		}
+
+		payloads, err := endpoint.Receive(datagram)
+		if err != nil {
+			log.Error("Failed to receive datagram from client %d: %v", header.ClientID, err)
 			continue
 		}
+		s.ClientManager.UpdateLastSeenUDP(header.ClientID)
+
+		for _, payload := range payloads {
+			message, err := messages.DeserializeMessage(payload)
+			if err != nil {
+				log.Error("Failed to deserialize message from client %d: %v", header.ClientID, err)
+				continue
+			}
+
+			if message.ClientID != header.ClientID {
+				log.Warn("Received UDP message for client %d in a datagram from client %d, ignoring", message.ClientID, header.ClientID)
+				continue
+			}
+
+			if !s.ClientManager.AllowMessage(message.ClientID, message.Type) {
+				continue
+			}
+			if message.Type == messages.MessageTypeClientSnapshotAck && !s.ClientManager.HasCapabilities(message.ClientID, version.CapabilityDeltaSnapshots) {
+				// clients without delta snapshots have no baselines, so they are always sent full snapshots
+				log.Trace("Dropped snapshot ack from client %d that does not support delta snapshots", message.ClientID)
+				continue
+			}
+
+			switch message.Type {
+			case messages.MessageTypeClientPing:
+				if err := s.handleClientPing(message, udpConn, addr, endpoint); err != nil {
+					log.Error("Failed to handle client ping: %v", err)
+				}
+			default:
+				if err := s.MessageQueue.Enqueue(message); err != nil {
+					s.ClientManager.RateLimitMetrics().QueueFull.Add(1)
+					log.Error("Failed to enqueue message: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// handleResends periodically resends unacked reliable datagrams and
+// sends acks to clients that have not received one with other traffic.
+func (s *UDPServer) handleResends(udpConn *net.UDPConn) {
+	ticker := time.NewTicker(ResendInterval)
+	defer ticker.Stop()
 
-		switch message.Type {
-		case messages.MessageTypeClientPing:
-			if err := s.handleClientPing(message, udpConn, addr); err != nil {
-				log.Error("Failed to handle client ping: %v", err)
+	for now := range ticker.C {
+		for _, client := range s.ClientManager.GetClients() {
+			if client.UDPAddress == nil {
+				continue
 			}
-		default:
-			if err := s.MessageQueue.Enqueue(message); err != nil {
-				log.Error("Failed to enqueue message: %v", err)
+
+			datagrams := client.Endpoint.Resend(now)
+			if ack, ok := client.Endpoint.Ack(); ok {
+				datagrams = append(datagrams, ack)
+			}
+
+			for _, datagram := range datagrams {
+				if _, err := udpConn.WriteToUDP(datagram, client.UDPAddress); err != nil {
+					log.Error("Failed to write datagram to UDP connection for client %d: %v", client.ID, err)
+				}
 			}
 		}
 	}
 }
 
-func (s *UDPServer) handleClientPing(msg *messages.Message, udpConn *net.UDPConn, addr *net.UDPAddr) error {
+// Drain stops the server from accepting clients that have not yet sent their first ping.
+// Clients that are already connected keep being served.
+func (s *UDPServer) Drain() {
+	s.draining.Store(true)
+}
+
+func (s *UDPServer) handleClientPing(msg *messages.Message, udpConn *net.UDPConn, addr *net.UDPAddr, endpoint *transport.Endpoint) error {
+	if s.draining.Load() {
+		if client := s.ClientManager.GetClient(msg.ClientID); client == nil || client.UDPAddress == nil {
+			log.Debug("Ignoring first ping from client %d while draining", msg.ClientID)
+			return nil
+		}
+	}
+
 	s.ClientManager.SetUDPAddress(msg.ClientID, addr)
 	m := &messages.Message{
 		ClientID: 0,
 		Type:     messages.MessageTypeServerPong,
 		Payload:  nil,
 	}
-	if err := WriteMessageToUDP(udpConn, addr, m); err != nil {
+	if err := WriteMessageToUDP(udpConn, addr, endpoint, m); err != nil {
 		return fmt.Errorf("failed to write pong message to client: %v", err)
 	}
 
 	return nil
 }
 
-// WriteMessageToUDP writes a Message to a UDP connection
-func WriteMessageToUDP(conn *net.UDPConn, addr *net.UDPAddr, msg *messages.Message) error {
+// WriteMessageToUDP writes a Message to a UDP connection on the channel selected by its type
+func WriteMessageToUDP(conn *net.UDPConn, addr *net.UDPAddr, endpoint *transport.Endpoint, msg *messages.Message) error {
 	b, err := messages.SerializeMessage(msg)
 	if err != nil {
 		return fmt.Errorf("failed to serialize message: %v", err)
 	}
 
-	_, err = conn.WriteToUDP(b, addr)
+	datagram, err := endpoint.Send(transport.ChannelTypeForMessage(msg.Type), b)
+	if err != nil {
+		return fmt.Errorf("failed to send message on endpoint: %v", err)
+	}
+
+	_, err = conn.WriteToUDP(datagram, addr)
 	if err != nil {
 		return fmt.Errorf("failed to write message to UDP connection: %v", err)
 	}
@@ -103,18 +197,37 @@ func WriteMessageToUDP(conn *net.UDPConn, addr *net.UDPAddr, msg *messages.Messa
 	return nil
 }
 
-// ReadMessageFromUDP reads a Message from a UDP connection
-func ReadMessageFromUDP(conn *net.UDPConn) (*messages.Message, *net.UDPAddr, error) {
-	buf := make([]byte, messages.UDPMessageBufferSize)
-	n, addr, err := conn.ReadFromUDP(buf)
+// WriteMessageToClientUDP writes a Message to a client on the channel selected by its type.
+// If the UDP address of the client is not known yet, reliable messages are kept
+// by its endpoint until they can be resent and other messages return an error.
+func WriteMessageToClientUDP(conn *net.UDPConn, client *Client, msg *messages.Message) error {
+	if client.UDPAddress != nil {
+		return WriteMessageToUDP(conn, client.UDPAddress, client.Endpoint, msg)
+	}
+
+	if transport.ChannelTypeForMessage(msg.Type) != transport.ChannelTypeReliableOrdered {
+		return fmt.Errorf("client %d does not have a UDP address", client.ID)
+	}
+
+	b, err := messages.SerializeMessage(msg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read message from UDP connection: %v", err)
+		return fmt.Errorf("failed to serialize message: %v", err)
 	}
 
-	msg, err := messages.DeserializeMessage(buf[:n])
+	if _, err := client.Endpoint.Send(transport.ChannelTypeReliableOrdered, b); err != nil {
+		return fmt.Errorf("failed to send message on endpoint: %v", err)
+	}
+
+	return nil
+}
+
+// ReadFromUDP reads a datagram from a UDP connection
+func ReadFromUDP(conn *net.UDPConn) ([]byte, *net.UDPAddr, error) {
+	buf := make([]byte, transport.HeaderSize+transport.SealOverhead+messages.UDPMessageBufferSize)
+	n, addr, err := conn.ReadFromUDP(buf)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to deserialize message: %v", err)
+		return nil, nil, fmt.Errorf("failed to read datagram from UDP connection: %v", err)
 	}
 
-	return msg, addr, nil
+	return buf[:n], addr, nil
 }
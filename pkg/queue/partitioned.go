@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PartitionedQueue is a queue with a sub-queue for each partition, such as each client,
+// so that a single partition can neither fill the queue nor starve the others.
+// Items are read from the partitions in turn, and at most a fair share of each
+// partition is read at once, leaving the rest for the next read.
+type PartitionedQueue struct {
+	partition           func(item interface{}) uint32
+	partitionCapacity   int
+	maxReadPerPartition int
+
+	partitions map[uint32][]interface{}
+	// order is the order in which partitions are read
+	order []uint32
+	size  int
+	lock  sync.RWMutex
+}
+
+// NewPartitionedQueueOptions are the options for creating a new PartitionedQueue
+type NewPartitionedQueueOptions struct {
+	// Partition returns the partition of an item
+	Partition func(item interface{}) uint32
+	// PartitionCapacity is the maximum number of items in a partition
+	PartitionCapacity int
+	// MaxReadPerPartition is the maximum number of items of each partition
+	// returned by ReadAllMessages. Zero returns all items.
+	MaxReadPerPartition int
+}
+
+// NewPartitionedQueue creates a new partitioned queue.
+func NewPartitionedQueue(opts NewPartitionedQueueOptions) Queue {
+	return &PartitionedQueue{
+		partition:           opts.Partition,
+		partitionCapacity:   opts.PartitionCapacity,
+		maxReadPerPartition: opts.MaxReadPerPartition,
+		partitions:          make(map[uint32][]interface{}),
+	}
+}
+
+func (q *PartitionedQueue) Enqueue(item interface{}) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	key := q.partition(item)
+	items, ok := q.partitions[key]
+	if len(items) == q.partitionCapacity {
+		return fmt.Errorf("queue partition %d is full", key)
+	}
+	if !ok {
+		q.order = append(q.order, key)
+	}
+
+	q.partitions[key] = append(items, item)
+	q.size++
+	return nil
+}
+
+// Dequeue removes and returns the item from the front of the next partition in turn.
+func (q *PartitionedQueue) Dequeue() (interface{}, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.size == 0 {
+		return nil, fmt.Errorf("queue is empty")
+	}
+
+	key := q.order[0]
+	q.order = q.order[1:]
+	item := q.partitions[key][0]
+	if q.take(key, 1) {
+		q.order = append(q.order, key)
+	}
+	return item, nil
+}
+
+func (q *PartitionedQueue) Size() (int, error) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return q.size, nil
+}
+
+// ReadAllMessages reads the pending messages of all partitions, up to the maximum per partition,
+// interleaving the partitions so that each one's messages keep their order.
+func (q *PartitionedQueue) ReadAllMessages() ([]interface{}, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var messages []interface{}
+	for round := 0; q.maxReadPerPartition == 0 || round < q.maxReadPerPartition; round++ {
+		read := false
+		for _, key := range q.order {
+			if items := q.partitions[key]; round < len(items) {
+				messages = append(messages, items[round])
+				read = true
+			}
+		}
+		if !read {
+			break
+		}
+	}
+
+	order := q.order
+	q.order = nil
+	for _, key := range order {
+		n := len(q.partitions[key])
+		if q.maxReadPerPartition > 0 && n > q.maxReadPerPartition {
+			n = q.maxReadPerPartition
+		}
+		if q.take(key, n) {
+			q.order = append(q.order, key)
+		}
+	}
+	return messages, nil
+}
+
+func (q *PartitionedQueue) ClearQueue() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.partitions = make(map[uint32][]interface{})
+	q.order = nil
+	q.size = 0
+	return nil
+}
+
+// take removes n items from the front of a partition and returns true if any are left.
+// Empty partitions are removed. The lock must be held by the caller.
+func (q *PartitionedQueue) take(key uint32, n int) bool {
+	items := q.partitions[key][n:]
+	q.size -= n
+	if len(items) == 0 {
+		delete(q.partitions, key)
+		return false
+	}
+	q.partitions[key] = items
+	return true
+}
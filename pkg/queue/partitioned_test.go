@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type partitionedItem struct {
+	partition uint32
+	seq       int
+}
+
+func newTestPartitionedQueue(maxReadPerPartition int) Queue {
+	return NewPartitionedQueue(NewPartitionedQueueOptions{
+		Partition: func(item interface{}) uint32 {
+			return item.(partitionedItem).partition
+		},
+		PartitionCapacity:   4,
+		MaxReadPerPartition: maxReadPerPartition,
+	})
+}
+
+func TestPartitionedQueue_ReadAllMessages(t *testing.T) {
+	q := newTestPartitionedQueue(2)
+
+	// a flooding partition fills up without affecting the others
+	for i := 0; i < 6; i++ {
+		err := q.Enqueue(partitionedItem{partition: 1, seq: i})
+		if i < 4 {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+	assert.NoError(t, q.Enqueue(partitionedItem{partition: 2, seq: 0}))
+
+	size, _ := q.Size()
+	assert.Equal(t, 5, size)
+
+	// partitions are interleaved and each is read up to its fair share
+	items, err := q.ReadAllMessages()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		partitionedItem{partition: 1, seq: 0},
+		partitionedItem{partition: 2, seq: 0},
+		partitionedItem{partition: 1, seq: 1},
+	}, items)
+
+	items, err = q.ReadAllMessages()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		partitionedItem{partition: 1, seq: 2},
+		partitionedItem{partition: 1, seq: 3},
+	}, items)
+
+	size, _ = q.Size()
+	assert.Equal(t, 0, size)
+	items, err = q.ReadAllMessages()
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestPartitionedQueue_Dequeue(t *testing.T) {
+	q := newTestPartitionedQueue(0)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, q.Enqueue(partitionedItem{partition: 1, seq: i}))
+	}
+	assert.NoError(t, q.Enqueue(partitionedItem{partition: 2, seq: 0}))
+
+	for _, want := range []partitionedItem{{1, 0}, {2, 0}, {1, 1}} {
+		item, err := q.Dequeue()
+		assert.NoError(t, err)
+		assert.Equal(t, want, item)
+	}
+	_, err := q.Dequeue()
+	assert.Error(t, err)
+
+	assert.NoError(t, q.Enqueue(partitionedItem{partition: 3, seq: 0}))
+	assert.NoError(t, q.ClearQueue())
+	size, _ := q.Size()
+	assert.Equal(t, 0, size)
+}
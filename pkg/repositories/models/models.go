@@ -16,4 +16,5 @@ type Player struct {
 	X           float64 `json:"x"`
 	Y           float64 `json:"y"`
 	Hitpoints   int16   `json:"hitpoints"`
+	ZoneID      uint32  `json:"zone_id"`
 }
@@ -3,6 +3,8 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -17,10 +19,11 @@ type PostgresRepository struct {
 	conn *pgx.Conn
 }
 
-// NewPostgresRepository creates a new PSQLRepository.
+// NewPostgresRepository creates a new PSQLRepository and executes the migrations in a directory
+// that have not been executed against the database yet.
 // It panics if it is unable to connect to the database after 2 minutes.
 // The caller is responsible for calling Close() on the repository.
-func NewPostgresRepository(ctx context.Context, connStr string) (Repository, error) {
+func NewPostgresRepository(ctx context.Context, connStr string, migrations string) (Repository, error) {
 	const maxRetry = 24
 	const retryInterval = time.Second * 5
 
@@ -47,6 +50,11 @@ func NewPostgresRepository(ctx context.Context, connStr string) (Repository, err
 		return nil, fmt.Errorf("failed to establish database connection after %d attempts: %v", maxRetry, err)
 	}
 
+	if err := migratePostgres(ctx, conn, migrations); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
 	return &PostgresRepository{
 		conn: conn,
 	}, nil
@@ -70,6 +78,61 @@ func connectDb(ctx context.Context, connStr string) (*pgx.Conn, error) {
 	return conn, nil
 }
 
+// migrationsLockID is the key of the advisory lock held while migrating a database
+const migrationsLockID = 0x666c7977 // "flyw"
+
+// migratePostgres executes the migrations in a directory in order of their names, skipping
+// those already executed against the database. Servers sharing a database can start at once,
+// so the migrations are executed in a single transaction under an advisory lock.
+func migratePostgres(ctx context.Context, conn *pgx.Conn, migrations string) error {
+	paths, err := migrationFiles(migrations)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationsLockID); err != nil {
+		return fmt.Errorf("failed to lock migrations: %v", err)
+	}
+	if _, err := tx.Exec(ctx, "CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY);"); err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
+	for _, migrationPath := range paths {
+		name := filepath.Base(migrationPath)
+		var executed bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM migrations WHERE name = $1)", name).Scan(&executed); err != nil {
+			return fmt.Errorf("failed to check migration %s: %v", name, err)
+		}
+		if executed {
+			continue
+		}
+
+		migration, err := os.ReadFile(migrationPath)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %v", migrationPath, err)
+		}
+		// without arguments, the migration is sent with the simple protocol so it can hold several statements
+		if _, err := tx.Exec(ctx, string(migration)); err != nil {
+			return fmt.Errorf("failed to execute migration %s: %v", migrationPath, err)
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO migrations (name) VALUES ($1)", name); err != nil {
+			return fmt.Errorf("failed to record migration %s: %v", migrationPath, err)
+		}
+		log.Info("Executed migration %s", name)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit migrations: %v", err)
+	}
+	return nil
+}
+
 func (r *PostgresRepository) Close(ctx context.Context) error {
 	return r.conn.Close(ctx)
 }
@@ -178,10 +241,10 @@ func (r *PostgresRepository) SaveGameState(ctx context.Context, gameState *gamet
 
 	for _, playerState := range gameState.Players {
 		q := `
-		INSERT INTO players (character_id, timestamp, x, y, flipH, hitpoints) VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (character_id) DO UPDATE SET timestamp = $2, x = $3, y = $4, flipH = $5, hitpoints = $6;
+		INSERT INTO players (character_id, timestamp, x, y, flipH, hitpoints, zone_id) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (character_id) DO UPDATE SET timestamp = $2, x = $3, y = $4, flipH = $5, hitpoints = $6, zone_id = $7;
 		`
-		_, err = tx.Exec(ctx, q, playerState.CharacterID, gameState.Timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints)
+		_, err = tx.Exec(ctx, q, playerState.CharacterID, gameState.Timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints, playerState.ZoneID)
 		if err != nil {
 			return fmt.Errorf("failed to insert player: %v", err)
 		}
@@ -196,10 +259,10 @@ func (r *PostgresRepository) SaveGameState(ctx context.Context, gameState *gamet
 
 func (r *PostgresRepository) SavePlayerState(ctx context.Context, timestamp int64, characterID int32, playerState *gametypes.PlayerState) error {
 	q := `
-	INSERT INTO players (character_id, timestamp, x, y, flipH, hitpoints) VALUES ($1, $2, $3, $4, $5, $6)
-	ON CONFLICT (character_id) DO UPDATE SET timestamp = $2, x = $3, y = $4, flipH = $5, hitpoints = $6;
+	INSERT INTO players (character_id, timestamp, x, y, flipH, hitpoints, zone_id) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (character_id) DO UPDATE SET timestamp = $2, x = $3, y = $4, flipH = $5, hitpoints = $6, zone_id = $7;
 	`
-	_, err := r.conn.Exec(ctx, q, characterID, timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints)
+	_, err := r.conn.Exec(ctx, q, characterID, timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints, playerState.ZoneID)
 	if err != nil {
 		return fmt.Errorf("failed to insert player: %v", err)
 	}
@@ -209,13 +272,14 @@ func (r *PostgresRepository) SavePlayerState(ctx context.Context, timestamp int6
 
 func (r *PostgresRepository) LoadPlayerState(ctx context.Context, characterID int32) (*gametypes.PlayerState, error) {
 	q := `
-	SELECT x, y, flipH, hitpoints FROM players WHERE character_id = $1;
+	SELECT x, y, flipH, hitpoints, zone_id FROM players WHERE character_id = $1;
 	`
 	var x float64
 	var y float64
 	var flipH bool
 	var hitpoints int16
-	if err := r.conn.QueryRow(ctx, q, characterID).Scan(&x, &y, &flipH, &hitpoints); err != nil {
+	var zoneID uint32
+	if err := r.conn.QueryRow(ctx, q, characterID).Scan(&x, &y, &flipH, &hitpoints, &zoneID); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, &ErrNotFound{}
 		}
@@ -230,6 +294,7 @@ func (r *PostgresRepository) LoadPlayerState(ctx context.Context, characterID in
 		},
 		FlipH:     flipH,
 		Hitpoints: hitpoints,
+		ZoneID:    zoneID,
 	}, nil
 
 }
@@ -2,6 +2,9 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 
 	gametypes "github.com/cbodonnell/flywheel/pkg/game/types"
 	"github.com/cbodonnell/flywheel/pkg/repositories/models"
@@ -22,3 +25,20 @@ type Repository interface {
 	SavePlayerState(ctx context.Context, timestamp int64, characterID int32, playerState *gametypes.PlayerState) error
 	LoadPlayerState(ctx context.Context, characterID int32) (*gametypes.PlayerState, error)
 }
+
+// migrationFiles returns the paths of the migrations in a directory in order of their names
+func migrationFiles(migrations string) ([]string, error) {
+	dir, err := os.ReadDir(migrations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	var paths []string
+	for _, entry := range dir {
+		if entry.IsDir() {
+			continue
+		}
+		paths = append(paths, filepath.Join(migrations, entry.Name()))
+	}
+	return paths, nil
+}
@@ -28,30 +28,60 @@ func NewSQLiteRepository(ctx context.Context, path string, migrations string) (R
 		return nil, fmt.Errorf("failed to enable foreign keys: %v", err)
 	}
 
-	dir, err := os.ReadDir(migrations)
+	if err := migrate(ctx, db, migrations); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteRepository{
+		db: db,
+	}, nil
+}
+
+// migrate executes the migrations in a directory in order of their names,
+// skipping those already executed against the database
+func migrate(ctx context.Context, db *sql.DB, migrations string) error {
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS migrations (name TEXT PRIMARY KEY);"); err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
+	paths, err := migrationFiles(migrations)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+		return err
 	}
 
-	for _, entry := range dir {
-		if entry.IsDir() {
+	for _, migrationPath := range paths {
+		name := filepath.Base(migrationPath)
+		var executed bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM migrations WHERE name = ?)", name).Scan(&executed); err != nil {
+			return fmt.Errorf("failed to check migration %s: %v", name, err)
+		}
+		if executed {
 			continue
 		}
 
-		migrationPath := filepath.Join(migrations, entry.Name())
 		migration, err := os.ReadFile(migrationPath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read migration %s: %v", migrationPath, err)
+			return fmt.Errorf("failed to read migration %s: %v", migrationPath, err)
 		}
 
-		if _, err := db.ExecContext(ctx, string(migration)); err != nil {
-			return nil, fmt.Errorf("failed to execute migration %s: %v", migrationPath, err)
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, string(migration)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to execute migration %s: %v", migrationPath, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO migrations (name) VALUES (?)", name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %v", migrationPath, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %v", migrationPath, err)
 		}
 	}
 
-	return &SQLiteRepository{
-		db: db,
-	}, nil
+	return nil
 }
 
 func (r *SQLiteRepository) Close(ctx context.Context) error {
@@ -171,10 +201,10 @@ func (r *SQLiteRepository) SaveGameState(ctx context.Context, gameState *gametyp
 
 	for _, playerState := range gameState.Players {
 		q := `
-		INSERT OR REPLACE INTO players (character_id, timestamp, x, y, flipH, hitpoints)
-		VALUES (?, ?, ?, ?, ?, ?);
+		INSERT OR REPLACE INTO players (character_id, timestamp, x, y, flipH, hitpoints, zone_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
 		`
-		_, err = tx.ExecContext(ctx, q, playerState.CharacterID, gameState.Timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints)
+		_, err = tx.ExecContext(ctx, q, playerState.CharacterID, gameState.Timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints, playerState.ZoneID)
 		if err != nil {
 			return fmt.Errorf("failed to insert player: %v", err)
 		}
@@ -189,10 +219,10 @@ func (r *SQLiteRepository) SaveGameState(ctx context.Context, gameState *gametyp
 
 func (r *SQLiteRepository) SavePlayerState(ctx context.Context, timestamp int64, characterID int32, playerState *gametypes.PlayerState) error {
 	q := `
-	INSERT OR REPLACE INTO players (character_id, timestamp, x, y, flipH, hitpoints)
-	VALUES (?, ?, ?, ?, ?, ?);
+	INSERT OR REPLACE INTO players (character_id, timestamp, x, y, flipH, hitpoints, zone_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?);
 	`
-	_, err := r.db.ExecContext(ctx, q, characterID, timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints)
+	_, err := r.db.ExecContext(ctx, q, characterID, timestamp, playerState.Position.X, playerState.Position.Y, playerState.FlipH, playerState.Hitpoints, playerState.ZoneID)
 	if err != nil {
 		return fmt.Errorf("failed to insert player: %v", err)
 	}
@@ -202,13 +232,14 @@ func (r *SQLiteRepository) SavePlayerState(ctx context.Context, timestamp int64,
 
 func (r *SQLiteRepository) LoadPlayerState(ctx context.Context, characterID int32) (*gametypes.PlayerState, error) {
 	q := `
-	SELECT x, y, flipH, hitpoints FROM players WHERE character_id = $1;
+	SELECT x, y, flipH, hitpoints, zone_id FROM players WHERE character_id = $1;
 	`
 	var x float64
 	var y float64
 	var flipH bool
 	var hitpoints int16
-	if err := r.db.QueryRowContext(ctx, q, characterID).Scan(&x, &y, &flipH, &hitpoints); err != nil {
+	var zoneID uint32
+	if err := r.db.QueryRowContext(ctx, q, characterID).Scan(&x, &y, &flipH, &hitpoints, &zoneID); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, &ErrNotFound{}
 		}
@@ -223,5 +254,6 @@ func (r *SQLiteRepository) LoadPlayerState(ctx context.Context, characterID int3
 		},
 		FlipH:     flipH,
 		Hitpoints: hitpoints,
+		ZoneID:    zoneID,
 	}, nil
 }
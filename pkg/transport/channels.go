@@ -0,0 +1,32 @@
+package transport
+
+import "github.com/cbodonnell/flywheel/pkg/messages"
+
+// ChannelTypeForMessage returns the channel a message of the given type is sent on.
+// Events that must not be lost use the reliable channel, state that is superseded
+// by newer state uses the sequenced channel and everything else is unreliable.
+func ChannelTypeForMessage(t messages.MessageType) ChannelType {
+	switch t {
+	case messages.MessageTypeServerPlayerConnect,
+		messages.MessageTypeServerPlayerDisconnect,
+		messages.MessageTypeServerNPCHit,
+		messages.MessageTypeServerNPCKill,
+		messages.MessageTypeServerPlayerHit,
+		messages.MessageTypeServerPlayerKill,
+		messages.MessageTypeServerEntityEnterView,
+		messages.MessageTypeServerEntityLeaveView,
+		messages.MessageTypeServerShutdown,
+		messages.MessageTypeServerZoneChange:
+		return ChannelTypeReliableOrdered
+	case messages.MessageTypeClientPlayerUpdate,
+		messages.MessageTypeClientSnapshotAck:
+		return ChannelTypeUnreliableSequenced
+	default:
+		// ServerGameUpdate is unreliable rather than sequenced since an update may be
+		// split across several datagrams, and stale updates are instead discarded by
+		// the client when reassembling them (see messages.GameStateAssembler).
+		// ServerPlayerUpdate and ServerNPCUpdate are unreliable since updates for
+		// different entities would share the channel.
+		return ChannelTypeUnreliable
+	}
+}
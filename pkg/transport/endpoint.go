@@ -0,0 +1,241 @@
+package transport
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultResendTimeout is how long a reliable datagram waits for an ack before it is resent
+	DefaultResendTimeout = 100 * time.Millisecond
+	// MaxPendingReliable is the maximum number of unacked reliable datagrams per endpoint
+	MaxPendingReliable = 1024
+	// ReceiveWindow is how far ahead of the next expected reliable sequence datagrams are buffered
+	ReceiveWindow = 1024
+	// AckBitsSize is the number of sequences after the cumulative ack covered by AckBits
+	AckBitsSize = 32
+)
+
+// Endpoint tracks the channel state for one side of a connection between a client and the server.
+// It does not own a socket: Send and Resend return datagrams for the caller to write and
+// Receive consumes datagrams read by the caller.
+type Endpoint struct {
+	clientID      uint32
+	resendTimeout time.Duration
+	cipher        *SessionCipher
+	lock          sync.Mutex
+
+	// outgoing state
+	sequencedSendSeq uint32
+	reliableSendSeq  uint32
+	pending          map[uint32]*pendingDatagram
+
+	// incoming state
+	sequencedRecvSeq uint32
+	reliableRecvSeq  uint32
+	received         map[uint32][]byte
+	ackRequired      bool
+}
+
+type pendingDatagram struct {
+	payload  []byte
+	lastSent time.Time
+}
+
+type NewEndpointOptions struct {
+	// ClientID is written to the header of every datagram sent by the endpoint
+	ClientID      uint32
+	ResendTimeout time.Duration
+	// Cipher seals every datagram sent and opens every datagram received by the endpoint.
+	// Without it datagrams are sent in the clear and are not authenticated.
+	Cipher *SessionCipher
+}
+
+// NewEndpoint creates a new Endpoint
+func NewEndpoint(opts NewEndpointOptions) *Endpoint {
+	resendTimeout := opts.ResendTimeout
+	if resendTimeout == 0 {
+		resendTimeout = DefaultResendTimeout
+	}
+
+	return &Endpoint{
+		clientID:      opts.ClientID,
+		resendTimeout: resendTimeout,
+		cipher:        opts.Cipher,
+		pending:       make(map[uint32]*pendingDatagram),
+		received:      make(map[uint32][]byte),
+	}
+}
+
+// Send returns a datagram carrying the payload on the given channel.
+// Reliable payloads are kept until acked and returned again by Resend,
+// so a reliable datagram that cannot be written right away is not lost.
+func (e *Endpoint) Send(channel ChannelType, payload []byte) ([]byte, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var seq uint32
+	switch channel {
+	case ChannelTypeUnreliable:
+	case ChannelTypeUnreliableSequenced:
+		e.sequencedSendSeq++
+		seq = e.sequencedSendSeq
+	case ChannelTypeReliableOrdered:
+		if len(e.pending) >= MaxPendingReliable {
+			return nil, fmt.Errorf("too many unacked reliable datagrams (%d)", len(e.pending))
+		}
+		e.reliableSendSeq++
+		seq = e.reliableSendSeq
+		e.pending[seq] = &pendingDatagram{
+			payload:  payload,
+			lastSent: time.Now(),
+		}
+	default:
+		return nil, fmt.Errorf("cannot send on channel %s", channel)
+	}
+
+	return e.encode(channel, seq, payload), nil
+}
+
+// Receive processes a datagram and returns the payloads that are ready for delivery, in order.
+// A reliable datagram may release several buffered payloads at once, while stale,
+// duplicate and ack-only datagrams return none. Datagrams that cannot be authenticated
+// by the endpoint's cipher, or are replayed, are rejected with an error.
+func (e *Endpoint) Receive(datagram []byte) ([][]byte, error) {
+	h, payload, err := DecodeDatagram(datagram)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode datagram: %v", err)
+	}
+
+	if e.cipher != nil {
+		payload, err = e.cipher.Open(datagram[:HeaderSize], payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open datagram: %v", err)
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.processAcks(h.Ack, h.AckBits)
+
+	switch h.Channel {
+	case ChannelTypeUnreliable:
+		return [][]byte{payload}, nil
+	case ChannelTypeUnreliableSequenced:
+		if h.Sequence <= e.sequencedRecvSeq {
+			return nil, nil
+		}
+		e.sequencedRecvSeq = h.Sequence
+		return [][]byte{payload}, nil
+	case ChannelTypeReliableOrdered:
+		// always ack, even duplicates, since the ack for the original may have been lost
+		e.ackRequired = true
+		if h.Sequence <= e.reliableRecvSeq || h.Sequence > e.reliableRecvSeq+ReceiveWindow {
+			return nil, nil
+		}
+		e.received[h.Sequence] = payload
+
+		var ready [][]byte
+		for {
+			next, ok := e.received[e.reliableRecvSeq+1]
+			if !ok {
+				break
+			}
+			delete(e.received, e.reliableRecvSeq+1)
+			e.reliableRecvSeq++
+			ready = append(ready, next)
+		}
+		return ready, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Resend returns datagrams for the reliable payloads that have not been acked within the resend timeout
+func (e *Endpoint) Resend(now time.Time) [][]byte {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	seqs := make([]uint32, 0, len(e.pending))
+	for seq, p := range e.pending {
+		if now.Sub(p.lastSent) >= e.resendTimeout {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	datagrams := make([][]byte, 0, len(seqs))
+	for _, seq := range seqs {
+		p := e.pending[seq]
+		p.lastSent = now
+		datagrams = append(datagrams, e.encode(ChannelTypeReliableOrdered, seq, p.payload))
+	}
+
+	return datagrams
+}
+
+// Ack returns an ack-only datagram if reliable datagrams have been received
+// since the last datagram sent by the endpoint, which would otherwise have carried the ack
+func (e *Endpoint) Ack() ([]byte, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.ackRequired {
+		return nil, false
+	}
+
+	return e.encode(ChannelTypeAck, 0, nil), true
+}
+
+// Pending returns the number of reliable datagrams waiting for an ack
+func (e *Endpoint) Pending() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.pending)
+}
+
+// encode builds a datagram carrying the current acks.
+// The lock must be held by the caller.
+func (e *Endpoint) encode(channel ChannelType, seq uint32, payload []byte) []byte {
+	var ackBits uint32
+	for i := uint32(0); i < AckBitsSize; i++ {
+		if _, ok := e.received[e.reliableRecvSeq+2+i]; ok {
+			ackBits |= 1 << i
+		}
+	}
+	e.ackRequired = false
+
+	h := Header{
+		ClientID: e.clientID,
+		Channel:  channel,
+		Sequence: seq,
+		Ack:      e.reliableRecvSeq,
+		AckBits:  ackBits,
+	}
+	if e.cipher == nil {
+		return EncodeDatagram(h, payload)
+	}
+
+	// resent datagrams are sealed again, so every datagram has a new counter
+	datagram := make([]byte, 0, HeaderSize+SealOverhead+len(payload))
+	datagram = append(datagram, EncodeDatagram(h, nil)...)
+	return e.cipher.Seal(datagram, payload)
+}
+
+// processAcks removes the reliable payloads acked by the remote endpoint.
+// The lock must be held by the caller.
+func (e *Endpoint) processAcks(ack uint32, ackBits uint32) {
+	for seq := range e.pending {
+		if seq <= ack {
+			delete(e.pending, seq)
+		}
+	}
+	for i := uint32(0); i < AckBitsSize; i++ {
+		if ackBits&(1<<i) != 0 {
+			delete(e.pending, ack+2+i)
+		}
+	}
+}
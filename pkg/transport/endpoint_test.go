@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpoint_ReliableOrdered(t *testing.T) {
+	sender := NewEndpoint(NewEndpointOptions{ClientID: 1})
+	receiver := NewEndpoint(NewEndpointOptions{ClientID: 1})
+
+	var datagrams [][]byte
+	for _, p := range []string{"a", "b", "c", "d"} {
+		d, err := sender.Send(ChannelTypeReliableOrdered, []byte(p))
+		assert.NoError(t, err)
+		datagrams = append(datagrams, d)
+	}
+
+	// "b" is lost and "d" arrives before "c"
+	got, err := receiver.Receive(datagrams[0])
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a")}, got)
+
+	got, err = receiver.Receive(datagrams[3])
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	got, err = receiver.Receive(datagrams[2])
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	// the ack covers "a" cumulatively and "c" and "d" selectively
+	ack, ok := receiver.Ack()
+	assert.True(t, ok)
+	_, err = sender.Receive(ack)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sender.Pending())
+
+	resent := sender.Resend(time.Now().Add(DefaultResendTimeout))
+	assert.Len(t, resent, 1)
+
+	got, err = receiver.Receive(resent[0])
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c"), []byte("d")}, got)
+
+	// duplicates are acked but not delivered again
+	got, err = receiver.Receive(datagrams[2])
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	ack, ok = receiver.Ack()
+	assert.True(t, ok)
+	_, err = sender.Receive(ack)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sender.Pending())
+	assert.Empty(t, sender.Resend(time.Now().Add(DefaultResendTimeout)))
+
+	_, ok = receiver.Ack()
+	assert.False(t, ok)
+}
+
+func TestEndpoint_UnreliableSequenced(t *testing.T) {
+	sender := NewEndpoint(NewEndpointOptions{ClientID: 1})
+	receiver := NewEndpoint(NewEndpointOptions{ClientID: 1})
+
+	first, err := sender.Send(ChannelTypeUnreliableSequenced, []byte("first"))
+	assert.NoError(t, err)
+	second, err := sender.Send(ChannelTypeUnreliableSequenced, []byte("second"))
+	assert.NoError(t, err)
+
+	got, err := receiver.Receive(second)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("second")}, got)
+
+	// stale datagrams are dropped
+	got, err = receiver.Receive(first)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	// sequenced datagrams are not resent
+	assert.Empty(t, sender.Resend(time.Now().Add(DefaultResendTimeout)))
+}
+
+func TestDecodeDatagram(t *testing.T) {
+	want := Header{
+		ClientID: 42,
+		Channel:  ChannelTypeReliableOrdered,
+		Sequence: 7,
+		Ack:      3,
+		AckBits:  5,
+	}
+	h, payload, err := DecodeDatagram(EncodeDatagram(want, []byte("payload")))
+	assert.NoError(t, err)
+	assert.Equal(t, want, h)
+	assert.Equal(t, []byte("payload"), payload)
+
+	_, _, err = DecodeDatagram([]byte{1, 2, 3})
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// HeaderSize is the size of the header prepended to every datagram
+	HeaderSize = 17
+)
+
+// ChannelType represents the delivery guarantees of a datagram
+type ChannelType uint8
+
+const (
+	// ChannelTypeUnreliable datagrams are delivered at most once, in any order
+	ChannelTypeUnreliable ChannelType = iota
+	// ChannelTypeUnreliableSequenced datagrams are delivered at most once
+	// and datagrams older than the newest one received are dropped
+	ChannelTypeUnreliableSequenced
+	// ChannelTypeReliableOrdered datagrams are acked and resent until
+	// received, and are delivered exactly once in the order they were sent
+	ChannelTypeReliableOrdered
+	// ChannelTypeAck datagrams carry acks for the reliable channel and no payload
+	ChannelTypeAck
+)
+
+func (c ChannelType) String() string {
+	switch c {
+	case ChannelTypeUnreliable:
+		return "Unreliable"
+	case ChannelTypeUnreliableSequenced:
+		return "UnreliableSequenced"
+	case ChannelTypeReliableOrdered:
+		return "ReliableOrdered"
+	case ChannelTypeAck:
+		return "Ack"
+	default:
+		return "Unknown"
+	}
+}
+
+// Header is prepended to every datagram sent between a client and the server.
+// Ack is the last reliable sequence delivered in order by the sender of the datagram,
+// and bit i of AckBits is set if reliable sequence Ack+2+i has also been received.
+type Header struct {
+	ClientID uint32
+	Channel  ChannelType
+	Sequence uint32
+	Ack      uint32
+	AckBits  uint32
+}
+
+// EncodeDatagram returns a datagram with the header followed by the payload
+func EncodeDatagram(h Header, payload []byte) []byte {
+	b := make([]byte, HeaderSize+len(payload))
+	binary.BigEndian.PutUint32(b[0:4], h.ClientID)
+	b[4] = byte(h.Channel)
+	binary.BigEndian.PutUint32(b[5:9], h.Sequence)
+	binary.BigEndian.PutUint32(b[9:13], h.Ack)
+	binary.BigEndian.PutUint32(b[13:17], h.AckBits)
+	copy(b[HeaderSize:], payload)
+	return b
+}
+
+// DecodeDatagram splits a datagram into its header and payload
+func DecodeDatagram(b []byte) (Header, []byte, error) {
+	if len(b) < HeaderSize {
+		return Header{}, nil, fmt.Errorf("datagram of %d bytes is smaller than the header", len(b))
+	}
+
+	h := Header{
+		ClientID: binary.BigEndian.Uint32(b[0:4]),
+		Channel:  ChannelType(b[4]),
+		Sequence: binary.BigEndian.Uint32(b[5:9]),
+		Ack:      binary.BigEndian.Uint32(b[9:13]),
+		AckBits:  binary.BigEndian.Uint32(b[13:17]),
+	}
+	if h.Channel > ChannelTypeAck {
+		return Header{}, nil, fmt.Errorf("unknown channel type %d", h.Channel)
+	}
+
+	return h, b[HeaderSize:], nil
+}
@@ -0,0 +1,189 @@
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// SessionKeySize is the size of the AES-256 keys derived for a session
+	SessionKeySize = 32
+	// CounterSize is the size of the packet counter sent with every sealed datagram
+	CounterSize = 8
+	// SealOverhead is the number of bytes added to a datagram by sealing it
+	SealOverhead = CounterSize + 16
+	// ReplayWindowSize is how far behind the highest counter received a datagram is still accepted
+	ReplayWindowSize = 64
+
+	sessionKeyInfo = "flywheel udp session"
+)
+
+// Role is the side of a session
+type Role uint8
+
+const (
+	RoleClient Role = iota
+	RoleServer
+)
+
+// GenerateSessionKeyPair generates an ephemeral X25519 key pair used to derive session keys.
+// The public key is exchanged during login.
+func GenerateSessionKeyPair() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// NewSessionCipher derives the keys for a session from the local private key and the
+// public key of the peer, both exchanged during login, and returns a cipher for the
+// given side of the session. Each direction of the session uses its own key.
+func NewSessionCipher(role Role, privateKey *ecdh.PrivateKey, peerPublicKey []byte) (*SessionCipher, error) {
+	peerKey, err := ecdh.X25519().NewPublicKey(peerPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer public key: %v", err)
+	}
+	secret, err := privateKey.ECDH(peerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %v", err)
+	}
+
+	var clientPublicKey, serverPublicKey []byte
+	if role == RoleClient {
+		clientPublicKey, serverPublicKey = privateKey.PublicKey().Bytes(), peerPublicKey
+	} else {
+		clientPublicKey, serverPublicKey = peerPublicKey, privateKey.PublicKey().Bytes()
+	}
+	salt := append(append([]byte{}, clientPublicKey...), serverPublicKey...)
+
+	keys := make([]byte, 2*SessionKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(sessionKeyInfo)), keys); err != nil {
+		return nil, fmt.Errorf("failed to derive session keys: %v", err)
+	}
+	clientKey, serverKey := keys[:SessionKeySize], keys[SessionKeySize:]
+
+	if role == RoleClient {
+		return newSessionCipher(clientKey, serverKey)
+	}
+	return newSessionCipher(serverKey, clientKey)
+}
+
+// SessionCipher seals and opens the datagrams of one side of a session with AES-GCM.
+// Every sealed datagram carries a counter used as the nonce, and datagrams with a
+// counter that has already been received, or is too old to tell, are rejected.
+type SessionCipher struct {
+	send cipher.AEAD
+	recv cipher.AEAD
+
+	lock        sync.Mutex
+	sendCounter uint64
+	// recvCounter is the highest counter received and bit i of recvWindow
+	// is set if counter recvCounter-i has been received
+	recvCounter uint64
+	recvWindow  uint64
+}
+
+func newSessionCipher(sendKey, recvKey []byte) (*SessionCipher, error) {
+	send, err := newAEAD(sendKey)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := newAEAD(recvKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionCipher{
+		send: send,
+		recv: recv,
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AEAD: %v", err)
+	}
+	return aead, nil
+}
+
+// Seal appends the counter and the encrypted payload to dst, which holds the
+// datagram header. The header is authenticated but not encrypted.
+func (c *SessionCipher) Seal(dst []byte, payload []byte) []byte {
+	c.lock.Lock()
+	c.sendCounter++
+	counter := c.sendCounter
+	c.lock.Unlock()
+
+	header := dst
+	dst = binary.BigEndian.AppendUint64(dst, counter)
+	return c.send.Seal(dst, nonce(counter), payload, header)
+}
+
+// Open authenticates and decrypts the sealed part of a datagram
+// and returns the payload. The header is authenticated with it.
+func (c *SessionCipher) Open(header []byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < SealOverhead {
+		return nil, fmt.Errorf("sealed payload of %d bytes is too short", len(sealed))
+	}
+	counter := binary.BigEndian.Uint64(sealed[:CounterSize])
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.accept(counter) {
+		return nil, fmt.Errorf("replayed or stale datagram %d", counter)
+	}
+	payload, err := c.recv.Open(nil, nonce(counter), sealed[CounterSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate datagram: %v", err)
+	}
+	c.record(counter)
+
+	return payload, nil
+}
+
+// accept returns true if a counter has not been received and is within the replay window.
+// The lock must be held by the caller.
+func (c *SessionCipher) accept(counter uint64) bool {
+	if counter == 0 {
+		return false
+	}
+	if counter > c.recvCounter {
+		return true
+	}
+	age := c.recvCounter - counter
+	return age < ReplayWindowSize && c.recvWindow&(1<<age) == 0
+}
+
+// record marks a counter as received, which must only happen once its datagram is authenticated.
+// The lock must be held by the caller.
+func (c *SessionCipher) record(counter uint64) {
+	if counter > c.recvCounter {
+		shift := counter - c.recvCounter
+		if shift >= ReplayWindowSize {
+			c.recvWindow = 0
+		} else {
+			c.recvWindow <<= shift
+		}
+		c.recvWindow |= 1
+		c.recvCounter = counter
+		return
+	}
+	c.recvWindow |= 1 << (c.recvCounter - counter)
+}
+
+func nonce(counter uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], counter)
+	return n
+}
@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSessionCiphers(t *testing.T) (*SessionCipher, *SessionCipher) {
+	clientKey, err := GenerateSessionKeyPair()
+	assert.NoError(t, err)
+	serverKey, err := GenerateSessionKeyPair()
+	assert.NoError(t, err)
+
+	client, err := NewSessionCipher(RoleClient, clientKey, serverKey.PublicKey().Bytes())
+	assert.NoError(t, err)
+	server, err := NewSessionCipher(RoleServer, serverKey, clientKey.PublicKey().Bytes())
+	assert.NoError(t, err)
+	return client, server
+}
+
+func TestSessionCipher_SealOpen(t *testing.T) {
+	client, server := newTestSessionCiphers(t)
+
+	header := []byte("header")
+	sealed := client.Seal(append([]byte{}, header...), []byte("payload"))
+	assert.Len(t, sealed, len(header)+SealOverhead+len("payload"))
+	assert.NotContains(t, string(sealed), "payload")
+
+	payload, err := server.Open(header, sealed[len(header):])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), payload)
+
+	// each direction has its own key
+	_, err = client.Open(header, server.Seal(append([]byte{}, header...), []byte("reply"))[len(header):])
+	assert.NoError(t, err)
+	_, err = client.Open(header, client.Seal(append([]byte{}, header...), []byte("payload"))[len(header):])
+	assert.Error(t, err)
+}
+
+func TestSessionCipher_Tampering(t *testing.T) {
+	client, server := newTestSessionCiphers(t)
+
+	header := []byte("header")
+	sealed := client.Seal(append([]byte{}, header...), []byte("payload"))[len(header):]
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err := server.Open(header, tampered)
+	assert.Error(t, err)
+
+	_, err = server.Open([]byte("HEADER"), sealed)
+	assert.Error(t, err)
+
+	_, err = server.Open(header, sealed[:SealOverhead-1])
+	assert.Error(t, err)
+
+	// a rejected datagram does not use up its counter
+	_, err = server.Open(header, sealed)
+	assert.NoError(t, err)
+
+	_, otherServer := newTestSessionCiphers(t)
+	_, err = otherServer.Open(header, client.Seal(append([]byte{}, header...), []byte("payload"))[len(header):])
+	assert.Error(t, err)
+}
+
+func TestSessionCipher_Replay(t *testing.T) {
+	client, server := newTestSessionCiphers(t)
+
+	var sealed [][]byte
+	for i := 0; i < ReplayWindowSize+3; i++ {
+		sealed = append(sealed, client.Seal(nil, []byte{byte(i)}))
+	}
+
+	// out of order datagrams within the window are accepted once
+	_, err := server.Open(nil, sealed[1])
+	assert.NoError(t, err)
+	_, err = server.Open(nil, sealed[0])
+	assert.NoError(t, err)
+	_, err = server.Open(nil, sealed[0])
+	assert.Error(t, err)
+	_, err = server.Open(nil, sealed[1])
+	assert.Error(t, err)
+
+	// datagrams older than the window are rejected
+	_, err = server.Open(nil, sealed[ReplayWindowSize+2])
+	assert.NoError(t, err)
+	_, err = server.Open(nil, sealed[2])
+	assert.Error(t, err)
+	_, err = server.Open(nil, sealed[3])
+	assert.NoError(t, err)
+}
+
+func TestEndpoint_Cipher(t *testing.T) {
+	client, server := newTestSessionCiphers(t)
+	sender := NewEndpoint(NewEndpointOptions{ClientID: 1, Cipher: client})
+	receiver := NewEndpoint(NewEndpointOptions{ClientID: 1, Cipher: server})
+
+	datagram, err := sender.Send(ChannelTypeReliableOrdered, []byte("a"))
+	assert.NoError(t, err)
+	got, err := receiver.Receive(datagram)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a")}, got)
+
+	// a replayed datagram is rejected before it is delivered
+	_, err = receiver.Receive(datagram)
+	assert.Error(t, err)
+
+	// the header is authenticated
+	tampered := append([]byte{}, datagram...)
+	tampered[0] ^= 1
+	_, err = receiver.Receive(tampered)
+	assert.Error(t, err)
+
+	// resends are sealed again and are not mistaken for replays
+	resent := sender.Resend(time.Now().Add(DefaultResendTimeout))
+	assert.Len(t, resent, 1)
+	got, err = receiver.Receive(resent[0])
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
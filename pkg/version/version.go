@@ -1,7 +1,80 @@
 package version
 
+import "fmt"
+
 var version = "dev"
 
 func Get() string {
 	return version
 }
+
+const (
+	// ProtocolVersion is the version of the wire protocol spoken by this build.
+	// It must be incremented whenever a change to the messages breaks older peers.
+	ProtocolVersion uint16 = 6
+	// MinProtocolVersion is the oldest protocol version of a peer this build can talk to
+	MinProtocolVersion uint16 = 6
+)
+
+// Capability is a protocol feature supported by a peer
+type Capability uint32
+
+const (
+	// CapabilityDeltaSnapshots is support for game snapshots delta-compressed against acked baselines
+	CapabilityDeltaSnapshots Capability = 1 << iota
+	// CapabilitySnapshotParts is support for game snapshots split across datagrams
+	CapabilitySnapshotParts
+	// CapabilityInterestManagement is support for entities entering and leaving a client's view
+	CapabilityInterestManagement
+	// CapabilityEncryptedUDP is support for UDP datagrams sealed with session keys negotiated at login
+	CapabilityEncryptedUDP
+)
+
+const (
+	// Capabilities is the set of capabilities supported by this build
+	Capabilities = CapabilityDeltaSnapshots | CapabilitySnapshotParts | CapabilityInterestManagement | CapabilityEncryptedUDP
+	// RequiredCapabilities is the set of capabilities a peer must support to be compatible.
+	// The other capabilities are optional, and only used with peers that support them.
+	RequiredCapabilities = CapabilitySnapshotParts | CapabilityInterestManagement | CapabilityEncryptedUDP
+)
+
+// Has returns true if a set of capabilities includes all of another
+func (c Capability) Has(capabilities Capability) bool {
+	return c&capabilities == capabilities
+}
+
+// ErrIncompatibleProtocol is returned when a peer's protocol is not compatible with this build
+type ErrIncompatibleProtocol struct {
+	ProtocolVersion     uint16
+	MissingCapabilities Capability
+}
+
+func (e *ErrIncompatibleProtocol) Error() string {
+	if e.ProtocolVersion < MinProtocolVersion || e.ProtocolVersion > ProtocolVersion {
+		return fmt.Sprintf("protocol version %d is not in the supported range %d-%d", e.ProtocolVersion, MinProtocolVersion, ProtocolVersion)
+	}
+	return fmt.Sprintf("missing required capabilities %#x", uint32(e.MissingCapabilities))
+}
+
+// IsPeerOutdated returns true if the peer speaks an older protocol than this build supports
+func (e *ErrIncompatibleProtocol) IsPeerOutdated() bool {
+	return e.ProtocolVersion < MinProtocolVersion || e.MissingCapabilities != 0
+}
+
+// CheckCompatibility returns an ErrIncompatibleProtocol if a peer with the
+// given protocol version and capabilities cannot talk to this build.
+func CheckCompatibility(protocolVersion uint16, capabilities Capability) error {
+	missing := RequiredCapabilities &^ capabilities
+	if protocolVersion < MinProtocolVersion || protocolVersion > ProtocolVersion || missing != 0 {
+		return &ErrIncompatibleProtocol{
+			ProtocolVersion:     protocolVersion,
+			MissingCapabilities: missing,
+		}
+	}
+	return nil
+}
+
+// NegotiateCapabilities returns the capabilities supported by both this build and a peer
+func NegotiateCapabilities(capabilities Capability) Capability {
+	return Capabilities & capabilities
+}
@@ -0,0 +1,39 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	assert.NoError(t, CheckCompatibility(ProtocolVersion, Capabilities))
+
+	err := CheckCompatibility(MinProtocolVersion-1, Capabilities)
+	if assert.IsType(t, &ErrIncompatibleProtocol{}, err) {
+		assert.True(t, err.(*ErrIncompatibleProtocol).IsPeerOutdated())
+	}
+
+	err = CheckCompatibility(ProtocolVersion+1, Capabilities)
+	if assert.IsType(t, &ErrIncompatibleProtocol{}, err) {
+		assert.False(t, err.(*ErrIncompatibleProtocol).IsPeerOutdated())
+	}
+
+	err = CheckCompatibility(ProtocolVersion, Capabilities&^CapabilitySnapshotParts)
+	if assert.IsType(t, &ErrIncompatibleProtocol{}, err) {
+		assert.Equal(t, CapabilitySnapshotParts, err.(*ErrIncompatibleProtocol).MissingCapabilities)
+		assert.True(t, err.(*ErrIncompatibleProtocol).IsPeerOutdated())
+	}
+}
+
+func TestOptionalCapabilities(t *testing.T) {
+	assert.True(t, Capabilities.Has(RequiredCapabilities))
+	assert.NotEqual(t, Capabilities, RequiredCapabilities, "some capabilities should be optional")
+
+	// a peer without delta snapshots is compatible, but does not negotiate them
+	capabilities := Capabilities &^ CapabilityDeltaSnapshots
+	assert.NoError(t, CheckCompatibility(ProtocolVersion, capabilities))
+	negotiated := NegotiateCapabilities(capabilities)
+	assert.False(t, negotiated.Has(CapabilityDeltaSnapshots))
+	assert.True(t, negotiated.Has(RequiredCapabilities))
+}
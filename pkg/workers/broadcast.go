@@ -2,7 +2,6 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/cbodonnell/flywheel/pkg/log"
@@ -16,8 +15,13 @@ type BroadcastMessageWorker struct {
 }
 
 type BroadcastMessage struct {
-	Type    messages.MessageType
-	Message interface{}
+	// ClientID is the client the message is sent to, or 0 to send it to the clients in ClientIDs
+	ClientID uint32
+	// ClientIDs are the clients the message is sent to if ClientID is 0,
+	// where nil sends it to all clients and an empty slice to none
+	ClientIDs []uint32
+	Type      messages.MessageType
+	Message   interface{}
 }
 
 type NewBroadcastMessageWorkerOptions struct {
@@ -51,14 +55,6 @@ func (w *BroadcastMessageWorker) Start(ctx context.Context) {
 				if err := w.handleServerGameUpdate(msg); err != nil {
 					log.Error("Failed to handle server game update message: %v", err)
 				}
-			case messages.MessageTypeServerPlayerUpdate:
-				if err := w.handleServerPlayerUpdate(msg); err != nil {
-					log.Error("Failed to handle server player update message: %v", err)
-				}
-			case messages.MessageTypeServerNPCUpdate:
-				if err := w.handleServerNPCUpdate(msg); err != nil {
-					log.Error("Failed to handle server NPC update message: %v", err)
-				}
 			case messages.MessageTypeServerNPCHit:
 				if err := w.handleServerNPCHit(msg); err != nil {
 					log.Error("Failed to handle server NPC hit message: %v", err)
@@ -75,6 +71,22 @@ func (w *BroadcastMessageWorker) Start(ctx context.Context) {
 				if err := w.handleServerPlayerKill(msg); err != nil {
 					log.Error("Failed to handle server player kill message: %v", err)
 				}
+			case messages.MessageTypeServerEntityEnterView:
+				if err := w.handleServerEntityEnterView(msg); err != nil {
+					log.Error("Failed to handle server entity enter view message: %v", err)
+				}
+			case messages.MessageTypeServerEntityLeaveView:
+				if err := w.handleServerEntityLeaveView(msg); err != nil {
+					log.Error("Failed to handle server entity leave view message: %v", err)
+				}
+			case messages.MessageTypeServerShutdown:
+				if err := w.handleServerShutdown(msg); err != nil {
+					log.Error("Failed to handle server shutdown message: %v", err)
+				}
+			case messages.MessageTypeServerZoneChange:
+				if err := w.handleServerZoneChange(msg); err != nil {
+					log.Error("Failed to handle server zone change message: %v", err)
+				}
 			default:
 				log.Error("Unknown server message type: %v", msg.Type)
 			}
@@ -88,21 +100,21 @@ func (w *BroadcastMessageWorker) handleServerPlayerConnect(msg BroadcastMessage)
 		return fmt.Errorf("failed to cast server player connect message")
 	}
 
-	payload, err := json.Marshal(playerConnect)
+	payload, err := messages.SerializeServerPlayerConnect(playerConnect)
 	if err != nil {
-		return fmt.Errorf("failed to marshal player state: %v", err)
+		return fmt.Errorf("failed to serialize player state: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
+	for _, client := range w.recipients(msg) {
 		msg := &messages.Message{
 			ClientID: 0,
 			Type:     messages.MessageTypeServerPlayerConnect,
 			Payload:  payload,
 		}
 
-		err := network.WriteMessageToTCP(client.TCPConn, msg)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
-			log.Error("Failed to write message to TCP connection for client %d: %v", client.ID, err)
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
 	}
@@ -116,21 +128,21 @@ func (w *BroadcastMessageWorker) handleServerPlayerDisconnect(msg BroadcastMessa
 		return fmt.Errorf("failed to cast server player disconnect message")
 	}
 
-	payload, err := json.Marshal(playerDisconnect)
+	payload, err := messages.SerializeServerPlayerDisconnect(playerDisconnect)
 	if err != nil {
-		return fmt.Errorf("failed to marshal player disconnect message: %v", err)
+		return fmt.Errorf("failed to serialize player disconnect message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
+	for _, client := range w.recipients(msg) {
 		msg := &messages.Message{
 			ClientID: 0,
 			Type:     messages.MessageTypeServerPlayerDisconnect,
 			Payload:  payload,
 		}
 
-		err := network.WriteMessageToTCP(client.TCPConn, msg)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
-			log.Error("Failed to write message to TCP connection for client %d: %v", client.ID, err)
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
 	}
@@ -138,30 +150,62 @@ func (w *BroadcastMessageWorker) handleServerPlayerDisconnect(msg BroadcastMessa
 	return nil
 }
 
+// handleServerGameUpdate sends a game update to its client, split into
+// as few datagrams as fit the update (usually just one).
 func (w *BroadcastMessageWorker) handleServerGameUpdate(msg BroadcastMessage) error {
 	serverGameUpdate, ok := msg.Message.(*messages.ServerGameUpdate)
 	if !ok {
 		return fmt.Errorf("failed to cast server game update message")
 	}
 
-	payload, err := messages.SerializeGameState(serverGameUpdate)
+	payloads, err := messages.SerializeGameStateParts(serverGameUpdate, messages.GameStatePartSize)
 	if err != nil {
 		return fmt.Errorf("failed to serialize game state: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
-		message := &messages.Message{
-			ClientID: 0,
-			Type:     messages.MessageTypeServerGameUpdate,
-			Payload:  payload,
-		}
-
+	for _, client := range w.recipients(msg) {
 		if client.UDPAddress == nil {
 			log.Trace("Client %d does not have a UDP address", client.ID)
 			continue
 		}
 
-		err := network.WriteMessageToUDP(w.clientManager.GetUDPConn(), client.UDPAddress, message)
+		for _, payload := range payloads {
+			message := &messages.Message{
+				ClientID: 0,
+				Type:     messages.MessageTypeServerGameUpdate,
+				Payload:  payload,
+			}
+
+			err := network.WriteMessageToUDP(w.clientManager.GetUDPConn(), client.UDPAddress, client.Endpoint, message)
+			if err != nil {
+				log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *BroadcastMessageWorker) handleServerNPCHit(msg BroadcastMessage) error {
+	npcHit, ok := msg.Message.(*messages.ServerNPCHit)
+	if !ok {
+		return fmt.Errorf("failed to cast server NPC hit message")
+	}
+
+	payload, err := messages.SerializeServerNPCHit(npcHit)
+	if err != nil {
+		return fmt.Errorf("failed to serialize NPC hit message: %v", err)
+	}
+
+	for _, client := range w.recipients(msg) {
+		msg := &messages.Message{
+			ClientID: 0,
+			Type:     messages.MessageTypeServerNPCHit,
+			Payload:  payload,
+		}
+
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
 			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
@@ -171,30 +215,53 @@ func (w *BroadcastMessageWorker) handleServerGameUpdate(msg BroadcastMessage) er
 	return nil
 }
 
-func (w *BroadcastMessageWorker) handleServerPlayerUpdate(msg BroadcastMessage) error {
-	playerUpdate, ok := msg.Message.(*messages.ServerPlayerUpdate)
+func (w *BroadcastMessageWorker) handleServerNPCKill(msg BroadcastMessage) error {
+	npcKill, ok := msg.Message.(*messages.ServerNPCKill)
 	if !ok {
-		return fmt.Errorf("failed to cast server player update message")
+		return fmt.Errorf("failed to cast server NPC kill message")
 	}
 
-	payload, err := messages.SerializeServerPlayerUpdate(playerUpdate)
+	payload, err := messages.SerializeServerNPCKill(npcKill)
 	if err != nil {
-		return fmt.Errorf("failed to serialize player state: %v", err)
+		return fmt.Errorf("failed to serialize NPC kill message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
-		message := &messages.Message{
+	for _, client := range w.recipients(msg) {
+		msg := &messages.Message{
 			ClientID: 0,
-			Type:     messages.MessageTypeServerPlayerUpdate,
+			Type:     messages.MessageTypeServerNPCKill,
 			Payload:  payload,
 		}
 
-		if client.UDPAddress == nil {
-			log.Trace("Client %d does not have a UDP address", client.ID)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
+		if err != nil {
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
+	}
 
-		err := network.WriteMessageToUDP(w.clientManager.GetUDPConn(), client.UDPAddress, message)
+	return nil
+}
+
+func (w *BroadcastMessageWorker) handleServerPlayerHit(msg BroadcastMessage) error {
+	playerHit, ok := msg.Message.(*messages.ServerPlayerHit)
+	if !ok {
+		return fmt.Errorf("failed to cast server player hit message")
+	}
+
+	payload, err := messages.SerializeServerPlayerHit(playerHit)
+	if err != nil {
+		return fmt.Errorf("failed to serialize player hit message: %v", err)
+	}
+
+	for _, client := range w.recipients(msg) {
+		msg := &messages.Message{
+			ClientID: 0, // ClientID 0 means the message is from the server
+			Type:     messages.MessageTypeServerPlayerHit,
+			Payload:  payload,
+		}
+
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
 			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
@@ -204,30 +271,25 @@ func (w *BroadcastMessageWorker) handleServerPlayerUpdate(msg BroadcastMessage)
 	return nil
 }
 
-func (w *BroadcastMessageWorker) handleServerNPCUpdate(msg BroadcastMessage) error {
-	npcUpdate, ok := msg.Message.(*messages.ServerNPCUpdate)
+func (w *BroadcastMessageWorker) handleServerPlayerKill(msg BroadcastMessage) error {
+	playerKill, ok := msg.Message.(*messages.ServerPlayerKill)
 	if !ok {
-		return fmt.Errorf("failed to cast server NPC update message")
+		return fmt.Errorf("failed to cast server player kill message")
 	}
 
-	payload, err := messages.SerializeServerNPCUpdate(npcUpdate)
+	payload, err := messages.SerializeServerPlayerKill(playerKill)
 	if err != nil {
-		return fmt.Errorf("failed to serialize NPC state: %v", err)
+		return fmt.Errorf("failed to serialize player kill message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
-		message := &messages.Message{
+	for _, client := range w.recipients(msg) {
+		msg := &messages.Message{
 			ClientID: 0,
-			Type:     messages.MessageTypeServerNPCUpdate,
+			Type:     messages.MessageTypeServerPlayerKill,
 			Payload:  payload,
 		}
 
-		if client.UDPAddress == nil {
-			log.Trace("Client %d does not have a UDP address", client.ID)
-			continue
-		}
-
-		err := network.WriteMessageToUDP(w.clientManager.GetUDPConn(), client.UDPAddress, message)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
 			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
@@ -237,27 +299,27 @@ func (w *BroadcastMessageWorker) handleServerNPCUpdate(msg BroadcastMessage) err
 	return nil
 }
 
-func (w *BroadcastMessageWorker) handleServerNPCHit(msg BroadcastMessage) error {
-	npcHit, ok := msg.Message.(*messages.ServerNPCHit)
+func (w *BroadcastMessageWorker) handleServerEntityEnterView(msg BroadcastMessage) error {
+	entityEnterView, ok := msg.Message.(*messages.ServerEntityEnterView)
 	if !ok {
-		return fmt.Errorf("failed to cast server NPC hit message")
+		return fmt.Errorf("failed to cast server entity enter view message")
 	}
 
-	payload, err := json.Marshal(npcHit)
+	payload, err := messages.SerializeServerEntityEnterView(entityEnterView)
 	if err != nil {
-		return fmt.Errorf("failed to marshal NPC hit message: %v", err)
+		return fmt.Errorf("failed to serialize entity enter view message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
+	for _, client := range w.recipients(msg) {
 		msg := &messages.Message{
 			ClientID: 0,
-			Type:     messages.MessageTypeServerNPCHit,
+			Type:     messages.MessageTypeServerEntityEnterView,
 			Payload:  payload,
 		}
 
-		err := network.WriteMessageToTCP(client.TCPConn, msg)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
-			log.Error("Failed to write message to TCP connection for client %d: %v", client.ID, err)
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
 	}
@@ -265,27 +327,27 @@ func (w *BroadcastMessageWorker) handleServerNPCHit(msg BroadcastMessage) error
 	return nil
 }
 
-func (w *BroadcastMessageWorker) handleServerNPCKill(msg BroadcastMessage) error {
-	npcKill, ok := msg.Message.(*messages.ServerNPCKill)
+func (w *BroadcastMessageWorker) handleServerEntityLeaveView(msg BroadcastMessage) error {
+	entityLeaveView, ok := msg.Message.(*messages.ServerEntityLeaveView)
 	if !ok {
-		return fmt.Errorf("failed to cast server NPC kill message")
+		return fmt.Errorf("failed to cast server entity leave view message")
 	}
 
-	payload, err := json.Marshal(npcKill)
+	payload, err := messages.SerializeServerEntityLeaveView(entityLeaveView)
 	if err != nil {
-		return fmt.Errorf("failed to marshal NPC kill message: %v", err)
+		return fmt.Errorf("failed to serialize entity leave view message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
+	for _, client := range w.recipients(msg) {
 		msg := &messages.Message{
 			ClientID: 0,
-			Type:     messages.MessageTypeServerNPCKill,
+			Type:     messages.MessageTypeServerEntityLeaveView,
 			Payload:  payload,
 		}
 
-		err := network.WriteMessageToTCP(client.TCPConn, msg)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
-			log.Error("Failed to write message to TCP connection for client %d: %v", client.ID, err)
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
 	}
@@ -293,27 +355,27 @@ func (w *BroadcastMessageWorker) handleServerNPCKill(msg BroadcastMessage) error
 	return nil
 }
 
-func (w *BroadcastMessageWorker) handleServerPlayerHit(msg BroadcastMessage) error {
-	playerHit, ok := msg.Message.(*messages.ServerPlayerHit)
+func (w *BroadcastMessageWorker) handleServerShutdown(msg BroadcastMessage) error {
+	shutdown, ok := msg.Message.(*messages.ServerShutdown)
 	if !ok {
-		return fmt.Errorf("failed to cast server player hit message")
+		return fmt.Errorf("failed to cast server shutdown message")
 	}
 
-	payload, err := json.Marshal(playerHit)
+	payload, err := messages.SerializeServerShutdown(shutdown)
 	if err != nil {
-		return fmt.Errorf("failed to marshal player hit message: %v", err)
+		return fmt.Errorf("failed to serialize server shutdown message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
+	for _, client := range w.recipients(msg) {
 		msg := &messages.Message{
-			ClientID: 0, // ClientID 0 means the message is from the server
-			Type:     messages.MessageTypeServerPlayerHit,
+			ClientID: 0,
+			Type:     messages.MessageTypeServerShutdown,
 			Payload:  payload,
 		}
 
-		err := network.WriteMessageToTCP(client.TCPConn, msg)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
-			log.Error("Failed to write message to TCP connection for client %d: %v", client.ID, err)
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
 	}
@@ -321,30 +383,54 @@ func (w *BroadcastMessageWorker) handleServerPlayerHit(msg BroadcastMessage) err
 	return nil
 }
 
-func (w *BroadcastMessageWorker) handleServerPlayerKill(msg BroadcastMessage) error {
-	playerKill, ok := msg.Message.(*messages.ServerPlayerKill)
+func (w *BroadcastMessageWorker) handleServerZoneChange(msg BroadcastMessage) error {
+	zoneChange, ok := msg.Message.(*messages.ServerZoneChange)
 	if !ok {
-		return fmt.Errorf("failed to cast server player kill message")
+		return fmt.Errorf("failed to cast server zone change message")
 	}
 
-	payload, err := json.Marshal(playerKill)
+	payload, err := messages.SerializeServerZoneChange(zoneChange)
 	if err != nil {
-		return fmt.Errorf("failed to marshal player kill message: %v", err)
+		return fmt.Errorf("failed to serialize zone change message: %v", err)
 	}
 
-	for _, client := range w.clientManager.GetClients() {
+	for _, client := range w.recipients(msg) {
 		msg := &messages.Message{
 			ClientID: 0,
-			Type:     messages.MessageTypeServerPlayerKill,
+			Type:     messages.MessageTypeServerZoneChange,
 			Payload:  payload,
 		}
 
-		err := network.WriteMessageToTCP(client.TCPConn, msg)
+		err := network.WriteMessageToClientUDP(w.clientManager.GetUDPConn(), client, msg)
 		if err != nil {
-			log.Error("Failed to write message to TCP connection for client %d: %v", client.ID, err)
+			log.Error("Failed to write message to UDP connection for client %d: %v", client.ID, err)
 			continue
 		}
 	}
 
 	return nil
 }
+
+// recipients returns the clients a broadcast message is sent to
+func (w *BroadcastMessageWorker) recipients(msg BroadcastMessage) []*network.Client {
+	if msg.ClientID == 0 {
+		if msg.ClientIDs == nil {
+			return w.clientManager.GetClients()
+		}
+		clients := make([]*network.Client, 0, len(msg.ClientIDs))
+		for _, clientID := range msg.ClientIDs {
+			if client := w.clientManager.GetClient(clientID); client != nil {
+				clients = append(clients, client)
+			}
+		}
+		return clients
+	}
+
+	client := w.clientManager.GetClient(msg.ClientID)
+	if client == nil {
+		log.Trace("Client %d is no longer connected", msg.ClientID)
+		return nil
+	}
+
+	return []*network.Client{client}
+}
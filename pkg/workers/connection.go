@@ -4,6 +4,7 @@ import (
 	"context"
 
 	gameconstants "github.com/cbodonnell/flywheel/pkg/game/constants"
+	gamelevels "github.com/cbodonnell/flywheel/pkg/game/levels"
 	gametypes "github.com/cbodonnell/flywheel/pkg/game/types"
 	"github.com/cbodonnell/flywheel/pkg/kinematic"
 	"github.com/cbodonnell/flywheel/pkg/log"
@@ -69,19 +70,20 @@ func (w *ConnectionEventWorker) handleClientConnect(event network.ConnectionEven
 	var position kinematic.Vector
 	var flipH bool
 	var hitpoints int16
+	var zoneID uint32
 	if lastKnownState, err := w.repository.LoadPlayerState(context.Background(), character.ID); err == nil {
 		position = lastKnownState.Position
 		flipH = lastKnownState.FlipH
 		hitpoints = lastKnownState.Hitpoints
+		zoneID = lastKnownState.ZoneID
 	} else {
 		if !repositories.IsNotFound(err) {
 			log.Error("Failed to get player state for character %d: %v", character.ID, err)
 		}
 		log.Debug("Adding character %d with default values", character.ID)
-		position = kinematic.Vector{
-			X: gameconstants.PlayerStartingX,
-			Y: gameconstants.PlayerStartingY,
-		}
+		world := gamelevels.Active()
+		position = world.Start().Level.PlayerSpawn()
+		zoneID = world.StartZone
 		hitpoints = gameconstants.PlayerHitpoints
 	}
 
@@ -92,6 +94,7 @@ func (w *ConnectionEventWorker) handleClientConnect(event network.ConnectionEven
 		CharacterPosition:  position,
 		CharacterFlipH:     flipH,
 		CharacterHitpoints: hitpoints,
+		CharacterZoneID:    zoneID,
 	}); err != nil {
 		log.Error("Failed to enqueue connect player event: %v", err)
 	}
@@ -23,6 +23,8 @@ type SaveStateRequest struct {
 	Timestamp int64
 	Type      SaveStateRequestType
 	State     interface{}
+	// Done optionally receives the result of the request once it is saved
+	Done chan<- error
 }
 
 type SaveStateRequestType int
@@ -48,18 +50,23 @@ func (w *SaveGameStateWorker) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case saveRequest := <-w.saveStateChan:
+			var err error
 			switch saveRequest.Type {
 			case SaveStateRequestTypePlayer:
-				if err := w.savePlayerState(ctx, saveRequest); err != nil {
+				if err = w.savePlayerState(ctx, saveRequest); err != nil {
 					log.Error("Failed to save player state: %v", err)
 				}
 			case SaveStateRequestTypeGame:
-				if err := w.saveGameState(ctx, saveRequest); err != nil {
+				if err = w.saveGameState(ctx, saveRequest); err != nil {
 					log.Error("Failed to save game state: %v", err)
 				}
 			default:
+				err = fmt.Errorf("unknown save state request type: %v", saveRequest.Type)
 				log.Error("Unknown save state request type: %v", saveRequest.Type)
 			}
+			if saveRequest.Done != nil {
+				saveRequest.Done <- err
+			}
 		}
 	}
 }